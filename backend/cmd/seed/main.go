@@ -51,16 +51,26 @@ func main() {
 
 	// Seed products (Digital Assets)
 	products := []models.Product{
-		{Name: "Premium Music Album NFT", Icon: "musical-notes", YieldRate: "Limited Edition", Price: "¥299", Duration: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Music", Stock: 100},
-		{Name: "Digital Art Collection", Icon: "color-palette", YieldRate: "Exclusive", Price: "¥599", Duration: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Art", Stock: 50},
-		{Name: "Game Asset Bundle", Icon: "game-controller", YieldRate: "Rare Items", Price: "¥199", Duration: "Permanent", RiskLevel: "medium", ProductType: "digital", Category: "Gaming", Stock: 200},
-		{Name: "Video Course Collection", Icon: "videocam", YieldRate: "Full Access", Price: "¥399", Duration: "Lifetime", RiskLevel: "low", ProductType: "digital", Category: "Education", Stock: 0},
-		{Name: "E-Book Bundle", Icon: "book", YieldRate: "Complete Series", Price: "¥149", Duration: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Books", Stock: 0},
-		{Name: "Photography Pack", Icon: "image", YieldRate: "HD Quality", Price: "¥249", Duration: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Photography", Stock: 150},
+		{Name: "Premium Music Album NFT", Icon: "musical-notes", YieldLabel: "Limited Edition", Price: 299, PriceLabel: "¥299", DurationLabel: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Music", Stock: 100},
+		{Name: "Digital Art Collection", Icon: "color-palette", YieldLabel: "Exclusive", Price: 599, PriceLabel: "¥599", DurationLabel: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Art", Stock: 50},
+		{Name: "Game Asset Bundle", Icon: "game-controller", YieldLabel: "Rare Items", Price: 199, PriceLabel: "¥199", DurationLabel: "Permanent", RiskLevel: "medium", ProductType: "digital", Category: "Gaming", Stock: 200},
+		{Name: "Video Course Collection", Icon: "videocam", YieldLabel: "Full Access", Price: 399, PriceLabel: "¥399", DurationLabel: "Lifetime", RiskLevel: "low", ProductType: "digital", Category: "Education", Stock: 0},
+		{Name: "E-Book Bundle", Icon: "book", YieldLabel: "Complete Series", Price: 149, PriceLabel: "¥149", DurationLabel: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Books", Stock: 0},
+		{Name: "Photography Pack", Icon: "image", YieldLabel: "HD Quality", Price: 249, PriceLabel: "¥249", DurationLabel: "Permanent", RiskLevel: "low", ProductType: "digital", Category: "Photography", Stock: 150},
 	}
 	for _, p := range products {
 		db.FirstOrCreate(&p, models.Product{Name: p.Name})
 	}
 
+	// Seed farm investment products, marked to market by their commodity
+	farmProducts := []models.Product{
+		{Name: "Wheat Harvest Share", Icon: "barley", MinInvestment: 100, AnnualYield: 6.5, TermDays: 180, PriceLabel: "¥100起", YieldLabel: "6.5%", DurationLabel: "6个月", RiskLevel: "medium", ProductType: "physical", Category: "Agriculture", Commodity: "Wheat (Soft Red)"},
+		{Name: "Corn Growers Fund", Icon: "corn", MinInvestment: 100, AnnualYield: 5.8, TermDays: 365, PriceLabel: "¥100起", YieldLabel: "5.8%", DurationLabel: "12个月", RiskLevel: "medium", ProductType: "physical", Category: "Agriculture", Commodity: "Corn (Yellow)"},
+		{Name: "Soybean Cooperative Note", Icon: "soy-sauce", MinInvestment: 500, AnnualYield: 7.2, TermDays: 270, PriceLabel: "¥500起", YieldLabel: "7.2%", DurationLabel: "9个月", RiskLevel: "high", ProductType: "physical", Category: "Agriculture", Commodity: "Soybeans"},
+	}
+	for _, p := range farmProducts {
+		db.Where(models.Product{Name: p.Name}).Assign(models.Product{Commodity: p.Commodity}).FirstOrCreate(&p)
+	}
+
 	log.Println("Database seeded successfully!")
 }
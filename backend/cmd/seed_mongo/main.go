@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/community"
+	"conflux-demo/backend/internal/database"
 	"conflux-demo/backend/internal/mongodb"
 	mongoModels "conflux-demo/backend/internal/mongodb/models"
 
@@ -16,6 +18,11 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Initialize MySQL, where post authors are looked up
+	if err := database.Initialize(cfg); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
 	// Initialize MongoDB
 	if err := mongodb.Initialize(cfg); err != nil {
 		log.Fatalf("Failed to initialize MongoDB: %v", err)
@@ -102,9 +109,8 @@ func seedPosts() {
 	// Clear existing posts
 	collection.DeleteMany(ctx, bson.M{})
 
-	posts := []interface{}{
-		mongoModels.Post{
-			UserID:        "0xfB11f0cFE930B10696208d52e4AF121507B57B00",
+	posts := []community.Post{
+		{
 			Username:      "FarmerJohn",
 			WalletAddress: "0xfB11f0cFE930B10696208d52e4AF121507B57B00",
 			Content:       "Just harvested my first batch of organic corn! The yield is looking great this year. Very excited about the results! 🌽 #harvest #organic",
@@ -113,8 +119,7 @@ func seedPosts() {
 			CreatedAt:     time.Now().Add(-2 * time.Hour),
 			UpdatedAt:     time.Now().Add(-2 * time.Hour),
 		},
-		mongoModels.Post{
-			UserID:        "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb1",
+		{
 			Username:      "AgriTech_Sarah",
 			WalletAddress: "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb1",
 			Content:       "Has anyone tried the new drone spraying system? Thinking of investing in one for my orchard. Would love to hear your experiences!",
@@ -123,8 +128,7 @@ func seedPosts() {
 			CreatedAt:     time.Now().Add(-4 * time.Hour),
 			UpdatedAt:     time.Now().Add(-4 * time.Hour),
 		},
-		mongoModels.Post{
-			UserID:        "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		{
 			Username:      "GreenThumb",
 			WalletAddress: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
 			Content:       "Wheat prices are rallying! 📈 Good time to sell if you have stock. The market conditions are favorable right now.",
@@ -133,8 +137,7 @@ func seedPosts() {
 			CreatedAt:     time.Now().Add(-6 * time.Hour),
 			UpdatedAt:     time.Now().Add(-6 * time.Hour),
 		},
-		mongoModels.Post{
-			UserID:        "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		{
 			Username:      "RuralLife",
 			WalletAddress: "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
 			Content:       "Beautiful sunset over the fields today. 🌅 Reminds me why I love farming. There's nothing quite like working the land.",
@@ -143,8 +146,7 @@ func seedPosts() {
 			CreatedAt:     time.Now().Add(-1 * 24 * time.Hour),
 			UpdatedAt:     time.Now().Add(-1 * 24 * time.Hour),
 		},
-		mongoModels.Post{
-			UserID:        "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		{
 			Username:      "MarketWatcher",
 			WalletAddress: "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
 			Content:       "Policy update: New subsidies available for irrigation systems. Check the news tab! This is a great opportunity for farmers to upgrade their equipment. 💧",
@@ -153,8 +155,7 @@ func seedPosts() {
 			CreatedAt:     time.Now().Add(-1 * 24 * time.Hour),
 			UpdatedAt:     time.Now().Add(-1 * 24 * time.Hour),
 		},
-		mongoModels.Post{
-			UserID:        "0x8626f6940E2eb28930eFb4CeF49B2d1F2C9C1199",
+		{
 			Username:      "TechFarmer",
 			WalletAddress: "0x8626f6940E2eb28930eFb4CeF49B2d1F2C9C1199",
 			Content:       "Implementing blockchain technology for supply chain tracking. The transparency is amazing! Customers can now see exactly where their food comes from. 🔗",
@@ -163,8 +164,7 @@ func seedPosts() {
 			CreatedAt:     time.Now().Add(-2 * 24 * time.Hour),
 			UpdatedAt:     time.Now().Add(-2 * 24 * time.Hour),
 		},
-		mongoModels.Post{
-			UserID:        "0xdD870fA1b7C4700F2BD7f44238821C26f7392148",
+		{
 			Username:      "OrganicMary",
 			WalletAddress: "0xdD870fA1b7C4700F2BD7f44238821C26f7392148",
 			Content:       "My tomatoes are thriving this season! 🍅 Using natural pest control methods and the results are incredible. Happy to share tips if anyone is interested!",
@@ -173,8 +173,7 @@ func seedPosts() {
 			CreatedAt:     time.Now().Add(-3 * 24 * time.Hour),
 			UpdatedAt:     time.Now().Add(-3 * 24 * time.Hour),
 		},
-		mongoModels.Post{
-			UserID:        "0x583031D1113aD414F02576BD6afaBfb302140225",
+		{
 			Username:      "CropScience",
 			WalletAddress: "0x583031D1113aD414F02576BD6afaBfb302140225",
 			Content:       "Soil testing results came back excellent! pH levels are perfect for the next planting season. Proper soil management really pays off. 🌱",
@@ -185,10 +184,18 @@ func seedPosts() {
 		},
 	}
 
-	result, err := collection.InsertMany(ctx, posts)
-	if err != nil {
-		log.Fatalf("Failed to seed posts: %v", err)
+	// Posts belong to users, who are created for the seed wallets as needed
+	repo := community.NewMongoRepository(mongodb.GetDB())
+	for i := range posts {
+		user, err := community.UserForWallet(database.GetDB(), posts[i].WalletAddress)
+		if err != nil {
+			log.Fatalf("Failed to find user for %s: %v", posts[i].WalletAddress, err)
+		}
+		posts[i].UserID = user.ID
+		if err := repo.CreatePost(ctx, &posts[i]); err != nil {
+			log.Fatalf("Failed to seed posts: %v", err)
+		}
 	}
 
-	log.Printf("Successfully seeded %d community posts", len(result.InsertedIDs))
+	log.Printf("Successfully seeded %d community posts", len(posts))
 }
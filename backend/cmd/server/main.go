@@ -1,14 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/alerts"
+	"conflux-demo/backend/internal/api/handlers"
 	"conflux-demo/backend/internal/api/routes"
 	"conflux-demo/backend/internal/blockchain"
+	"conflux-demo/backend/internal/community"
 	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/inventory"
+	"conflux-demo/backend/internal/kyc"
+	"conflux-demo/backend/internal/marketdata"
+	"conflux-demo/backend/internal/media"
+	"conflux-demo/backend/internal/moderation"
 	"conflux-demo/backend/internal/mongodb"
+	"conflux-demo/backend/internal/notify"
+	"conflux-demo/backend/internal/suitability"
+	"conflux-demo/backend/internal/yield"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,16 +43,38 @@ func main() {
 		log.Printf("Warning: Failed to initialize MongoDB: %v", err)
 	}
 
+	// Select the community store, migrating older content as needed
+	community.Initialize(cfg, database.GetDB(), mongodb.GetDB())
+
 	// Initialize Conflux client
 	if err := blockchain.Initialize(cfg); err != nil {
 		log.Fatalf("Failed to initialize Conflux client: %v", err)
 	}
 
+	// Apply the suitability policy, KYC verifier, media store and moderation
+	if err := suitability.Initialize(cfg); err != nil {
+		log.Fatalf("Failed to initialize suitability policy: %v", err)
+	}
+	kyc.Initialize(cfg)
+	media.Initialize(cfg)
+	moderation.Initialize(cfg, database.GetDB())
+
+	// Set up notifications and price alerts before ingestion starts
+	notify.Initialize(cfg)
+	alerts.Initialize()
+
+	// Start background workers
+	inventory.Initialize(cfg)
+	yield.Initialize(cfg)
+	marketdata.Initialize(cfg)
+	handlers.StartSettlementReconciler(context.Background(), time.Minute)
+	handlers.StartTransferReconciler(context.Background(), time.Minute)
+
 	// Create Gin router
 	router := gin.Default()
 
 	// Setup routes
-	routes.SetupRoutes(router)
+	routes.SetupRoutes(router, cfg)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -28,6 +30,36 @@ type Config struct {
 
 	RWATokenContract    string
 	MarketplaceContract string
+
+	ReservationTTLMinutes int
+	YieldPayoutDays       int
+	RiskExcessPolicy      string
+
+	KYCVerifier    string
+	KYCAutoApprove bool
+	KYCDocumentDir string
+
+	AdminAccounts string
+
+	MarketSources      string
+	MarketCSVDir       string
+	MarketHTTPURL      string
+	MarketPollSeconds  int
+	MarketStaleMinutes int
+
+	MarketStreamHeartbeatSeconds int
+
+	PushGatewayURL string
+
+	MediaStore       string
+	MediaDir         string
+	MediaBaseURL     string
+	MediaMaxUploadMB int
+
+	CommunityStore            string
+	CommunityPostsPerHour     int
+	CommunityCommentsPerHour  int
+	ModerationReportThreshold int
 }
 
 func Load() *Config {
@@ -55,6 +87,36 @@ func Load() *Config {
 
 		RWATokenContract:    getEnv("RWA_TOKEN_CONTRACT", ""),
 		MarketplaceContract: getEnv("MARKETPLACE_CONTRACT", ""),
+
+		ReservationTTLMinutes: getEnvInt("RESERVATION_TTL_MINUTES", 15),
+		YieldPayoutDays:       getEnvInt("YIELD_PAYOUT_DAYS", 30),
+		RiskExcessPolicy:      getEnv("RISK_EXCESS_POLICY", "acknowledge"),
+
+		KYCVerifier:    getEnv("KYC_VERIFIER", "local"),
+		KYCAutoApprove: getEnv("KYC_AUTO_APPROVE", "false") == "true",
+		KYCDocumentDir: getEnv("KYC_DOCUMENT_DIR", "./uploads/kyc"),
+
+		AdminAccounts: getEnv("ADMIN_ACCOUNTS", ""),
+
+		MarketSources:      getEnv("MARKET_SOURCES", ""),
+		MarketCSVDir:       getEnv("MARKET_CSV_DIR", "./data/market"),
+		MarketHTTPURL:      getEnv("MARKET_HTTP_URL", ""),
+		MarketPollSeconds:  getEnvInt("MARKET_POLL_SECONDS", 60),
+		MarketStaleMinutes: getEnvInt("MARKET_STALE_MINUTES", 30),
+
+		MarketStreamHeartbeatSeconds: getEnvInt("MARKET_STREAM_HEARTBEAT_SECONDS", 15),
+
+		PushGatewayURL: getEnv("PUSH_GATEWAY_URL", ""),
+
+		MediaStore:       getEnv("MEDIA_STORE", "local"),
+		MediaDir:         getEnv("MEDIA_DIR", "./uploads/media"),
+		MediaBaseURL:     getEnv("MEDIA_BASE_URL", "/media"),
+		MediaMaxUploadMB: getEnvInt("MEDIA_MAX_UPLOAD_MB", 10),
+
+		CommunityStore:            getEnv("COMMUNITY_STORE", "mongo"),
+		CommunityPostsPerHour:     getEnvInt("COMMUNITY_POSTS_PER_HOUR", 10),
+		CommunityCommentsPerHour:  getEnvInt("COMMUNITY_COMMENTS_PER_HOUR", 60),
+		ModerationReportThreshold: getEnvInt("MODERATION_REPORT_THRESHOLD", 3),
 	}
 }
 
@@ -64,3 +126,25 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// AdminCredentials parses ADMIN_ACCOUNTS, a comma-separated list of
+// user:password pairs
+func (c *Config) AdminCredentials() map[string]string {
+	accounts := map[string]string{}
+	for _, pair := range strings.Split(c.AdminAccounts, ",") {
+		user, pass, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && user != "" && pass != "" {
+			accounts[user] = pass
+		}
+	}
+	return accounts
+}
@@ -0,0 +1,150 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/marketdata"
+	"conflux-demo/backend/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+// Rule kinds
+const (
+	KindAbove   = "above"    // Price rises to or through the threshold
+	KindBelow   = "below"    // Price falls to or through the threshold
+	KindDayMove = "day_move" // Price moves at least threshold percent from the previous close
+)
+
+// ValidKind reports whether kind is a supported rule kind
+func ValidKind(kind string) bool {
+	return kind == KindAbove || kind == KindBelow || kind == KindDayMove
+}
+
+// Evaluator checks alert rules against new prices
+type Evaluator struct {
+	DB       *gorm.DB
+	Notifier notify.Notifier
+}
+
+// Initialize evaluates stored prices on a background queue, so slow
+// deliveries do not hold up ingestion. While a product's price waits to be
+// evaluated, a newer one replaces it; rules see the latest price.
+func Initialize() {
+	evaluator := &Evaluator{DB: database.GetDB(), Notifier: notify.Default()}
+	queue := newPriceQueue()
+
+	go func() {
+		ctx := context.Background()
+		for {
+			row, ok := queue.Pop(ctx)
+			if !ok {
+				return
+			}
+			if err := evaluator.Evaluate(ctx, row); err != nil {
+				log.Printf("Failed to evaluate price alerts for %s: %v", row.ProductName, err)
+			}
+		}
+	}()
+
+	marketdata.OnStored(func(row models.MarketData) {
+		queue.Push(row)
+	})
+}
+
+// Evaluate checks the active rules of the row's product, notifies the owners
+// of triggered rules and records the price on every rule. Rows older than a
+// rule's last seen price are ignored by that rule.
+func (e *Evaluator) Evaluate(ctx context.Context, row models.MarketData) error {
+	var rules []models.AlertRule
+	if err := e.DB.WithContext(ctx).
+		Where("product_name = ? AND active = ?", row.ProductName, true).
+		Find(&rules).Error; err != nil {
+		return err
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.LastPriceAt != nil && !row.Timestamp.After(*rule.LastPriceAt) {
+			continue
+		}
+
+		triggered, message := Check(rule, row)
+		updates := map[string]interface{}{
+			"last_price":    row.Price,
+			"last_price_at": row.Timestamp,
+		}
+		if triggered {
+			updates["last_triggered_at"] = row.Timestamp
+		}
+		if err := e.DB.WithContext(ctx).Model(rule).Updates(updates).Error; err != nil {
+			return err
+		}
+		if !triggered {
+			continue
+		}
+
+		err := e.Notifier.Notify(ctx, notify.Message{
+			UserID: rule.UserID,
+			Type:   notify.TypePriceAlert,
+			Title:  fmt.Sprintf("%s price alert", row.ProductName),
+			Body:   message,
+			Data: map[string]interface{}{
+				"rule_id":   rule.ID,
+				"product":   row.ProductName,
+				"kind":      rule.Kind,
+				"threshold": rule.Threshold,
+				"price":     row.Price,
+				"timestamp": row.Timestamp,
+			},
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			// Channels log their own failures; the rule still counts as triggered
+			log.Printf("Price alert %d delivery incomplete: %v", rule.ID, err)
+		}
+	}
+	return nil
+}
+
+// Check reports whether row triggers rule, with a message describing why.
+// Crossing rules need a previous price on the other side of the threshold,
+// so a rule does not fire for a price that was already past it. Day move
+// rules fire at most once per day.
+func Check(rule *models.AlertRule, row models.MarketData) (bool, string) {
+	switch rule.Kind {
+	case KindAbove:
+		if rule.LastPrice > 0 && rule.LastPrice < rule.Threshold && row.Price >= rule.Threshold {
+			return true, fmt.Sprintf("%s rose to %.2f, above your %.2f alert", row.ProductName, row.Price, rule.Threshold)
+		}
+	case KindBelow:
+		if rule.LastPrice > rule.Threshold && row.Price <= rule.Threshold {
+			return true, fmt.Sprintf("%s fell to %.2f, below your %.2f alert", row.ProductName, row.Price, rule.Threshold)
+		}
+	case KindDayMove:
+		prevClose := row.Price - row.Change
+		if prevClose <= 0 || sameDay(rule.LastTriggeredAt, row.Timestamp) {
+			return false, ""
+		}
+		percent := row.Change / prevClose * 100
+		if math.Abs(percent) >= rule.Threshold {
+			return true, fmt.Sprintf("%s moved %+.2f%% today to %.2f", row.ProductName, percent, row.Price)
+		}
+	}
+	return false, ""
+}
+
+func sameDay(t *time.Time, day time.Time) bool {
+	if t == nil {
+		return false
+	}
+	y1, m1, d1 := t.In(day.Location()).Date()
+	y2, m2, d2 := day.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
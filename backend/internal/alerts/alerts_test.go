@@ -0,0 +1,137 @@
+package alerts
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/notify"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "alerts.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AlertRule{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func tick(price, change float64, at time.Time) models.MarketData {
+	return models.MarketData{ProductName: "Corn", Price: price, Change: change, Timestamp: at}
+}
+
+func TestEvaluateThresholdCrossing(t *testing.T) {
+	db := openTestDB(t)
+	fake := &notify.Fake{}
+	e := &Evaluator{DB: db, Notifier: fake}
+
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	db.Create(&models.AlertRule{UserID: 1, ProductName: "Corn", Kind: KindAbove, Threshold: 200, Active: true, LastPrice: 190})
+	db.Create(&models.AlertRule{UserID: 2, ProductName: "Corn", Kind: KindBelow, Threshold: 180, Active: true, LastPrice: 190})
+
+	for i, price := range []float64{195, 201, 205, 199, 202, 179} {
+		if err := e.Evaluate(context.Background(), tick(price, 0, start.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+	}
+
+	var above, below int
+	for _, m := range fake.Messages() {
+		switch m.UserID {
+		case 1:
+			above++
+		case 2:
+			below++
+		}
+	}
+	// 195 -> 201 and 199 -> 202 cross upwards; 202 -> 179 crosses downwards
+	if above != 2 || below != 1 {
+		t.Errorf("above alerts = %d, below alerts = %d, want 2 and 1", above, below)
+	}
+
+	// A stale row is ignored
+	if err := e.Evaluate(context.Background(), tick(250, 0, start)); err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if len(fake.Messages()) != 3 {
+		t.Errorf("stale row triggered an alert")
+	}
+}
+
+func TestEvaluateDayMoveOncePerDay(t *testing.T) {
+	db := openTestDB(t)
+	fake := &notify.Fake{}
+	e := &Evaluator{DB: db, Notifier: fake}
+
+	db.Create(&models.AlertRule{UserID: 1, ProductName: "Corn", Kind: KindDayMove, Threshold: 5, Active: true})
+	paused := models.AlertRule{UserID: 2, ProductName: "Corn", Kind: KindDayMove, Threshold: 5, Active: true}
+	db.Create(&paused)
+	db.Model(&paused).Update("active", false)
+
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	rows := []models.MarketData{
+		tick(103, 3, day),                   // +3%
+		tick(106, 6, day.Add(time.Hour)),    // +6%, fires
+		tick(94, -6, day.Add(2*time.Hour)),  // -6%, already fired today
+		tick(93, -7, day.Add(24*time.Hour)), // next day, fires
+	}
+	for _, row := range rows {
+		if err := e.Evaluate(context.Background(), row); err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+	}
+
+	messages := fake.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("got %d alerts, want 2", len(messages))
+	}
+	if messages[0].UserID != 1 || messages[0].Type != notify.TypePriceAlert {
+		t.Errorf("unexpected message %+v", messages[0])
+	}
+}
+
+func TestPriceQueueKeepsLatestPerProduct(t *testing.T) {
+	q := newPriceQueue()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+
+	q.Push(tick(100, 0, start))
+	q.Push(models.MarketData{ProductName: "Wheat", Price: 50, Timestamp: start})
+	q.Push(tick(120, 0, start.Add(2*time.Minute)))
+	q.Push(tick(110, 0, start.Add(time.Minute))) // Older than the pending price
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	want := []struct {
+		product string
+		price   float64
+	}{
+		{"Corn", 120},
+		{"Wheat", 50},
+	}
+	for _, w := range want {
+		row, ok := q.Pop(ctx)
+		if !ok {
+			t.Fatalf("queue empty, want %s", w.product)
+		}
+		if row.ProductName != w.product || row.Price != w.price {
+			t.Errorf("popped %s at %.2f, want %s at %.2f", row.ProductName, row.Price, w.product, w.price)
+		}
+	}
+
+	// Nothing is left, so Pop waits until the context ends
+	if row, ok := q.Pop(ctx); ok {
+		t.Errorf("popped %+v from an empty queue", row)
+	}
+}
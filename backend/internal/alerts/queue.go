@@ -0,0 +1,69 @@
+package alerts
+
+import (
+	"context"
+	"sync"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+// priceQueue holds the latest price of each product waiting to be
+// evaluated. A newer price replaces one still waiting, so the queue never
+// grows beyond one row per product and a slow evaluator falls behind by
+// skipping intermediate prices rather than whole products.
+type priceQueue struct {
+	mu      sync.Mutex
+	pending map[string]models.MarketData
+	order   []string // Products with a pending price, oldest first
+	ready   chan struct{}
+}
+
+func newPriceQueue() *priceQueue {
+	return &priceQueue{
+		pending: map[string]models.MarketData{},
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// Push queues row, replacing an older pending price of the same product.
+// A row older than the pending one is dropped.
+func (q *priceQueue) Push(row models.MarketData) {
+	q.mu.Lock()
+	existing, ok := q.pending[row.ProductName]
+	if ok && row.Timestamp.Before(existing.Timestamp) {
+		q.mu.Unlock()
+		return
+	}
+	q.pending[row.ProductName] = row
+	if !ok {
+		q.order = append(q.order, row.ProductName)
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Pop waits for the next pending price. It returns false once ctx is done.
+func (q *priceQueue) Pop(ctx context.Context) (models.MarketData, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.order) > 0 {
+			product := q.order[0]
+			q.order = q.order[1:]
+			row := q.pending[product]
+			delete(q.pending, product)
+			q.mu.Unlock()
+			return row, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return models.MarketData{}, false
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"conflux-demo/backend/internal/alerts"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Most alert rules and notification endpoints per user
+const (
+	maxAlertRules            = 50
+	maxNotificationEndpoints = 10
+)
+
+// latestPrice returns the latest row of a product, or false if it has no
+// prices
+func latestPrice(product string) (*models.MarketData, bool) {
+	var rows []models.MarketData
+	database.GetDB().Where("product_name = ?", product).Order("timestamp DESC").Limit(1).Find(&rows)
+	if len(rows) == 0 {
+		return nil, false
+	}
+	return &rows[0], true
+}
+
+// GetWatchlist returns the current user's watched products with their
+// latest prices
+func GetWatchlist(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var items []models.WatchlistItem
+	if err := database.GetDB().Where("user_id = ?", user.ID).Order("created_at ASC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch watchlist"})
+		return
+	}
+
+	prices, err := latestMarketData()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
+		return
+	}
+	byProduct := map[string]models.MarketData{}
+	for _, p := range prices {
+		byProduct[p.ProductName] = p
+	}
+
+	data := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		entry := gin.H{"id": item.ID, "product_name": item.ProductName, "created_at": item.CreatedAt}
+		if p, ok := byProduct[item.ProductName]; ok {
+			entry["price"] = p.Price
+			entry["change"] = p.Change
+			entry["change_percent"] = p.ChangePercent
+			entry["timestamp"] = p.Timestamp
+		}
+		data = append(data, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": data})
+}
+
+// AddToWatchlist watches a product that has market prices
+func AddToWatchlist(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ProductName string `json:"product_name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := latestPrice(input.ProductName); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No market prices for this product"})
+		return
+	}
+
+	item := models.WatchlistItem{UserID: user.ID, ProductName: input.ProductName}
+	if err := database.GetDB().Where(item).FirstOrCreate(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update watchlist"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "data": item})
+}
+
+// RemoveFromWatchlist stops watching a product and deletes its alert rules
+func RemoveFromWatchlist(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	product := c.Param("product")
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND product_name = ?", user.ID, product).Delete(&models.WatchlistItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("user_id = ? AND product_name = ?", user.ID, product).Delete(&models.AlertRule{}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product is not on your watchlist"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update watchlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// GetAlertRules returns the current user's alert rules
+func GetAlertRules(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var rules []models.AlertRule
+	if err := database.GetDB().Where("user_id = ?", user.ID).Order("created_at ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": rules})
+}
+
+// CreateAlertRule adds an alert rule and watches its product. Crossing rules
+// start from the latest price, so they fire on the next crossing.
+func CreateAlertRule(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ProductName string  `json:"product_name" binding:"required"`
+		Kind        string  `json:"kind" binding:"required"`
+		Threshold   float64 `json:"threshold" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !alerts.ValidKind(input.Kind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be above, below or day_move"})
+		return
+	}
+	if input.Threshold <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be positive"})
+		return
+	}
+
+	latest, ok := latestPrice(input.ProductName)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No market prices for this product"})
+		return
+	}
+
+	var count int64
+	database.GetDB().Model(&models.AlertRule{}).Where("user_id = ?", user.ID).Count(&count)
+	if count >= maxAlertRules {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many alert rules"})
+		return
+	}
+
+	rule := models.AlertRule{
+		UserID:      user.ID,
+		ProductName: input.ProductName,
+		Kind:        input.Kind,
+		Threshold:   input.Threshold,
+		Active:      true,
+		LastPrice:   latest.Price,
+		LastPriceAt: &latest.Timestamp,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		item := models.WatchlistItem{UserID: user.ID, ProductName: input.ProductName}
+		if err := tx.Where(item).FirstOrCreate(&item).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rule).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "data": rule})
+}
+
+// UpdateAlertRule changes a rule's threshold or pauses and resumes it
+func UpdateAlertRule(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var rule models.AlertRule
+	if err := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), user.ID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	var input struct {
+		Threshold *float64 `json:"threshold"`
+		Active    *bool    `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Threshold != nil {
+		if *input.Threshold <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be positive"})
+			return
+		}
+		updates["threshold"] = *input.Threshold
+	}
+	if input.Active != nil {
+		updates["active"] = *input.Active
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	if err := database.GetDB().Model(&rule).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": rule})
+}
+
+// DeleteAlertRule removes one of the current user's rules
+func DeleteAlertRule(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), user.ID).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// GetNotificationEndpoints returns the current user's webhooks and push tokens
+func GetNotificationEndpoints(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var endpoints []models.NotificationEndpoint
+	if err := database.GetDB().Where("user_id = ?", user.ID).Order("id ASC").Find(&endpoints).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch endpoints"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": endpoints})
+}
+
+// AddWebhook registers an http(s) URL on a public host for notifications.
+// The signing secret is only returned here.
+func AddWebhook(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL string `json:"url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := notify.CheckWebhookURL(c.Request.Context(), input.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := notify.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	endpoint := models.NotificationEndpoint{
+		UserID: user.ID,
+		Kind:   notify.EndpointWebhook,
+		Target: input.URL,
+		Secret: secret,
+	}
+	if !addNotificationEndpoint(c, &endpoint) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "data": endpoint, "secret": secret})
+}
+
+// AddPushToken registers a device push token
+func AddPushToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Platform string `json:"platform" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch input.Platform {
+	case "ios", "android", "web":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "platform must be ios, android or web"})
+		return
+	}
+
+	endpoint := models.NotificationEndpoint{
+		UserID:   user.ID,
+		Kind:     notify.EndpointPush,
+		Target:   strings.TrimSpace(input.Token),
+		Platform: input.Platform,
+	}
+	if !addNotificationEndpoint(c, &endpoint) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "data": endpoint})
+}
+
+// addNotificationEndpoint stores an endpoint unless the user already has it
+// or too many, writing the error response and returning false otherwise
+func addNotificationEndpoint(c *gin.Context, endpoint *models.NotificationEndpoint) bool {
+	db := database.GetDB()
+
+	var existing int64
+	db.Model(&models.NotificationEndpoint{}).
+		Where("user_id = ? AND kind = ? AND target = ?", endpoint.UserID, endpoint.Kind, endpoint.Target).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Endpoint already registered"})
+		return false
+	}
+
+	var count int64
+	db.Model(&models.NotificationEndpoint{}).Where("user_id = ?", endpoint.UserID).Count(&count)
+	if count >= maxNotificationEndpoints {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many notification endpoints"})
+		return false
+	}
+
+	if err := db.Create(endpoint).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save endpoint"})
+		return false
+	}
+	return true
+}
+
+// DeleteNotificationEndpoint removes a webhook or push token
+func DeleteNotificationEndpoint(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), user.ID).Delete(&models.NotificationEndpoint{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete endpoint"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Endpoint not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
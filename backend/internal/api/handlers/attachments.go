@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"conflux-demo/backend/internal/community"
+	"conflux-demo/backend/internal/media"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Most attachments on one post
+const maxPostAttachments = 9
+
+// UploadAttachment stores an image sent as the multipart field "file" and
+// returns an attachment to reference from CreatePost's attachment_ids.
+// GPS data is stripped and a thumbnail generated on upload.
+func UploadAttachment(c *gin.Context) {
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	// Leave room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, media.MaxUploadBytes()+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if header.Size > media.MaxUploadBytes() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	upload, err := media.Save(ctx, media.Default(), "community", data)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, media.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	attachment := community.Attachment{
+		UploaderWallet: user.WalletAddress,
+		ContentType:    upload.ContentType,
+		Key:            upload.Key,
+		ThumbnailKey:   upload.ThumbnailKey,
+		Size:           upload.Size,
+		Width:          upload.Width,
+		Height:         upload.Height,
+	}
+	if err := community.Default().CreateAttachment(ctx, &attachment); err != nil {
+		media.Default().Delete(ctx, upload.Key)
+		media.Default().Delete(ctx, upload.ThumbnailKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+	attachments := []community.Attachment{attachment}
+	resolveAttachmentURLs(attachments)
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "data": attachments[0]})
+}
+
+// claimAttachments assigns the wallet's unclaimed uploads to a post and
+// returns them in the requested order. Nothing is claimed unless every ID
+// is available.
+func claimAttachments(ctx context.Context, wallet, postID string, ids []string) ([]community.Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > maxPostAttachments {
+		return nil, fmt.Errorf("a post can have at most %d attachments", maxPostAttachments)
+	}
+	for _, id := range ids {
+		if !community.ValidID(id) {
+			return nil, errors.New("invalid attachment ID " + id)
+		}
+	}
+
+	attachments, err := community.Default().ClaimAttachments(ctx, wallet, postID, ids)
+	if errors.Is(err, community.ErrAttachmentUnavailable) {
+		return nil, errors.New("attachments must be your own unused uploads")
+	}
+	return attachments, err
+}
+
+// resolveAttachmentURLs fills in the URLs of attachments from the current
+// blob store
+func resolveAttachmentURLs(attachments []community.Attachment) {
+	store := media.Default()
+	for i := range attachments {
+		attachments[i].URL = store.URL(attachments[i].Key)
+		attachments[i].ThumbnailURL = store.URL(attachments[i].ThumbnailKey)
+	}
+}
+
+// GetMedia serves a stored blob for stores without their own public URLs
+func GetMedia(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !media.ValidKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	blob, err := media.Default().Open(c.Request.Context(), key)
+	if errors.Is(err, media.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer blob.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// Keys are random and never reused
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, blob, nil)
+}
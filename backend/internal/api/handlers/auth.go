@@ -136,3 +136,21 @@ func GetCurrentUser(c *gin.Context) {
 		},
 	})
 }
+
+// currentUser loads the authenticated user, writing an error response and
+// returning false if there is none
+func currentUser(c *gin.Context) (*models.User, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	return &user, true
+}
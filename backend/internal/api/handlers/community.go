@@ -2,165 +2,348 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"conflux-demo/backend/internal/mongodb"
-	mongoModels "conflux-demo/backend/internal/mongodb/models"
+	"conflux-demo/backend/internal/community"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/moderation"
+	"conflux-demo/backend/internal/notify"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// GetPosts returns a list of community posts from MongoDB
+// GetPosts returns a page of visible community posts, newest first or,
+// with sort=hot, by likes, comments and age
 func GetPosts(c *gin.Context) {
-	// Pagination
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	listPosts(c, community.PostQuery{})
+}
+
+// listPosts writes a page of the posts q selects, newest first or, with
+// sort=hot, by hot score. Later pages are requested with cursor set to the
+// next_cursor of the previous one, which is empty on the last page.
+func listPosts(c *gin.Context, q community.PostQuery) {
+	sortMode := c.DefaultQuery("sort", community.SortNew)
+	if sortMode != community.SortNew && sortMode != community.SortHot {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be new or hot"})
+		return
+	}
+
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-	skip := int64((page - 1) * pageSize)
-	limit := int64(pageSize)
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 10
+	}
+	cursor, ok := pageCursor(c)
+	if !ok {
+		return
+	}
+	q.Sort = sortMode
+	q.Limit = pageSize
+	q.After = cursor
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection := mongodb.GetCollection("posts")
-
-	// Sort by created_at descending
-	opts := options.Find().
-		SetSkip(skip).
-		SetLimit(limit).
-		SetSort(bson.D{{Key: "created_at", Value: -1}})
-
-	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	posts, err := community.Default().ListPosts(ctx, q)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var posts []mongoModels.Post
-	if err := cursor.All(ctx, &posts); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode posts"})
+	mobilePosts, err := postViews(ctx, viewerID(c), posts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch likes"})
 		return
 	}
 
-	// Format response for mobile app
-	var mobilePosts []gin.H
+	var next string
+	if len(posts) == pageSize {
+		next = posts[len(posts)-1].Cursor(sortMode).String()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":          true,
+		"data":        mobilePosts,
+		"sort":        sortMode,
+		"page_size":   pageSize,
+		"next_cursor": next,
+	})
+}
+
+// postViews formats posts for the mobile app, marking those the viewer
+// has liked
+func postViews(ctx context.Context, viewerID uint, posts []community.Post) ([]gin.H, error) {
+	postIDs := make([]string, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	liked, err := community.Default().LikedPosts(ctx, viewerID, postIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mobilePosts := make([]gin.H, 0, len(posts))
 	for _, post := range posts {
+		presentPost(&post)
 		mobilePosts = append(mobilePosts, gin.H{
-			"id":             post.ID.Hex(),
+			"id":             post.ID,
 			"user":           post.Username,
+			"user_id":        post.UserID,
 			"wallet_address": post.WalletAddress,
 			"content":        post.Content,
 			"time":           formatTimeAgo(post.CreatedAt),
 			"likes":          post.LikesCount,
 			"comments":       post.CommentsCount,
+			"liked_by_me":    liked[post.ID],
+			"attachments":    post.Attachments,
+			"tags":           post.Tags,
+			"mentions":       post.Mentions,
+			"edited_at":      post.EditedAt,
 			"created_at":     post.CreatedAt,
 		})
 	}
+	return mobilePosts, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"ok":        true,
-		"data":      mobilePosts,
-		"page":      page,
-		"page_size": pageSize,
-	})
+// presentPost prepares a post for a response: lists are never null and
+// attachments have URLs
+func presentPost(post *community.Post) {
+	if post.Attachments == nil {
+		post.Attachments = []community.Attachment{}
+	}
+	resolveAttachmentURLs(post.Attachments)
+	post.Tags = nonNilTags(post.Tags)
+	post.Mentions = nonNilMentions(post.Mentions)
 }
 
-// CreatePost creates a new community post in MongoDB
+// CreatePost creates a new community post, authored by the authenticated
+// user. attachment_ids lists images uploaded with UploadAttachment; content
+// may be empty when there are attachments.
 func CreatePost(c *gin.Context) {
 	var input struct {
-		WalletAddress string `json:"wallet_address" binding:"required"`
-		Username      string `json:"username"`
-		Content       string `json:"content" binding:"required"`
+		WalletAddress string   `json:"wallet_address"`
+		Username      string   `json:"username"`
+		Content       string   `json:"content"`
+		AttachmentIDs []string `json:"attachment_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if strings.TrimSpace(input.Content) == "" && len(input.AttachmentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content or attachment_ids is required"})
+		return
+	}
+
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	// Older clients still send their wallet; it must be the caller's
+	if input.WalletAddress != "" && !strings.EqualFold(input.WalletAddress, user.WalletAddress) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "wallet_address does not match the authenticated user"})
+		return
+	}
 
-	// Default username if not provided
-	username := input.Username
-	if username == "" {
-		username = input.WalletAddress[:8] + "..."
+	status, held, ok := moderateContent(c, input.Content)
+	if !ok {
+		return
+	}
+	if !checkRateLimit(c, moderation.AllowPost, user.ID) {
+		return
 	}
 
-	post := mongoModels.Post{
-		UserID:        input.WalletAddress,
+	username := displayName(input.Username, user)
+	tags, mentions := parseContent(input.Content)
+	now := time.Now()
+	post := community.Post{
+		ID:            community.NewID(),
+		UserID:        user.ID,
 		Username:      username,
-		WalletAddress: input.WalletAddress,
+		WalletAddress: user.WalletAddress,
 		Content:       input.Content,
-		LikesCount:    0,
-		CommentsCount: 0,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		Tags:          tags,
+		Mentions:      mentions,
+		Status:        status,
+		Moderation:    held,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	collection := mongodb.GetCollection("posts")
-	result, err := collection.InsertOne(ctx, post)
+	attachments, err := claimAttachments(ctx, user.WalletAddress, post.ID, input.AttachmentIDs)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	post.Attachments = attachments
+
+	if err := community.Default().CreatePost(ctx, &post); err != nil {
+		// Release the attachments for another attempt
+		if err := community.Default().ReleaseAttachments(ctx, post.ID); err != nil {
+			log.Printf("Failed to release attachments of post %s: %v", post.ID, err)
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
 		return
 	}
 
-	post.ID = result.InsertedID.(primitive.ObjectID)
+	if status == moderation.StatusVisible {
+		notifyMentions(post.Mentions, nil, user.ID, username, post.Content, mentionData(post.ID, ""))
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"ok":   true,
-		"data": post,
-	})
+	presentPost(&post)
+	response := gin.H{"ok": true, "data": post}
+	if status == moderation.StatusPendingReview {
+		response["message"] = "Your post will appear once it has been reviewed"
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
-// LikePost increments the like count for a post
-func LikePost(c *gin.Context) {
-	idStr := c.Param("id")
-
-	objectID, err := primitive.ObjectIDFromHex(idStr)
+// visiblePost loads a post others may see and interact with, writing a 404
+// or 500 and returning false otherwise
+func visiblePost(c *gin.Context, ctx context.Context, id string) (*community.Post, bool) {
+	post, err := community.Default().GetPost(ctx, id)
+	if errors.Is(err, community.ErrNotFound) || (err == nil && !post.Visible()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return nil, false
+	}
+	return post, true
+}
+
+// ownPost loads a live post written by the authenticated user, writing the
+// error response and returning false otherwise
+func ownPost(c *gin.Context, ctx context.Context) (*community.Post, bool) {
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	postID := c.Param("id")
+	if !community.ValidID(postID) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return nil, false
+	}
+
+	post, err := community.Default().GetPost(ctx, postID)
+	if errors.Is(err, community.ErrNotFound) || (err == nil && post.DeletedAt != nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return nil, false
+	}
+	if post.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can change this post"})
+		return nil, false
+	}
+	return post, true
+}
+
+// UpdatePost replaces the content of the author's post, keeping the previous
+// content as a revision
+func UpdatePost(c *gin.Context) {
+	var input struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	collection := mongodb.GetCollection("posts")
-
-	update := bson.M{
-		"$inc": bson.M{"likes_count": 1},
-		"$set": bson.M{"updated_at": time.Now()},
+	post, ok := ownPost(c, ctx)
+	if !ok {
+		return
+	}
+	if post.Content == input.Content {
+		presentPost(post)
+		c.JSON(http.StatusOK, gin.H{"ok": true, "data": post})
+		return
+	}
+	status, held, ok := moderateContent(c, input.Content)
+	if !ok {
+		return
 	}
 
-	result, err := collection.UpdateByID(ctx, objectID, update)
+	now := time.Now()
+	tags, mentions := parseContent(input.Content)
+	edit := community.Edit{Content: input.Content, Tags: tags, Mentions: mentions, At: now}
+	// An edit can send a visible post to review, but never undoes a hold
+	if held != nil && post.Visible() {
+		edit.Hold = true
+		edit.FlaggedTerms = held.FlaggedTerms
+		post.Status = status
+	}
+	err := community.Default().EditPost(ctx, post.ID, post.Content, edit)
+	if errors.Is(err, community.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Post changed, reload and try again"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to like post"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
 		return
 	}
 
-	if result.MatchedCount == 0 {
+	if post.Visible() {
+		notifyMentions(mentions, post.Mentions, post.UserID, post.Username, input.Content, mentionData(post.ID, ""))
+	}
+
+	post.Content = input.Content
+	post.Tags = tags
+	post.Mentions = mentions
+	post.EditedAt = &now
+	post.UpdatedAt = now
+	presentPost(post)
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": post})
+}
+
+// DeletePost soft deletes the author's post together with its comments and
+// likes. The counters are left as they were when the post was deleted.
+func DeletePost(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	post, ok := ownPost(c, ctx)
+	if !ok {
+		return
+	}
+
+	comments, likes, err := community.Default().DeletePost(ctx, post.ID, time.Now())
+	if errors.Is(err, community.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"ok":      true,
-		"message": "Post liked successfully",
+		"ok":               true,
+		"deleted_comments": comments,
+		"deleted_likes":    likes,
 	})
 }
 
-// UnlikePost decrements the like count for a post
-func UnlikePost(c *gin.Context) {
-	idStr := c.Param("id")
-
-	objectID, err := primitive.ObjectIDFromHex(idStr)
-	if err != nil {
+// GetPostRevisions returns a post's earlier versions, oldest first
+func GetPostRevisions(c *gin.Context) {
+	postID := c.Param("id")
+	if !community.ValidID(postID) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
 		return
 	}
@@ -168,101 +351,248 @@ func UnlikePost(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	collection := mongodb.GetCollection("posts")
+	post, ok := visiblePost(c, ctx, postID)
+	if !ok {
+		return
+	}
+	revisions, err := community.Default().PostRevisions(ctx, postID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	presentPost(post)
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": revisions, "current": post})
+}
+
+// LikePost records the authenticated user's like of a post. Liking twice has no
+// effect.
+func LikePost(c *gin.Context) {
+	setPostLike(c, true)
+}
+
+// UnlikePost removes the authenticated user's like of a post, if any
+func UnlikePost(c *gin.Context) {
+	setPostLike(c, false)
+}
 
-	// Ensure likes_count doesn't go below 0
-	update := bson.M{
-		"$inc": bson.M{"likes_count": -1},
-		"$set": bson.M{"updated_at": time.Now()},
+// setPostLike adds or removes one like. The repository moves likes_count
+// only when the like actually changed, so the counter stays consistent.
+func setPostLike(c *gin.Context, like bool) {
+	postID := c.Param("id")
+	if !community.ValidID(postID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
 	}
 
-	filter := bson.M{
-		"_id":         objectID,
-		"likes_count": bson.M{"$gt": 0},
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
 	}
 
-	result, err := collection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlike post"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, ok := visiblePost(c, ctx, postID); !ok {
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found or already at 0 likes"})
+	changed, likes, err := community.Default().SetLike(ctx, postID, user.ID, like)
+	if err != nil {
+		action := "like"
+		if !like {
+			action = "unlike"
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " post"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"ok":      true,
-		"message": "Post unliked successfully",
+		"liked":   like,
+		"changed": changed,
+		"likes":   likes,
 	})
 }
 
-// GetComments returns comments for a specific post
-func GetComments(c *gin.Context) {
-	postIDStr := c.Param("id")
+// viewerID returns the ID of the user viewing community content: the
+// authenticated user, or the user with the wallet_address query parameter
+// on the unauthenticated mobile routes. It is 0 when there is neither.
+func viewerID(c *gin.Context) uint {
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uint); ok {
+			return id
+		}
+	}
+	wallet := strings.TrimSpace(c.Query("wallet_address"))
+	if wallet == "" {
+		return 0
+	}
+	var user models.User
+	if err := database.GetDB().Select("id").
+		Where("LOWER(wallet_address) = ?", strings.ToLower(wallet)).
+		First(&user).Error; err != nil {
+		return 0
+	}
+	return user.ID
+}
 
-	postID, err := primitive.ObjectIDFromHex(postIDStr)
+// actingUser returns the user acting on community content: the
+// authenticated user or, on the unauthenticated mobile routes, the user
+// with the wallet_address query parameter or bodyWallet, who is created on
+// first use. Without either it writes a 400 and returns false.
+func actingUser(c *gin.Context, bodyWallet string) (*models.User, bool) {
+	if _, ok := c.Get("user_id"); ok {
+		return currentUser(c)
+	}
+	wallet := strings.TrimSpace(c.Query("wallet_address"))
+	if wallet == "" {
+		wallet = strings.TrimSpace(bodyWallet)
+	}
+	if wallet == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet_address is required"})
+		return nil, false
+	}
+	user, err := community.UserForWallet(database.GetDB(), wallet)
+	if errors.Is(err, community.ErrInvalidWallet) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet_address"})
+		return nil, false
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+	return user, true
+}
+
+// displayName is the name content is shown under: the requested one, else
+// the user's, else their short wallet
+func displayName(requested string, user *models.User) string {
+	if requested != "" {
+		return requested
+	}
+	if user.Username != "" {
+		return user.Username
+	}
+	return shortWallet(user.WalletAddress)
+}
+
+// Deepest reply level; replies to a comment at this depth join its thread
+// as siblings
+const maxCommentDepth = 2
+
+// commentView formats a comment for the mobile app. Deleted and moderated
+// comments keep their place in the thread without their author or content.
+func commentView(comment community.Comment) gin.H {
+	hidden := comment.Status != "" && comment.Status != moderation.StatusVisible
+	view := gin.H{
+		"id":             comment.ID,
+		"post_id":        comment.PostID,
+		"parent_id":      nil,
+		"depth":          comment.Depth,
+		"user":           comment.Username,
+		"user_id":        comment.UserID,
+		"wallet_address": comment.WalletAddress,
+		"content":        comment.Content,
+		"replies_count":  comment.RepliesCount,
+		"tags":           nonNilTags(comment.Tags),
+		"mentions":       nonNilMentions(comment.Mentions),
+		"edited_at":      comment.EditedAt,
+		"deleted":        comment.DeletedAt != nil,
+		"hidden":         hidden,
+		"created_at":     comment.CreatedAt,
+		"time":           formatTimeAgo(comment.CreatedAt),
+	}
+	if comment.ParentID != "" {
+		view["parent_id"] = comment.ParentID
+	}
+	if comment.DeletedAt != nil || hidden {
+		view["user"] = ""
+		view["user_id"] = 0
+		view["wallet_address"] = ""
+		view["content"] = ""
+		view["tags"] = []string{}
+		view["mentions"] = []community.Mention{}
+	}
+	return view
+}
+
+// GetComments returns a page of a post's top-level comments, oldest first,
+// or of the replies to parent_id when it is set. Each comment carries its
+// reply count; replies are fetched with parent_id.
+func GetComments(c *gin.Context) {
+	postID := c.Param("id")
+	if !community.ValidID(postID) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
 		return
 	}
+	parentID := c.Query("parent_id")
+	if parentID != "" && !community.ValidID(parentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+		return
+	}
+
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	cursor, ok := pageCursor(c)
+	if !ok {
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection := mongodb.GetCollection("comments")
-
-	// Sort by created_at ascending (oldest first)
-	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
-
-	cursor, err := collection.Find(ctx, bson.M{"post_id": postID}, opts)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+	if _, ok := visiblePost(c, ctx, postID); !ok {
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var comments []mongoModels.Comment
-	if err := cursor.All(ctx, &comments); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode comments"})
+	comments, total, err := community.Default().ListComments(ctx, community.CommentQuery{
+		PostID:   postID,
+		ParentID: parentID,
+		Limit:    pageSize,
+		After:    cursor,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
 		return
 	}
 
 	// Format response for mobile app
-	var mobileComments []gin.H
+	mobileComments := make([]gin.H, 0, len(comments))
 	for _, comment := range comments {
-		mobileComments = append(mobileComments, gin.H{
-			"id":         comment.ID.Hex(),
-			"post_id":    comment.PostID.Hex(),
-			"user":       comment.Username,
-			"user_id":    comment.UserID,
-			"content":    comment.Content,
-			"created_at": comment.CreatedAt,
-			"time":       formatTimeAgo(comment.CreatedAt),
-		})
+		mobileComments = append(mobileComments, commentView(comment))
+	}
+
+	var next string
+	if len(comments) == pageSize {
+		next = comments[len(comments)-1].Cursor().String()
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"ok":   true,
-		"data": mobileComments,
+		"ok":          true,
+		"data":        mobileComments,
+		"page_size":   pageSize,
+		"total":       total,
+		"next_cursor": next,
 	})
 }
 
-// CreateComment creates a new comment on a post
+// CreateComment creates a comment on a post, or a reply when parent_id is
+// set, authored by the authenticated user
 func CreateComment(c *gin.Context) {
-	postIDStr := c.Param("id")
-
-	postID, err := primitive.ObjectIDFromHex(postIDStr)
-	if err != nil {
+	postID := c.Param("id")
+	if !community.ValidID(postID) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
 		return
 	}
 
 	var input struct {
-		WalletAddress string `json:"wallet_address" binding:"required"`
+		WalletAddress string `json:"wallet_address"`
 		Username      string `json:"username"`
 		Content       string `json:"content" binding:"required"`
+		ParentID      string `json:"parent_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -270,58 +600,263 @@ func CreateComment(c *gin.Context) {
 		return
 	}
 
-	// Default username if not provided
-	username := input.Username
-	if username == "" {
-		username = input.WalletAddress[:8] + "..."
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	if input.WalletAddress != "" && !strings.EqualFold(input.WalletAddress, user.WalletAddress) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "wallet_address does not match the authenticated user"})
+		return
 	}
 
-	comment := mongoModels.Comment{
-		PostID:    postID,
-		UserID:    input.WalletAddress,
-		Username:  username,
-		Content:   input.Content,
-		CreatedAt: time.Now(),
+	status, held, ok := moderateContent(c, input.Content)
+	if !ok {
+		return
 	}
 
+	username := displayName(input.Username, user)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Insert comment
-	collection := mongodb.GetCollection("comments")
-	result, err := collection.InsertOne(ctx, comment)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+	post, ok := visiblePost(c, ctx, postID)
+	if !ok {
 		return
 	}
 
-	comment.ID = result.InsertedID.(primitive.ObjectID)
+	tags, mentions := parseContent(input.Content)
+	comment := community.Comment{
+		ID:            community.NewID(),
+		PostID:        postID,
+		UserID:        user.ID,
+		Username:      username,
+		WalletAddress: user.WalletAddress,
+		Content:       input.Content,
+		Tags:          tags,
+		Mentions:      mentions,
+		Status:        status,
+		Moderation:    held,
+		CreatedAt:     time.Now(),
+	}
+
+	if input.ParentID != "" {
+		if !community.ValidID(input.ParentID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+			return
+		}
+		parent, err := community.Default().GetComment(ctx, input.ParentID)
+		if err != nil || parent.PostID != postID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		if parent.DeletedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot reply to a deleted comment"})
+			return
+		}
+		if parent.Depth >= maxCommentDepth {
+			// Keep the thread at the maximum depth
+			comment.ParentID = parent.ParentID
+			comment.Depth = parent.Depth
+		} else {
+			comment.ParentID = parent.ID
+			comment.Depth = parent.Depth + 1
+		}
+	}
 
-	// Increment comments count on the post
-	postsCollection := mongodb.GetCollection("posts")
-	update := bson.M{
-		"$inc": bson.M{"comments_count": 1},
-		"$set": bson.M{"updated_at": time.Now()},
+	if !checkRateLimit(c, moderation.AllowComment, user.ID) {
+		return
 	}
 
-	_, err = postsCollection.UpdateByID(ctx, postID, update)
-	if err != nil {
-		// Log error but don't fail the request
-		// The comment was created successfully
-		c.JSON(http.StatusCreated, gin.H{
-			"ok":      true,
-			"data":    comment,
-			"warning": "Comment created but post count may not be updated",
-		})
+	if err := community.Default().CreateComment(ctx, &comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
 
+	if status == moderation.StatusVisible {
+		notifyPostAuthor(post, comment)
+		notifyMentions(comment.Mentions, nil, user.ID, username, comment.Content, mentionData(postID, comment.ID))
+	}
+	comment.Tags = nonNilTags(comment.Tags)
+	comment.Mentions = nonNilMentions(comment.Mentions)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"ok":   true,
 		"data": comment,
 	})
 }
 
+// authenticatedUser returns the user behind the bearer token, writing a 401
+// when there is none
+func authenticatedUser(c *gin.Context) (*models.User, bool) {
+	if _, ok := c.Get("user_id"); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		return nil, false
+	}
+	return currentUser(c)
+}
+
+// ownComment loads a live comment written by the authenticated user,
+// writing the error response and returning false otherwise
+func ownComment(c *gin.Context, ctx context.Context) (*community.Comment, bool) {
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	commentID := c.Param("id")
+	if !community.ValidID(commentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return nil, false
+	}
+
+	comment, err := community.Default().GetComment(ctx, commentID)
+	if errors.Is(err, community.ErrNotFound) || (err == nil && comment.DeletedAt != nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comment"})
+		return nil, false
+	}
+	if comment.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can change this comment"})
+		return nil, false
+	}
+	return comment, true
+}
+
+// UpdateComment replaces the content of the author's comment, keeping the
+// previous content as a revision
+func UpdateComment(c *gin.Context) {
+	var input struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	comment, ok := ownComment(c, ctx)
+	if !ok {
+		return
+	}
+	if comment.Content == input.Content {
+		c.JSON(http.StatusOK, gin.H{"ok": true, "data": commentView(*comment)})
+		return
+	}
+	status, held, ok := moderateContent(c, input.Content)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	tags, mentions := parseContent(input.Content)
+	edit := community.Edit{Content: input.Content, Tags: tags, Mentions: mentions, At: now}
+	// An edit can send a visible comment to review, but never undoes a hold
+	if held != nil && comment.Visible() {
+		edit.Hold = true
+		edit.FlaggedTerms = held.FlaggedTerms
+		comment.Status = status
+	}
+	err := community.Default().EditComment(ctx, comment.ID, comment.Content, edit)
+	if errors.Is(err, community.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Comment changed, reload and try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+
+	if comment.Visible() {
+		notifyMentions(mentions, comment.Mentions, comment.UserID, comment.Username, input.Content, mentionData(comment.PostID, comment.ID))
+	}
+
+	comment.Content = input.Content
+	comment.Tags = tags
+	comment.Mentions = mentions
+	comment.EditedAt = &now
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": commentView(*comment)})
+}
+
+// DeleteComment soft deletes the author's comment. Replies stay in place
+// under the deleted comment.
+func DeleteComment(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	comment, ok := ownComment(c, ctx)
+	if !ok {
+		return
+	}
+
+	deleted, err := community.Default().DeleteComment(ctx, comment.ID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	count := 0
+	if deleted {
+		count = 1
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "deleted": count})
+}
+
+// GetCommentRevisions returns a comment's earlier versions, oldest first
+func GetCommentRevisions(c *gin.Context) {
+	commentID := c.Param("id")
+	if !community.ValidID(commentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	comment, err := community.Default().GetComment(ctx, commentID)
+	if err != nil || !comment.Visible() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	revisions, err := community.Default().CommentRevisions(ctx, commentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": revisions, "current": commentView(*comment)})
+}
+
+// shortWallet is the default display name of a wallet
+func shortWallet(wallet string) string {
+	if len(wallet) <= 8 {
+		return wallet
+	}
+	return wallet[:8] + "..."
+}
+
+// notifyPostAuthor tells a post's author about a new comment, unless they
+// wrote it themselves
+func notifyPostAuthor(post *community.Post, comment community.Comment) {
+	if post.UserID == 0 || post.UserID == comment.UserID {
+		return
+	}
+	notify.Publish(notify.Message{
+		UserID: post.UserID,
+		Type:   notify.TypeComment,
+		Title:  comment.Username + " commented on your post",
+		Body:   comment.Content,
+		Data: map[string]interface{}{
+			"post_id":    post.ID,
+			"comment_id": comment.ID,
+		},
+	})
+}
+
 // Helper function to format time ago
 func formatTimeAgo(t time.Time) string {
 	duration := time.Since(t)
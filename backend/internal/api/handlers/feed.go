@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"conflux-demo/backend/internal/community"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Most followed users whose posts make up a home feed
+const maxFeedFollows = 5000
+
+// pageCursor reads the cursor query parameter, writing a 400 and returning
+// false when it is malformed. The cursor is nil on the first page.
+func pageCursor(c *gin.Context) (*community.Cursor, bool) {
+	raw := c.Query("cursor")
+	if raw == "" {
+		return nil, true
+	}
+	cursor, err := community.ParseCursor(raw)
+	if err != nil || !community.ValidID(cursor.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return nil, false
+	}
+	return &cursor, true
+}
+
+// GetFeed returns the viewer's home feed: visible posts by the users they
+// follow, paged and sorted like GetPosts. Reading the feed never creates a
+// user, so a wallet without an account gets an empty feed.
+func GetFeed(c *gin.Context) {
+	if _, ok := c.Get("user_id"); !ok && strings.TrimSpace(c.Query("wallet_address")) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet_address is required"})
+		return
+	}
+
+	authors := []uint{}
+	if viewer := viewerID(c); viewer != 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		followees, err := community.Default().Followees(ctx, viewer, maxFeedFollows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows"})
+			return
+		}
+		if followees != nil {
+			authors = followees
+		}
+	}
+
+	listPosts(c, community.PostQuery{Authors: authors})
+}
+
+// FollowUser adds the user with the wallet in the path to the
+// authenticated user's feed. Following twice has no effect.
+func FollowUser(c *gin.Context) {
+	setFollow(c, true)
+}
+
+// UnfollowUser removes the user with the wallet in the path from the
+// authenticated user's feed, if they were followed
+func UnfollowUser(c *gin.Context) {
+	setFollow(c, false)
+}
+
+// setFollow adds or removes one follow
+func setFollow(c *gin.Context, follow bool) {
+	target, ok := communityUser(c)
+	if !ok {
+		return
+	}
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	if user.ID == target.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changed, followers, err := community.Default().SetFollow(ctx, user.ID, target.ID, follow)
+	if err != nil {
+		action := "follow"
+		if !follow {
+			action = "unfollow"
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":        true,
+		"following": follow,
+		"changed":   changed,
+		"followers": followers,
+	})
+}
+
+// GetFollowers lists who follows the user with the wallet in the path,
+// most recent first
+func GetFollowers(c *gin.Context) {
+	listFollows(c, true)
+}
+
+// GetFollowing lists whom the user with the wallet in the path follows,
+// most recent first
+func GetFollowing(c *gin.Context) {
+	listFollows(c, false)
+}
+
+// listFollows writes a page of the user's followers, or of whom they
+// follow
+func listFollows(c *gin.Context, followers bool) {
+	user, ok := communityUser(c)
+	if !ok {
+		return
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	cursor, ok := pageCursor(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	follows, total, err := community.Default().ListFollows(ctx, community.FollowQuery{
+		UserID:    user.ID,
+		Followers: followers,
+		Limit:     pageSize,
+		After:     cursor,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows"})
+		return
+	}
+
+	ids := make([]uint, 0, len(follows))
+	for _, f := range follows {
+		if followers {
+			ids = append(ids, f.FollowerID)
+		} else {
+			ids = append(ids, f.FolloweeID)
+		}
+	}
+	var users []models.User
+	if len(ids) > 0 {
+		if err := database.GetDB().Select("id", "wallet_address", "username").
+			Where("id IN ?", ids).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+			return
+		}
+	}
+	byID := map[uint]models.User{}
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	data := make([]gin.H, 0, len(follows))
+	for i, f := range follows {
+		u := byID[ids[i]]
+		username := u.Username
+		if username == "" {
+			username = shortWallet(u.WalletAddress)
+		}
+		data = append(data, gin.H{
+			"user_id":        ids[i],
+			"wallet_address": u.WalletAddress,
+			"username":       username,
+			"followed_at":    f.CreatedAt,
+		})
+	}
+
+	var next string
+	if len(follows) == pageSize {
+		next = follows[len(follows)-1].Cursor().String()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":          true,
+		"data":        data,
+		"total":       total,
+		"page_size":   pageSize,
+		"next_cursor": next,
+	})
+}
+
+// communityUser loads the user with the wallet in the path, writing a 404
+// and returning false if there is none
+func communityUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	err := database.GetDB().Select("id", "wallet_address", "username").
+		Where("LOWER(wallet_address) = ?", strings.ToLower(c.Param("wallet"))).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+	return &user, true
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/kyc"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Largest accepted KYC document
+const maxKYCDocumentSize = 10 << 20
+
+// Accepted document content types, detected from the file content, and the
+// extension each is stored with
+var kycDocumentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+// Document kinds of a submission, in display order
+var kycDocumentKinds = []string{"id_front", "id_back", "selfie"}
+
+// kycDocumentPath returns the stored path of a document kind, or "" if the
+// submission has none
+func kycDocumentPath(s *models.KYCSubmission, kind string) string {
+	switch kind {
+	case "id_front":
+		return s.FrontPath
+	case "id_back":
+		return s.BackPath
+	case "selfie":
+		return s.SelfiePath
+	}
+	return ""
+}
+
+// GetKYCStatus returns the current user's KYC status, limits and latest
+// submission
+func GetKYCStatus(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	limits := gin.H{}
+	for _, kind := range []string{kyc.LimitDeposit, kyc.LimitInvestment} {
+		used, err := kyc.UsedToday(database.GetDB(), user.ID, kind, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute limits"})
+			return
+		}
+		limits[kind] = gin.H{"daily_limit": kyc.LimitFor(user, kind), "used_today": roundFen(used)}
+	}
+
+	var latest *models.KYCSubmission
+	var submission models.KYCSubmission
+	if err := database.GetDB().Where("user_id = ?", user.ID).Order("id DESC").First(&submission).Error; err == nil {
+		latest = &submission
+	}
+
+	status := user.KYCStatus
+	if status == "" {
+		status = kyc.StatusNone
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"ok":         true,
+		"status":     status,
+		"tier":       user.KYCTier,
+		"limits":     limits,
+		"submission": latest,
+	})
+}
+
+// SubmitKYC accepts identity documents as multipart form data: full_name,
+// id_type, id_number and the id_front, id_back and optional selfie files
+func SubmitKYC(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	fullName := strings.TrimSpace(c.PostForm("full_name"))
+	idType := c.PostForm("id_type")
+	idNumber := strings.ToUpper(strings.TrimSpace(c.PostForm("id_number")))
+	if fullName == "" || idType == "" || idNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "full_name, id_type and id_number are required"})
+		return
+	}
+	if !kyc.CanTransition(user.KYCStatus, kyc.StatusPending) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot submit while KYC status is %s", user.KYCStatus)})
+		return
+	}
+
+	submission := models.KYCSubmission{
+		FullName:      fullName,
+		IDType:        idType,
+		RequestedTier: kyc.TierBasic,
+	}
+
+	dir := filepath.Join(kyc.DocumentDir(), strconv.FormatUint(uint64(user.ID), 10))
+	prefix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	for _, doc := range []struct {
+		field    string
+		required bool
+		path     *string
+	}{
+		{"id_front", true, &submission.FrontPath},
+		{"id_back", idType == kyc.IDTypeResident, &submission.BackPath},
+		{"selfie", false, &submission.SelfiePath},
+	} {
+		file, err := c.FormFile(doc.field)
+		if err != nil {
+			if doc.required {
+				c.JSON(http.StatusBadRequest, gin.H{"error": doc.field + " is required"})
+				return
+			}
+			continue
+		}
+		path, err := saveKYCDocument(c, file, dir, prefix+"-"+doc.field)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		*doc.path = path
+	}
+	if submission.SelfiePath != "" {
+		submission.RequestedTier = kyc.TierAdvanced
+	}
+
+	if err := kyc.Submit(c.Request.Context(), database.GetDB(), user, &submission, idNumber); err != nil {
+		if errors.Is(err, kyc.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "KYC submission is no longer allowed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit KYC documents"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ok":         true,
+		"status":     user.KYCStatus,
+		"tier":       user.KYCTier,
+		"submission": submission,
+	})
+}
+
+// saveKYCDocument validates and stores one uploaded document. The type is
+// sniffed from the content; the client's file name and content type are not
+// trusted.
+func saveKYCDocument(c *gin.Context, file *multipart.FileHeader, dir, name string) (string, error) {
+	if file.Size > maxKYCDocumentSize {
+		return "", fmt.Errorf("%s is larger than 10MB", file.Filename)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s", file.Filename)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxKYCDocumentSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s", file.Filename)
+	}
+	if len(data) > maxKYCDocumentSize {
+		return "", fmt.Errorf("%s is larger than 10MB", file.Filename)
+	}
+	ext, ok := kycDocumentTypes[strings.Split(http.DetectContentType(data), ";")[0]]
+	if !ok {
+		return "", fmt.Errorf("%s must be a JPEG, PNG or PDF file", file.Filename)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+ext)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkKYCLimit writes a 403 and returns false if amount would exceed the
+// user's daily limit of kind
+func checkKYCLimit(c *gin.Context, user *models.User, kind string, amount float64) bool {
+	err := kyc.CheckLimit(database.GetDB(), user, kind, amount)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, kyc.ErrLimitExceeded) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":      err.Error(),
+			"kyc_status": user.KYCStatus,
+			"kyc_tier":   user.KYCTier,
+		})
+		return false
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check KYC limits"})
+	return false
+}
+
+// AdminListKYCSubmissions returns submissions, pending ones by default
+func AdminListKYCSubmissions(c *gin.Context) {
+	status := c.DefaultQuery("status", kyc.StatusPending)
+
+	var submissions []models.KYCSubmission
+	if err := database.GetDB().Where("status = ?", status).
+		Order("created_at ASC").Limit(100).
+		Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": submissions})
+}
+
+// AdminGetKYCSubmission returns a submission with its decision history
+func AdminGetKYCSubmission(c *gin.Context) {
+	var submission models.KYCSubmission
+	if err := database.GetDB().First(&submission, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+
+	var decisions []models.KYCDecision
+	database.GetDB().Where("submission_id = ?", submission.ID).Order("id ASC").Find(&decisions)
+
+	documents := []string{}
+	for _, kind := range kycDocumentKinds {
+		if kycDocumentPath(&submission, kind) != "" {
+			documents = append(documents, kind)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":        true,
+		"data":      submission,
+		"documents": documents,
+		"decisions": decisions,
+	})
+}
+
+// AdminGetKYCDocument streams one document of a submission
+func AdminGetKYCDocument(c *gin.Context) {
+	var submission models.KYCSubmission
+	if err := database.GetDB().First(&submission, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+
+	path := kycDocumentPath(&submission, c.Param("kind"))
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	c.File(path)
+}
+
+// AdminApproveKYC verifies a pending submission. The tier defaults to the
+// requested one.
+func AdminApproveKYC(c *gin.Context) {
+	var input struct {
+		Tier int    `json:"tier"`
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reviewKYC(c, true, input.Tier, input.Note)
+}
+
+// AdminRejectKYC rejects a pending submission; a note is required
+func AdminRejectKYC(c *gin.Context) {
+	var input struct {
+		Note string `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reviewKYC(c, false, 0, input.Note)
+}
+
+func reviewKYC(c *gin.Context, approve bool, tier int, note string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+	reviewer := c.MustGet(gin.AuthUserKey).(string)
+
+	submission, err := kyc.Review(database.GetDB(), uint(id), reviewer, approve, tier, note)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"ok": true, "data": submission})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+	case errors.Is(err, kyc.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Submission is not pending review"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
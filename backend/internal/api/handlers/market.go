@@ -1,57 +1,244 @@
 package handlers
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"conflux-demo/backend/internal/database"
 	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/marketdata"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetMarketPrices returns current market prices
 func GetMarketPrices(c *gin.Context) {
+	marketData, err := latestMarketData()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": marketData})
+}
+
+// GetMarketSources returns the health of the market data sources
+func GetMarketSources(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": marketdata.Statuses()})
+}
+
+// latestMarketData returns the latest row of each product
+func latestMarketData() ([]models.MarketData, error) {
 	var marketData []models.MarketData
 
-	// Get the latest price for each product
 	subQuery := database.GetDB().Model(&models.MarketData{}).
 		Select("product_name, MAX(timestamp) as max_timestamp").
 		Group("product_name")
 
-	if err := database.GetDB().
+	err := database.GetDB().
 		Joins("INNER JOIN (?) as latest ON market_data.product_name = latest.product_name AND market_data.timestamp = latest.max_timestamp", subQuery).
-		Find(&marketData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
-		return
-	}
+		Order("market_data.product_name ASC").
+		Find(&marketData).Error
+	return marketData, err
+}
 
-	c.JSON(http.StatusOK, gin.H{"data": marketData})
+// Longest moving average and most candles a history request may ask for
+const (
+	maxMovingAverage = 200
+	maxCandles       = 2000
+)
+
+// candle is one OHLC bucket of a price history response
+type candle struct {
+	Time   time.Time           `json:"time"`
+	Open   float64             `json:"open"`
+	High   float64             `json:"high"`
+	Low    float64             `json:"low"`
+	Close  float64             `json:"close"`
+	Volume float64             `json:"volume"`
+	Ticks  int                 `json:"ticks"`
+	MA     map[string]*float64 `json:"ma"` // Keyed "ma7", "ma30"; null until enough candles
 }
 
-// GetPriceHistory returns historical price data for a product
+// GetPriceHistory returns OHLC candles of a product. The range is from/to
+// (RFC 3339 or YYYY-MM-DD) or the last days, 30 by default; interval is 1h,
+// 1d or 1w; ma lists moving average periods over candle closes, "7,30" by
+// default.
 func GetPriceHistory(c *gin.Context) {
 	product := c.Param("product")
 
-	// Default to last 30 days
-	days := 30
-	if d := c.Query("days"); d != "" {
-		// Parse days parameter
+	interval := c.DefaultQuery("interval", marketdata.Interval1d)
+	if _, err := marketdata.BucketStart(interval, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be 1h, 1d or 1w"})
+		return
+	}
+
+	from, to, err := historyRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	periods, err := movingAveragePeriods(c.DefaultQuery("ma", "7,30"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, _ := marketdata.BucketStart(interval, from)
+	if bucketCount(interval, start, to) > maxCandles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range covers more than %d candles", maxCandles)})
+		return
 	}
 
-	startTime := time.Now().AddDate(0, 0, -days)
+	// Earlier candles seed the moving averages of the first ones in range
+	lookback := 0
+	for _, p := range periods {
+		if p-1 > lookback {
+			lookback = p - 1
+		}
+	}
 
-	var history []models.MarketData
+	var rows []models.MarketCandle
 	if err := database.GetDB().
-		Where("product_name = ? AND timestamp >= ?", product, startTime).
-		Order("timestamp ASC").
-		Find(&history).Error; err != nil {
+		Where("product_name = ? AND `interval` = ? AND bucket_start <= ?", product, interval, to).
+		Where("bucket_start >= ?", start).
+		Order("bucket_start ASC").
+		Find(&rows).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
 		return
 	}
 
+	var seed []models.MarketCandle
+	if lookback > 0 {
+		if err := database.GetDB().
+			Where("product_name = ? AND `interval` = ? AND bucket_start < ?", product, interval, start).
+			Order("bucket_start DESC").Limit(lookback).
+			Find(&seed).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+			return
+		}
+	}
+
+	closes := make([]float64, 0, len(seed)+len(rows))
+	for i := len(seed) - 1; i >= 0; i-- {
+		closes = append(closes, seed[i].Close)
+	}
+
+	candles := make([]candle, 0, len(rows))
+	for _, row := range rows {
+		closes = append(closes, row.Close)
+		cd := candle{
+			Time:   row.BucketStart,
+			Open:   row.Open,
+			High:   row.High,
+			Low:    row.Low,
+			Close:  row.Close,
+			Volume: row.Volume,
+			Ticks:  row.Ticks,
+			MA:     map[string]*float64{},
+		}
+		for _, p := range periods {
+			cd.MA["ma"+strconv.Itoa(p)] = movingAverage(closes, p)
+		}
+		candles = append(candles, cd)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"product": product,
-		"data":    history,
+		"product":  product,
+		"interval": interval,
+		"from":     from,
+		"to":       to,
+		"data":     candles,
 	})
 }
+
+// historyRange parses from/to, or days back from now when from is missing
+func historyRange(c *gin.Context) (time.Time, time.Time, error) {
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %v", err)
+		}
+		to = t
+	}
+
+	var from time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %v", err)
+		}
+		from = t
+	} else {
+		days := 30
+		if d := c.Query("days"); d != "" {
+			n, err := strconv.Atoi(d)
+			if err != nil || n < 1 || n > 3650 {
+				return time.Time{}, time.Time{}, fmt.Errorf("days must be between 1 and 3650")
+			}
+			days = n
+		}
+		from = to.AddDate(0, 0, -days)
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+// parseHistoryTime accepts RFC 3339 timestamps and local YYYY-MM-DD dates
+func parseHistoryTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Local(), nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// movingAveragePeriods parses a comma separated list of periods; an empty
+// list disables moving averages
+func movingAveragePeriods(v string) ([]int, error) {
+	periods := []int{}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		p, err := strconv.Atoi(part)
+		if err != nil || p < 2 || p > maxMovingAverage {
+			return nil, fmt.Errorf("ma periods must be between 2 and %d", maxMovingAverage)
+		}
+		periods = append(periods, p)
+	}
+	return periods, nil
+}
+
+// bucketCount returns how many candles of interval start between start and
+// to, counting no further than one past maxCandles
+func bucketCount(interval string, start, to time.Time) int {
+	count := 0
+	for t := start; !t.After(to) && count <= maxCandles; t = marketdata.NextBucket(interval, t) {
+		count++
+	}
+	return count
+}
+
+// movingAverage returns the mean of the last period closes, or nil if there
+// are fewer
+func movingAverage(closes []float64, period int) *float64 {
+	if len(closes) < period {
+		return nil
+	}
+	sum := 0.0
+	for _, v := range closes[len(closes)-period:] {
+		sum += v
+	}
+	avg := math.Round(sum/float64(period)*10000) / 10000
+	return &avg
+}
@@ -0,0 +1,782 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"conflux-demo/backend/internal/blockchain"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/kyc"
+	"conflux-demo/backend/internal/yield"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errListingNotOpen      = errors.New("listing is not open")
+	errAssetUnavailable    = errors.New("asset is no longer available")
+	errInsufficientBalance = errors.New("insufficient balance")
+	errOwnListing          = errors.New("cannot buy your own listing")
+	errOfferNotPending     = errors.New("offer is not pending")
+	errSettlementPending   = errors.New("settlement is awaiting confirmation")
+)
+
+// marketplaceError maps settlement errors to HTTP responses
+func marketplaceError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, errListingNotOpen), errors.Is(err, errAssetUnavailable), errors.Is(err, errOfferNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errInsufficientBalance):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	case errors.Is(err, errOwnListing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, kyc.ErrLimitExceeded):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// GetListings returns marketplace listings, open ones by default
+func GetListings(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	query := database.GetDB().
+		Preload("Asset.Product").
+		Where("listings.status = ?", c.DefaultQuery("status", "open"))
+
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Joins("JOIN user_assets ON user_assets.id = listings.asset_id").
+			Where("user_assets.product_id = ?", productID)
+	}
+
+	var listings []models.Listing
+	if err := query.Order("listings.created_at DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&listings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch listings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      listings,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetListing returns a listing with its pending offers
+func GetListing(c *gin.Context) {
+	var listing models.Listing
+	if err := database.GetDB().Preload("Asset.Product").First(&listing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Listing not found"})
+		return
+	}
+
+	var offers []models.Offer
+	if err := database.GetDB().
+		Where("listing_id = ? AND status = ?", listing.ID, "pending").
+		Order("amount DESC").
+		Find(&offers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": listing, "offers": offers})
+}
+
+// CreateListing puts one of the current user's active assets up for sale
+func CreateListing(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		AssetID uint    `json:"asset_id" binding:"required"`
+		Price   float64 `json:"price" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var listing models.Listing
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var asset models.UserAsset
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND wallet_address = ? AND status = ?", input.AssetID, user.WalletAddress, "active").
+			First(&asset).Error; err != nil {
+			return errAssetUnavailable
+		}
+
+		var open int64
+		if err := tx.Model(&models.Listing{}).
+			Where("asset_id = ? AND status = ?", asset.ID, "open").
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return errAssetUnavailable
+		}
+
+		listing = models.Listing{
+			AssetID:  asset.ID,
+			SellerID: user.ID,
+			Price:    roundFen(input.Price),
+			Status:   "open",
+		}
+		return tx.Create(&listing).Error
+	})
+	if err != nil {
+		marketplaceError(c, err, "Failed to create listing")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": listing})
+}
+
+// CancelListing withdraws a listing and refunds every pending offer
+func CancelListing(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var listing models.Listing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND seller_id = ?", c.Param("id"), user.ID).
+			First(&listing).Error; err != nil {
+			return err
+		}
+		if listing.Status != "open" {
+			return errListingNotOpen
+		}
+
+		if err := refundPendingOffers(tx, listing.ID, "rejected"); err != nil {
+			return err
+		}
+		return tx.Model(&listing).Update("status", "cancelled").Error
+	})
+	if err != nil {
+		marketplaceError(c, err, "Failed to cancel listing")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Listing cancelled"})
+}
+
+// BuyListing purchases a listing at its asking price. The buyer's daily
+// investment limit and risk suitability apply as for a primary investment.
+func BuyListing(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		AcknowledgeRisk bool `json:"acknowledge_risk"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var listing models.Listing
+	if err := database.GetDB().Preload("Asset.Product").First(&listing, c.Param("id")).Error; err != nil {
+		marketplaceError(c, err, "Failed to complete purchase")
+		return
+	}
+	ack, ok := checkSuitability(c, user, &listing.Asset.Product, listing.Price, input.AcknowledgeRisk, "marketplace")
+	if !ok {
+		return
+	}
+	if !checkKYCLimit(c, user, kyc.LimitInvestment, listing.Price) {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&listing, listing.ID).Error; err != nil {
+			return err
+		}
+		if listing.SellerID == user.ID {
+			return errOwnListing
+		}
+		if err := debitBalance(tx, user.ID, listing.Price); err != nil {
+			return err
+		}
+		if err := recordAcknowledgement(tx, ack); err != nil {
+			return err
+		}
+		return beginSettlement(tx, &listing, user.ID, nil, listing.Price)
+	})
+	if err != nil {
+		marketplaceError(c, err, "Failed to complete purchase")
+		return
+	}
+
+	if err := completeSettlement(&listing); err != nil {
+		if errors.Is(err, errSettlementPending) {
+			c.JSON(http.StatusAccepted, gin.H{"message": "Settlement pending", "data": listing})
+			return
+		}
+		marketplaceError(c, err, "Failed to complete purchase")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase completed", "data": listing})
+}
+
+// CreateOffer places a bid on a listing, holding the amount in escrow. The
+// buyer's limit and suitability are checked now, as the seller accepts
+// without the buyer present.
+func CreateOffer(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Amount          float64 `json:"amount" binding:"required,gt=0"`
+		AcknowledgeRisk bool    `json:"acknowledge_risk"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amount := roundFen(input.Amount)
+
+	var listing models.Listing
+	if err := database.GetDB().Preload("Asset.Product").First(&listing, c.Param("id")).Error; err != nil {
+		marketplaceError(c, err, "Failed to create offer")
+		return
+	}
+	ack, ok := checkSuitability(c, user, &listing.Asset.Product, amount, input.AcknowledgeRisk, "marketplace_offer")
+	if !ok {
+		return
+	}
+	if !checkKYCLimit(c, user, kyc.LimitInvestment, amount) {
+		return
+	}
+
+	var offer models.Offer
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+			First(&listing, listing.ID).Error; err != nil {
+			return err
+		}
+		if listing.Status != "open" {
+			return errListingNotOpen
+		}
+		if listing.SellerID == user.ID {
+			return errOwnListing
+		}
+
+		if err := debitBalance(tx, user.ID, amount); err != nil {
+			return err
+		}
+		if err := recordAcknowledgement(tx, ack); err != nil {
+			return err
+		}
+
+		offer = models.Offer{
+			ListingID: listing.ID,
+			BuyerID:   user.ID,
+			Amount:    amount,
+			Status:    "pending",
+		}
+		return tx.Create(&offer).Error
+	})
+	if err != nil {
+		marketplaceError(c, err, "Failed to create offer")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": offer})
+}
+
+// AcceptOffer sells the listing to the offer's buyer using the escrowed
+// funds. The buyer's daily investment limit is checked again on the day of
+// the sale.
+func AcceptOffer(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var listing models.Listing
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var offer models.Offer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&offer, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if offer.Status != "pending" {
+			return errOfferNotPending
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND seller_id = ?", offer.ListingID, user.ID).
+			First(&listing).Error; err != nil {
+			return err
+		}
+
+		var buyer models.User
+		if err := tx.First(&buyer, offer.BuyerID).Error; err != nil {
+			return err
+		}
+		if err := kyc.CheckLimit(tx, &buyer, kyc.LimitInvestment, offer.Amount); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&offer).Update("status", "accepted").Error; err != nil {
+			return err
+		}
+		return beginSettlement(tx, &listing, offer.BuyerID, &offer.ID, offer.Amount)
+	})
+	if err != nil {
+		marketplaceError(c, err, "Failed to accept offer")
+		return
+	}
+
+	if err := completeSettlement(&listing); err != nil {
+		if errors.Is(err, errSettlementPending) {
+			c.JSON(http.StatusAccepted, gin.H{"message": "Settlement pending", "data": listing})
+			return
+		}
+		marketplaceError(c, err, "Failed to accept offer")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Offer accepted", "data": listing})
+}
+
+// RejectOffer lets the seller decline an offer; the escrow is refunded
+func RejectOffer(c *gin.Context) {
+	closeOffer(c, "rejected")
+}
+
+// WithdrawOffer lets the buyer cancel an offer; the escrow is refunded
+func WithdrawOffer(c *gin.Context) {
+	closeOffer(c, "withdrawn")
+}
+
+func closeOffer(c *gin.Context, status string) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var offer models.Offer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&offer, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if offer.Status != "pending" {
+			return errOfferNotPending
+		}
+
+		if status == "withdrawn" && offer.BuyerID != user.ID {
+			return gorm.ErrRecordNotFound
+		}
+		if status == "rejected" {
+			var listing models.Listing
+			if err := tx.Where("id = ? AND seller_id = ?", offer.ListingID, user.ID).
+				First(&listing).Error; err != nil {
+				return err
+			}
+		}
+
+		return refundOffer(tx, &offer, status)
+	})
+	if err != nil {
+		marketplaceError(c, err, "Failed to update offer")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Offer " + status})
+}
+
+// A sale is settled in three steps so no database transaction is held open
+// while waiting for the chain:
+//
+//  1. beginSettlement, inside the caller's transaction, takes the buyer's
+//     funds and moves the listing and asset to "settling"
+//  2. completeSettlement settles on chain, if configured, with no
+//     transaction open
+//  3. finishSettlement pays the seller and transfers the asset, or
+//     abortSettlement reopens the listing and returns the buyer's funds
+//
+// A settlement whose on-chain outcome is unknown stays "settling" with its
+// transaction hash recorded until ReconcileSettlements resolves it.
+
+// How long a settlement may stay unresolved without a transaction hash
+// before it is assumed never to have reached the chain
+const settlementStaleAfter = 10 * time.Minute
+
+// beginSettlement reserves an open listing for buyerID at price. The buyer's
+// funds must already have been taken; offerID is the accepted offer whose
+// escrow paid for it, if any.
+func beginSettlement(tx *gorm.DB, listing *models.Listing, buyerID uint, offerID *uint, price float64) error {
+	if listing.Status != "open" {
+		return errListingNotOpen
+	}
+
+	var asset models.UserAsset
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&asset, listing.AssetID).Error; err != nil {
+		return err
+	}
+	if asset.Status != "active" {
+		return errAssetUnavailable
+	}
+
+	var seller models.User
+	if err := tx.First(&seller, listing.SellerID).Error; err != nil {
+		return err
+	}
+	if asset.WalletAddress != seller.WalletAddress {
+		return errAssetUnavailable
+	}
+
+	if err := tx.Model(&asset).Update("status", "settling").Error; err != nil {
+		return err
+	}
+
+	listing.Status = "settling"
+	listing.BuyerID = &buyerID
+	listing.OfferID = offerID
+	listing.SalePrice = price
+	return tx.Model(listing).Select("status", "buyer_id", "offer_id", "sale_price").Updates(listing).Error
+}
+
+// completeSettlement settles a "settling" listing on chain when a
+// marketplace contract is configured, then finishes it. A sale that failed
+// on chain is aborted; one whose outcome is unknown returns
+// errSettlementPending.
+func completeSettlement(listing *models.Listing) error {
+	db := database.GetDB()
+
+	var asset models.UserAsset
+	if err := db.First(&asset, listing.AssetID).Error; err != nil {
+		return err
+	}
+
+	client := blockchain.GetClient()
+	if !client.MarketplaceEnabled() || asset.NFTAddress == "" {
+		return finishSettlement(listing, "")
+	}
+
+	var seller, buyer models.User
+	if err := db.First(&seller, listing.SellerID).Error; err != nil {
+		return err
+	}
+	if err := db.First(&buyer, *listing.BuyerID).Error; err != nil {
+		return err
+	}
+
+	tokenID, ok := new(big.Int).SetString(asset.TokenID, 10)
+	if !ok {
+		err := fmt.Errorf("asset %d has non-numeric token ID %q", asset.ID, asset.TokenID)
+		if abortErr := abortSettlement(listing); abortErr != nil {
+			log.Printf("Failed to abort settlement of listing %d: %v", listing.ID, abortErr)
+		}
+		return err
+	}
+
+	hash, err := client.SettleSale(asset.NFTAddress, tokenID, seller.WalletAddress, buyer.WalletAddress, int64(math.Round(listing.SalePrice*100)))
+	if hash != "" {
+		// Record the hash first so the sale can be reconciled if finishing fails
+		listing.SettlementTx = hash
+		if err := db.Model(listing).Update("settlement_tx", hash).Error; err != nil {
+			log.Printf("Failed to record settlement %s of listing %d: %v", hash, listing.ID, err)
+		}
+	}
+	if err != nil {
+		if hash != "" && !errors.Is(err, blockchain.ErrSettlementReverted) {
+			log.Printf("Settlement %s of listing %d is unconfirmed: %v", hash, listing.ID, err)
+			return errSettlementPending
+		}
+		if abortErr := abortSettlement(listing); abortErr != nil {
+			log.Printf("Failed to abort settlement of listing %d: %v", listing.ID, abortErr)
+		}
+		return fmt.Errorf("on-chain settlement failed: %w", err)
+	}
+
+	return finishSettlement(listing, hash)
+}
+
+// finishSettlement pays the seller, transfers the asset to the buyer and
+// marks the listing sold. settlementTx is empty for a sale settled off
+// chain.
+func finishSettlement(listing *models.Listing, settlementTx string) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(listing, listing.ID).Error; err != nil {
+			return err
+		}
+		if listing.Status != "settling" {
+			return errListingNotOpen
+		}
+
+		var asset models.UserAsset
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Product").
+			First(&asset, listing.AssetID).Error; err != nil {
+			return err
+		}
+		var seller, buyer models.User
+		if err := tx.First(&seller, listing.SellerID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&buyer, *listing.BuyerID).Error; err != nil {
+			return err
+		}
+
+		price := listing.SalePrice
+		now := time.Now()
+
+		if err := tx.Model(&models.User{}).Where("id = ?", seller.ID).
+			Update("balance", gorm.Expr("balance + ?", price)).Error; err != nil {
+			return err
+		}
+
+		// The seller keeps the yield of the days they held the asset; the
+		// buyer continues its term from the day of the sale
+		if _, err := yield.SettleTransfer(tx, &asset, now); err != nil {
+			return err
+		}
+		if err := tx.Model(&asset).Update("status", "sold").Error; err != nil {
+			return err
+		}
+
+		termStart := yield.TermStart(&asset)
+		purchased := models.UserAsset{
+			WalletAddress:    buyer.WalletAddress,
+			ProductID:        asset.ProductID,
+			TokenID:          asset.TokenID,
+			NFTAddress:       asset.NFTAddress,
+			InvestmentAmount: price,
+			PurchaseDate:     now,
+			TermStartDate:    &termStart,
+			AccruedThrough:   asset.AccruedThrough,
+			LastPayoutAt:     &now,
+			Status:           "active",
+			TxHash:           settlementTx,
+		}
+		if err := tx.Create(&purchased).Error; err != nil {
+			return err
+		}
+
+		if asset.NFTAddress != "" {
+			if err := moveCustody(tx, asset.NFTAddress, asset.TokenID, seller.WalletAddress, buyer.WalletAddress, settlementTx, asset.PurchaseDate, now); err != nil {
+				return err
+			}
+		}
+
+		// Transaction hashes are unique, so only the buyer's record carries
+		// the on-chain one
+		purchaseRef := settlementTx
+		if purchaseRef == "" {
+			purchaseRef = marketReference(listing.ID, "purchase")
+		}
+		amount := fmt.Sprintf("%.2f", price)
+		records := []models.Transaction{
+			{UserID: buyer.ID, Type: "marketPurchase", Amount: amount, Status: "success", PaymentMethod: "balance", TxHash: purchaseRef, CreatedAt: now},
+			{UserID: seller.ID, Type: "marketSale", Amount: amount, Status: "success", PaymentMethod: "balance", TxHash: marketReference(listing.ID, "sale"), CreatedAt: now},
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+
+		if err := refundPendingOffers(tx, listing.ID, "rejected"); err != nil {
+			return err
+		}
+
+		listing.Status = "sold"
+		listing.SettlementTx = settlementTx
+		listing.SoldAt = &now
+		return tx.Model(listing).Select("status", "settlement_tx", "sold_at").Updates(listing).Error
+	})
+}
+
+// abortSettlement reopens a "settling" listing: the asset becomes active
+// again and the buyer's funds are returned, to the balance for a direct
+// purchase or to the offer's escrow for an accepted offer
+func abortSettlement(listing *models.Listing) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(listing, listing.ID).Error; err != nil {
+			return err
+		}
+		if listing.Status != "settling" {
+			return errListingNotOpen
+		}
+
+		if err := tx.Model(&models.UserAsset{}).
+			Where("id = ? AND status = ?", listing.AssetID, "settling").
+			Update("status", "active").Error; err != nil {
+			return err
+		}
+
+		if listing.OfferID != nil {
+			if err := tx.Model(&models.Offer{}).Where("id = ?", *listing.OfferID).
+				Update("status", "pending").Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&models.User{}).Where("id = ?", *listing.BuyerID).
+			Update("balance", gorm.Expr("balance + ?", listing.SalePrice)).Error; err != nil {
+			return err
+		}
+
+		listing.Status = "open"
+		listing.BuyerID = nil
+		listing.OfferID = nil
+		listing.SalePrice = 0
+		listing.SettlementTx = ""
+		return tx.Model(listing).Select("status", "buyer_id", "offer_id", "sale_price", "settlement_tx").Updates(listing).Error
+	})
+}
+
+// ReconcileSettlements resolves listings left "settling" by an unconfirmed
+// on-chain settlement or an interrupted request. Sales confirmed on chain
+// are finished and reverted ones aborted; a settlement without a hash is
+// aborted once it is stale.
+func ReconcileSettlements(now time.Time) (finished, aborted int, err error) {
+	var listings []models.Listing
+	if err := database.GetDB().Where("status = ?", "settling").Find(&listings).Error; err != nil {
+		return 0, 0, err
+	}
+
+	client := blockchain.GetClient()
+	for i := range listings {
+		listing := &listings[i]
+
+		if listing.SettlementTx == "" {
+			if now.Sub(listing.UpdatedAt) < settlementStaleAfter {
+				continue
+			}
+			if err := abortSettlement(listing); err != nil {
+				log.Printf("Failed to abort stale settlement of listing %d: %v", listing.ID, err)
+				continue
+			}
+			aborted++
+			continue
+		}
+
+		if !client.MarketplaceEnabled() {
+			continue
+		}
+		receipt, err := client.GetTransactionReceipt(listing.SettlementTx)
+		if err != nil || receipt == nil {
+			// Not mined yet or the node is unreachable; try again later
+			continue
+		}
+		if !blockchain.ReceiptSucceeded(receipt) {
+			if err := abortSettlement(listing); err != nil {
+				log.Printf("Failed to abort reverted settlement of listing %d: %v", listing.ID, err)
+				continue
+			}
+			aborted++
+			continue
+		}
+		if err := finishSettlement(listing, listing.SettlementTx); err != nil {
+			log.Printf("Failed to finish settlement of listing %d: %v", listing.ID, err)
+			continue
+		}
+		finished++
+	}
+	return finished, aborted, nil
+}
+
+// StartSettlementReconciler runs ReconcileSettlements every interval until
+// ctx is cancelled
+func StartSettlementReconciler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				finished, aborted, err := ReconcileSettlements(now)
+				if err != nil {
+					log.Printf("Failed to reconcile marketplace settlements: %v", err)
+				} else if finished+aborted > 0 {
+					log.Printf("Reconciled marketplace settlements: %d finished, %d aborted", finished, aborted)
+				}
+			}
+		}
+	}()
+}
+
+// marketReference is the transaction reference of one side of a listing's
+// sale that has no on-chain hash of its own
+func marketReference(listingID uint, side string) string {
+	return fmt.Sprintf("market-%d-%s", listingID, side)
+}
+
+// debitBalance takes amount from the user's balance, failing if it would
+// go negative
+func debitBalance(tx *gorm.DB, userID uint, amount float64) error {
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND balance >= ?", userID, amount).
+		Update("balance", gorm.Expr("balance - ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientBalance
+	}
+	return nil
+}
+
+// refundOffer returns an offer's escrow to the buyer and closes it
+func refundOffer(tx *gorm.DB, offer *models.Offer, status string) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", offer.BuyerID).
+		Update("balance", gorm.Expr("balance + ?", offer.Amount)).Error; err != nil {
+		return err
+	}
+	return tx.Model(offer).Update("status", status).Error
+}
+
+// refundPendingOffers refunds every pending offer on a listing
+func refundPendingOffers(tx *gorm.DB, listingID uint, status string) error {
+	var offers []models.Offer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("listing_id = ? AND status = ?", listingID, "pending").
+		Find(&offers).Error; err != nil {
+		return err
+	}
+
+	for i := range offers {
+		if err := refundOffer(tx, &offers[i], status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func roundFen(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+)
+
+// marketFixture is a seller with one asset listed for sale and a buyer
+// with funds to buy it
+type marketFixture struct {
+	seller, buyer models.User
+	asset         models.UserAsset
+	listing       models.Listing
+}
+
+const (
+	listingPrice = 300.0
+	buyerFunds   = 500.0
+)
+
+func newMarketFixture(t *testing.T, db *gorm.DB) *marketFixture {
+	t.Helper()
+
+	f := &marketFixture{
+		seller: models.User{Email: "seller@example.com", WalletAddress: "cfx:seller"},
+		buyer:  models.User{Email: "buyer@example.com", WalletAddress: "cfx:buyer", Balance: buyerFunds},
+	}
+	db.Create(&f.seller)
+	db.Create(&f.buyer)
+
+	product := models.Product{Name: "Rice", Price: 250, RiskLevel: "low"}
+	db.Create(&product)
+	f.asset = models.UserAsset{
+		WalletAddress:    f.seller.WalletAddress,
+		ProductID:        product.ID,
+		TokenID:          "1",
+		InvestmentAmount: 250,
+		PurchaseDate:     time.Now(),
+		Status:           "active",
+	}
+	db.Create(&f.asset)
+	f.listing = models.Listing{AssetID: f.asset.ID, SellerID: f.seller.ID, Price: listingPrice, Status: "open"}
+	db.Create(&f.listing)
+	return f
+}
+
+// settle starts settling the listing for the buyer at the asking price, as
+// BuyListing does before it reaches the chain
+func (f *marketFixture) settle(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := debitBalance(tx, f.buyer.ID, f.listing.Price); err != nil {
+			return err
+		}
+		return beginSettlement(tx, &f.listing, f.buyer.ID, nil, f.listing.Price)
+	})
+	if err != nil {
+		t.Fatalf("begin settlement: %v", err)
+	}
+}
+
+// check asserts the balances of both parties, the owner and status of the
+// listed asset and the listing's status
+func (f *marketFixture) check(t *testing.T, db *gorm.DB, sellerBalance, buyerBalance float64, assetStatus, listingStatus string) {
+	t.Helper()
+
+	var seller, buyer models.User
+	db.First(&seller, f.seller.ID)
+	db.First(&buyer, f.buyer.ID)
+	if seller.Balance != sellerBalance || buyer.Balance != buyerBalance {
+		t.Errorf("balances seller %v buyer %v, want %v and %v", seller.Balance, buyer.Balance, sellerBalance, buyerBalance)
+	}
+
+	var asset models.UserAsset
+	db.First(&asset, f.asset.ID)
+	if asset.WalletAddress != f.seller.WalletAddress || asset.Status != assetStatus {
+		t.Errorf("listed asset owner %q status %q, want %q %q", asset.WalletAddress, asset.Status, f.seller.WalletAddress, assetStatus)
+	}
+
+	var listing models.Listing
+	db.First(&listing, f.listing.ID)
+	if listing.Status != listingStatus {
+		t.Errorf("listing status = %q, want %q", listing.Status, listingStatus)
+	}
+}
+
+func TestBuyListingSettlesOffChain(t *testing.T) {
+	db := useTestDB(t)
+	f := newMarketFixture(t, db)
+	target := "/marketplace/listings/" + strconv.Itoa(int(f.listing.ID)) + "/buy"
+
+	if rec := serve(t, BuyListing, http.MethodPost, "/marketplace/listings/:id/buy", target, f.seller.ID, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("seller buying own listing: %d, want 400", rec.Code)
+	}
+	rec := serve(t, BuyListing, http.MethodPost, "/marketplace/listings/:id/buy", target, f.buyer.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("buy: %d %s", rec.Code, rec.Body)
+	}
+	f.check(t, db, listingPrice, buyerFunds-listingPrice, "sold", "sold")
+
+	var listing models.Listing
+	db.First(&listing, f.listing.ID)
+	if listing.SettlementTx != "" {
+		t.Errorf("off-chain settlement tx = %q, want empty", listing.SettlementTx)
+	}
+
+	var purchased models.UserAsset
+	if err := db.Where("wallet_address = ? AND status = ?", f.buyer.WalletAddress, "active").First(&purchased).Error; err != nil {
+		t.Fatalf("buyer's asset: %v", err)
+	}
+	if purchased.InvestmentAmount != listingPrice || purchased.TxHash != "" {
+		t.Errorf("buyer's asset = %+v", purchased)
+	}
+
+	var records []models.Transaction
+	db.Order("id").Find(&records)
+	if len(records) != 2 || records[0].TxHash != marketReference(f.listing.ID, "purchase") ||
+		records[1].TxHash != marketReference(f.listing.ID, "sale") {
+		t.Errorf("transactions = %+v", records)
+	}
+}
+
+func TestCancelListingRefundsOffers(t *testing.T) {
+	db := useTestDB(t)
+	f := newMarketFixture(t, db)
+	target := "/marketplace/listings/" + strconv.Itoa(int(f.listing.ID))
+
+	// The offer's amount is held in escrow, out of the buyer's balance
+	offer := models.Offer{ListingID: f.listing.ID, BuyerID: f.buyer.ID, Amount: 200, Status: "pending"}
+	db.Create(&offer)
+	db.Model(&f.buyer).Update("balance", buyerFunds-offer.Amount)
+
+	if rec := serve(t, CancelListing, http.MethodDelete, "/marketplace/listings/:id", target, f.buyer.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("cancelling another user's listing: %d, want 404", rec.Code)
+	}
+	if rec := serve(t, CancelListing, http.MethodDelete, "/marketplace/listings/:id", target, f.seller.ID, nil); rec.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", rec.Code, rec.Body)
+	}
+	f.check(t, db, 0, buyerFunds, "active", "cancelled")
+
+	db.First(&offer, offer.ID)
+	if offer.Status != "rejected" {
+		t.Errorf("offer status = %q, want rejected", offer.Status)
+	}
+	if rec := serve(t, CancelListing, http.MethodDelete, "/marketplace/listings/:id", target, f.seller.ID, nil); rec.Code != http.StatusConflict {
+		t.Errorf("cancelling twice: %d, want 409", rec.Code)
+	}
+}
+
+func TestAbortSettlementAfterFailedSettlement(t *testing.T) {
+	db := useTestDB(t)
+	f := newMarketFixture(t, db)
+
+	f.settle(t, db)
+	f.check(t, db, 0, buyerFunds-listingPrice, "settling", "settling")
+
+	// A settlement that reverted on chain is aborted
+	db.Model(&f.listing).Update("settlement_tx", "0xreverted")
+	if err := abortSettlement(&f.listing); err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+	f.check(t, db, 0, buyerFunds, "active", "open")
+
+	var listing models.Listing
+	db.First(&listing, f.listing.ID)
+	if listing.BuyerID != nil || listing.SettlementTx != "" || listing.SalePrice != 0 {
+		t.Errorf("reopened listing = %+v", listing)
+	}
+	if err := abortSettlement(&f.listing); err != errListingNotOpen {
+		t.Errorf("aborting an open listing: %v, want errListingNotOpen", err)
+	}
+}
+
+func TestReconcileSettlements(t *testing.T) {
+	tests := []struct {
+		name          string
+		settlementTx  string
+		after         time.Duration
+		wantAborted   int
+		buyerBalance  float64
+		assetStatus   string
+		listingStatus string
+	}{
+		{"stale without hash", "", time.Hour, 1, buyerFunds, "active", "open"},
+		{"recent without hash", "", time.Minute, 0, buyerFunds - listingPrice, "settling", "settling"},
+		{"awaiting receipt", "0xpending", time.Hour, 0, buyerFunds - listingPrice, "settling", "settling"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := useTestDB(t)
+			f := newMarketFixture(t, db)
+			f.settle(t, db)
+			if tt.settlementTx != "" {
+				db.Model(&f.listing).Update("settlement_tx", tt.settlementTx)
+			}
+
+			finished, aborted, err := ReconcileSettlements(time.Now().Add(tt.after))
+			if err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+			if finished != 0 || aborted != tt.wantAborted {
+				t.Errorf("finished %d aborted %d, want 0 and %d", finished, aborted, tt.wantAborted)
+			}
+			f.check(t, db, 0, tt.buyerBalance, tt.assetStatus, tt.listingStatus)
+		})
+	}
+}
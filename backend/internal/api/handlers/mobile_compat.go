@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
+	"math/big"
 	"net/http"
 	"time"
 
+	"conflux-demo/backend/internal/blockchain"
 	"conflux-demo/backend/internal/database"
 	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/kyc"
 	"conflux-demo/backend/internal/mongodb"
 	mongoModels "conflux-demo/backend/internal/mongodb/models"
 
@@ -58,8 +63,8 @@ func GetMobileNews(c *gin.Context) {
 }
 
 func GetMobileMarket(c *gin.Context) {
-	var marketData []models.MarketData
-	if err := database.GetDB().Order("timestamp DESC").Find(&marketData).Error; err != nil {
+	marketData, err := latestMarketData()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
 		return
 	}
@@ -94,9 +99,9 @@ func GetMobileProducts(c *gin.Context) {
 		mobileData = append(mobileData, gin.H{
 			"id":           item.ID,
 			"name":         item.Name,
-			"yield_rate":   item.YieldRate,
-			"price":        item.Price,
-			"duration":     item.Duration,
+			"yield_rate":   yieldText(&item),
+			"price":        priceText(&item),
+			"duration":     durationText(&item),
 			"risk":         item.RiskLevel, // Mobile expects "risk", Go has "RiskLevel"
 			"icon":         item.Icon,
 			"product_type": item.ProductType,
@@ -164,19 +169,23 @@ func MobileTopUp(c *gin.Context) {
 		// Create new user
 		user = models.User{
 			WalletAddress: input.Address,
-			Balance:       input.RMB,
+			Balance:       0,
 		}
 		if err := database.GetDB().Create(&user).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
 		}
-	} else {
-		// Update existing user balance
-		user.Balance += input.RMB
-		if err := database.GetDB().Save(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update balance"})
-			return
-		}
+	}
+
+	if !checkKYCLimit(c, &user, kyc.LimitDeposit, input.RMB) {
+		return
+	}
+
+	// Update user balance
+	user.Balance += input.RMB
+	if err := database.GetDB().Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update balance"})
+		return
 	}
 
 	// Create transaction record
@@ -224,7 +233,116 @@ func MobileMintNFT(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true, "txHash": "0xmockhash..."})
 }
 
+// MobileTransferNFT relays safeTransferFrom for a FarmBatchNFT token owned
+// by the authenticated user's wallet, after checking on-chain ownership. The
+// off-chain asset and custody records move only once the receipt confirms
+// success; a transfer still unconfirmed when the handler stops waiting is
+// finished by ReconcileTransfers.
 func MobileTransferNFT(c *gin.Context) {
-	// POST { from, to, tokenId, nftAddress }
-	c.JSON(http.StatusOK, gin.H{"ok": true, "txHash": "0xmockhash..."})
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		From       string      `json:"from" binding:"required"`
+		To         string      `json:"to" binding:"required"`
+		TokenID    json.Number `json:"tokenId" binding:"required"`
+		NFTAddress string      `json:"nftAddress" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokenID, ok := new(big.Int).SetString(input.TokenID.String(), 10)
+	if !ok || tokenID.Sign() < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	client := blockchain.GetClient()
+	fromAddr, err := client.ParseAddress(input.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from address"})
+		return
+	}
+	toAddr, err := client.ParseAddress(input.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to address"})
+		return
+	}
+	if fromAddr.Equals(&toAddr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer to the current owner"})
+		return
+	}
+	// The relayer is approved by every owner, so it must only move the
+	// caller's own tokens
+	userAddr, err := client.ParseAddress(user.WalletAddress)
+	if err != nil || !userAddr.Equals(&fromAddr) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "from must be your wallet address"})
+		return
+	}
+
+	isOwner, err := client.IsOwner(input.NFTAddress, tokenID, input.From)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to query token owner"})
+		return
+	}
+	if !isOwner {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sender does not own this token"})
+		return
+	}
+
+	transfer, err := prepareTransfer(user, input.NFTAddress, tokenID.String(), fromAddr, toAddr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare transfer"})
+		return
+	}
+
+	txHash, err := client.SafeTransferFrom(input.NFTAddress, input.From, input.To, tokenID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to submit transfer"})
+		return
+	}
+
+	// Record the transfer first so ReconcileTransfers can finish it if the
+	// receipt does not arrive in time
+	transfer.TxHash = txHash
+	if err := database.GetDB().Create(transfer).Error; err != nil {
+		log.Printf("Failed to record transfer %s: %v", txHash, err)
+	}
+
+	receipt, err := client.WaitForReceipt(txHash)
+	if err != nil {
+		c.JSON(http.StatusAccepted, gin.H{
+			"ok":      true,
+			"txHash":  txHash,
+			"status":  "pending",
+			"warning": "Transfer submitted but not yet confirmed",
+		})
+		return
+	}
+	if !blockchain.ReceiptSucceeded(receipt) {
+		if err := failTransfer(transfer); err != nil {
+			log.Printf("Failed to mark transfer %s failed: %v", txHash, err)
+		}
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":  "Transfer reverted on chain",
+			"txHash": txHash,
+		})
+		return
+	}
+
+	if err := finishTransfer(transfer, time.Now()); err != nil {
+		log.Printf("Failed to finish transfer %s: %v", txHash, err)
+		c.JSON(http.StatusOK, gin.H{
+			"ok":      true,
+			"txHash":  txHash,
+			"warning": "Transfer confirmed but custody records may not be updated",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "txHash": txHash, "status": "success"})
 }
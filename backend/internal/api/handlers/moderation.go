@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"conflux-demo/backend/internal/community"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/moderation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// moderateContent runs text through the blocklist. Blocked text gets a 422
+// and false; otherwise it returns the status the content starts in.
+func moderateContent(c *gin.Context, text string) (string, *community.Moderation, bool) {
+	verdict := moderation.Check(text)
+	if verdict.Action == moderation.ActionBlock {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Content violates the community guidelines"})
+		return "", nil, false
+	}
+	if verdict.Action == moderation.ActionReview {
+		return moderation.StatusPendingReview, &community.Moderation{FlaggedTerms: verdict.Terms}, true
+	}
+	return moderation.StatusVisible, nil, true
+}
+
+// checkRateLimit writes a 429 with Retry-After and returns false when allow
+// refuses the wallet
+func checkRateLimit(c *gin.Context, allow func(uint) (bool, time.Duration), userID uint) bool {
+	ok, wait := allow(userID)
+	if ok {
+		return true
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Posting too often, try again later",
+		"retry_after": seconds,
+	})
+	return false
+}
+
+// ReportPost records the authenticated user's report of a post
+func ReportPost(c *gin.Context) {
+	createReport(c, community.KindPost)
+}
+
+// ReportComment records the authenticated user's report of a comment
+func ReportComment(c *gin.Context) {
+	createReport(c, community.KindComment)
+}
+
+// liveContent loads a post or comment that has not been deleted
+func liveContent(ctx context.Context, kind, id string) (*community.Post, *community.Comment, error) {
+	repo := community.Default()
+	if kind == community.KindPost {
+		post, err := repo.GetPost(ctx, id)
+		if err == nil && post.DeletedAt != nil {
+			err = community.ErrNotFound
+		}
+		return post, nil, err
+	}
+	comment, err := repo.GetComment(ctx, id)
+	if err == nil && comment.DeletedAt != nil {
+		err = community.ErrNotFound
+	}
+	return nil, comment, err
+}
+
+// createReport stores one report per user and target. Once a visible target
+// has ModerationReportThreshold open reports it is held for review.
+func createReport(c *gin.Context, targetType string) {
+	targetID := c.Param("id")
+	if !community.ValidID(targetID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + targetType + " ID"})
+		return
+	}
+
+	var input struct {
+		WalletAddress string `json:"wallet_address"`
+		Reason        string `json:"reason" binding:"required"`
+		Note          string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !moderation.ValidReason(input.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be one of " + strings.Join(moderation.ReportReasons, ", ")})
+		return
+	}
+	if len(input.Note) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note must be at most 500 characters"})
+		return
+	}
+
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	if input.WalletAddress != "" && !strings.EqualFold(input.WalletAddress, user.WalletAddress) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "wallet_address does not match the authenticated user"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, _, err := liveContent(ctx, targetType, targetID); err != nil {
+		if errors.Is(err, community.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + targetType})
+		return
+	}
+
+	report := community.Report{
+		TargetType:     targetType,
+		TargetID:       targetID,
+		ReporterWallet: user.WalletAddress,
+		Reason:         input.Reason,
+		Note:           input.Note,
+	}
+	_, err := community.Default().CreateReport(ctx, &report, moderation.ReportThreshold())
+	if errors.Is(err, community.ErrAlreadyReported) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this " + targetType})
+		return
+	}
+	if errors.Is(err, community.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save report on %s %s: %v", targetType, targetID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "data": report})
+}
+
+// AdminModerationQueue lists posts and comments held for review, oldest
+// first, with their open reports. type narrows it to post or comment.
+func AdminModerationQueue(c *gin.Context) {
+	types := []string{community.KindPost, community.KindComment}
+	if t := c.Query("type"); t != "" {
+		if t != community.KindPost && t != community.KindComment {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be post or comment"})
+			return
+		}
+		types = []string{t}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	items := []gin.H{}
+	for _, t := range types {
+		var held []gin.H
+		if t == community.KindPost {
+			posts, err := community.Default().HeldPosts(ctx, 100)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review queue"})
+				return
+			}
+			for _, p := range posts {
+				held = append(held, queueItem(t, p.ID, p.Content, p.UserID, p.WalletAddress, p.Moderation, p.CreatedAt))
+			}
+		} else {
+			comments, err := community.Default().HeldComments(ctx, 100)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review queue"})
+				return
+			}
+			for _, cm := range comments {
+				held = append(held, queueItem(t, cm.ID, cm.Content, cm.UserID, cm.WalletAddress, cm.Moderation, cm.CreatedAt))
+			}
+		}
+
+		for _, item := range held {
+			reports, err := community.Default().OpenReports(ctx, item["id"].(string))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+				return
+			}
+			item["reports"] = reports
+			items = append(items, item)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": items})
+}
+
+func queueItem(kind, id, content string, authorID uint, wallet string, m *community.Moderation, createdAt time.Time) gin.H {
+	return gin.H{
+		"type":       kind,
+		"id":         id,
+		"content":    content,
+		"author":     wallet,
+		"author_id":  authorID,
+		"moderation": m,
+		"created_at": createdAt,
+	}
+}
+
+// AdminListReports returns reports by status (open by default), oldest
+// first
+func AdminListReports(c *gin.Context) {
+	status := c.DefaultQuery("status", community.ReportOpen)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reports, err := community.Default().ListReports(ctx, status, 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": reports})
+}
+
+// AdminModeratePost sets a post visible or hidden and resolves its reports
+func AdminModeratePost(c *gin.Context) {
+	moderateTarget(c, "post")
+}
+
+// AdminModerateComment sets a comment visible or hidden and resolves its
+// reports
+func AdminModerateComment(c *gin.Context) {
+	moderateTarget(c, "comment")
+}
+
+func moderateTarget(c *gin.Context, targetType string) {
+	targetID := c.Param("id")
+	if !community.ValidID(targetID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + targetType + " ID"})
+		return
+	}
+
+	var input struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != moderation.StatusVisible && input.Status != moderation.StatusHidden {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be visible or hidden"})
+		return
+	}
+	reviewer := "admin:" + c.MustGet(gin.AuthUserKey).(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	review := community.Review{
+		Status: input.Status,
+		By:     reviewer,
+		Note:   input.Note,
+		At:     time.Now(),
+	}
+	previous, err := community.Default().Review(ctx, targetType, targetID, review)
+	if errors.Is(err, community.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update " + targetType})
+		return
+	}
+
+	// Mentions in held content are announced once it is approved
+	if previous == moderation.StatusPendingReview && input.Status == moderation.StatusVisible {
+		post, comment, err := liveContent(ctx, targetType, targetID)
+		switch {
+		case err != nil:
+		case post != nil:
+			notifyMentions(post.Mentions, nil, post.UserID, post.Username, post.Content, mentionData(post.ID, ""))
+		default:
+			notifyMentions(comment.Mentions, nil, comment.UserID, comment.Username, comment.Content, mentionData(comment.PostID, comment.ID))
+		}
+	}
+
+	resolved, err := community.Default().ResolveReports(ctx, targetID, review)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":               true,
+		"status":           input.Status,
+		"resolved_reports": resolved,
+	})
+}
+
+// AdminListBlockedTerms returns the blocklist
+func AdminListBlockedTerms(c *gin.Context) {
+	var terms []models.BlockedTerm
+	if err := database.GetDB().Order("id ASC").Find(&terms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked terms"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": terms})
+}
+
+// AdminCreateBlockedTerm adds a keyword or regular expression to the
+// blocklist, which applies to new posts, comments and edits immediately
+func AdminCreateBlockedTerm(c *gin.Context) {
+	var input struct {
+		Kind    string `json:"kind" binding:"required"`
+		Pattern string `json:"pattern" binding:"required"`
+		Action  string `json:"action"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Action == "" {
+		input.Action = moderation.ActionBlock
+	}
+
+	term := models.BlockedTerm{
+		Kind:      input.Kind,
+		Pattern:   input.Pattern,
+		Action:    input.Action,
+		Note:      input.Note,
+		CreatedBy: "admin:" + c.MustGet(gin.AuthUserKey).(string),
+	}
+	if _, err := moderation.NewFilter([]models.BlockedTerm{term}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if err := db.Create(&term).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save blocked term"})
+		return
+	}
+	if err := moderation.Reload(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Term saved but blocklist not reloaded: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "data": term})
+}
+
+// AdminDeleteBlockedTerm removes a blocklist term
+func AdminDeleteBlockedTerm(c *gin.Context) {
+	db := database.GetDB()
+	result := db.Delete(&models.BlockedTerm{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete blocked term"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blocked term not found"})
+		return
+	}
+	if err := moderation.Reload(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Term deleted but blocklist not reloaded: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"conflux-demo/backend/internal/blockchain"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/yield"
+
+	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetNFTCustody returns every holder of a token in acquisition order
+func GetNFTCustody(c *gin.Context) {
+	nftAddress := c.Param("nftAddress")
+	tokenID := c.Param("tokenId")
+
+	var history []models.NFTCustody
+	if err := database.GetDB().
+		Where("nft_address = ? AND token_id = ?", nftAddress, tokenID).
+		Order("acquired_at ASC, id ASC").
+		Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch custody history"})
+		return
+	}
+
+	var holders []gin.H
+	for _, h := range history {
+		holders = append(holders, gin.H{
+			"holder":      h.Holder,
+			"tx_hash":     h.TxHash,
+			"acquired_at": h.AcquiredAt,
+			"released_at": h.ReleasedAt,
+			"current":     h.ReleasedAt == nil,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":          true,
+		"nft_address": nftAddress,
+		"token_id":    tokenID,
+		"data":        holders,
+	})
+}
+
+// walletOf returns the wallet address of the user registered with any
+// spelling of addr, or its base32 form when nobody is
+func walletOf(tx *gorm.DB, addr cfxaddress.Address) (string, error) {
+	var users []models.User
+	if err := tx.Select("wallet_address").
+		Where("LOWER(wallet_address) IN ?", blockchain.AddressForms(addr)).
+		Limit(1).Find(&users).Error; err != nil {
+		return "", err
+	}
+	if len(users) > 0 {
+		return users[0].WalletAddress, nil
+	}
+	return addr.MustGetBase32Address(), nil
+}
+
+// openCustody records holder as the current custodian of a token if no
+// custody period is open yet
+func openCustody(tx *gorm.DB, nftAddress, tokenID, holder, txHash string, at time.Time) error {
+	var count int64
+	if err := tx.Model(&models.NFTCustody{}).
+		Where("nft_address = ? AND token_id = ? AND released_at IS NULL", nftAddress, tokenID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return tx.Create(&models.NFTCustody{
+		NFTAddress: nftAddress,
+		TokenID:    tokenID,
+		Holder:     holder,
+		TxHash:     txHash,
+		AcquiredAt: at,
+	}).Error
+}
+
+// moveCustody closes the previous holder's custody period and opens one for
+// the new holder. A missing previous record is back-filled from fromSince so
+// the history never starts mid-chain.
+func moveCustody(tx *gorm.DB, nftAddress, tokenID, from, to, txHash string, fromSince, at time.Time) error {
+	if err := openCustody(tx, nftAddress, tokenID, from, "", fromSince); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.NFTCustody{}).
+		Where("nft_address = ? AND token_id = ? AND released_at IS NULL", nftAddress, tokenID).
+		Update("released_at", at).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.NFTCustody{
+		NFTAddress: nftAddress,
+		TokenID:    tokenID,
+		Holder:     to,
+		TxHash:     txHash,
+		AcquiredAt: at,
+	}).Error
+}
+
+// A relayed transfer moves through these steps:
+//
+//  1. prepareTransfer finds the sender's asset and the recipient's wallet
+//  2. the transfer is recorded "pending" once it has a transaction hash
+//  3. finishTransfer moves the asset and custody when the receipt succeeds,
+//     or failTransfer closes it when the transaction reverted
+//
+// Transfers whose receipt the request did not wait for are resolved by
+// ReconcileTransfers.
+
+// errTransferResolved is returned when finishing a transfer that is no
+// longer pending
+var errTransferResolved = errors.New("transfer is not pending")
+
+// prepareTransfer builds the pending record for user's transfer of a token
+// from one wallet to another. Wallet columns hold whichever spelling the
+// owner registered with, so both ends are matched on every form.
+func prepareTransfer(user *models.User, nftAddress, tokenID string, from, to cfxaddress.Address) (*models.NFTTransfer, error) {
+	db := database.GetDB()
+
+	toWallet, err := walletOf(db, to)
+	if err != nil {
+		return nil, err
+	}
+	transfer := &models.NFTTransfer{
+		UserID:     user.ID,
+		NFTAddress: nftAddress,
+		TokenID:    tokenID,
+		FromWallet: user.WalletAddress,
+		ToWallet:   toWallet,
+		Status:     "pending",
+	}
+
+	var assets []models.UserAsset
+	if err := db.Where("nft_address = ? AND token_id = ? AND LOWER(wallet_address) IN ? AND status = ?",
+		nftAddress, tokenID, blockchain.AddressForms(from), "active").
+		Limit(1).Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	if len(assets) > 0 {
+		transfer.AssetID = &assets[0].ID
+	}
+	return transfer, nil
+}
+
+// finishTransfer moves a confirmed transfer's asset and custody to the
+// recipient and marks it confirmed. The sender keeps the yield of the days
+// they held the asset; the recipient continues its term.
+func finishTransfer(transfer *models.NFTTransfer, now time.Time) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(transfer, transfer.ID).Error; err != nil {
+			return err
+		}
+		if transfer.Status != "pending" {
+			return errTransferResolved
+		}
+
+		fromSince := now
+		if transfer.AssetID != nil {
+			var asset models.UserAsset
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Product").
+				Where("id = ? AND status = ?", *transfer.AssetID, "active").First(&asset).Error
+			if err == nil {
+				fromSince = asset.PurchaseDate
+				// Pay out under the sender's registered spelling so the
+				// yield reaches their account even if the asset was
+				// recorded with another one
+				asset.WalletAddress = transfer.FromWallet
+				if _, err := yield.SettleTransfer(tx, &asset, now); err != nil {
+					return err
+				}
+				if err := tx.Model(&asset).Update("wallet_address", transfer.ToWallet).Error; err != nil {
+					return err
+				}
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		if err := moveCustody(tx, transfer.NFTAddress, transfer.TokenID, transfer.FromWallet, transfer.ToWallet, transfer.TxHash, fromSince, now); err != nil {
+			return err
+		}
+		return tx.Model(transfer).Update("status", "confirmed").Error
+	})
+}
+
+// failTransfer marks a pending transfer that reverted on chain as failed
+func failTransfer(transfer *models.NFTTransfer) error {
+	return database.GetDB().Model(&models.NFTTransfer{}).
+		Where("id = ? AND status = ?", transfer.ID, "pending").
+		Update("status", "failed").Error
+}
+
+// ReconcileTransfers resolves transfers left pending when the request
+// stopped waiting for their receipt. Transfers confirmed on chain are
+// finished and reverted ones failed; unmined ones are left for later.
+func ReconcileTransfers(now time.Time) (confirmed, failed int, err error) {
+	var transfers []models.NFTTransfer
+	if err := database.GetDB().Where("status = ?", "pending").Find(&transfers).Error; err != nil {
+		return 0, 0, err
+	}
+
+	client := blockchain.GetClient()
+	if client == nil {
+		return 0, 0, nil
+	}
+	for i := range transfers {
+		transfer := &transfers[i]
+
+		receipt, err := client.GetTransactionReceipt(transfer.TxHash)
+		if err != nil || receipt == nil {
+			// Not mined yet or the node is unreachable; try again later
+			continue
+		}
+		if !blockchain.ReceiptSucceeded(receipt) {
+			if err := failTransfer(transfer); err != nil {
+				log.Printf("Failed to mark transfer %s failed: %v", transfer.TxHash, err)
+				continue
+			}
+			failed++
+			continue
+		}
+		if err := finishTransfer(transfer, now); err != nil {
+			log.Printf("Failed to finish transfer %s: %v", transfer.TxHash, err)
+			continue
+		}
+		confirmed++
+	}
+	return confirmed, failed, nil
+}
+
+// StartTransferReconciler runs ReconcileTransfers every interval until ctx
+// is cancelled
+func StartTransferReconciler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				confirmed, failed, err := ReconcileTransfers(now)
+				if err != nil {
+					log.Printf("Failed to reconcile NFT transfers: %v", err)
+				} else if confirmed+failed > 0 {
+					log.Printf("Reconciled NFT transfers: %d confirmed, %d failed", confirmed, failed)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
+)
+
+func TestFinishTransferMovesAssetAndCustody(t *testing.T) {
+	const (
+		nft   = "cfx:nft"
+		token = "7"
+	)
+	from := cfxaddress.MustNewFromHex("0x1000000000000000000000000000000000000001", 1029)
+	to := cfxaddress.MustNewFromHex("0x1000000000000000000000000000000000000002", 1029)
+	now := time.Date(2026, 3, 11, 12, 0, 0, 0, time.Local)
+	purchased := now.AddDate(0, 0, -10)
+
+	tests := []struct {
+		name      string
+		withAsset bool
+		status    string
+		wantErr   error
+		wantOwner string
+		wantPaid  float64
+		holders   []string
+	}{
+		{"asset moves with settled yield", true, "pending", nil, "recipient", 10, []string{"sender", "recipient"}},
+		{"token without asset", false, "pending", nil, "", 0, []string{"sender", "recipient"}},
+		{"already failed", true, "failed", errTransferResolved, "sender", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := useTestDB(t)
+
+			// The sender registered with the base32 spelling but the asset
+			// was recorded with the hex one
+			sender := models.User{Email: "sender@example.com", WalletAddress: from.MustGetBase32Address()}
+			recipient := models.User{Email: "recipient@example.com", WalletAddress: to.MustGetBase32Address()}
+			db.Create(&sender)
+			db.Create(&recipient)
+			wallets := map[string]string{"sender": sender.WalletAddress, "recipient": recipient.WalletAddress}
+
+			product := models.Product{Name: "Rice", Price: 1000, AnnualYield: 36.5}
+			db.Create(&product)
+			if tt.withAsset {
+				db.Create(&models.UserAsset{
+					WalletAddress:    strings.ToLower(from.GetHexAddress()),
+					ProductID:        product.ID,
+					TokenID:          token,
+					NFTAddress:       nft,
+					InvestmentAmount: 1000,
+					PurchaseDate:     purchased,
+					Status:           "active",
+				})
+			}
+
+			transfer, err := prepareTransfer(&sender, nft, token, from, to)
+			if err != nil {
+				t.Fatalf("prepare: %v", err)
+			}
+			if (transfer.AssetID != nil) != tt.withAsset {
+				t.Fatalf("asset ID = %v, want found %v", transfer.AssetID, tt.withAsset)
+			}
+			if transfer.ToWallet != recipient.WalletAddress {
+				t.Errorf("to wallet = %q, want %q", transfer.ToWallet, recipient.WalletAddress)
+			}
+			transfer.TxHash = "0xtransfer"
+			transfer.Status = tt.status
+			db.Create(transfer)
+
+			if err := finishTransfer(transfer, now); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("finish: %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.NFTTransfer
+			db.First(&stored, transfer.ID)
+			wantStatus := "confirmed"
+			if tt.wantErr != nil {
+				wantStatus = tt.status
+			}
+			if stored.Status != wantStatus {
+				t.Errorf("transfer status = %q, want %q", stored.Status, wantStatus)
+			}
+
+			if tt.withAsset {
+				var asset models.UserAsset
+				db.First(&asset)
+				owner := wallets[tt.wantOwner]
+				if tt.wantOwner == "sender" {
+					owner = strings.ToLower(from.GetHexAddress())
+				}
+				if asset.WalletAddress != owner || asset.Status != "active" {
+					t.Errorf("asset owner %q status %q, want %q active", asset.WalletAddress, asset.Status, owner)
+				}
+				if asset.PaidYield != tt.wantPaid {
+					t.Errorf("paid yield = %v, want %v", asset.PaidYield, tt.wantPaid)
+				}
+			}
+
+			var paidSender models.User
+			db.First(&paidSender, sender.ID)
+			if paidSender.Balance != tt.wantPaid {
+				t.Errorf("sender balance = %v, want %v", paidSender.Balance, tt.wantPaid)
+			}
+
+			rec := serve(t, GetNFTCustody, http.MethodGet, "/nft/:nftAddress/:tokenId/custody", "/nft/"+nft+"/"+token+"/custody", 0, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("custody: %d %s", rec.Code, rec.Body)
+			}
+			var resp struct {
+				Data []struct {
+					Holder  string `json:"holder"`
+					TxHash  string `json:"tx_hash"`
+					Current bool   `json:"current"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode custody: %v", err)
+			}
+			if len(resp.Data) != len(tt.holders) {
+				t.Fatalf("custody = %+v, want holders %v", resp.Data, tt.holders)
+			}
+			for i, name := range tt.holders {
+				h := resp.Data[i]
+				last := i == len(tt.holders)-1
+				if h.Holder != wallets[name] || h.Current != last {
+					t.Errorf("holder %d = %+v, want %s current=%v", i, h, wallets[name], last)
+				}
+				if last && h.TxHash != "0xtransfer" {
+					t.Errorf("current holder tx = %q, want 0xtransfer", h.TxHash)
+				}
+			}
+		})
+	}
+}
+
+func TestFailTransferLeavesAssetWithSender(t *testing.T) {
+	db := useTestDB(t)
+
+	asset := models.UserAsset{WalletAddress: "cfx:sender", TokenID: "1", NFTAddress: "cfx:nft", Status: "active"}
+	db.Create(&asset)
+	transfer := models.NFTTransfer{AssetID: &asset.ID, NFTAddress: "cfx:nft", TokenID: "1",
+		FromWallet: "cfx:sender", ToWallet: "cfx:recipient", TxHash: "0xreverted", Status: "pending"}
+	db.Create(&transfer)
+
+	if err := failTransfer(&transfer); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	if err := finishTransfer(&transfer, time.Now()); !errors.Is(err, errTransferResolved) {
+		t.Fatalf("finish after failing: %v, want errTransferResolved", err)
+	}
+
+	db.First(&asset, asset.ID)
+	if asset.WalletAddress != "cfx:sender" {
+		t.Errorf("asset moved to %q", asset.WalletAddress)
+	}
+	var custody int64
+	db.Model(&models.NFTCustody{}).Count(&custody)
+	if custody != 0 {
+		t.Errorf("%d custody records after a failed transfer, want 0", custody)
+	}
+}
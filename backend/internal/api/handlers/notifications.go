@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"conflux-demo/backend/internal/mongodb"
+	mongoModels "conflux-demo/backend/internal/mongodb/models"
+	"conflux-demo/backend/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetNotifications returns the current user's notifications, newest first,
+// with unread counts per type. Filters: type, unread=true. Pages continue
+// with before set to the last ID returned.
+func GetNotifications(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := bson.M{"user_id": user.ID}
+	if t := c.Query("type"); t != "" {
+		filter["type"] = t
+	}
+	if c.Query("unread") == "true" {
+		filter["read_at"] = nil
+	}
+	if before := c.Query("before"); before != "" {
+		id, err := primitive.ObjectIDFromHex(before)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before cursor"})
+			return
+		}
+		filter["_id"] = bson.M{"$lt": id}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := mongodb.GetCollection(notify.InboxCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []mongoModels.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode notifications"})
+		return
+	}
+
+	unread, byType, err := unreadCounts(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	var next string
+	if len(notifications) == limit {
+		next = notifications[len(notifications)-1].ID.Hex()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":             true,
+		"data":           notifications,
+		"unread":         unread,
+		"unread_by_type": byType,
+		"next_before":    next,
+	})
+}
+
+// unreadCounts returns the user's total and per-type unread notifications
+func unreadCounts(ctx context.Context, userID uint) (int64, map[string]int64, error) {
+	collection := mongodb.GetCollection(notify.InboxCollection)
+	cursor, err := collection.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"user_id": userID, "read_at": nil}},
+		{"$group": bson.M{"_id": "$type", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		Type  string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return 0, nil, err
+	}
+
+	total := int64(0)
+	byType := map[string]int64{}
+	for _, g := range groups {
+		byType[g.Type] = g.Count
+		total += g.Count
+	}
+	return total, byType, nil
+}
+
+// MarkNotificationRead marks one of the current user's notifications read
+func MarkNotificationRead(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := mongodb.GetCollection(notify.InboxCollection)
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": user.ID, "read_at": nil},
+		bson.M{"$set": bson.M{"read_at": time.Now()}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "updated": result.ModifiedCount})
+}
+
+// MarkAllNotificationsRead marks every unread notification read, or only
+// those of the type query parameter
+func MarkAllNotificationsRead(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"user_id": user.ID, "read_at": nil}
+	if t := c.Query("type"); t != "" {
+		filter["type"] = t
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := mongodb.GetCollection(notify.InboxCollection)
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read_at": time.Now()}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "updated": result.ModifiedCount})
+}
+
+// GetNotificationPreferences returns the delivery channels of every type
+func GetNotificationPreferences(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefs := &notify.MongoPreferences{Collection: mongodb.GetCollection(notify.PreferencesCollection)}
+	settings, err := prefs.Get(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": settings})
+}
+
+// UpdateNotificationPreferences sets the channels of the types in the body,
+// e.g. {"comment": {"inbox": true, "push": false, "webhook": false}}
+func UpdateNotificationPreferences(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input map[string]mongoModels.ChannelSettings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for t := range input {
+		if !notify.ValidType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type " + t})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefs := &notify.MongoPreferences{Collection: mongodb.GetCollection(notify.PreferencesCollection)}
+	if err := prefs.Set(ctx, user.ID, input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences"})
+		return
+	}
+	settings, err := prefs.Get(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": settings})
+}
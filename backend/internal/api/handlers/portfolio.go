@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPortfolioDays = 30
+	maxPortfolioDays     = 365
+)
+
+// pricePoint is one commodity quote
+type pricePoint struct {
+	at    time.Time
+	price float64
+}
+
+// priceSeries holds the quotes of one commodity in time order
+type priceSeries []pricePoint
+
+// at returns the latest price at or before t, falling back to the earliest
+// quote when t predates the series
+func (s priceSeries) at(t time.Time) (float64, bool) {
+	if len(s) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(s), func(i int) bool { return s[i].at.After(t) })
+	if i == 0 {
+		return s[0].price, true
+	}
+	return s[i-1].price, true
+}
+
+// loadPriceSeries loads quotes for a commodity from the given time onwards,
+// plus the last quote before it so prices at from are known
+func loadPriceSeries(db *gorm.DB, commodity string, from time.Time) (priceSeries, error) {
+	var rows []models.MarketData
+	if err := db.Where("product_name = ? AND timestamp >= ?", commodity, from).
+		Order("timestamp ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var before []models.MarketData
+	if err := db.Where("product_name = ? AND timestamp < ?", commodity, from).
+		Order("timestamp DESC").Limit(1).Find(&before).Error; err != nil {
+		return nil, err
+	}
+
+	series := make(priceSeries, 0, len(rows)+1)
+	for _, r := range append(before, rows...) {
+		series = append(series, pricePoint{at: r.Timestamp, price: r.Price})
+	}
+	return series, nil
+}
+
+// assetValue marks an asset's principal to market. Assets whose product has
+// no commodity, or no quotes, are valued at cost.
+func assetValue(asset *models.UserAsset, series priceSeries, at time.Time) (value, refPrice, price float64) {
+	refPrice, ok := series.at(asset.PurchaseDate)
+	if !ok || refPrice <= 0 {
+		return asset.InvestmentAmount, 0, 0
+	}
+	price, _ = series.at(at)
+	return asset.InvestmentAmount * price / refPrice, refPrice, price
+}
+
+// GetPortfolio values the current user's holdings: cost, accrued yield,
+// market value, unrealized P&L, allocation and daily value history
+func GetPortfolio(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	days := defaultPortfolioDays
+	if d := c.Query("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > maxPortfolioDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+			return
+		}
+		days = n
+	}
+
+	db := database.GetDB()
+	var assets []models.UserAsset
+	if err := db.Preload("Product").
+		Where("wallet_address = ? AND status IN ?", user.WalletAddress, []string{"active", "matured"}).
+		Order("purchase_date ASC").
+		Find(&assets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets"})
+		return
+	}
+
+	now := time.Now()
+	y, m, d := now.Date()
+	historyStart := time.Date(y, m, d, 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(days - 1))
+
+	// Load quotes once per commodity, from the earliest date any valuation needs
+	seriesFrom := historyStart
+	for _, a := range assets {
+		if a.PurchaseDate.Before(seriesFrom) {
+			seriesFrom = a.PurchaseDate
+		}
+	}
+	series := map[string]priceSeries{}
+	for _, a := range assets {
+		commodity := a.Product.Commodity
+		if commodity == "" {
+			continue
+		}
+		if _, loaded := series[commodity]; loaded {
+			continue
+		}
+		s, err := loadPriceSeries(db, commodity, seriesFrom)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
+			return
+		}
+		series[commodity] = s
+	}
+
+	var totalInvested, totalAccrued, totalPaid, totalValue float64
+	byCategory := map[string]float64{}
+	byRisk := map[string]float64{}
+	positions := []gin.H{}
+	for i := range assets {
+		a := &assets[i]
+		value, refPrice, price := assetValue(a, series[a.Product.Commodity], now)
+		unpaid := a.AccruedYield - a.PaidYield
+
+		positions = append(positions, gin.H{
+			"asset_id":          a.ID,
+			"product_id":        a.ProductID,
+			"product_name":      a.Product.Name,
+			"category":          a.Product.Category,
+			"risk_level":        a.Product.RiskLevel,
+			"commodity":         a.Product.Commodity,
+			"status":            a.Status,
+			"investment_amount": roundFen(a.InvestmentAmount),
+			"reference_price":   refPrice,
+			"current_price":     price,
+			"current_value":     roundFen(value),
+			"accrued_yield":     roundFen(a.AccruedYield),
+			"unrealized_pnl":    roundFen(value - a.InvestmentAmount + unpaid),
+		})
+
+		totalInvested += a.InvestmentAmount
+		totalAccrued += a.AccruedYield
+		totalPaid += a.PaidYield
+		totalValue += value
+		byCategory[a.Product.Category] += value
+		byRisk[a.Product.RiskLevel] += value
+	}
+
+	unrealized := totalValue - totalInvested + totalAccrued - totalPaid
+	var unrealizedPercent float64
+	if totalInvested > 0 {
+		unrealizedPercent = unrealized / totalInvested * 100
+	}
+
+	history, err := portfolioHistory(db, assets, series, historyStart, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build portfolio history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok": true,
+		"data": gin.H{
+			"total_invested":         roundFen(totalInvested),
+			"accrued_yield":          roundFen(totalAccrued),
+			"paid_yield":             roundFen(totalPaid),
+			"current_value":          roundFen(totalValue),
+			"unrealized_pnl":         roundFen(unrealized),
+			"unrealized_pnl_percent": roundFen(unrealizedPercent),
+			"allocation": gin.H{
+				"by_category":   allocation(byCategory, totalValue),
+				"by_risk_level": allocation(byRisk, totalValue),
+			},
+			"positions": positions,
+			"history":   history,
+		},
+	})
+}
+
+// allocation turns per-bucket values into a list sorted by value, with the
+// share of the total
+func allocation(buckets map[string]float64, total float64) []gin.H {
+	names := make([]string, 0, len(buckets))
+	for name := range buckets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return buckets[names[i]] > buckets[names[j]] })
+
+	result := []gin.H{}
+	for _, name := range names {
+		var percent float64
+		if total > 0 {
+			percent = buckets[name] / total * 100
+		}
+		label := name
+		if label == "" {
+			label = "other"
+		}
+		result = append(result, gin.H{
+			"name":    label,
+			"value":   roundFen(buckets[name]),
+			"percent": roundFen(percent),
+		})
+	}
+	return result
+}
+
+// portfolioHistory values the current holdings at the end of each day.
+// value is the market value of the principal, like current_value; the
+// yield accrued up to that day is reported separately.
+func portfolioHistory(db *gorm.DB, assets []models.UserAsset, series map[string]priceSeries, start time.Time, days int) ([]gin.H, error) {
+	history := []gin.H{}
+	if len(assets) == 0 {
+		return history, nil
+	}
+
+	ids := make([]uint, len(assets))
+	for i, a := range assets {
+		ids[i] = a.ID
+	}
+
+	// Yield earned before the window, then per day inside it
+	var earned float64
+	if err := db.Model(&models.YieldAccrual{}).
+		Where("asset_id IN ? AND accrual_date < ?", ids, start).
+		Select("COALESCE(SUM(amount), 0)").Scan(&earned).Error; err != nil {
+		return nil, err
+	}
+
+	var accruals []models.YieldAccrual
+	if err := db.Where("asset_id IN ? AND accrual_date >= ?", ids, start).
+		Find(&accruals).Error; err != nil {
+		return nil, err
+	}
+	dailyYield := map[string]float64{}
+	for _, acc := range accruals {
+		dailyYield[acc.AccrualDate.Format("2006-01-02")] += acc.Amount
+	}
+
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		end := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		date := day.Format("2006-01-02")
+		earned += dailyYield[date]
+
+		var invested, value float64
+		for j := range assets {
+			a := &assets[j]
+			if a.PurchaseDate.After(end) {
+				continue
+			}
+			v, _, _ := assetValue(a, series[a.Product.Commodity], end)
+			invested += a.InvestmentAmount
+			value += v
+		}
+
+		history = append(history, gin.H{
+			"date":          date,
+			"invested":      roundFen(invested),
+			"value":         roundFen(value),
+			"accrued_yield": roundFen(earned),
+		})
+	}
+	return history, nil
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+func TestGetPortfolioValuesHeldAssets(t *testing.T) {
+	db := useTestDB(t)
+	now := time.Now()
+
+	user := models.User{Email: "holder@example.com", WalletAddress: "cfx:holder"}
+	other := models.User{Email: "other@example.com", WalletAddress: "cfx:other"}
+	db.Create(&user)
+	db.Create(&other)
+
+	rice := models.Product{Name: "Rice", Category: "grain", RiskLevel: "low", Commodity: "rice"}
+	tea := models.Product{Name: "Tea", Category: "tea", RiskLevel: "medium"}
+	db.Create(&rice)
+	db.Create(&tea)
+	db.Create(&[]models.MarketData{
+		{ProductName: "rice", Price: 100, Timestamp: now.AddDate(0, 0, -11)},
+		{ProductName: "rice", Price: 120, Timestamp: now.Add(-time.Hour)},
+	})
+
+	// Rice tracks its commodity, up 20% since purchase; tea has no
+	// commodity and is valued at cost. Sold assets and other users' assets
+	// are not held.
+	assets := []models.UserAsset{
+		{WalletAddress: user.WalletAddress, ProductID: rice.ID, InvestmentAmount: 1000, PurchaseDate: now.AddDate(0, 0, -10), Status: "active", AccruedYield: 5, PaidYield: 2},
+		{WalletAddress: user.WalletAddress, ProductID: tea.ID, InvestmentAmount: 500, PurchaseDate: now.AddDate(0, 0, -100), Status: "matured", AccruedYield: 50, PaidYield: 50},
+		{WalletAddress: user.WalletAddress, ProductID: rice.ID, InvestmentAmount: 800, PurchaseDate: now.AddDate(0, 0, -20), Status: "sold", AccruedYield: 8, PaidYield: 8},
+		{WalletAddress: other.WalletAddress, ProductID: rice.ID, InvestmentAmount: 300, PurchaseDate: now.AddDate(0, 0, -5), Status: "active"},
+	}
+	db.Create(&assets)
+
+	rec := serve(t, GetPortfolio, http.MethodGet, "/portfolio", "/portfolio?days=2", user.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("portfolio: %d %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Data struct {
+			TotalInvested     float64 `json:"total_invested"`
+			AccruedYield      float64 `json:"accrued_yield"`
+			PaidYield         float64 `json:"paid_yield"`
+			CurrentValue      float64 `json:"current_value"`
+			UnrealizedPnL     float64 `json:"unrealized_pnl"`
+			UnrealizedPercent float64 `json:"unrealized_pnl_percent"`
+			Positions         []struct {
+				AssetID       uint    `json:"asset_id"`
+				Status        string  `json:"status"`
+				CurrentValue  float64 `json:"current_value"`
+				UnrealizedPnL float64 `json:"unrealized_pnl"`
+			} `json:"positions"`
+			History []struct {
+				Date     string  `json:"date"`
+				Invested float64 `json:"invested"`
+				Value    float64 `json:"value"`
+			} `json:"history"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := resp.Data
+
+	totals := []struct {
+		name      string
+		got, want float64
+	}{
+		{"total_invested", got.TotalInvested, 1500},
+		{"accrued_yield", got.AccruedYield, 55},
+		{"paid_yield", got.PaidYield, 52},
+		{"current_value", got.CurrentValue, 1700},
+		{"unrealized_pnl", got.UnrealizedPnL, 203},
+		{"unrealized_pnl_percent", got.UnrealizedPercent, 13.53},
+	}
+	for _, tt := range totals {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	positions := []struct {
+		assetID uint
+		status  string
+		value   float64
+		pnl     float64
+	}{
+		{assets[1].ID, "matured", 500, 0},
+		{assets[0].ID, "active", 1200, 203},
+	}
+	if len(got.Positions) != len(positions) {
+		t.Fatalf("positions = %+v, want %d", got.Positions, len(positions))
+	}
+	for i, want := range positions {
+		p := got.Positions[i]
+		if p.AssetID != want.assetID || p.Status != want.status || p.CurrentValue != want.value || p.UnrealizedPnL != want.pnl {
+			t.Errorf("position %d = %+v, want %+v", i, p, want)
+		}
+	}
+
+	// Today's history point values the same holdings as current_value
+	if len(got.History) != 2 {
+		t.Fatalf("history has %d days, want 2", len(got.History))
+	}
+	today := got.History[1]
+	if today.Date != now.Format("2006-01-02") || today.Invested != 1500 || today.Value != 1700 {
+		t.Errorf("today's history = %+v, want 1500 invested valued at 1700", today)
+	}
+}
@@ -1,24 +1,90 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"conflux-demo/backend/internal/database"
 	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/inventory"
+	"conflux-demo/backend/internal/kyc"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetProducts returns a list of RWA products
+// riskLevels orders product risk levels from lowest to highest
+var riskLevels = []string{"low", "medium", "high"}
+
+// productSorts maps the sort parameter to its column and default direction
+var productSorts = map[string]struct {
+	column string
+	desc   bool
+}{
+	"yield": {"annual_yield", true},
+	"price": {"price", false},
+}
+
+// GetProducts returns a list of RWA products. It supports sort=yield|price
+// with order=asc|desc, and min_yield and max_risk filters.
 func GetProducts(c *gin.Context) {
-	var products []models.Product
+	query := database.GetDB().Model(&models.Product{})
+
+	if v := c.Query("min_yield"); v != "" {
+		minYield, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_yield must be a number"})
+			return
+		}
+		query = query.Where("annual_yield >= ?", minYield)
+	}
+
+	if v := c.Query("max_risk"); v != "" {
+		max := -1
+		for i, level := range riskLevels {
+			if level == v {
+				max = i
+			}
+		}
+		if max < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_risk must be one of low, medium, high"})
+			return
+		}
+		query = query.Where("risk_level IN ?", riskLevels[:max+1])
+	}
+
+	if v := c.Query("sort"); v != "" {
+		sort, ok := productSorts[v]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be yield or price"})
+			return
+		}
+		desc := sort.desc
+		switch c.Query("order") {
+		case "asc":
+			desc = false
+		case "desc":
+			desc = true
+		}
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: sort.column}, Desc: desc})
+	}
 
-	if err := database.GetDB().Order("created_at DESC").Find(&products).Error; err != nil {
+	var products []models.Product
+	if err := query.Order("created_at DESC").Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": products})
+	data := make([]gin.H, len(products))
+	for i := range products {
+		data[i] = productResponse(&products[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
 }
 
 // GetProductDetail returns a single product
@@ -31,22 +97,68 @@ func GetProductDetail(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": product})
+	c.JSON(http.StatusOK, gin.H{"data": productResponse(&product)})
 }
 
-// InvestInProduct handles investment in a product
+// productResponse keeps the v1 product contract: price, yield_rate and
+// duration stay display strings. The typed values are added alongside as
+// unit_price, annual_yield and term_days.
+func productResponse(p *models.Product) gin.H {
+	return gin.H{
+		"id":               p.ID,
+		"name":             p.Name,
+		"description":      p.Description,
+		"icon":             p.Icon,
+		"yield_rate":       yieldText(p),
+		"price":            priceText(p),
+		"duration":         durationText(p),
+		"risk_level":       p.RiskLevel,
+		"product_type":     p.ProductType,
+		"category":         p.Category,
+		"stock":            p.Stock,
+		"contract_address": p.ContractAddress,
+		"created_at":       p.CreatedAt,
+		"unit_price":       p.Price,
+		"min_investment":   p.MinInvestment,
+		"annual_yield":     p.AnnualYield,
+		"term_days":        p.TermDays,
+		"commodity":        p.Commodity,
+	}
+}
+
+// InvestInProduct handles investment in a product. The requested quantity
+// is reserved immediately and held until payment or reservation expiry.
+// The investor is the authenticated user.
 func InvestInProduct(c *gin.Context) {
 	id := c.Param("id")
 
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	var input struct {
-		UserID uint   `json:"user_id" binding:"required"`
-		Amount string `json:"amount" binding:"required"`
+		Amount          string `json:"amount" binding:"required"`
+		Quantity        int    `json:"quantity"`
+		AcknowledgeRisk bool   `json:"acknowledge_risk"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
+	if input.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+	amount, err := strconv.ParseFloat(input.Amount, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be a number"})
+		return
+	}
 
 	// Get product
 	var product models.Product
@@ -55,23 +167,120 @@ func InvestInProduct(c *gin.Context) {
 		return
 	}
 
+	if err := validateInvestment(&product, amount, input.Quantity); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	ack, ok := checkSuitability(c, user, &product, amount, input.AcknowledgeRisk, "invest")
+	if !ok {
+		return
+	}
+	if !checkKYCLimit(c, user, kyc.LimitInvestment, amount) {
+		return
+	}
+
 	// TODO: Implement blockchain transaction for investment
 	// For now, just create a transaction record
 	transaction := models.Transaction{
-		UserID: input.UserID,
+		UserID: user.ID,
 		Type:   "investment",
-		Amount: input.Amount,
+		Amount: fmt.Sprintf("%.2f", amount),
 		Status: "pending",
-		TxHash: "", // Will be filled after blockchain transaction
+		TxHash: fmt.Sprintf("0x%x", time.Now().UnixNano()), // Replaced after blockchain transaction
 	}
 
-	if err := database.GetDB().Create(&transaction).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
+	var reservation *models.StockReservation
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		reservation, err = inventory.Reserve(tx, product.ID, user.WalletAddress, input.Quantity, inventory.ReservationTTL())
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+		if err := recordAcknowledgement(tx, ack); err != nil {
+			return err
+		}
+		reservation.TransactionID = &transaction.ID
+		return tx.Model(reservation).Update("transaction_id", transaction.ID).Error
+	})
+	if err != nil {
+		writeReservationError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":     "Investment initiated",
 		"transaction": transaction,
+		"reservation": reservation,
 	})
 }
+
+// writeReservationError maps inventory errors to HTTP responses
+func writeReservationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, inventory.ErrSoldOut):
+		c.JSON(http.StatusConflict, gin.H{"error": "Product sold out"})
+	case errors.Is(err, inventory.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, inventory.ErrReservationClosed):
+		c.JSON(http.StatusGone, gin.H{"error": "Reservation has expired or was already used"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
+	}
+}
+
+// validateInvestment checks an amount against the product's pricing. Priced
+// products must be paid in full for the quantity bought; every investment
+// must meet the product's minimum ticket.
+func validateInvestment(product *models.Product, amount float64, quantity int) error {
+	if amount <= 0 {
+		return errors.New("investment amount must be positive")
+	}
+	if product.Price > 0 {
+		expected := roundFen(product.Price * float64(quantity))
+		if math.Abs(roundFen(amount)-expected) >= 0.01 {
+			return fmt.Errorf("investment amount must be %.2f for %d unit(s)", expected, quantity)
+		}
+	}
+	if product.MinInvestment > 0 && amount < product.MinInvestment {
+		return fmt.Errorf("minimum investment is %.2f", product.MinInvestment)
+	}
+	return nil
+}
+
+// yieldText returns the yield display text, formatting the rate when a
+// product has no label
+func yieldText(p *models.Product) string {
+	if p.YieldLabel != "" {
+		return p.YieldLabel
+	}
+	if p.AnnualYield > 0 {
+		return strconv.FormatFloat(p.AnnualYield, 'f', -1, 64) + "%"
+	}
+	return ""
+}
+
+// priceText returns the price display text
+func priceText(p *models.Product) string {
+	if p.PriceLabel != "" {
+		return p.PriceLabel
+	}
+	if p.Price > 0 {
+		return fmt.Sprintf("¥%.2f", p.Price)
+	}
+	return ""
+}
+
+// durationText returns the term display text
+func durationText(p *models.Product) string {
+	if p.DurationLabel != "" {
+		return p.DurationLabel
+	}
+	if p.TermDays > 0 {
+		return fmt.Sprintf("%d天", p.TermDays)
+	}
+	return "Permanent"
+}
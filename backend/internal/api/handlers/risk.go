@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/suitability"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetRiskQuestionnaire returns the risk questionnaire and the current
+// user's tier
+func GetRiskQuestionnaire(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":          true,
+		"questions":   suitability.Questions,
+		"risk_score":  user.RiskScore,
+		"risk_tier":   user.RiskTier,
+		"max_risk":    suitability.MaxRisk(user.RiskTier),
+		"assessed_at": user.RiskAssessedAt,
+	})
+}
+
+// SubmitRiskProfile scores the questionnaire answers and stores the
+// resulting tier on the user
+func SubmitRiskProfile(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Answers map[string]string `json:"answers" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	score, tier, err := suitability.Score(input.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	answers, err := json.Marshal(input.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answers"})
+		return
+	}
+
+	now := time.Now()
+	profile := models.RiskProfile{
+		UserID:  user.ID,
+		Answers: string(answers),
+		Score:   score,
+		Tier:    tier,
+	}
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&profile).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"risk_score":       score,
+			"risk_tier":        tier,
+			"risk_assessed_at": now,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save risk profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":         true,
+		"risk_score": score,
+		"risk_tier":  tier,
+		"max_risk":   suitability.MaxRisk(tier),
+	})
+}
+
+// checkSuitability decides whether user may invest in product. When the
+// product is riskier than the user's tier it writes a 403 and returns
+// false, unless the policy allows it and the user acknowledged the risk.
+// ack is non-nil when an acknowledgement must be recorded with the
+// investment.
+func checkSuitability(c *gin.Context, user *models.User, product *models.Product, amount float64, acknowledged bool, source string) (ack *models.RiskAcknowledgement, ok bool) {
+	if !suitability.Exceeds(user.RiskTier, product.RiskLevel) {
+		return nil, true
+	}
+
+	details := gin.H{
+		"product_risk": product.RiskLevel,
+		"risk_tier":    user.RiskTier,
+		"max_risk":     suitability.MaxRisk(user.RiskTier),
+	}
+	if suitability.Policy() == suitability.PolicyReject {
+		details["error"] = "Product risk exceeds your risk tier"
+		c.JSON(http.StatusForbidden, details)
+		return nil, false
+	}
+	if !acknowledged {
+		details["error"] = "Product risk exceeds your risk tier; set acknowledge_risk to proceed"
+		details["requires_acknowledgement"] = true
+		c.JSON(http.StatusForbidden, details)
+		return nil, false
+	}
+
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return &models.RiskAcknowledgement{
+		UserID:        user.ID,
+		WalletAddress: user.WalletAddress,
+		ProductID:     product.ID,
+		ProductRisk:   product.RiskLevel,
+		RiskTier:      user.RiskTier,
+		Amount:        amount,
+		Source:        source,
+		IPAddress:     c.ClientIP(),
+		UserAgent:     userAgent,
+	}, true
+}
+
+// recordAcknowledgement stores ack if one is required
+func recordAcknowledgement(tx *gorm.DB, ack *models.RiskAcknowledgement) error {
+	if ack == nil {
+		return nil
+	}
+	return tx.Create(ack).Error
+}
+
+// AdminListRiskAcknowledgements returns risk acknowledgements for
+// compliance review, newest first, optionally for one user
+func AdminListRiskAcknowledgements(c *gin.Context) {
+	query := database.GetDB().Order("created_at DESC").Limit(200)
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var acks []models.RiskAcknowledgement
+	if err := query.Find(&acks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch acknowledgements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "data": acks})
+}
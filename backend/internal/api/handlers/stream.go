@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/marketdata"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Rows buffered per stream before it is dropped as lagging
+	streamBuffer = 256
+	// Most rows replayed when a stream resumes
+	maxStreamReplay = 1000
+	streamWriteWait = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// The API allows every origin, see middleware.CORS
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// streamMessage is one message of a price stream
+type streamMessage struct {
+	Type string             `json:"type"` // "price", "heartbeat" or "lagged"
+	Data *models.MarketData `json:"data,omitempty"`
+	Time time.Time          `json:"time"`
+}
+
+// StreamMarketPrices pushes new prices over WebSocket, or as server-sent
+// events when the request is not an upgrade. products limits the stream to
+// a comma separated list; since, or an SSE Last-Event-ID, replays the rows
+// stored after that timestamp before going live.
+func StreamMarketPrices(c *gin.Context) {
+	products := splitProducts(c.Query("products"))
+
+	since := c.Query("since")
+	if since == "" {
+		since = c.GetHeader("Last-Event-ID")
+	}
+	var sinceTime time.Time
+	if since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		sinceTime = t
+	}
+
+	// Subscribe before replaying so no row falls between the two
+	sub := marketdata.Subscribe(products, streamBuffer)
+	defer sub.Close()
+
+	var replay []models.MarketData
+	if !sinceTime.IsZero() {
+		query := database.GetDB().Where("timestamp > ?", sinceTime)
+		if len(products) > 0 {
+			query = query.Where("product_name IN ?", products)
+		}
+		if err := query.Order("timestamp ASC, id ASC").Limit(maxStreamReplay).Find(&replay).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
+			return
+		}
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		streamWebSocket(c, sub, replay)
+		return
+	}
+	streamSSE(c, sub, replay)
+}
+
+func splitProducts(v string) []string {
+	products := []string{}
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			products = append(products, p)
+		}
+	}
+	return products
+}
+
+// replayFilter returns the replayed rows and a check that skips live rows
+// already replayed
+func replayFilter(replay []models.MarketData) func(models.MarketData) bool {
+	seen := make(map[uint]bool, len(replay))
+	for _, row := range replay {
+		seen[row.ID] = true
+	}
+	return func(row models.MarketData) bool { return !seen[row.ID] }
+}
+
+// streamWebSocket writes stream messages as JSON text frames. Clients may
+// send {"type": "subscribe", "products": [...]} to change their products.
+func streamWebSocket(c *gin.Context, sub *marketdata.Subscription, replay []models.MarketData) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	interval := marketdata.HeartbeatInterval()
+	done := make(chan struct{})
+
+	// Read pump: handles subscription changes, pongs and the close
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(4096)
+		conn.SetReadDeadline(time.Now().Add(2 * interval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * interval))
+		})
+		for {
+			var msg struct {
+				Type     string   `json:"type"`
+				Products []string `json:"products"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				if _, ok := err.(*json.SyntaxError); ok {
+					continue
+				}
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(2 * interval))
+			if msg.Type == "subscribe" {
+				sub.SetProducts(msg.Products)
+			}
+		}
+	}()
+
+	write := func(msg streamMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		return conn.WriteJSON(msg) == nil
+	}
+
+	for i := range replay {
+		if !write(streamMessage{Type: "price", Data: &replay[i], Time: replay[i].Timestamp}) {
+			return
+		}
+	}
+	fresh := replayFilter(replay)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case row, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					write(streamMessage{Type: "lagged", Time: time.Now()})
+				}
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "lagged"),
+					time.Now().Add(streamWriteWait))
+				return
+			}
+			if !fresh(row) {
+				continue
+			}
+			if !write(streamMessage{Type: "price", Data: &row, Time: row.Timestamp}) {
+				return
+			}
+		case now := <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, now.Add(streamWriteWait)); err != nil {
+				return
+			}
+			if !write(streamMessage{Type: "heartbeat", Time: now}) {
+				return
+			}
+		}
+	}
+}
+
+// streamSSE writes price events with the row timestamp as the event ID, so
+// a reconnecting EventSource resumes where it left off
+func streamSSE(c *gin.Context, sub *marketdata.Subscription, replay []models.MarketData) {
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event, id string, data interface{}) bool {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return false
+		}
+		if id != "" {
+			fmt.Fprintf(w, "id: %s\n", id)
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return false
+		}
+		w.Flush()
+		return true
+	}
+	sendRow := func(row models.MarketData) bool {
+		return send("price", row.Timestamp.Format(time.RFC3339Nano), row)
+	}
+
+	for _, row := range replay {
+		if !sendRow(row) {
+			return
+		}
+	}
+	fresh := replayFilter(replay)
+
+	ticker := time.NewTicker(marketdata.HeartbeatInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case row, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					send("lagged", "", gin.H{"time": time.Now()})
+				}
+				return
+			}
+			if fresh(row) && !sendRow(row) {
+				return
+			}
+		case now := <-ticker.C:
+			if !send("heartbeat", "", gin.H{"time": now}) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"conflux-demo/backend/internal/community"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Trending topics count activity at half weight after this long
+const trendingHalfLife = 24 * time.Hour
+
+// parseContent extracts the #topics and resolved @mentions of content. A
+// failed user lookup drops the mentions rather than the post.
+func parseContent(content string) ([]string, []community.Mention) {
+	tags := community.ParseTags(content)
+
+	handles := community.ParseMentions(content)
+	if len(handles) == 0 {
+		return tags, nil
+	}
+	users, err := community.ResolveMentions(database.GetDB(), handles)
+	if err != nil {
+		log.Printf("Failed to resolve mentions: %v", err)
+		return tags, nil
+	}
+	mentions := make([]community.Mention, 0, len(users))
+	for _, u := range users {
+		mentions = append(mentions, community.Mention{UserID: u.ID, Wallet: u.WalletAddress, Username: u.Username})
+	}
+	return tags, mentions
+}
+
+// notifyMentions tells users newly mentioned by the author, skipping those
+// already in previous and the author themselves
+func notifyMentions(mentions, previous []community.Mention, authorID uint, authorName, content string, data map[string]interface{}) {
+	notified := map[uint]bool{}
+	for _, m := range previous {
+		notified[m.UserID] = true
+	}
+	for _, m := range mentions {
+		if notified[m.UserID] || m.UserID == authorID {
+			continue
+		}
+		notified[m.UserID] = true
+		notify.Publish(notify.Message{
+			UserID: m.UserID,
+			Type:   notify.TypeMention,
+			Title:  authorName + " mentioned you",
+			Body:   content,
+			Data:   data,
+		})
+	}
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+func nonNilMentions(mentions []community.Mention) []community.Mention {
+	if mentions == nil {
+		return []community.Mention{}
+	}
+	return mentions
+}
+
+// GetTopicPosts returns the visible posts tagged with a topic, paged and
+// sorted like GetPosts
+func GetTopicPosts(c *gin.Context) {
+	tag := community.NormalizeTag(c.Param("tag"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic"})
+		return
+	}
+	listPosts(c, community.PostQuery{Tag: tag})
+}
+
+// GetTrendingTopics ranks the topics of the last hours (default 72, at
+// most 168) by posts and comments using them, recent ones counting more
+func GetTrendingTopics(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "72"))
+	if hours < 1 || hours > 168 {
+		hours = 72
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	activity, err := community.Default().TagActivity(ctx, now.Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch topics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":    true,
+		"data":  community.Trending(activity, now, trendingHalfLife, limit),
+		"hours": hours,
+	})
+}
+
+// mentionData identifies the post and, for comments, the comment a mention
+// notification links to
+func mentionData(postID, commentID string) map[string]interface{} {
+	data := map[string]interface{}{"post_id": postID}
+	if commentID != "" {
+		data["comment_id"] = commentID
+	}
+	return data
+}
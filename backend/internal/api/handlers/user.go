@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"conflux-demo/backend/internal/blockchain"
 	"conflux-demo/backend/internal/database"
 	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/kyc"
 
 	"github.com/gin-gonic/gin"
 )
@@ -119,10 +121,25 @@ func Deposit(c *gin.Context) {
 		return
 	}
 
+	amount, err := strconv.ParseFloat(input.Amount, 64)
+	if err != nil || amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be a positive number"})
+		return
+	}
+
+	var user models.User
+	if err := database.GetDB().First(&user, input.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !checkKYCLimit(c, &user, kyc.LimitDeposit, amount) {
+		return
+	}
+
 	transaction := models.Transaction{
 		UserID: input.UserID,
 		Type:   "deposit",
-		Amount: input.Amount,
+		Amount: fmt.Sprintf("%.2f", amount),
 		Status: "pending",
 		TxHash: "",
 	}
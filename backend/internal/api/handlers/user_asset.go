@@ -1,16 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"conflux-demo/backend/internal/database"
 	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/inventory"
+	"conflux-demo/backend/internal/kyc"
+	"conflux-demo/backend/internal/notify"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// errReservationMismatch reports a purchase whose quantity or amount differs
+// from the reservation it confirms
+var errReservationMismatch = errors.New("purchase does not match the reservation")
+
 // GetUserAssets returns all assets owned by a user
 func GetUserAssets(c *gin.Context) {
 	address := c.Param("address")
@@ -37,7 +46,7 @@ func GetUserAssets(c *gin.Context) {
 			"product_id":        asset.ProductID,
 			"product_name":      asset.Product.Name,
 			"product_icon":      asset.Product.Icon,
-			"yield_rate":        asset.Product.YieldRate,
+			"yield_rate":        yieldText(&asset.Product),
 			"token_id":          asset.TokenID,
 			"nft_address":       asset.NFTAddress,
 			"investment_amount": fmt.Sprintf("%.2f", asset.InvestmentAmount),
@@ -101,12 +110,22 @@ func GetUserTransactions(c *gin.Context) {
 	})
 }
 
-// RecordInvestment records a new investment/purchase
+// RecordInvestment records a new investment/purchase by the authenticated
+// user. It confirms the given pending reservation, or reserves stock on the
+// spot if none is given.
 func RecordInvestment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	var input struct {
-		WalletAddress    string  `json:"wallet_address" binding:"required"`
+		WalletAddress    string  `json:"wallet_address"`
 		ProductID        uint    `json:"product_id" binding:"required"`
 		InvestmentAmount float64 `json:"investment_amount" binding:"required"`
+		Quantity         int     `json:"quantity"`
+		ReservationID    uint    `json:"reservation_id"`
+		AcknowledgeRisk  bool    `json:"acknowledge_risk"`
 		TokenID          string  `json:"token_id"`
 		NFTAddress       string  `json:"nft_address"`
 		TxHash           string  `json:"tx_hash"`
@@ -116,17 +135,42 @@ func RecordInvestment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Older clients still send their wallet
+	if input.WalletAddress != "" && input.WalletAddress != user.WalletAddress {
+		c.JSON(http.StatusForbidden, gin.H{"error": "wallet_address does not match the authenticated user"})
+		return
+	}
+	input.WalletAddress = user.WalletAddress
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
+	if input.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
 
-	// Find or create user
-	var user models.User
-	result := database.GetDB().Where("wallet_address = ?", input.WalletAddress).First(&user)
-	if result.Error != nil {
-		user = models.User{
-			WalletAddress: input.WalletAddress,
-			Balance:       0,
+	var product models.Product
+	if err := database.GetDB().First(&product, input.ProductID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err := validateInvestment(&product, input.InvestmentAmount, input.Quantity); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reservations made by InvestInProduct were checked when they were made,
+	// and their pending transaction already counts towards the KYC limit.
+	// confirmPurchase only accepts the user's own reservation with the same
+	// quantity and amount.
+	var ack *models.RiskAcknowledgement
+	if input.ReservationID == 0 {
+		var ok bool
+		ack, ok = checkSuitability(c, user, &product, input.InvestmentAmount, input.AcknowledgeRisk, "record")
+		if !ok {
+			return
 		}
-		if err := database.GetDB().Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		if !checkKYCLimit(c, user, kyc.LimitInvestment, input.InvestmentAmount) {
 			return
 		}
 	}
@@ -149,11 +193,59 @@ func RecordInvestment(c *gin.Context) {
 		TxHash:           input.TxHash,
 	}
 
-	if err := database.GetDB().Create(&asset).Error; err != nil {
+	// Set when the purchase settles a reservation whose pending transaction
+	// record already exists
+	var linkedTx *uint
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		linkedTx, err = confirmPurchase(tx, input.ReservationID, input.ProductID, input.WalletAddress, input.Quantity, input.InvestmentAmount, input.TxHash)
+		if err != nil {
+			return err
+		}
+		if err := recordAcknowledgement(tx, ack); err != nil {
+			return err
+		}
+		if err := tx.Create(&asset).Error; err != nil {
+			return err
+		}
+		if asset.NFTAddress == "" {
+			return nil
+		}
+		return openCustody(tx, asset.NFTAddress, asset.TokenID, asset.WalletAddress, asset.TxHash, asset.PurchaseDate)
+	})
+	if errors.Is(err, errReservationMismatch) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Quantity and investment amount must match the reservation"})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, inventory.ErrSoldOut) || errors.Is(err, inventory.ErrProductNotFound) ||
+			errors.Is(err, inventory.ErrReservationClosed) {
+			writeReservationError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record investment"})
 		return
 	}
 
+	if linkedTx != nil {
+		notify.Publish(notify.Message{
+			UserID: user.ID,
+			Type:   notify.TypeTransaction,
+			Title:  "Investment confirmed",
+			Body:   fmt.Sprintf("Your investment of %.2f has settled", input.InvestmentAmount),
+			Data: map[string]interface{}{
+				"transaction_id": *linkedTx,
+				"asset_id":       asset.ID,
+				"status":         "success",
+			},
+		})
+		c.JSON(http.StatusCreated, gin.H{
+			"ok":   true,
+			"data": asset,
+		})
+		return
+	}
+
 	// Create transaction record
 	transaction := models.Transaction{
 		UserID:    user.ID,
@@ -179,3 +271,56 @@ func RecordInvestment(c *gin.Context) {
 		"data": asset,
 	})
 }
+
+// confirmPurchase settles the stock for a paid purchase. A reservation made
+// by InvestInProduct must belong to the same wallet and product and be for
+// the same quantity and amount, and its pending transaction is marked
+// successful and returned; without one the stock is reserved and confirmed
+// in the same transaction.
+func confirmPurchase(tx *gorm.DB, reservationID, productID uint, wallet string, quantity int, amount float64, txHash string) (*uint, error) {
+	if reservationID == 0 {
+		reservation, err := inventory.Reserve(tx, productID, wallet, quantity, inventory.ReservationTTL())
+		if err != nil {
+			return nil, err
+		}
+		return nil, inventory.Confirm(tx, reservation.ID)
+	}
+
+	var reservation models.StockReservation
+	if err := tx.Where("id = ? AND product_id = ? AND wallet_address = ?", reservationID, productID, wallet).
+		First(&reservation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, inventory.ErrReservationClosed
+		}
+		return nil, err
+	}
+	if reservation.Quantity != quantity {
+		return nil, errReservationMismatch
+	}
+	if reservation.TransactionID != nil {
+		var pending models.Transaction
+		if err := tx.First(&pending, *reservation.TransactionID).Error; err != nil {
+			return nil, err
+		}
+		if pending.Amount != fmt.Sprintf("%.2f", amount) {
+			return nil, errReservationMismatch
+		}
+	}
+	if err := inventory.Confirm(tx, reservation.ID); err != nil {
+		return nil, err
+	}
+	if reservation.TransactionID == nil {
+		return nil, nil
+	}
+
+	updates := map[string]interface{}{"status": "success"}
+	if txHash != "" {
+		updates["tx_hash"] = txHash
+	}
+	if err := tx.Model(&models.Transaction{}).
+		Where("id = ? AND status = ?", *reservation.TransactionID, "pending").
+		Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return reservation.TransactionID, nil
+}
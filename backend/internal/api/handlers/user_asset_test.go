@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/inventory"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB points the database package at a fresh SQLite database
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "handlers.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.MarketData{},
+		&models.Product{},
+		&models.Transaction{},
+		&models.UserAsset{},
+		&models.NFTCustody{},
+		&models.NFTTransfer{},
+		&models.Listing{},
+		&models.Offer{},
+		&models.StockReservation{},
+		&models.YieldAccrual{},
+		&models.RiskProfile{},
+		&models.RiskAcknowledgement{},
+		&models.KYCSubmission{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
+
+// serve sends a JSON request to handler as the user with userID, or
+// anonymously when it is 0, and returns the recorded response
+func serve(t *testing.T, handler gin.HandlerFunc, method, route, target string, userID uint, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, func(c *gin.Context) {
+		if userID != 0 {
+			c.Set("user_id", userID)
+		}
+	}, handler)
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, target, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRecordInvestmentAsAuthenticatedUser(t *testing.T) {
+	db := useTestDB(t)
+
+	user := models.User{Email: "buyer@example.com", WalletAddress: "cfx:buyer"}
+	db.Create(&user)
+	product := models.Product{Name: "Rice", Price: 100, Stock: 10, RiskLevel: "low"}
+	db.Create(&product)
+
+	invest := func(userID uint, body gin.H) *httptest.ResponseRecorder {
+		return serve(t, RecordInvestment, http.MethodPost, "/invest", "/invest", userID, body)
+	}
+
+	if rec := invest(0, gin.H{"product_id": product.ID, "investment_amount": 100}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous investment: %d, want 401", rec.Code)
+	}
+	rec := invest(user.ID, gin.H{"wallet_address": "cfx:victim", "product_id": product.ID, "investment_amount": 100})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("investment for another wallet: %d, want 403", rec.Code)
+	}
+	var users int64
+	db.Model(&models.User{}).Count(&users)
+	if users != 1 {
+		t.Errorf("%d users after investing for another wallet, want 1", users)
+	}
+
+	rec = invest(user.ID, gin.H{"product_id": product.ID, "investment_amount": 200, "quantity": 2})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("investment: %d %s", rec.Code, rec.Body)
+	}
+	var asset models.UserAsset
+	db.First(&asset)
+	if asset.WalletAddress != user.WalletAddress || asset.InvestmentAmount != 200 {
+		t.Errorf("asset = %+v", asset)
+	}
+}
+
+func TestConfirmPurchaseMatchesReservation(t *testing.T) {
+	db := useTestDB(t)
+
+	product := models.Product{Name: "Rice", Price: 100, Stock: 10, RiskLevel: "low"}
+	db.Create(&product)
+
+	// A reservation made by InvestInProduct for 2 units
+	reserve := func() uint {
+		reservation, err := inventory.Reserve(db, product.ID, "cfx:buyer", 2, time.Minute)
+		if err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+		pending := models.Transaction{UserID: 1, Type: "investment", Amount: "200.00", Status: "pending"}
+		db.Create(&pending)
+		db.Model(reservation).Update("transaction_id", pending.ID)
+		return reservation.ID
+	}
+	id := reserve()
+
+	tests := []struct {
+		name     string
+		wallet   string
+		quantity int
+		amount   float64
+		want     error
+	}{
+		{"more units", "cfx:buyer", 5, 500, errReservationMismatch},
+		{"larger amount", "cfx:buyer", 2, 300, errReservationMismatch},
+		{"another wallet", "cfx:other", 2, 200, inventory.ErrReservationClosed},
+		{"matching", "cfx:buyer", 2, 200, nil},
+		{"already used", "cfx:buyer", 2, 200, inventory.ErrReservationClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var linked *uint
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				linked, err = confirmPurchase(tx, id, product.ID, tt.wallet, tt.quantity, tt.amount, "")
+				return err
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("confirmPurchase = %v, want %v", err, tt.want)
+			}
+			if err == nil && linked == nil {
+				t.Error("pending transaction not returned")
+			}
+		})
+	}
+
+	var reloaded models.Product
+	db.First(&reloaded, product.ID)
+	if reloaded.Stock != 8 {
+		t.Errorf("stock = %d, want 8", reloaded.Stock)
+	}
+}
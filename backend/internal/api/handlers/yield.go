@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/yield"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetYieldProjections returns the accrued and expected yield of the current
+// user's active and matured assets
+func GetYieldProjections(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var assets []models.UserAsset
+	if err := database.GetDB().
+		Preload("Product").
+		Where("wallet_address = ? AND status IN ?", user.WalletAddress, []string{"active", "matured"}).
+		Order("purchase_date DESC").
+		Find(&assets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets"})
+		return
+	}
+
+	now := time.Now()
+	var totalInvested, totalAccrued, totalPaid, totalPending float64
+	projections := []gin.H{}
+	for i := range assets {
+		asset := &assets[i]
+		p := yield.Project(asset, now)
+
+		var maturity *string
+		if p.MaturityDate != nil {
+			date := p.MaturityDate.Format("2006-01-02")
+			maturity = &date
+		}
+
+		projections = append(projections, gin.H{
+			"asset_id":          asset.ID,
+			"product_id":        asset.ProductID,
+			"product_name":      asset.Product.Name,
+			"status":            asset.Status,
+			"investment_amount": asset.InvestmentAmount,
+			"annual_yield":      asset.Product.AnnualYield,
+			"term_days":         asset.Product.TermDays,
+			"daily_yield":       roundFen(p.DailyYield),
+			"accrued_yield":     roundFen(p.AccruedYield),
+			"paid_yield":        roundFen(p.PaidYield),
+			"maturity_date":     maturity,
+			"days_remaining":    p.DaysRemaining,
+			"projected_pending": roundFen(p.ProjectedPending),
+			"projected_total":   roundFen(p.ProjectedTotal),
+		})
+
+		totalInvested += asset.InvestmentAmount
+		totalAccrued += p.AccruedYield
+		totalPaid += p.PaidYield
+		totalPending += p.ProjectedPending
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok": true,
+		"summary": gin.H{
+			"total_invested":    roundFen(totalInvested),
+			"accrued_yield":     roundFen(totalAccrued),
+			"paid_yield":        roundFen(totalPaid),
+			"projected_pending": roundFen(totalPending),
+			"projected_total":   roundFen(totalAccrued + totalPending),
+		},
+		"data": projections,
+	})
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"conflux-demo/backend/internal/community"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireCommunity rejects requests while the community store is
+// unavailable, which happens when its database could not be set up at
+// startup
+func RequireCommunity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if community.Default() == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Community is unavailable"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
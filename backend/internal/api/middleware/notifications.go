@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"conflux-demo/backend/internal/mongodb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireNotifications rejects requests while the notification inbox is
+// unavailable, which happens when MongoDB could not be reached at startup
+func RequireNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mongodb.GetDB() == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Notifications are unavailable"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,6 +1,9 @@
 package routes
 
 import (
+	"log"
+
+	"conflux-demo/backend/config"
 	"conflux-demo/backend/internal/api/handlers"
 	"conflux-demo/backend/internal/api/middleware"
 
@@ -8,7 +11,7 @@ import (
 )
 
 // SetupRoutes configures all API routes
-func SetupRoutes(router *gin.Engine) {
+func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 	// Apply CORS middleware
 	router.Use(middleware.CORS())
 
@@ -36,8 +39,14 @@ func SetupRoutes(router *gin.Engine) {
 		{
 			market.GET("/prices", handlers.GetMarketPrices)
 			market.GET("/history/:product", handlers.GetPriceHistory)
+			market.GET("/sources", handlers.GetMarketSources)
+			market.GET("/stream", handlers.StreamMarketPrices)
 		}
 
+		// Marketplace routes (public)
+		v1.GET("/marketplace/listings", handlers.GetListings)
+		v1.GET("/marketplace/listings/:id", handlers.GetListing)
+
 		// Protected routes (authentication required)
 		protected := v1.Group("")
 		protected.Use(middleware.AuthMiddleware())
@@ -46,11 +55,30 @@ func SetupRoutes(router *gin.Engine) {
 			protected.GET("/me", handlers.GetCurrentUser)
 
 			// Community routes
-			community := protected.Group("/community")
+			community := protected.Group("/community", middleware.RequireCommunity())
 			{
 				community.GET("/posts", handlers.GetPosts)
 				community.POST("/posts", handlers.CreatePost)
+				community.POST("/attachments", handlers.UploadAttachment)
+				community.PATCH("/posts/:id", handlers.UpdatePost)
+				community.DELETE("/posts/:id", handlers.DeletePost)
+				community.GET("/posts/:id/revisions", handlers.GetPostRevisions)
 				community.POST("/posts/:id/like", handlers.LikePost)
+				community.POST("/posts/:id/unlike", handlers.UnlikePost)
+				community.GET("/posts/:id/comments", handlers.GetComments)
+				community.POST("/posts/:id/comments", handlers.CreateComment)
+				community.PATCH("/comments/:id", handlers.UpdateComment)
+				community.DELETE("/comments/:id", handlers.DeleteComment)
+				community.GET("/comments/:id/revisions", handlers.GetCommentRevisions)
+				community.POST("/posts/:id/report", handlers.ReportPost)
+				community.POST("/comments/:id/report", handlers.ReportComment)
+				community.GET("/topics", handlers.GetTrendingTopics)
+				community.GET("/topics/:tag", handlers.GetTopicPosts)
+				community.GET("/feed", handlers.GetFeed)
+				community.POST("/users/:wallet/follow", handlers.FollowUser)
+				community.POST("/users/:wallet/unfollow", handlers.UnfollowUser)
+				community.GET("/users/:wallet/followers", handlers.GetFollowers)
+				community.GET("/users/:wallet/following", handlers.GetFollowing)
 			}
 
 			// Product routes
@@ -61,6 +89,50 @@ func SetupRoutes(router *gin.Engine) {
 				products.POST("/:id/invest", handlers.InvestInProduct)
 			}
 
+			// Marketplace routes
+			marketplace := protected.Group("/marketplace")
+			{
+				marketplace.POST("/listings", handlers.CreateListing)
+				marketplace.DELETE("/listings/:id", handlers.CancelListing)
+				marketplace.POST("/listings/:id/buy", handlers.BuyListing)
+				marketplace.POST("/listings/:id/offers", handlers.CreateOffer)
+				marketplace.POST("/offers/:id/accept", handlers.AcceptOffer)
+				marketplace.POST("/offers/:id/reject", handlers.RejectOffer)
+				marketplace.POST("/offers/:id/withdraw", handlers.WithdrawOffer)
+			}
+
+			// Portfolio routes
+			protected.GET("/portfolio", handlers.GetPortfolio)
+
+			// KYC routes
+			protected.GET("/kyc", handlers.GetKYCStatus)
+			protected.POST("/kyc/submissions", handlers.SubmitKYC)
+
+			// Yield routes
+			protected.GET("/yield/projections", handlers.GetYieldProjections)
+
+			// Notification routes
+			notifications := protected.Group("/notifications", middleware.RequireNotifications())
+			{
+				notifications.GET("", handlers.GetNotifications)
+				notifications.POST("/:id/read", handlers.MarkNotificationRead)
+				notifications.POST("/read-all", handlers.MarkAllNotificationsRead)
+				notifications.GET("/preferences", handlers.GetNotificationPreferences)
+				notifications.PUT("/preferences", handlers.UpdateNotificationPreferences)
+			}
+
+			// Watchlist and price alert routes
+			protected.GET("/watchlist", handlers.GetWatchlist)
+			protected.POST("/watchlist", handlers.AddToWatchlist)
+			protected.DELETE("/watchlist/:product", handlers.RemoveFromWatchlist)
+			alerts := protected.Group("/alerts")
+			{
+				alerts.GET("", handlers.GetAlertRules)
+				alerts.POST("", handlers.CreateAlertRule)
+				alerts.PATCH("/:id", handlers.UpdateAlertRule)
+				alerts.DELETE("/:id", handlers.DeleteAlertRule)
+			}
+
 			// User routes
 			user := protected.Group("/user")
 			{
@@ -69,7 +141,35 @@ func SetupRoutes(router *gin.Engine) {
 				user.GET("/transactions", handlers.GetTransactions)
 				user.POST("/transfer", handlers.Transfer)
 				user.POST("/deposit", handlers.Deposit)
+				user.GET("/risk-profile", handlers.GetRiskQuestionnaire)
+				user.POST("/risk-profile", handlers.SubmitRiskProfile)
+				user.GET("/notification-endpoints", handlers.GetNotificationEndpoints)
+				user.POST("/webhooks", handlers.AddWebhook)
+				user.POST("/push-tokens", handlers.AddPushToken)
+				user.DELETE("/notification-endpoints/:id", handlers.DeleteNotificationEndpoint)
+			}
+		}
+
+		// Admin review routes (HTTP basic auth, one account per reviewer)
+		if accounts := cfg.AdminCredentials(); len(accounts) > 0 {
+			admin := v1.Group("/admin", gin.BasicAuth(gin.Accounts(accounts)))
+			{
+				admin.GET("/kyc/submissions", handlers.AdminListKYCSubmissions)
+				admin.GET("/kyc/submissions/:id", handlers.AdminGetKYCSubmission)
+				admin.GET("/kyc/submissions/:id/documents/:kind", handlers.AdminGetKYCDocument)
+				admin.POST("/kyc/submissions/:id/approve", handlers.AdminApproveKYC)
+				admin.POST("/kyc/submissions/:id/reject", handlers.AdminRejectKYC)
+				admin.GET("/risk-acknowledgements", handlers.AdminListRiskAcknowledgements)
+				admin.GET("/moderation/queue", middleware.RequireCommunity(), handlers.AdminModerationQueue)
+				admin.GET("/moderation/reports", middleware.RequireCommunity(), handlers.AdminListReports)
+				admin.POST("/moderation/posts/:id", middleware.RequireCommunity(), handlers.AdminModeratePost)
+				admin.POST("/moderation/comments/:id", middleware.RequireCommunity(), handlers.AdminModerateComment)
+				admin.GET("/moderation/terms", handlers.AdminListBlockedTerms)
+				admin.POST("/moderation/terms", handlers.AdminCreateBlockedTerm)
+				admin.DELETE("/moderation/terms/:id", handlers.AdminDeleteBlockedTerm)
 			}
+		} else {
+			log.Println("ADMIN_ACCOUNTS not set, admin routes disabled")
 		}
 	}
 
@@ -78,21 +178,42 @@ func SetupRoutes(router *gin.Engine) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 	// Mobile App Routes (Compatibility)
+	router.GET("/media/*key", handlers.GetMedia)
 	router.GET("/api/news", handlers.GetMobileNews)
 	router.GET("/api/market", handlers.GetMobileMarket)
 	router.GET("/api/products", handlers.GetMobileProducts)
-	router.GET("/api/community/posts", handlers.GetPosts)
-	router.POST("/api/community/posts", handlers.CreatePost)
-	router.POST("/api/community/posts/:id/like", handlers.LikePost)
-	router.POST("/api/community/posts/:id/unlike", handlers.UnlikePost)
-	router.GET("/api/community/posts/:id/comments", handlers.GetComments)
-	router.POST("/api/community/posts/:id/comments", handlers.CreateComment)
+	mobileCommunity := router.Group("/api/community", middleware.OptionalAuth(), middleware.RequireCommunity())
+	{
+		mobileCommunity.GET("/posts", handlers.GetPosts)
+		mobileCommunity.POST("/posts", handlers.CreatePost)
+		mobileCommunity.POST("/attachments", handlers.UploadAttachment)
+		mobileCommunity.PATCH("/posts/:id", handlers.UpdatePost)
+		mobileCommunity.DELETE("/posts/:id", handlers.DeletePost)
+		mobileCommunity.GET("/posts/:id/revisions", handlers.GetPostRevisions)
+		mobileCommunity.POST("/posts/:id/like", handlers.LikePost)
+		mobileCommunity.POST("/posts/:id/unlike", handlers.UnlikePost)
+		mobileCommunity.GET("/posts/:id/comments", handlers.GetComments)
+		mobileCommunity.POST("/posts/:id/comments", handlers.CreateComment)
+		mobileCommunity.PATCH("/comments/:id", handlers.UpdateComment)
+		mobileCommunity.DELETE("/comments/:id", handlers.DeleteComment)
+		mobileCommunity.GET("/comments/:id/revisions", handlers.GetCommentRevisions)
+		mobileCommunity.POST("/posts/:id/report", handlers.ReportPost)
+		mobileCommunity.POST("/comments/:id/report", handlers.ReportComment)
+		mobileCommunity.GET("/topics", handlers.GetTrendingTopics)
+		mobileCommunity.GET("/topics/:tag", handlers.GetTopicPosts)
+		mobileCommunity.GET("/feed", handlers.GetFeed)
+		mobileCommunity.POST("/users/:wallet/follow", handlers.FollowUser)
+		mobileCommunity.POST("/users/:wallet/unfollow", handlers.UnfollowUser)
+		mobileCommunity.GET("/users/:wallet/followers", handlers.GetFollowers)
+		mobileCommunity.GET("/users/:wallet/following", handlers.GetFollowing)
+	}
 	router.GET("/balance/:address", handlers.MobileBalance)
 	router.POST("/topup", handlers.MobileTopUp)
 	router.GET("/assets/:address", handlers.GetUserAssets)
 	router.GET("/transactions/:address", handlers.GetUserTransactions)
-	router.POST("/invest", handlers.RecordInvestment)
+	router.POST("/invest", middleware.AuthMiddleware(), handlers.RecordInvestment)
 	router.POST("/relay/nft/mint", handlers.MobileMintNFT)
-	router.POST("/relay/nft/transfer", handlers.MobileTransferNFT)
+	router.POST("/relay/nft/transfer", middleware.AuthMiddleware(), handlers.MobileTransferNFT)
 	router.GET("/nft/default-address", handlers.MobileDefaultAddress)
+	router.GET("/nft/batch/:nftAddress/:tokenId/custody", handlers.GetNFTCustody)
 }
@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// settle(address nft, uint256 tokenId, address seller, address buyer, uint256 priceFen)
+const marketplaceSettleSignature = "settle(address,uint256,address,address,uint256)"
+
+// ErrSettlementReverted is returned when a settlement was mined but failed
+var ErrSettlementReverted = errors.New("settlement reverted")
+
+// MarketplaceEnabled reports whether on-chain settlement is configured
+func (c *Client) MarketplaceEnabled() bool {
+	return c != nil && c.Config.MarketplaceContract != ""
+}
+
+// SettleSale records a secondary sale on the marketplace contract, which
+// moves the NFT from seller to buyer. The price is passed in fen so the
+// contract can emit an auditable record of the off-chain payment.
+// It waits for the receipt and fails unless the outcome is successful. An
+// error with a non-empty hash means the transaction was sent: unless it
+// wraps ErrSettlementReverted its outcome is unknown.
+func (c *Client) SettleSale(nftAddress string, tokenID *big.Int, seller, buyer string, priceFen int64) (string, error) {
+	marketplace, err := c.ParseAddress(c.Config.MarketplaceContract)
+	if err != nil {
+		return "", err
+	}
+	nft, err := c.ParseAddress(nftAddress)
+	if err != nil {
+		return "", err
+	}
+	sellerAddr, err := c.ParseAddress(seller)
+	if err != nil {
+		return "", err
+	}
+	buyerAddr, err := c.ParseAddress(buyer)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := hex.DecodeString(functionSelector(marketplaceSettleSignature) +
+		encodeAddress(nft) +
+		encodeUint256(tokenID) +
+		encodeAddress(sellerAddr) +
+		encodeAddress(buyerAddr) +
+		encodeUint256(big.NewInt(priceFen)))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode calldata: %w", err)
+	}
+
+	txHash, err := c.SendTransaction(marketplace.String(), big.NewInt(0), data)
+	if err != nil {
+		return "", err
+	}
+
+	receipt, err := c.WaitForReceipt(txHash)
+	if err != nil {
+		return txHash, err
+	}
+	if !ReceiptSucceeded(receipt) {
+		return txHash, fmt.Errorf("%w: %s", ErrSettlementReverted, txHash)
+	}
+	return txHash, nil
+}
+
+// functionSelector returns the hex-encoded 4-byte selector of a signature
+func functionSelector(signature string) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signature))
+	return hex.EncodeToString(h.Sum(nil)[:4])
+}
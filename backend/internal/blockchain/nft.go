@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
+)
+
+// ERC-721 function selectors
+const (
+	selectorOwnerOf          = "6352211e" // ownerOf(uint256)
+	selectorSafeTransferFrom = "42842e0e" // safeTransferFrom(address,address,uint256)
+)
+
+// ParseAddress parses a base32 or hex address on the configured network
+func (c *Client) ParseAddress(address string) (cfxaddress.Address, error) {
+	addr, err := cfxaddress.New(address, c.Config.ConfluxNetworkID)
+	if err != nil {
+		return cfxaddress.Address{}, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	return addr, nil
+}
+
+// AddressForms returns the lowercase hex, base32 and verbose base32
+// spellings of an address, for matching wallet columns that hold any of them
+func AddressForms(addr cfxaddress.Address) []string {
+	return []string{
+		strings.ToLower(addr.GetHexAddress()),
+		strings.ToLower(addr.MustGetBase32Address()),
+		strings.ToLower(addr.MustGetVerboseBase32Address()),
+	}
+}
+
+// OwnerOf returns the current owner of an ERC-721 token as a hex address
+func (c *Client) OwnerOf(nftAddress string, tokenID *big.Int) (string, error) {
+	contract, err := c.ParseAddress(nftAddress)
+	if err != nil {
+		return "", err
+	}
+
+	data := "0x" + selectorOwnerOf + encodeUint256(tokenID)
+	result, err := c.SDK.Call(types.CallRequest{To: &contract, Data: &data}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to call ownerOf: %w", err)
+	}
+	if len(result) != 32 {
+		return "", fmt.Errorf("unexpected ownerOf result length %d", len(result))
+	}
+
+	return "0x" + hex.EncodeToString(result[12:]), nil
+}
+
+// IsOwner reports whether holder currently owns the token
+func (c *Client) IsOwner(nftAddress string, tokenID *big.Int, holder string) (bool, error) {
+	addr, err := c.ParseAddress(holder)
+	if err != nil {
+		return false, err
+	}
+
+	owner, err := c.OwnerOf(nftAddress, tokenID)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(owner, addr.GetHexAddress()), nil
+}
+
+// SafeTransferFrom relays safeTransferFrom(from, to, tokenId) from the
+// configured relayer account, which must be approved by the current owner
+func (c *Client) SafeTransferFrom(nftAddress, from, to string, tokenID *big.Int) (string, error) {
+	contract, err := c.ParseAddress(nftAddress)
+	if err != nil {
+		return "", err
+	}
+	fromAddr, err := c.ParseAddress(from)
+	if err != nil {
+		return "", err
+	}
+	toAddr, err := c.ParseAddress(to)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := hex.DecodeString(selectorSafeTransferFrom +
+		encodeAddress(fromAddr) +
+		encodeAddress(toAddr) +
+		encodeUint256(tokenID))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode calldata: %w", err)
+	}
+
+	return c.SendTransaction(contract.String(), big.NewInt(0), data)
+}
+
+// ReceiptSucceeded reports whether a receipt has a successful outcome
+func ReceiptSucceeded(receipt *types.TransactionReceipt) bool {
+	return receipt != nil && receipt.OutcomeStatus == 0
+}
+
+func encodeUint256(v *big.Int) string {
+	return fmt.Sprintf("%064x", v)
+}
+
+func encodeAddress(addr cfxaddress.Address) string {
+	h := strings.TrimPrefix(strings.ToLower(addr.GetHexAddress()), "0x")
+	return strings.Repeat("0", 64-len(h)) + h
+}
@@ -0,0 +1,183 @@
+package community
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Harvest day #Rice #rice #苹果 丰收", []string{"rice", "苹果"}},
+		{"#春耕#开始了 #organic_farming", []string{"春耕", "organic_farming"}},
+		{"issue#12 and #2024 and # alone", nil},
+		{"see https://example.com/#anchor", []string{"anchor"}},
+		{"##double", []string{"double"}},
+	}
+	for _, tt := range tests {
+		if got := ParseTags(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTags(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	for in, want := range map[string]string{"#Rice": "rice", "茶叶": "茶叶", "a b": "", "123": "", "": ""} {
+		if got := NormalizeTag(in); got != want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	addr := "0xAbCdEf0123456789abcdef0123456789ABCDEF01"
+	got := ParseMentions("@farmer_li thanks, cc @" + addr + " and @张三. mail me at li@example.com @farmer_li")
+	want := []string{"farmer_li", "0xabcdef0123456789abcdef0123456789abcdef01", "张三"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMentions = %v, want %v", got, want)
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	users := []models.User{
+		{WalletAddress: "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", Username: "li", Email: "li@example.com"},
+		{WalletAddress: "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", Username: "wang", Email: "w1@example.com"},
+		{WalletAddress: "0xcccccccccccccccccccccccccccccccccccccccc", Username: "wang", Email: "w2@example.com"},
+	}
+	for i := range users {
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ResolveMentions(db, []string{"li", "wang", "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "nobody"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint
+	for _, u := range got {
+		ids = append(ids, u.ID)
+	}
+	// "wang" is ambiguous and li's address repeats li
+	if want := []uint{users[0].ID, users[1].ID}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("resolved %v, want %v", ids, want)
+	}
+}
+
+func TestTrending(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	activity := []Activity{
+		{Tags: []string{"rice"}, At: now.Add(-48 * time.Hour)},
+		{Tags: []string{"rice"}, At: now.Add(-48 * time.Hour)},
+		{Tags: []string{"rice"}, At: now.Add(-48 * time.Hour), Comment: true},
+		{Tags: []string{"tea", "rice"}, At: now.Add(-time.Hour)},
+		{Tags: []string{"tea"}, At: now},
+	}
+
+	got := Trending(activity, now, 24*time.Hour, 10)
+	if len(got) != 2 || got[0].Tag != "tea" || got[1].Tag != "rice" {
+		t.Fatalf("ranking = %+v, want tea before rice", got)
+	}
+	// Three two-day-old items weigh 0.25 each, and the hour-old one almost 1
+	if got[1].Score < 1.7 || got[1].Score > 1.75 || got[1].Posts != 3 || got[1].Comments != 1 {
+		t.Errorf("rice = %+v", got[1])
+	}
+	if len(Trending(activity, now, 24*time.Hour, 1)) != 1 {
+		t.Error("limit not applied")
+	}
+}
+
+func TestHotScore(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	fresh := HotScore(0, 0, now)
+	if got := HotScore(10, 0, now) - fresh; math.Abs(got-1) > 1e-9 {
+		t.Errorf("ten likes add %v, want 1", got)
+	}
+	// A comment counts as two likes
+	if HotScore(0, 5, now) != HotScore(10, 0, now) {
+		t.Error("comments not weighted double")
+	}
+	// Ten times the engagement offsets twelve hours of age
+	if got := HotScore(10, 0, now.Add(-12*time.Hour)); math.Abs(got-fresh) > 1e-9 {
+		t.Errorf("older popular post = %v, want %v", got, fresh)
+	}
+	if HotScore(5, 0, now.Add(-24*time.Hour)) >= fresh {
+		t.Error("day-old post with few likes outranks a new one")
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 10, 12, 30, 15, 250e6, time.UTC)
+	for _, c := range []Cursor{
+		TimeCursor(at, "6650f0a1c2d3e4f5a6b7c8d9"),
+		{Key: HotScore(3, 1, at), ID: "6650f0a1c2d3e4f5a6b7c8da"},
+	} {
+		got, err := ParseCursor(c.String())
+		if err != nil || got != c {
+			t.Errorf("ParseCursor(%q) = %+v, %v; want %+v", c.String(), got, err, c)
+		}
+	}
+	if got, _ := ParseCursor(TimeCursor(at, "x").String()); !got.Time().Equal(at) {
+		t.Errorf("Time() = %v, want %v", got.Time(), at)
+	}
+	for _, bad := range []string{"", "!!", "bm9jdXJzb3I", "YWJjX3g"} {
+		if _, err := ParseCursor(bad); err != ErrInvalidCursor {
+			t.Errorf("ParseCursor(%q) err = %v", bad, err)
+		}
+	}
+}
+
+func TestUserForWallet(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, wallet := range []string{"", "alice", "0x123", "cfxtest:notanaddress", "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAG"} {
+		if _, err := UserForWallet(db, wallet); err != ErrInvalidWallet {
+			t.Errorf("UserForWallet(%q) err = %v, want ErrInvalidWallet", wallet, err)
+		}
+	}
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("created %d users for invalid wallets", count)
+	}
+
+	created, err := UserForWallet(db, "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := UserForWallet(db, "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	if err != nil || found.ID != created.ID {
+		t.Errorf("lookup ignoring case = %+v, %v, want user %d", found, err, created.ID)
+	}
+	if !ValidWallet("cfxtest:aarmzmzmzmzmzmzmzmzmzmzmzmzmzmzmzjz3jve8jt") {
+		t.Error("base32 address rejected")
+	}
+}
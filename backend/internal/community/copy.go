@@ -0,0 +1,178 @@
+package community
+
+import (
+	"context"
+	"log"
+
+	dbModels "conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportMongo copies the content of the MongoDB store into an empty MySQL
+// store, so switching COMMUNITY_STORE to mysql keeps existing posts,
+// comments, likes, follows, attachments and reports. It does nothing once
+// the MySQL store has content, and copies everything or nothing.
+func (r *SQLRepository) ImportMongo(ctx context.Context, src *MongoRepository) error {
+	db := r.DB.WithContext(ctx)
+	for _, model := range []interface{}{&dbModels.Post{}, &dbModels.Follow{}, &dbModels.Attachment{}} {
+		var count int64
+		if err := db.Model(model).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	counts := map[string]int{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		copies := []struct {
+			collection string
+			copy       func(cursor *mongo.Cursor) error
+		}{
+			{"posts", func(cursor *mongo.Cursor) error {
+				var doc models.Post
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				post := postFromDoc(doc)
+				row := postRow(&post)
+				if err := tx.Omit(clause.Associations).Create(&row).Error; err != nil {
+					return err
+				}
+				if err := insertPostTags(tx, post.ID, post.Tags, post.CreatedAt); err != nil {
+					return err
+				}
+				return createRevisions(tx, KindPost, post.ID, doc.Revisions)
+			}},
+			{"comments", func(cursor *mongo.Cursor) error {
+				var doc models.Comment
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				comment := commentFromDoc(doc)
+				row := commentRow(&comment)
+				if err := tx.Omit(clause.Associations).Create(&row).Error; err != nil {
+					return err
+				}
+				return createRevisions(tx, KindComment, comment.ID, doc.Revisions)
+			}},
+			{"post_likes", func(cursor *mongo.Cursor) error {
+				var doc models.PostLike
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				return tx.Omit(clause.Associations).Create(&dbModels.PostLike{
+					PostID:    doc.PostID.Hex(),
+					UserID:    doc.UserID,
+					CreatedAt: doc.CreatedAt,
+					DeletedAt: doc.DeletedAt,
+				}).Error
+			}},
+			{"follows", func(cursor *mongo.Cursor) error {
+				var doc models.Follow
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				return tx.Omit(clause.Associations).Create(&dbModels.Follow{
+					ID:         doc.ID.Hex(),
+					FollowerID: doc.FollowerID,
+					FolloweeID: doc.FolloweeID,
+					CreatedAt:  doc.CreatedAt,
+				}).Error
+			}},
+			{"attachments", func(cursor *mongo.Cursor) error {
+				var doc models.Attachment
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				row := dbModels.Attachment{
+					ID:             doc.ID.Hex(),
+					UploaderWallet: doc.UploaderWallet,
+					ContentType:    doc.ContentType,
+					Key:            doc.Key,
+					ThumbnailKey:   doc.ThumbnailKey,
+					Size:           doc.Size,
+					Width:          doc.Width,
+					Height:         doc.Height,
+					CreatedAt:      doc.CreatedAt,
+				}
+				if doc.PostID != nil {
+					postID := doc.PostID.Hex()
+					row.PostID = &postID
+				}
+				return tx.Create(&row).Error
+			}},
+			{"reports", func(cursor *mongo.Cursor) error {
+				var doc models.Report
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				report := reportFromDoc(doc)
+				return tx.Create(&dbModels.ContentReport{
+					ID:             report.ID,
+					TargetType:     report.TargetType,
+					TargetID:       report.TargetID,
+					ReporterWallet: report.ReporterWallet,
+					Reason:         report.Reason,
+					Note:           report.Note,
+					Status:         report.Status,
+					Resolution:     report.Resolution,
+					ResolvedBy:     report.ResolvedBy,
+					ResolvedAt:     report.ResolvedAt,
+					CreatedAt:      report.CreatedAt,
+				}).Error
+			}},
+		}
+
+		for _, c := range copies {
+			cursor, err := src.DB.Collection(c.collection).Find(ctx, bson.M{})
+			if err != nil {
+				return err
+			}
+			for cursor.Next(ctx) {
+				if err := c.copy(cursor); err != nil {
+					cursor.Close(ctx)
+					return err
+				}
+				counts[c.collection]++
+			}
+			err = cursor.Err()
+			cursor.Close(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if counts["posts"]+counts["follows"]+counts["attachments"] > 0 {
+		log.Printf("Copied %d posts, %d comments, %d likes, %d follows, %d attachments and %d reports from MongoDB",
+			counts["posts"], counts["comments"], counts["post_likes"], counts["follows"], counts["attachments"], counts["reports"])
+	}
+	return nil
+}
+
+// createRevisions stores the embedded revisions of a post or comment,
+// oldest first
+func createRevisions(tx *gorm.DB, kind, id string, revisions []models.Revision) error {
+	for _, rev := range revisions {
+		if err := tx.Create(&dbModels.ContentRevision{
+			TargetType: kind,
+			TargetID:   id,
+			Content:    rev.Content,
+			EditedAt:   rev.EditedAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
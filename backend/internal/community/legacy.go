@@ -0,0 +1,100 @@
+package community
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/moderation"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gorm.io/gorm"
+)
+
+// legacyPost is a row of the MySQL posts table from before community
+// content moved behind a repository
+type legacyPost struct {
+	ID            uint
+	UserID        uint
+	Content       string
+	LikesCount    int
+	CommentsCount int
+	CreatedAt     time.Time
+}
+
+// legacyPostsTable returns the table holding legacy posts, or "" if there
+// is none: posts while it predates the MySQL store's table of that name, or
+// legacy_posts once the MySQL store has moved it aside
+func legacyPostsTable(db *gorm.DB) string {
+	m := db.Migrator()
+	if m.HasTable("legacy_posts") {
+		return "legacy_posts"
+	}
+	if m.HasTable("posts") && !m.HasColumn(&models.Post{}, "wallet_address") {
+		return "posts"
+	}
+	return ""
+}
+
+// importLegacyPosts copies the legacy posts into r, linked to their
+// authors, and drops their table. A post's ID is derived from its row, so
+// an import cut short resumes without duplicates.
+func importLegacyPosts(ctx context.Context, db *gorm.DB, r Repository) error {
+	table := legacyPostsTable(db)
+	if table == "" {
+		return nil
+	}
+
+	var rows []legacyPost
+	if err := db.WithContext(ctx).Table(table).Order("id ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	imported := 0
+	for _, row := range rows {
+		var id primitive.ObjectID
+		binary.BigEndian.PutUint32(id[:4], uint32(row.CreatedAt.Unix()))
+		binary.BigEndian.PutUint64(id[4:], uint64(row.ID))
+		_, err := r.GetPost(ctx, id.Hex())
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		var user models.User
+		err = db.WithContext(ctx).Select("id", "wallet_address", "username").First(&user, row.UserID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Skipped legacy post %d of missing user %d", row.ID, row.UserID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := r.CreatePost(ctx, &Post{
+			ID:            id.Hex(),
+			UserID:        user.ID,
+			Username:      user.Username,
+			WalletAddress: user.WalletAddress,
+			Content:       row.Content,
+			LikesCount:    row.LikesCount,
+			CommentsCount: row.CommentsCount,
+			Status:        moderation.StatusVisible,
+			CreatedAt:     row.CreatedAt,
+		}); err != nil {
+			return err
+		}
+		imported++
+	}
+
+	if err := db.Migrator().DropTable(table); err != nil {
+		return err
+	}
+	log.Printf("Imported %d legacy posts", imported)
+	return nil
+}
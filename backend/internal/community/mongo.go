@@ -0,0 +1,1309 @@
+package community
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"conflux-demo/backend/internal/moderation"
+	"conflux-demo/backend/internal/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRepository keeps community content in the posts, comments,
+// post_likes, follows, attachments and reports collections. Revisions are
+// embedded in their post or comment.
+type MongoRepository struct {
+	DB *mongo.Database
+}
+
+// NewMongoRepository returns a repository on db
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	return &MongoRepository{DB: db}
+}
+
+// visibleStatus matches visible content, including content stored before
+// it had a status
+var visibleStatus = bson.M{"$in": bson.A{moderation.StatusVisible, nil}}
+
+// objectID parses a repository ID. IDs that NewID cannot have made match
+// nothing.
+func objectID(id string) (primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, ErrNotFound
+	}
+	return oid, nil
+}
+
+// afterCursor narrows filter to the documents that come after key and id
+// when sorted by field and then _id, descending unless asc
+func afterCursor(filter bson.M, field string, key interface{}, id string, asc bool) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	op := "$lt"
+	if asc {
+		op = "$gt"
+	}
+	filter["$or"] = bson.A{
+		bson.M{field: bson.M{op: key}},
+		bson.M{field: key, "_id": bson.M{op: oid}},
+	}
+	return nil
+}
+
+func findOptions(field string, order, limit int) *options.FindOptions {
+	opts := options.Find().SetSort(bson.D{{Key: field, Value: order}, {Key: "_id", Value: order}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	return opts
+}
+
+// CreatePost implements Repository
+func (r *MongoRepository) CreatePost(ctx context.Context, post *Post) error {
+	preparePost(post)
+	doc, err := postDoc(post)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.Collection("posts").InsertOne(ctx, doc)
+	return err
+}
+
+// GetPost implements Repository
+func (r *MongoRepository) GetPost(ctx context.Context, id string) (*Post, error) {
+	oid, err := objectID(id)
+	if err != nil {
+		return nil, err
+	}
+	var doc models.Post
+	if err := r.DB.Collection("posts").FindOne(ctx, bson.M{"_id": oid}).Decode(&doc); err != nil {
+		return nil, noDocument(err)
+	}
+	post := postFromDoc(doc)
+	return &post, nil
+}
+
+// ListPosts implements Repository
+func (r *MongoRepository) ListPosts(ctx context.Context, q PostQuery) ([]Post, error) {
+	if q.Authors != nil && len(q.Authors) == 0 {
+		return nil, nil
+	}
+	filter := bson.M{"deleted_at": nil, "status": visibleStatus}
+	if q.Tag != "" {
+		filter["tags"] = q.Tag
+	}
+	if q.Authors != nil {
+		filter["user_id"] = bson.M{"$in": q.Authors}
+	}
+
+	field := "created_at"
+	if q.Sort == SortHot {
+		field = "hot_score"
+	}
+	if q.After != nil {
+		var key interface{} = q.After.Key
+		if field == "created_at" {
+			key = q.After.Time()
+		}
+		if err := afterCursor(filter, field, key, q.After.ID, false); err != nil {
+			return nil, err
+		}
+	}
+
+	cursor, err := r.DB.Collection("posts").Find(ctx, filter, findOptions(field, -1, q.Limit))
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.Post
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	posts := make([]Post, 0, len(docs))
+	for _, doc := range docs {
+		posts = append(posts, postFromDoc(doc))
+	}
+	return posts, nil
+}
+
+// EditPost implements Repository
+func (r *MongoRepository) EditPost(ctx context.Context, id, previous string, e Edit) error {
+	set := editSet(e)
+	set["updated_at"] = stamp(e.At)
+	return r.edit(ctx, "posts", id, previous, e, set)
+}
+
+func editSet(e Edit) bson.M {
+	set := bson.M{"content": e.Content, "tags": e.Tags, "mentions": mentionDocs(e.Mentions), "edited_at": stamp(e.At)}
+	if e.Hold {
+		set["status"] = moderation.StatusPendingReview
+		set["moderation.flagged_terms"] = e.FlaggedTerms
+	}
+	return set
+}
+
+// edit applies an edit guarded on the current content, so concurrent edits
+// cannot lose a revision
+func (r *MongoRepository) edit(ctx context.Context, collection, id, previous string, e Edit, set bson.M) error {
+	oid, err := objectID(id)
+	if err != nil {
+		return err
+	}
+	result, err := r.DB.Collection(collection).UpdateOne(ctx,
+		bson.M{"_id": oid, "content": previous, "deleted_at": nil},
+		bson.M{
+			"$set":  set,
+			"$push": bson.M{"revisions": models.Revision{Content: previous, EditedAt: stamp(e.At)}},
+		})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// DeletePost implements Repository
+func (r *MongoRepository) DeletePost(ctx context.Context, id string, at time.Time) (comments, likes int64, err error) {
+	oid, err := objectID(id)
+	if err != nil {
+		return 0, 0, err
+	}
+	at = stamp(at)
+	result, err := r.DB.Collection("posts").UpdateOne(ctx,
+		bson.M{"_id": oid, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": at, "updated_at": at}})
+	if err != nil {
+		return 0, 0, err
+	}
+	if result.ModifiedCount == 0 {
+		return 0, 0, ErrNotFound
+	}
+
+	cascade := bson.M{"post_id": oid, "deleted_at": nil}
+	set := bson.M{"$set": bson.M{"deleted_at": at}}
+	deletedComments, err := r.DB.Collection("comments").UpdateMany(ctx, cascade, set)
+	if err != nil {
+		return 0, 0, err
+	}
+	deletedLikes, err := r.DB.Collection("post_likes").UpdateMany(ctx, cascade, set)
+	if err != nil {
+		return deletedComments.ModifiedCount, 0, err
+	}
+	return deletedComments.ModifiedCount, deletedLikes.ModifiedCount, nil
+}
+
+// PostRevisions implements Repository
+func (r *MongoRepository) PostRevisions(ctx context.Context, id string) ([]Revision, error) {
+	return r.revisions(ctx, "posts", id)
+}
+
+func (r *MongoRepository) revisions(ctx context.Context, collection, id string) ([]Revision, error) {
+	oid, err := objectID(id)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Revisions []models.Revision `bson:"revisions"`
+	}
+	if err := r.DB.Collection(collection).FindOne(ctx, bson.M{"_id": oid},
+		options.FindOne().SetProjection(bson.M{"revisions": 1})).Decode(&doc); err != nil {
+		return nil, noDocument(err)
+	}
+	revisions := make([]Revision, 0, len(doc.Revisions))
+	for _, rev := range doc.Revisions {
+		revisions = append(revisions, Revision{Content: rev.Content, EditedAt: rev.EditedAt})
+	}
+	return revisions, nil
+}
+
+// SetLike implements Repository. The like count only moves when the like
+// actually changed, so it stays consistent.
+func (r *MongoRepository) SetLike(ctx context.Context, postID string, userID uint, like bool) (bool, int, error) {
+	oid, err := objectID(postID)
+	if err != nil {
+		return false, 0, err
+	}
+
+	likes := r.DB.Collection("post_likes")
+	delta := 0
+	if like {
+		_, err := likes.InsertOne(ctx, models.PostLike{PostID: oid, UserID: userID, CreatedAt: stamp(time.Now())})
+		switch {
+		case err == nil:
+			delta = 1
+		case mongo.IsDuplicateKeyError(err):
+			// Already liked
+		default:
+			return false, 0, err
+		}
+	} else {
+		result, err := likes.DeleteOne(ctx, bson.M{"post_id": oid, "user_id": userID})
+		if err != nil {
+			return false, 0, err
+		}
+		if result.DeletedCount > 0 {
+			delta = -1
+		}
+	}
+
+	if delta != 0 {
+		filter := bson.M{"_id": oid}
+		if delta < 0 {
+			filter["likes_count"] = bson.M{"$gt": 0}
+		}
+		if _, err := r.DB.Collection("posts").UpdateOne(ctx, filter, bson.M{
+			"$inc": bson.M{"likes_count": delta},
+			"$set": bson.M{"updated_at": stamp(time.Now())},
+		}); err != nil {
+			return false, 0, err
+		}
+	}
+	post, err := r.refreshHotScore(ctx, oid)
+	if err != nil {
+		return false, 0, err
+	}
+	return delta != 0, post.LikesCount, nil
+}
+
+// refreshHotScore recomputes a post's hot score after its counts changed
+// and returns the post's counts. The score is only written if the counts
+// are still those it was computed from; a concurrent change refreshes it
+// again itself.
+func (r *MongoRepository) refreshHotScore(ctx context.Context, postID primitive.ObjectID) (*models.Post, error) {
+	posts := r.DB.Collection("posts")
+	var post models.Post
+	err := posts.FindOne(ctx, bson.M{"_id": postID},
+		options.FindOne().SetProjection(bson.M{"likes_count": 1, "comments_count": 1, "created_at": 1})).Decode(&post)
+	if err != nil {
+		return nil, noDocument(err)
+	}
+	_, err = posts.UpdateOne(ctx,
+		bson.M{"_id": postID, "likes_count": post.LikesCount, "comments_count": post.CommentsCount},
+		bson.M{"$set": bson.M{"hot_score": HotScore(post.LikesCount, post.CommentsCount, post.CreatedAt)}})
+	return &post, err
+}
+
+// LikedPosts implements Repository
+func (r *MongoRepository) LikedPosts(ctx context.Context, userID uint, postIDs []string) (map[string]bool, error) {
+	liked := map[string]bool{}
+	if userID == 0 || len(postIDs) == 0 {
+		return liked, nil
+	}
+	oids := make([]primitive.ObjectID, 0, len(postIDs))
+	for _, id := range postIDs {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+
+	cursor, err := r.DB.Collection("post_likes").Find(ctx, bson.M{
+		"user_id":    userID,
+		"post_id":    bson.M{"$in": oids},
+		"deleted_at": nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var likes []models.PostLike
+	if err := cursor.All(ctx, &likes); err != nil {
+		return nil, err
+	}
+	for _, l := range likes {
+		liked[l.PostID.Hex()] = true
+	}
+	return liked, nil
+}
+
+// CreateComment implements Repository
+func (r *MongoRepository) CreateComment(ctx context.Context, comment *Comment) error {
+	prepareComment(comment)
+	doc, err := commentDoc(comment)
+	if err != nil {
+		return err
+	}
+	if _, err := r.DB.Collection("comments").InsertOne(ctx, doc); err != nil {
+		return err
+	}
+
+	if doc.ParentID != nil {
+		if _, err := r.DB.Collection("comments").UpdateByID(ctx, *doc.ParentID,
+			bson.M{"$inc": bson.M{"replies_count": 1}}); err != nil {
+			return err
+		}
+	}
+	if _, err := r.DB.Collection("posts").UpdateByID(ctx, doc.PostID, bson.M{
+		"$inc": bson.M{"comments_count": 1},
+		"$set": bson.M{"updated_at": doc.CreatedAt},
+	}); err != nil {
+		return err
+	}
+	_, err = r.refreshHotScore(ctx, doc.PostID)
+	return err
+}
+
+// GetComment implements Repository
+func (r *MongoRepository) GetComment(ctx context.Context, id string) (*Comment, error) {
+	oid, err := objectID(id)
+	if err != nil {
+		return nil, err
+	}
+	var doc models.Comment
+	if err := r.DB.Collection("comments").FindOne(ctx, bson.M{"_id": oid}).Decode(&doc); err != nil {
+		return nil, noDocument(err)
+	}
+	comment := commentFromDoc(doc)
+	return &comment, nil
+}
+
+// ListComments implements Repository
+func (r *MongoRepository) ListComments(ctx context.Context, q CommentQuery) ([]Comment, int64, error) {
+	postID, err := objectID(q.PostID)
+	if err != nil {
+		return nil, 0, nil
+	}
+	filter := bson.M{"post_id": postID, "parent_id": nil}
+	if q.ParentID != "" {
+		parentID, err := objectID(q.ParentID)
+		if err != nil {
+			return nil, 0, nil
+		}
+		filter["parent_id"] = parentID
+	}
+
+	collection := r.DB.Collection("comments")
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q.After != nil {
+		if err := afterCursor(filter, "created_at", q.After.Time(), q.After.ID, true); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	cursor, err := collection.Find(ctx, filter, findOptions("created_at", 1, q.Limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	var docs []models.Comment
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+	comments := make([]Comment, 0, len(docs))
+	for _, doc := range docs {
+		comments = append(comments, commentFromDoc(doc))
+	}
+	return comments, total, nil
+}
+
+// EditComment implements Repository
+func (r *MongoRepository) EditComment(ctx context.Context, id, previous string, e Edit) error {
+	return r.edit(ctx, "comments", id, previous, e, editSet(e))
+}
+
+// DeleteComment implements Repository. Only the request that sets
+// deleted_at adjusts the counters.
+func (r *MongoRepository) DeleteComment(ctx context.Context, id string, at time.Time) (bool, error) {
+	comment, err := r.GetComment(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	doc, err := commentDoc(comment)
+	if err != nil {
+		return false, err
+	}
+
+	at = stamp(at)
+	comments := r.DB.Collection("comments")
+	result, err := comments.UpdateOne(ctx,
+		bson.M{"_id": doc.ID, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": at}})
+	if err != nil || result.ModifiedCount == 0 {
+		return false, err
+	}
+
+	if doc.ParentID != nil {
+		if _, err := comments.UpdateOne(ctx,
+			bson.M{"_id": *doc.ParentID, "replies_count": bson.M{"$gt": 0}},
+			bson.M{"$inc": bson.M{"replies_count": -1}}); err != nil {
+			return true, err
+		}
+	}
+	if _, err := r.DB.Collection("posts").UpdateOne(ctx,
+		bson.M{"_id": doc.PostID, "comments_count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"comments_count": -1}, "$set": bson.M{"updated_at": at}}); err != nil {
+		return true, err
+	}
+	_, err = r.refreshHotScore(ctx, doc.PostID)
+	return true, err
+}
+
+// CommentRevisions implements Repository
+func (r *MongoRepository) CommentRevisions(ctx context.Context, id string) ([]Revision, error) {
+	return r.revisions(ctx, "comments", id)
+}
+
+// SetFollow implements Repository
+func (r *MongoRepository) SetFollow(ctx context.Context, followerID, followeeID uint, follow bool) (bool, int64, error) {
+	follows := r.DB.Collection("follows")
+	changed := false
+	if follow {
+		_, err := follows.InsertOne(ctx, models.Follow{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+			CreatedAt:  stamp(time.Now()),
+		})
+		switch {
+		case err == nil:
+			changed = true
+		case mongo.IsDuplicateKeyError(err):
+			// Already following
+		default:
+			return false, 0, err
+		}
+	} else {
+		result, err := follows.DeleteOne(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID})
+		if err != nil {
+			return false, 0, err
+		}
+		changed = result.DeletedCount > 0
+	}
+
+	followers, err := follows.CountDocuments(ctx, bson.M{"followee_id": followeeID})
+	if err != nil {
+		return false, 0, err
+	}
+	return changed, followers, nil
+}
+
+// Followees implements Repository
+func (r *MongoRepository) Followees(ctx context.Context, userID uint, limit int) ([]uint, error) {
+	cursor, err := r.DB.Collection("follows").Find(ctx, bson.M{"follower_id": userID},
+		findOptions("created_at", -1, limit).SetProjection(bson.M{"followee_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.Follow
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(docs))
+	for _, f := range docs {
+		ids = append(ids, f.FolloweeID)
+	}
+	return ids, nil
+}
+
+// ListFollows implements Repository
+func (r *MongoRepository) ListFollows(ctx context.Context, q FollowQuery) ([]Follow, int64, error) {
+	filter := bson.M{"follower_id": q.UserID}
+	if q.Followers {
+		filter = bson.M{"followee_id": q.UserID}
+	}
+
+	collection := r.DB.Collection("follows")
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q.After != nil {
+		if err := afterCursor(filter, "created_at", q.After.Time(), q.After.ID, false); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	cursor, err := collection.Find(ctx, filter, findOptions("created_at", -1, q.Limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	var docs []models.Follow
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+	follows := make([]Follow, 0, len(docs))
+	for _, f := range docs {
+		follows = append(follows, Follow{ID: f.ID.Hex(), FollowerID: f.FollowerID, FolloweeID: f.FolloweeID, CreatedAt: f.CreatedAt})
+	}
+	return follows, total, nil
+}
+
+// contentCollection returns the collection of a kind of content
+func contentCollection(kind string) (string, error) {
+	switch kind {
+	case KindPost:
+		return "posts", nil
+	case KindComment:
+		return "comments", nil
+	}
+	return "", ErrNotFound
+}
+
+// CreateAttachment implements Repository
+func (r *MongoRepository) CreateAttachment(ctx context.Context, a *Attachment) error {
+	if a.ID == "" {
+		a.ID = NewID()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	a.CreatedAt = stamp(a.CreatedAt)
+	oid, err := primitive.ObjectIDFromHex(a.ID)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.Collection("attachments").InsertOne(ctx, models.Attachment{
+		ID:             oid,
+		UploaderWallet: a.UploaderWallet,
+		ContentType:    a.ContentType,
+		Key:            a.Key,
+		ThumbnailKey:   a.ThumbnailKey,
+		Size:           a.Size,
+		Width:          a.Width,
+		Height:         a.Height,
+		CreatedAt:      a.CreatedAt,
+	})
+	return err
+}
+
+// ClaimAttachments implements Repository
+func (r *MongoRepository) ClaimAttachments(ctx context.Context, wallet, postID string, ids []string) ([]Attachment, error) {
+	postOID, err := objectID(postID)
+	if err != nil {
+		return nil, err
+	}
+	oids := make([]primitive.ObjectID, 0, len(ids))
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, ErrAttachmentUnavailable
+		}
+		if !seen[oid] {
+			seen[oid] = true
+			oids = append(oids, oid)
+		}
+	}
+	if len(oids) == 0 {
+		return nil, nil
+	}
+
+	attachments := r.DB.Collection("attachments")
+	result, err := attachments.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": oids}, "uploader_wallet": wallet, "post_id": nil},
+		bson.M{"$set": bson.M{"post_id": postOID}})
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount != int64(len(oids)) {
+		if err := r.ReleaseAttachments(ctx, postID); err != nil {
+			return nil, err
+		}
+		return nil, ErrAttachmentUnavailable
+	}
+
+	cursor, err := attachments.Find(ctx, bson.M{"_id": bson.M{"$in": oids}})
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.Attachment
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	byID := map[primitive.ObjectID]models.Attachment{}
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	claimed := make([]Attachment, 0, len(oids))
+	for _, oid := range oids {
+		claimed = append(claimed, attachmentFromDoc(byID[oid]))
+	}
+	return claimed, nil
+}
+
+// ReleaseAttachments implements Repository
+func (r *MongoRepository) ReleaseAttachments(ctx context.Context, postID string) error {
+	oid, err := objectID(postID)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.Collection("attachments").UpdateMany(ctx,
+		bson.M{"post_id": oid}, bson.M{"$unset": bson.M{"post_id": ""}})
+	return err
+}
+
+// CreateReport implements Repository. A report that cannot be counted is
+// removed again, so it can be retried.
+func (r *MongoRepository) CreateReport(ctx context.Context, report *Report, threshold int) (bool, error) {
+	if _, err := contentCollection(report.TargetType); err != nil {
+		return false, err
+	}
+	targetID, err := objectID(report.TargetID)
+	if err != nil {
+		return false, err
+	}
+	if report.ID == "" {
+		report.ID = NewID()
+	}
+	oid, err := primitive.ObjectIDFromHex(report.ID)
+	if err != nil {
+		return false, err
+	}
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	report.CreatedAt = stamp(report.CreatedAt)
+	report.Status = ReportOpen
+
+	reports := r.DB.Collection("reports")
+	_, err = reports.InsertOne(ctx, models.Report{
+		ID:             oid,
+		TargetType:     report.TargetType,
+		TargetID:       targetID,
+		ReporterWallet: report.ReporterWallet,
+		Reason:         report.Reason,
+		Note:           report.Note,
+		Status:         report.Status,
+		CreatedAt:      report.CreatedAt,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, ErrAlreadyReported
+	}
+	if err != nil {
+		return false, err
+	}
+
+	held, err := r.countReport(ctx, report.TargetType, targetID, threshold)
+	if err != nil {
+		if _, delErr := reports.DeleteOne(ctx, bson.M{"_id": oid}); delErr != nil {
+			log.Printf("Failed to remove uncounted report %s: %v", report.ID, delErr)
+		}
+		return false, err
+	}
+	return held, nil
+}
+
+// OpenReports implements Repository
+func (r *MongoRepository) OpenReports(ctx context.Context, targetID string) ([]Report, error) {
+	oid, err := objectID(targetID)
+	if err != nil {
+		return []Report{}, nil
+	}
+	return r.findReports(ctx, bson.M{"target_id": oid, "status": ReportOpen}, 0)
+}
+
+// ListReports implements Repository
+func (r *MongoRepository) ListReports(ctx context.Context, status string, limit int) ([]Report, error) {
+	return r.findReports(ctx, bson.M{"status": status}, limit)
+}
+
+func (r *MongoRepository) findReports(ctx context.Context, filter bson.M, limit int) ([]Report, error) {
+	cursor, err := r.DB.Collection("reports").Find(ctx, filter, findOptions("created_at", 1, limit))
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.Report
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	reports := make([]Report, 0, len(docs))
+	for _, doc := range docs {
+		reports = append(reports, reportFromDoc(doc))
+	}
+	return reports, nil
+}
+
+// ResolveReports implements Repository
+func (r *MongoRepository) ResolveReports(ctx context.Context, targetID string, review Review) (int64, error) {
+	oid, err := objectID(targetID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := r.DB.Collection("reports").UpdateMany(ctx,
+		bson.M{"target_id": oid, "status": ReportOpen},
+		bson.M{"$set": bson.M{
+			"status":      ReportResolved,
+			"resolution":  review.Status,
+			"resolved_by": review.By,
+			"resolved_at": stamp(review.At),
+		}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// countReport counts an open report on live content and holds visible
+// content for review once it has threshold open reports
+func (r *MongoRepository) countReport(ctx context.Context, kind string, oid primitive.ObjectID, threshold int) (bool, error) {
+	name, err := contentCollection(kind)
+	if err != nil {
+		return false, err
+	}
+
+	targets := r.DB.Collection(name)
+	var target struct {
+		Moderation models.Moderation `bson:"moderation"`
+	}
+	err = targets.FindOneAndUpdate(ctx,
+		bson.M{"_id": oid, "deleted_at": nil},
+		bson.M{"$inc": bson.M{"moderation.open_reports": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&target)
+	if err != nil {
+		return false, noDocument(err)
+	}
+	if target.Moderation.OpenReports < threshold {
+		return false, nil
+	}
+	result, err := targets.UpdateOne(ctx,
+		bson.M{"_id": oid, "status": visibleStatus},
+		bson.M{"$set": bson.M{"status": moderation.StatusPendingReview}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// Review implements Repository
+func (r *MongoRepository) Review(ctx context.Context, kind, id string, review Review) (string, error) {
+	name, err := contentCollection(kind)
+	if err != nil {
+		return "", err
+	}
+	oid, err := objectID(id)
+	if err != nil {
+		return "", err
+	}
+
+	var before struct {
+		Status string `bson:"status"`
+	}
+	err = r.DB.Collection(name).FindOneAndUpdate(ctx,
+		bson.M{"_id": oid, "deleted_at": nil},
+		bson.M{"$set": bson.M{
+			"status":                  review.Status,
+			"moderation.open_reports": 0,
+			"moderation.reviewed_by":  review.By,
+			"moderation.review_note":  review.Note,
+			"moderation.reviewed_at":  stamp(review.At),
+		}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"status": 1})).Decode(&before)
+	if err != nil {
+		return "", noDocument(err)
+	}
+	return before.Status, nil
+}
+
+func heldFilter() bson.M {
+	return bson.M{"status": moderation.StatusPendingReview, "deleted_at": nil}
+}
+
+// HeldPosts implements Repository
+func (r *MongoRepository) HeldPosts(ctx context.Context, limit int) ([]Post, error) {
+	cursor, err := r.DB.Collection("posts").Find(ctx, heldFilter(), findOptions("created_at", 1, limit))
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.Post
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	posts := make([]Post, 0, len(docs))
+	for _, doc := range docs {
+		posts = append(posts, postFromDoc(doc))
+	}
+	return posts, nil
+}
+
+// HeldComments implements Repository
+func (r *MongoRepository) HeldComments(ctx context.Context, limit int) ([]Comment, error) {
+	cursor, err := r.DB.Collection("comments").Find(ctx, heldFilter(), findOptions("created_at", 1, limit))
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.Comment
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	comments := make([]Comment, 0, len(docs))
+	for _, doc := range docs {
+		comments = append(comments, commentFromDoc(doc))
+	}
+	return comments, nil
+}
+
+// TagActivity implements Repository
+func (r *MongoRepository) TagActivity(ctx context.Context, since time.Time) ([]Activity, error) {
+	filter := bson.M{
+		"tags":       bson.M{"$exists": true, "$ne": bson.A{}},
+		"created_at": bson.M{"$gte": since},
+		"deleted_at": nil,
+		"status":     visibleStatus,
+	}
+	opts := options.Find().SetProjection(bson.M{"tags": 1, "created_at": 1})
+
+	var activity []Activity
+	for _, name := range []string{"posts", "comments"} {
+		cursor, err := r.DB.Collection(name).Find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		var docs []struct {
+			Tags      []string  `bson:"tags"`
+			CreatedAt time.Time `bson:"created_at"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return nil, err
+		}
+		for _, d := range docs {
+			activity = append(activity, Activity{Tags: d.Tags, At: d.CreatedAt, Comment: name == "comments"})
+		}
+	}
+	return activity, nil
+}
+
+// EnsureIndexes creates the indexes the community collections rely on
+func (r *MongoRepository) EnsureIndexes(ctx context.Context) error {
+	// One like per user and post
+	_, err := r.DB.Collection("post_likes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Top-level comments and replies of a post, oldest first
+	_, err = r.DB.Collection("comments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One follow per pair, a user's followers and whom they follow
+	_, err = r.DB.Collection("follows").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "followee_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Cursor pages of the post listings: newest, hot and a home feed
+	_, err = r.DB.Collection("posts").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "hot_score", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One report per wallet and target, and the open reports of the review
+	// queue
+	_, err = r.DB.Collection("reports").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "target_id", Value: 1}, {Key: "reporter_wallet", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Attachments claimed by a post
+	_, err = r.DB.Collection("attachments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "post_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"posts", "comments"} {
+		_, err = r.DB.Collection(name).Indexes().CreateMany(ctx, []mongo.IndexModel{
+			// Review queue of held posts and comments
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+			// Topic feeds and trending topics
+			{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "created_at", Value: -1}}},
+			// Content mentioning a user
+			{Keys: bson.D{{Key: "mentions.user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate brings documents written before posts were linked to users up
+// to date, then creates the indexes. Authors, likers and follows stored by
+// wallet address get the user ID resolve returns for it, and posts stored
+// before hot ranking get their hot score.
+func (r *MongoRepository) Migrate(ctx context.Context, resolve func(wallet string) (uint, error)) error {
+	ids := map[string]uint{}
+	userID := func(wallet string) (uint, error) {
+		if id, ok := ids[wallet]; ok {
+			return id, nil
+		}
+		id, err := resolve(wallet)
+		if err != nil {
+			return 0, err
+		}
+		ids[wallet] = id
+		return id, nil
+	}
+
+	// The unique index on wallets would reject follows once converted
+	follows := r.DB.Collection("follows")
+	if _, err := follows.Indexes().DropOne(ctx, "follower_wallet_1_followee_wallet_1"); err != nil {
+		var cmdErr mongo.CommandError
+		if !errors.As(err, &cmdErr) || (cmdErr.Name != "IndexNotFound" && cmdErr.Name != "NamespaceNotFound") {
+			return err
+		}
+	}
+
+	byWallet := bson.M{"user_id": bson.M{"$type": "string"}}
+	for _, name := range []string{"posts", "comments", "post_likes"} {
+		linked, err := r.linkWallets(ctx, name, byWallet, func(doc bson.M) (bson.M, error) {
+			wallet, _ := doc["user_id"].(string)
+			id, err := userID(wallet)
+			if err != nil {
+				return nil, err
+			}
+			set := bson.M{"user_id": id}
+			if name == "comments" {
+				set["wallet_address"] = wallet
+			}
+			return bson.M{"$set": set}, nil
+		})
+		if err != nil {
+			return err
+		}
+		if linked > 0 {
+			log.Printf("Linked %d %s to users", linked, name)
+		}
+	}
+
+	linked, err := r.linkWallets(ctx, "follows", bson.M{"follower_wallet": bson.M{"$exists": true}}, func(doc bson.M) (bson.M, error) {
+		follower, _ := doc["follower_wallet"].(string)
+		followee, _ := doc["followee_wallet"].(string)
+		followerID, err := userID(follower)
+		if err != nil {
+			return nil, err
+		}
+		followeeID, err := userID(followee)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{
+			"$set":   bson.M{"follower_id": followerID, "followee_id": followeeID},
+			"$unset": bson.M{"follower_wallet": "", "followee_wallet": ""},
+		}, nil
+	})
+	if err != nil {
+		return err
+	}
+	if linked > 0 {
+		log.Printf("Linked %d follows to users", linked)
+	}
+
+	if err := r.backfillHotScores(ctx); err != nil {
+		return err
+	}
+	return r.EnsureIndexes(ctx)
+}
+
+// linkWallets applies update to each document matching filter. Documents
+// that would duplicate another once linked, such as two likes by wallets
+// differing only in case, are dropped.
+func (r *MongoRepository) linkWallets(ctx context.Context, name string, filter bson.M, update func(bson.M) (bson.M, error)) (int, error) {
+	collection := r.DB.Collection(name)
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	linked := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return linked, err
+		}
+		change, err := update(doc)
+		if err != nil {
+			return linked, err
+		}
+		_, err = collection.UpdateByID(ctx, doc["_id"], change)
+		if mongo.IsDuplicateKeyError(err) {
+			_, err = collection.DeleteOne(ctx, bson.M{"_id": doc["_id"]})
+		}
+		if err != nil {
+			return linked, err
+		}
+		linked++
+	}
+	return linked, cursor.Err()
+}
+
+// backfillHotScores scores the posts stored before hot ranking, so they
+// can be paged through by hot_score
+func (r *MongoRepository) backfillHotScores(ctx context.Context) error {
+	posts := r.DB.Collection("posts")
+	cursor, err := posts.Find(ctx, bson.M{"hot_score": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"likes_count": 1, "comments_count": 1, "created_at": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	scored := 0
+	for cursor.Next(ctx) {
+		var post models.Post
+		if err := cursor.Decode(&post); err != nil {
+			return err
+		}
+		score := HotScore(post.LikesCount, post.CommentsCount, post.CreatedAt)
+		if _, err := posts.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"hot_score": score}}); err != nil {
+			return err
+		}
+		scored++
+	}
+	if scored > 0 {
+		log.Printf("Scored %d posts for hot ranking", scored)
+	}
+	return cursor.Err()
+}
+
+func noDocument(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func attachmentFromDoc(doc models.Attachment) Attachment {
+	return Attachment{
+		ID:             doc.ID.Hex(),
+		UploaderWallet: doc.UploaderWallet,
+		ContentType:    doc.ContentType,
+		Key:            doc.Key,
+		ThumbnailKey:   doc.ThumbnailKey,
+		Size:           doc.Size,
+		Width:          doc.Width,
+		Height:         doc.Height,
+		CreatedAt:      doc.CreatedAt,
+	}
+}
+
+func reportFromDoc(doc models.Report) Report {
+	return Report{
+		ID:             doc.ID.Hex(),
+		TargetType:     doc.TargetType,
+		TargetID:       doc.TargetID.Hex(),
+		ReporterWallet: doc.ReporterWallet,
+		Reason:         doc.Reason,
+		Note:           doc.Note,
+		Status:         doc.Status,
+		Resolution:     doc.Resolution,
+		ResolvedBy:     doc.ResolvedBy,
+		ResolvedAt:     doc.ResolvedAt,
+		CreatedAt:      doc.CreatedAt,
+	}
+}
+
+func postDoc(p *Post) (models.Post, error) {
+	oid, err := primitive.ObjectIDFromHex(p.ID)
+	if err != nil {
+		return models.Post{}, err
+	}
+	doc := models.Post{
+		ID:            oid,
+		UserID:        p.UserID,
+		Username:      p.Username,
+		WalletAddress: p.WalletAddress,
+		Content:       p.Content,
+		LikesCount:    p.LikesCount,
+		CommentsCount: p.CommentsCount,
+		HotScore:      p.HotScore,
+		Tags:          p.Tags,
+		Mentions:      mentionDocs(p.Mentions),
+		Status:        p.Status,
+		Moderation:    moderationDoc(p.Moderation),
+		EditedAt:      p.EditedAt,
+		DeletedAt:     p.DeletedAt,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+	for _, a := range p.Attachments {
+		id, _ := primitive.ObjectIDFromHex(a.ID)
+		doc.Attachments = append(doc.Attachments, models.Attachment{
+			ID:           id,
+			ContentType:  a.ContentType,
+			Key:          a.Key,
+			ThumbnailKey: a.ThumbnailKey,
+			Size:         a.Size,
+			Width:        a.Width,
+			Height:       a.Height,
+			CreatedAt:    a.CreatedAt,
+		})
+	}
+	return doc, nil
+}
+
+func postFromDoc(doc models.Post) Post {
+	p := Post{
+		ID:            doc.ID.Hex(),
+		UserID:        doc.UserID,
+		Username:      doc.Username,
+		WalletAddress: doc.WalletAddress,
+		Content:       doc.Content,
+		LikesCount:    doc.LikesCount,
+		CommentsCount: doc.CommentsCount,
+		HotScore:      doc.HotScore,
+		Tags:          doc.Tags,
+		Mentions:      mentionsFromDocs(doc.Mentions),
+		Status:        doc.Status,
+		Moderation:    moderationFromDoc(doc.Moderation),
+		EditedAt:      doc.EditedAt,
+		DeletedAt:     doc.DeletedAt,
+		CreatedAt:     doc.CreatedAt,
+		UpdatedAt:     doc.UpdatedAt,
+	}
+	for _, a := range doc.Attachments {
+		p.Attachments = append(p.Attachments, Attachment{
+			ID:           a.ID.Hex(),
+			ContentType:  a.ContentType,
+			Key:          a.Key,
+			ThumbnailKey: a.ThumbnailKey,
+			Size:         a.Size,
+			Width:        a.Width,
+			Height:       a.Height,
+			CreatedAt:    a.CreatedAt,
+		})
+	}
+	return p
+}
+
+func commentDoc(c *Comment) (models.Comment, error) {
+	oid, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	postID, err := primitive.ObjectIDFromHex(c.PostID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	doc := models.Comment{
+		ID:            oid,
+		PostID:        postID,
+		Depth:         c.Depth,
+		UserID:        c.UserID,
+		Username:      c.Username,
+		WalletAddress: c.WalletAddress,
+		Content:       c.Content,
+		RepliesCount:  c.RepliesCount,
+		Tags:          c.Tags,
+		Mentions:      mentionDocs(c.Mentions),
+		Status:        c.Status,
+		Moderation:    moderationDoc(c.Moderation),
+		EditedAt:      c.EditedAt,
+		DeletedAt:     c.DeletedAt,
+		CreatedAt:     c.CreatedAt,
+	}
+	if c.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(c.ParentID)
+		if err != nil {
+			return models.Comment{}, err
+		}
+		doc.ParentID = &parentID
+	}
+	return doc, nil
+}
+
+func commentFromDoc(doc models.Comment) Comment {
+	c := Comment{
+		ID:            doc.ID.Hex(),
+		PostID:        doc.PostID.Hex(),
+		Depth:         doc.Depth,
+		UserID:        doc.UserID,
+		Username:      doc.Username,
+		WalletAddress: doc.WalletAddress,
+		Content:       doc.Content,
+		RepliesCount:  doc.RepliesCount,
+		Tags:          doc.Tags,
+		Mentions:      mentionsFromDocs(doc.Mentions),
+		Status:        doc.Status,
+		Moderation:    moderationFromDoc(doc.Moderation),
+		EditedAt:      doc.EditedAt,
+		DeletedAt:     doc.DeletedAt,
+		CreatedAt:     doc.CreatedAt,
+	}
+	if doc.ParentID != nil {
+		c.ParentID = doc.ParentID.Hex()
+	}
+	return c
+}
+
+func mentionDocs(mentions []Mention) []models.Mention {
+	if mentions == nil {
+		return nil
+	}
+	docs := make([]models.Mention, 0, len(mentions))
+	for _, m := range mentions {
+		docs = append(docs, models.Mention{UserID: m.UserID, Wallet: m.Wallet, Username: m.Username})
+	}
+	return docs
+}
+
+func mentionsFromDocs(docs []models.Mention) []Mention {
+	if docs == nil {
+		return nil
+	}
+	mentions := make([]Mention, 0, len(docs))
+	for _, m := range docs {
+		mentions = append(mentions, Mention{UserID: m.UserID, Wallet: m.Wallet, Username: m.Username})
+	}
+	return mentions
+}
+
+func moderationDoc(m *Moderation) *models.Moderation {
+	if m == nil {
+		return nil
+	}
+	return &models.Moderation{
+		FlaggedTerms: m.FlaggedTerms,
+		OpenReports:  m.OpenReports,
+		ReviewedBy:   m.ReviewedBy,
+		ReviewNote:   m.ReviewNote,
+		ReviewedAt:   m.ReviewedAt,
+	}
+}
+
+func moderationFromDoc(doc *models.Moderation) *Moderation {
+	if doc == nil {
+		return nil
+	}
+	return &Moderation{
+		FlaggedTerms: doc.FlaggedTerms,
+		OpenReports:  doc.OpenReports,
+		ReviewedBy:   doc.ReviewedBy,
+		ReviewNote:   doc.ReviewNote,
+		ReviewedAt:   doc.ReviewedAt,
+	}
+}
@@ -0,0 +1,77 @@
+package community
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Post orderings
+const (
+	SortNew = "new" // Newest first
+	SortHot = "hot" // By HotScore
+)
+
+// hotEpoch anchors hot scores. Only differences between scores matter, so
+// any fixed time works.
+var hotEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// hotGravity is how much newer a post with a tenth of another's engagement
+// must be to rank alongside it
+const hotGravity = 12 * time.Hour
+
+// HotScore ranks a post by engagement and age: the log of its likes plus
+// twice its comments, plus one for every hotGravity it was created after
+// hotEpoch. The age term does not change as time passes, so the score only
+// needs updating when the counts do and can be stored and indexed.
+func HotScore(likes, comments int, createdAt time.Time) float64 {
+	engagement := math.Max(float64(likes+2*comments), 1)
+	return math.Log10(engagement) + createdAt.Sub(hotEpoch).Seconds()/hotGravity.Seconds()
+}
+
+// ErrInvalidCursor is returned for a cursor that ParseCursor cannot read
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last item of a page: its sort key and ID. The next page
+// continues after it, so pages stay consistent while items are added and
+// cost the same however deep they go.
+type Cursor struct {
+	Key float64 // Sort field, with times as Unix milliseconds
+	ID  string  // Hex ID, breaking ties between equal keys
+}
+
+// TimeCursor returns the cursor of an item sorted by time
+func TimeCursor(t time.Time, id string) Cursor {
+	return Cursor{Key: float64(t.UnixMilli()), ID: id}
+}
+
+// Time returns the key of a cursor made by TimeCursor
+func (c Cursor) Time() time.Time {
+	return time.UnixMilli(int64(c.Key)).UTC()
+}
+
+// String encodes the cursor for clients to pass back
+func (c Cursor) String() string {
+	raw := strconv.FormatFloat(c.Key, 'g', -1, 64) + "_" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a cursor made by Cursor.String
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	key, id, ok := strings.Cut(string(raw), "_")
+	if !ok || id == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	k, err := strconv.ParseFloat(key, 64)
+	if err != nil || math.IsNaN(k) || math.IsInf(k, 0) {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Key: k, ID: id}, nil
+}
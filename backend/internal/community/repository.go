@@ -0,0 +1,450 @@
+package community
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/moderation"
+
+	"github.com/Conflux-Chain/go-conflux-sdk/types/cfxaddress"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+// Repository errors
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned by edits whose previous content no longer
+	// matches, because of a concurrent edit or deletion
+	ErrConflict = errors.New("content changed")
+	// ErrInvalidWallet is returned for a string that is not a hex or base32
+	// wallet address
+	ErrInvalidWallet = errors.New("invalid wallet address")
+	// ErrAlreadyReported is returned for a second report of the same content
+	// by the same wallet
+	ErrAlreadyReported = errors.New("already reported")
+	// ErrAttachmentUnavailable is returned when claiming uploads that are
+	// not the uploader's or already belong to a post
+	ErrAttachmentUnavailable = errors.New("attachment unavailable")
+)
+
+// Kinds of content, as reported and reviewed
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+)
+
+// Report states
+const (
+	ReportOpen     = "open"
+	ReportResolved = "resolved"
+)
+
+// Post is a community post. Its author is a user; Username and
+// WalletAddress are how they appeared when it was written.
+type Post struct {
+	ID            string       `json:"id"`
+	UserID        uint         `json:"user_id"`
+	Username      string       `json:"username"`
+	WalletAddress string       `json:"wallet_address"`
+	Content       string       `json:"content"`
+	LikesCount    int          `json:"likes_count"`
+	CommentsCount int          `json:"comments_count"`
+	HotScore      float64      `json:"-"` // Kept up to date by the repository
+	Attachments   []Attachment `json:"attachments"`
+	Tags          []string     `json:"tags"` // Lowercased #topics in the content
+	Mentions      []Mention    `json:"mentions"`
+	Status        string       `json:"status"` // A moderation status; empty is visible
+	Moderation    *Moderation  `json:"-"`
+	EditedAt      *time.Time   `json:"edited_at,omitempty"`
+	DeletedAt     *time.Time   `json:"deleted_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// Visible reports whether the post is live and not held or hidden
+func (p *Post) Visible() bool {
+	return p.DeletedAt == nil && visible(p.Status)
+}
+
+// Cursor returns the cursor that continues a listing in sort after p
+func (p *Post) Cursor(sort string) Cursor {
+	if sort == SortHot {
+		return Cursor{Key: p.HotScore, ID: p.ID}
+	}
+	return TimeCursor(p.CreatedAt, p.ID)
+}
+
+// Comment is a comment on a post. Replies name their parent; top-level
+// comments have no parent and depth 0.
+type Comment struct {
+	ID            string      `json:"id"`
+	PostID        string      `json:"post_id"`
+	ParentID      string      `json:"parent_id,omitempty"`
+	Depth         int         `json:"depth"`
+	UserID        uint        `json:"user_id"`
+	Username      string      `json:"username"`
+	WalletAddress string      `json:"wallet_address"`
+	Content       string      `json:"content"`
+	RepliesCount  int         `json:"replies_count"` // Replies not deleted
+	Tags          []string    `json:"tags"`
+	Mentions      []Mention   `json:"mentions"`
+	Status        string      `json:"status"` // As on Post
+	Moderation    *Moderation `json:"-"`
+	EditedAt      *time.Time  `json:"edited_at,omitempty"`
+	DeletedAt     *time.Time  `json:"deleted_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// Visible reports whether the comment is live and not held or hidden
+func (c *Comment) Visible() bool {
+	return c.DeletedAt == nil && visible(c.Status)
+}
+
+// Cursor returns the cursor that continues a listing after c
+func (c *Comment) Cursor() Cursor {
+	return TimeCursor(c.CreatedAt, c.ID)
+}
+
+func visible(status string) bool {
+	return status == "" || status == moderation.StatusVisible
+}
+
+// Attachment is an image on a post. Uploads are kept by the media package
+// and belong to their uploader until a post claims them; posts carry a copy
+// of their attachments.
+type Attachment struct {
+	ID             string    `json:"id"`
+	UploaderWallet string    `json:"-"`
+	ContentType    string    `json:"content_type"`
+	Key            string    `json:"-"` // Blob store key of the image
+	ThumbnailKey   string    `json:"-"` // Blob store key of the JPEG thumbnail
+	URL            string    `json:"url"`
+	ThumbnailURL   string    `json:"thumbnail_url"`
+	Size           int64     `json:"size"`
+	Width          int       `json:"width"`
+	Height         int       `json:"height"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Mention is a user @mentioned in a post or comment
+type Mention struct {
+	UserID   uint   `json:"user_id"`
+	Wallet   string `json:"wallet"`
+	Username string `json:"username"`
+}
+
+// Moderation records why content was held and the review decision on it
+type Moderation struct {
+	FlaggedTerms []string   `json:"flagged_terms,omitempty"` // Blocklist terms that held it
+	OpenReports  int        `json:"open_reports"`
+	ReviewedBy   string     `json:"reviewed_by,omitempty"`
+	ReviewNote   string     `json:"review_note,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Report is a user's complaint about a post or comment
+type Report struct {
+	ID             string     `json:"id"`
+	TargetType     string     `json:"target_type"` // KindPost or KindComment
+	TargetID       string     `json:"target_id"`
+	ReporterWallet string     `json:"reporter_wallet"`
+	Reason         string     `json:"reason"`
+	Note           string     `json:"note,omitempty"`
+	Status         string     `json:"status"`               // ReportOpen or ReportResolved
+	Resolution     string     `json:"resolution,omitempty"` // Status given to the target
+	ResolvedBy     string     `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Revision is the content of a post or comment before an edit
+type Revision struct {
+	Content  string    `json:"content"`
+	EditedAt time.Time `json:"edited_at"` // When this content was replaced
+}
+
+// Follow records that a user follows another's posts
+type Follow struct {
+	ID         string    `json:"id"`
+	FollowerID uint      `json:"follower_id"`
+	FolloweeID uint      `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Cursor returns the cursor that continues a listing after f
+func (f *Follow) Cursor() Cursor {
+	return TimeCursor(f.CreatedAt, f.ID)
+}
+
+// Edit replaces the content of a post or comment, keeping the previous
+// content as a revision
+type Edit struct {
+	Content  string
+	Tags     []string
+	Mentions []Mention
+	At       time.Time
+	// Hold sends the content to review for FlaggedTerms
+	Hold         bool
+	FlaggedTerms []string
+}
+
+// Review is an admin's decision on held or reported content
+type Review struct {
+	Status string // moderation.StatusVisible or StatusHidden
+	By     string
+	Note   string
+	At     time.Time
+}
+
+// PostQuery selects visible posts
+type PostQuery struct {
+	Tag     string // Only posts with this tag
+	Authors []uint // Only posts by these users, unless nil
+	Sort    string // SortNew or SortHot
+	Limit   int
+	After   *Cursor // Continue after this post
+}
+
+// CommentQuery selects the top-level comments of a post, or the replies
+// to ParentID. Deleted and held comments are included.
+type CommentQuery struct {
+	PostID   string
+	ParentID string
+	Limit    int
+	After    *Cursor // Continue after this comment
+}
+
+// FollowQuery selects the followers of a user, or whom they follow
+type FollowQuery struct {
+	UserID    uint
+	Followers bool
+	Limit     int
+	After     *Cursor
+}
+
+// Repository stores posts, comments, likes, follows, attachments and
+// reports. It keeps the like, comment, reply and report counters and hot
+// scores consistent.
+type Repository interface {
+	// CreatePost stores a new post with an ID from NewID
+	CreatePost(ctx context.Context, post *Post) error
+	// GetPost returns a post, including deleted and held ones
+	GetPost(ctx context.Context, id string) (*Post, error)
+	// ListPosts returns visible posts, newest or hottest first
+	ListPosts(ctx context.Context, q PostQuery) ([]Post, error)
+	// EditPost applies an edit if the post is live and its content is
+	// still previous, and returns ErrConflict otherwise
+	EditPost(ctx context.Context, id, previous string, e Edit) error
+	// DeletePost soft deletes a live post with its comments and likes
+	DeletePost(ctx context.Context, id string, at time.Time) (comments, likes int64, err error)
+	// PostRevisions returns a post's earlier contents, oldest first
+	PostRevisions(ctx context.Context, id string) ([]Revision, error)
+
+	// SetLike adds or removes a user's like of a post and returns whether
+	// that changed anything and the post's like count
+	SetLike(ctx context.Context, postID string, userID uint, like bool) (changed bool, likes int, err error)
+	// LikedPosts returns which of the posts the user likes
+	LikedPosts(ctx context.Context, userID uint, postIDs []string) (map[string]bool, error)
+
+	// CreateComment stores a new comment with an ID from NewID and counts
+	// it on its post and parent
+	CreateComment(ctx context.Context, comment *Comment) error
+	// GetComment returns a comment, including deleted and held ones
+	GetComment(ctx context.Context, id string) (*Comment, error)
+	// ListComments returns comments oldest first, with how many match q
+	// without its cursor
+	ListComments(ctx context.Context, q CommentQuery) ([]Comment, int64, error)
+	// EditComment is EditPost for comments
+	EditComment(ctx context.Context, id, previous string, e Edit) error
+	// DeleteComment soft deletes a live comment and reports whether it did
+	DeleteComment(ctx context.Context, id string, at time.Time) (bool, error)
+	// CommentRevisions returns a comment's earlier contents, oldest first
+	CommentRevisions(ctx context.Context, id string) ([]Revision, error)
+
+	// SetFollow adds or removes a follow and returns whether that changed
+	// anything and the followee's follower count
+	SetFollow(ctx context.Context, followerID, followeeID uint, follow bool) (changed bool, followers int64, err error)
+	// Followees returns up to limit users the user follows
+	Followees(ctx context.Context, userID uint, limit int) ([]uint, error)
+	// ListFollows returns follows, most recent first, with how many match
+	// q without its cursor
+	ListFollows(ctx context.Context, q FollowQuery) ([]Follow, int64, error)
+
+	// CreateAttachment stores an upload of UploaderWallet with an ID from
+	// NewID
+	CreateAttachment(ctx context.Context, a *Attachment) error
+	// ClaimAttachments assigns uploads to a post and returns them in the
+	// order given. It returns ErrAttachmentUnavailable, claiming nothing,
+	// unless all are the wallet's uploads without a post.
+	ClaimAttachments(ctx context.Context, wallet, postID string, ids []string) ([]Attachment, error)
+	// ReleaseAttachments returns the uploads claimed for a post to their
+	// uploader
+	ReleaseAttachments(ctx context.Context, postID string) error
+
+	// CreateReport stores an open report on live content and holds
+	// visible content for review once it has threshold open reports. A
+	// second report by the same wallet returns ErrAlreadyReported.
+	CreateReport(ctx context.Context, report *Report, threshold int) (held bool, err error)
+	// OpenReports returns the open reports on content, oldest first
+	OpenReports(ctx context.Context, targetID string) ([]Report, error)
+	// ListReports returns up to limit reports with a status, oldest first
+	ListReports(ctx context.Context, status string, limit int) ([]Report, error)
+	// Review sets the status of live content, clears its open reports and
+	// returns its previous status
+	Review(ctx context.Context, kind, id string, r Review) (previous string, err error)
+	// ResolveReports closes the open reports on content with a review and
+	// returns how many it closed
+	ResolveReports(ctx context.Context, targetID string, r Review) (int64, error)
+	// HeldPosts returns live posts pending review, oldest first
+	HeldPosts(ctx context.Context, limit int) ([]Post, error)
+	// HeldComments returns live comments pending review, oldest first
+	HeldComments(ctx context.Context, limit int) ([]Comment, error)
+
+	// TagActivity returns the visible tagged posts and comments created
+	// since then
+	TagActivity(ctx context.Context, since time.Time) ([]Activity, error)
+}
+
+// NewID returns a new post, comment, follow, attachment or report ID
+func NewID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// ValidID reports whether id could have come from NewID
+func ValidID(id string) bool {
+	return primitive.IsValidObjectID(id)
+}
+
+// stamp rounds a time to what MongoDB keeps
+func stamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+// preparePost fills in the ID, times and hot score of a new post
+func preparePost(p *Post) {
+	if p.ID == "" {
+		p.ID = NewID()
+	}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	p.CreatedAt = stamp(p.CreatedAt)
+	if p.UpdatedAt.IsZero() {
+		p.UpdatedAt = p.CreatedAt
+	}
+	p.UpdatedAt = stamp(p.UpdatedAt)
+	p.HotScore = HotScore(p.LikesCount, p.CommentsCount, p.CreatedAt)
+}
+
+// prepareComment fills in the ID and time of a new comment
+func prepareComment(c *Comment) {
+	if c.ID == "" {
+		c.ID = NewID()
+	}
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	c.CreatedAt = stamp(c.CreatedAt)
+}
+
+var repo Repository
+
+// Initialize selects the store named by COMMUNITY_STORE: "mongo" (the
+// default) or "mysql". MongoDB documents are migrated first; an empty mysql
+// store starts with a copy of them, and the posts of the old MySQL posts
+// table are imported into whichever store is selected. Default returns nil
+// while the selected store is unavailable.
+func Initialize(cfg *config.Config, db *gorm.DB, mongoDB *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var mongoRepo *MongoRepository
+	if mongoDB != nil {
+		mongoRepo = NewMongoRepository(mongoDB)
+		if err := mongoRepo.Migrate(ctx, func(wallet string) (uint, error) {
+			user, err := UserForWallet(db, wallet)
+			if err != nil {
+				return 0, err
+			}
+			return user.ID, nil
+		}); err != nil {
+			log.Printf("Warning: failed to migrate community documents: %v", err)
+		}
+	}
+
+	var r Repository
+	name := cfg.CommunityStore
+	switch name {
+	case "mysql":
+		sqlRepo := NewSQLRepository(db)
+		if err := sqlRepo.Migrate(ctx); err != nil {
+			log.Printf("Warning: failed to migrate the MySQL community store, community disabled: %v", err)
+			return
+		}
+		if mongoRepo != nil {
+			if err := sqlRepo.ImportMongo(ctx, mongoRepo); err != nil {
+				log.Printf("Warning: failed to copy community content from MongoDB: %v", err)
+			}
+		}
+		r = sqlRepo
+	default:
+		if name != "mongo" {
+			log.Printf("Warning: unknown community store %q, using mongo", name)
+			name = "mongo"
+		}
+		if mongoRepo == nil {
+			log.Println("Warning: MongoDB unavailable, community disabled")
+			return
+		}
+		r = mongoRepo
+	}
+
+	if err := importLegacyPosts(ctx, db, r); err != nil {
+		log.Printf("Warning: failed to import legacy posts: %v", err)
+	}
+	repo = r
+	log.Printf("Community store: %s", name)
+}
+
+// Default returns the selected store, or nil while it is unavailable
+func Default() Repository {
+	return repo
+}
+
+// ValidWallet reports whether wallet is a hex or base32 Conflux address
+func ValidWallet(wallet string) bool {
+	if hexWallet.MatchString(wallet) {
+		return true
+	}
+	_, err := cfxaddress.NewFromBase32(wallet)
+	return err == nil
+}
+
+var hexWallet = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// UserForWallet returns the user with a wallet address, ignoring case, and
+// creates one if there is none. It returns ErrInvalidWallet, without
+// creating anyone, for a string that is not an address.
+func UserForWallet(db *gorm.DB, wallet string) (*models.User, error) {
+	if !ValidWallet(wallet) {
+		return nil, ErrInvalidWallet
+	}
+	var user models.User
+	err := db.Where("LOWER(wallet_address) = ?", strings.ToLower(wallet)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = models.User{WalletAddress: wallet, Balance: 0}
+		err = db.Create(&user).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
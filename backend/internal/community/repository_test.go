@@ -0,0 +1,740 @@
+package community
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/moderation"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openSQLDB returns a SQLite database whose users 1 to 3 exist
+func openSQLDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "community.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		user := models.User{
+			WalletAddress: fmt.Sprintf("0x%040d", i),
+			Username:      fmt.Sprintf("user%d", i),
+			Email:         fmt.Sprintf("user%d@example.com", i),
+		}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestSQLRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) Repository {
+		r := NewSQLRepository(openSQLDB(t))
+		if err := r.Migrate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+func TestSQLRepositoryImportsLegacyPosts(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLDB(t)
+
+	// The posts table from before the community store
+	if err := db.Exec("CREATE TABLE posts (id integer PRIMARY KEY, user_id integer, content text, likes_count integer, comments_count integer, created_at datetime)").Error; err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	db.Exec("INSERT INTO posts VALUES (1, 2, 'first harvest', 4, 1, ?), (2, 9, 'orphan', 0, 0, ?)", at, at)
+
+	r := NewSQLRepository(db)
+	if err := r.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := legacyPostsTable(db); got != "legacy_posts" {
+		t.Fatalf("legacy table = %q, want legacy_posts", got)
+	}
+	if err := importLegacyPosts(ctx, db, r); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasTable("legacy_posts") {
+		t.Error("legacy table not dropped")
+	}
+
+	posts, err := r.ListPosts(ctx, PostQuery{Sort: SortNew})
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("ListPosts = %+v, %v", posts, err)
+	}
+	post := posts[0]
+	if post.UserID != 2 || post.WalletAddress != fmt.Sprintf("0x%040d", 2) || post.Content != "first harvest" ||
+		post.LikesCount != 4 || !post.CreatedAt.Equal(at) {
+		t.Errorf("imported post = %+v", post)
+	}
+
+	// A second start finds nothing to import
+	if err := r.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := importLegacyPosts(ctx, db, r); err != nil {
+		t.Fatal(err)
+	}
+	if posts, _ := r.ListPosts(ctx, PostQuery{Sort: SortNew}); len(posts) != 1 {
+		t.Errorf("%d posts after restart, want 1", len(posts))
+	}
+}
+
+// TestMongoRepository runs against the server at MONGO_TEST_URI, in a
+// database dropped afterwards
+func TestMongoRepository(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(ctx) })
+
+	testRepository(t, func(t *testing.T) Repository {
+		db := client.Database("community_test_" + NewID())
+		t.Cleanup(func() { db.Drop(ctx) })
+		r := NewMongoRepository(db)
+		if err := r.EnsureIndexes(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+// TestSQLRepositoryImportMongo copies a MongoDB store at MONGO_TEST_URI
+// into an empty SQLite one
+func TestSQLRepositoryImportMongo(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(ctx) })
+	mdb := client.Database("community_test_" + NewID())
+	t.Cleanup(func() { mdb.Drop(ctx) })
+	src := NewMongoRepository(mdb)
+	if err := src.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+	wallet := fmt.Sprintf("0x%040d", 1)
+	upload := &Attachment{UploaderWallet: wallet, Key: "community/a.png", CreatedAt: at}
+	src.CreateAttachment(ctx, upload)
+	post := &Post{UserID: 1, WalletAddress: wallet, Content: "harvest #rice", Tags: []string{"rice"}, CreatedAt: at}
+	src.CreatePost(ctx, post)
+	claimed, _ := src.ClaimAttachments(ctx, wallet, post.ID, []string{upload.ID})
+	src.EditPost(ctx, post.ID, post.Content, Edit{Content: "harvest #rice!", Tags: []string{"rice"}, At: at.Add(time.Minute)})
+	comment := &Comment{PostID: post.ID, UserID: 2, Content: "nice", CreatedAt: at.Add(2 * time.Minute)}
+	src.CreateComment(ctx, comment)
+	src.SetLike(ctx, post.ID, 2, true)
+	src.SetFollow(ctx, 2, 1, true)
+	src.CreateReport(ctx, &Report{TargetType: KindComment, TargetID: comment.ID, ReporterWallet: wallet, Reason: "spam"}, 5)
+	if len(claimed) != 1 {
+		t.Fatalf("claimed = %+v", claimed)
+	}
+
+	dst := NewSQLRepository(openSQLDB(t))
+	if err := dst.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for run := 0; run < 2; run++ {
+		if err := dst.ImportMongo(ctx, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := dst.GetPost(ctx, post.ID)
+	if err != nil || got.Content != "harvest #rice!" || got.LikesCount != 1 || got.CommentsCount != 1 {
+		t.Errorf("copied post = %+v, %v", got, err)
+	}
+	if revisions, _ := dst.PostRevisions(ctx, post.ID); len(revisions) != 1 || revisions[0].Content != "harvest #rice" {
+		t.Errorf("copied revisions = %+v", revisions)
+	}
+	if posts, _ := dst.ListPosts(ctx, PostQuery{Tag: "rice"}); len(posts) != 1 {
+		t.Errorf("%d posts tagged rice, want 1", len(posts))
+	}
+	if liked, _ := dst.LikedPosts(ctx, 2, []string{post.ID}); !liked[post.ID] {
+		t.Error("like not copied")
+	}
+	if followees, _ := dst.Followees(ctx, 2, 10); !reflect.DeepEqual(followees, []uint{1}) {
+		t.Errorf("followees = %v", followees)
+	}
+	if reports, _ := dst.OpenReports(ctx, comment.ID); len(reports) != 1 {
+		t.Errorf("copied reports = %+v", reports)
+	}
+	if err := dst.ReleaseAttachments(ctx, post.ID); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := dst.ClaimAttachments(ctx, wallet, NewID(), []string{upload.ID}); err != nil || again[0].Key != "community/a.png" {
+		t.Errorf("copied attachment = %+v, %v", again, err)
+	}
+}
+
+// testRepository checks the behaviour every Repository must share. Each
+// subtest gets an empty repository from newRepo whose users 1 to 3 exist.
+func testRepository(t *testing.T, newRepo func(t *testing.T) Repository) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	newPost := func(t *testing.T, r Repository, userID uint, at time.Time, tags ...string) *Post {
+		t.Helper()
+		post := &Post{
+			UserID:        userID,
+			Username:      fmt.Sprintf("user%d", userID),
+			WalletAddress: fmt.Sprintf("0x%040d", userID),
+			Content:       fmt.Sprintf("post by %d at %s", userID, at.Format(time.TimeOnly)),
+			Tags:          tags,
+			Status:        moderation.StatusVisible,
+			CreatedAt:     at,
+		}
+		if err := r.CreatePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+	newComment := func(t *testing.T, r Repository, post *Post, parent *Comment, userID uint, at time.Time) *Comment {
+		t.Helper()
+		comment := &Comment{
+			PostID:    post.ID,
+			UserID:    userID,
+			Username:  fmt.Sprintf("user%d", userID),
+			Content:   "comment at " + at.Format(time.TimeOnly),
+			Status:    moderation.StatusVisible,
+			CreatedAt: at,
+		}
+		if parent != nil {
+			comment.ParentID = parent.ID
+			comment.Depth = parent.Depth + 1
+		}
+		if err := r.CreateComment(ctx, comment); err != nil {
+			t.Fatal(err)
+		}
+		return comment
+	}
+	getPost := func(t *testing.T, r Repository, id string) *Post {
+		t.Helper()
+		post, err := r.GetPost(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+	ids := func(posts []Post) []string {
+		out := []string{}
+		for _, p := range posts {
+			out = append(out, p.ID)
+		}
+		return out
+	}
+
+	t.Run("list posts", func(t *testing.T) {
+		r := newRepo(t)
+		var posts []*Post
+		for i := 0; i < 5; i++ {
+			var tags []string
+			if i%2 == 0 {
+				tags = []string{"rice"}
+			}
+			posts = append(posts, newPost(t, r, uint(i%2+1), base.Add(time.Duration(i)*time.Minute), tags...))
+		}
+		held := newPost(t, r, 1, base.Add(time.Hour), "rice")
+		if _, err := r.Review(ctx, KindPost, held.ID, Review{Status: moderation.StatusPendingReview, At: base}); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := r.GetPost(ctx, posts[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Content != posts[0].Content || got.UserID != 1 || !got.CreatedAt.Equal(posts[0].CreatedAt) ||
+			!reflect.DeepEqual(got.Tags, []string{"rice"}) || got.HotScore != posts[0].HotScore {
+			t.Errorf("GetPost = %+v, want %+v", got, posts[0])
+		}
+		if _, err := r.GetPost(ctx, NewID()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetPost of a missing post: %v, want ErrNotFound", err)
+		}
+
+		// Newest first, continued by cursor, without the held post
+		var pages [][]string
+		q := PostQuery{Sort: SortNew, Limit: 2}
+		for {
+			page, err := r.ListPosts(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages = append(pages, ids(page))
+			if len(page) < q.Limit {
+				break
+			}
+			cursor := page[len(page)-1].Cursor(SortNew)
+			q.After = &cursor
+		}
+		want := [][]string{{posts[4].ID, posts[3].ID}, {posts[2].ID, posts[1].ID}, {posts[0].ID}}
+		if !reflect.DeepEqual(pages, want) {
+			t.Errorf("pages = %v, want %v", pages, want)
+		}
+
+		tagged, err := r.ListPosts(ctx, PostQuery{Tag: "rice", Sort: SortNew})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := ids(tagged), []string{posts[4].ID, posts[2].ID, posts[0].ID}; !reflect.DeepEqual(got, want) {
+			t.Errorf("tagged = %v, want %v", got, want)
+		}
+
+		byAuthor, err := r.ListPosts(ctx, PostQuery{Authors: []uint{2}, Sort: SortNew})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := ids(byAuthor), []string{posts[3].ID, posts[1].ID}; !reflect.DeepEqual(got, want) {
+			t.Errorf("by author = %v, want %v", got, want)
+		}
+		none, err := r.ListPosts(ctx, PostQuery{Authors: []uint{}, Sort: SortNew})
+		if err != nil || len(none) != 0 {
+			t.Errorf("no authors = %v, %v, want none", ids(none), err)
+		}
+
+		// Engagement lifts the oldest post to the top of the hot list
+		for userID := uint(1); userID <= 3; userID++ {
+			if _, _, err := r.SetLike(ctx, posts[0].ID, userID, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		newComment(t, r, posts[0], nil, 2, base.Add(time.Hour))
+		var hot []string
+		q = PostQuery{Sort: SortHot, Limit: 2}
+		for {
+			page, err := r.ListPosts(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hot = append(hot, ids(page)...)
+			if len(page) < q.Limit {
+				break
+			}
+			cursor := page[len(page)-1].Cursor(SortHot)
+			q.After = &cursor
+		}
+		want2 := []string{posts[0].ID, posts[4].ID, posts[3].ID, posts[2].ID, posts[1].ID}
+		if !reflect.DeepEqual(hot, want2) {
+			t.Errorf("hot = %v, want %v", hot, want2)
+		}
+	})
+
+	t.Run("edit post", func(t *testing.T) {
+		r := newRepo(t)
+		post := newPost(t, r, 1, base, "rice")
+		editedAt := base.Add(time.Minute)
+		if err := r.EditPost(ctx, post.ID, post.Content, Edit{Content: "now about #tea", Tags: []string{"tea"}, At: editedAt}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.EditPost(ctx, post.ID, post.Content, Edit{Content: "lost", At: editedAt}); !errors.Is(err, ErrConflict) {
+			t.Errorf("stale edit: %v, want ErrConflict", err)
+		}
+
+		got := getPost(t, r, post.ID)
+		if got.Content != "now about #tea" || got.EditedAt == nil || !got.EditedAt.Equal(editedAt) || !reflect.DeepEqual(got.Tags, []string{"tea"}) {
+			t.Errorf("edited post = %+v", got)
+		}
+		revisions, err := r.PostRevisions(ctx, post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revisions) != 1 || revisions[0].Content != post.Content || !revisions[0].EditedAt.Equal(editedAt) {
+			t.Errorf("revisions = %+v", revisions)
+		}
+		for tag, want := range map[string]int{"rice": 0, "tea": 1} {
+			if posts, err := r.ListPosts(ctx, PostQuery{Tag: tag}); err != nil || len(posts) != want {
+				t.Errorf("posts tagged %s = %d, %v, want %d", tag, len(posts), err, want)
+			}
+		}
+
+		// An edit that trips the blocklist holds the post
+		if err := r.EditPost(ctx, post.ID, got.Content, Edit{Content: "flagged", At: editedAt, Hold: true, FlaggedTerms: []string{"bad"}}); err != nil {
+			t.Fatal(err)
+		}
+		got = getPost(t, r, post.ID)
+		if got.Status != moderation.StatusPendingReview || got.Moderation == nil || !reflect.DeepEqual(got.Moderation.FlaggedTerms, []string{"bad"}) {
+			t.Errorf("held post = %+v, moderation %+v", got, got.Moderation)
+		}
+		if posts, _ := r.ListPosts(ctx, PostQuery{}); len(posts) != 0 {
+			t.Errorf("held post is listed")
+		}
+	})
+
+	t.Run("delete post", func(t *testing.T) {
+		r := newRepo(t)
+		post := newPost(t, r, 1, base)
+		newComment(t, r, post, nil, 2, base.Add(time.Minute))
+		if _, _, err := r.SetLike(ctx, post.ID, 2, true); err != nil {
+			t.Fatal(err)
+		}
+
+		comments, likes, err := r.DeletePost(ctx, post.ID, base.Add(time.Hour))
+		if err != nil || comments != 1 || likes != 1 {
+			t.Errorf("DeletePost = %d, %d, %v, want 1, 1", comments, likes, err)
+		}
+		if _, _, err := r.DeletePost(ctx, post.ID, base.Add(time.Hour)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second delete: %v, want ErrNotFound", err)
+		}
+		got := getPost(t, r, post.ID)
+		if got.DeletedAt == nil || got.Visible() || got.CommentsCount != 1 || got.LikesCount != 1 {
+			t.Errorf("deleted post = %+v", got)
+		}
+		if liked, _ := r.LikedPosts(ctx, 2, []string{post.ID}); liked[post.ID] {
+			t.Errorf("like of a deleted post still counts")
+		}
+		if err := r.EditPost(ctx, post.ID, post.Content, Edit{Content: "x", At: base}); !errors.Is(err, ErrConflict) {
+			t.Errorf("edit of a deleted post: %v, want ErrConflict", err)
+		}
+		if posts, _ := r.ListPosts(ctx, PostQuery{}); len(posts) != 0 {
+			t.Errorf("deleted post is listed")
+		}
+	})
+
+	t.Run("likes", func(t *testing.T) {
+		r := newRepo(t)
+		post := newPost(t, r, 1, base)
+		steps := []struct {
+			userID  uint
+			like    bool
+			changed bool
+			likes   int
+		}{
+			{2, true, true, 1},
+			{2, true, false, 1},
+			{3, true, true, 2},
+			{3, false, true, 1},
+			{3, false, false, 1},
+		}
+		for _, s := range steps {
+			changed, likes, err := r.SetLike(ctx, post.ID, s.userID, s.like)
+			if err != nil || changed != s.changed || likes != s.likes {
+				t.Errorf("SetLike(%d, %v) = %v, %d, %v, want %v, %d", s.userID, s.like, changed, likes, err, s.changed, s.likes)
+			}
+		}
+
+		liked, err := r.LikedPosts(ctx, 2, []string{post.ID, NewID()})
+		if err != nil || !reflect.DeepEqual(liked, map[string]bool{post.ID: true}) {
+			t.Errorf("LikedPosts = %v, %v", liked, err)
+		}
+		if liked, _ := r.LikedPosts(ctx, 3, []string{post.ID}); len(liked) != 0 {
+			t.Errorf("LikedPosts after unlike = %v", liked)
+		}
+		got := getPost(t, r, post.ID)
+		if want := HotScore(1, 0, post.CreatedAt); got.HotScore != want {
+			t.Errorf("hot score = %v, want %v", got.HotScore, want)
+		}
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		r := newRepo(t)
+		post := newPost(t, r, 1, base)
+		first := newComment(t, r, post, nil, 2, base.Add(time.Minute))
+		second := newComment(t, r, post, nil, 3, base.Add(2*time.Minute))
+		reply := newComment(t, r, post, first, 1, base.Add(3*time.Minute))
+
+		if got := getPost(t, r, post.ID); got.CommentsCount != 3 || got.HotScore != HotScore(0, 3, post.CreatedAt) {
+			t.Errorf("post after comments = %+v", got)
+		}
+		got, err := r.GetComment(ctx, first.ID)
+		if err != nil || got.RepliesCount != 1 || got.PostID != post.ID || got.ParentID != "" {
+			t.Errorf("GetComment = %+v, %v", got, err)
+		}
+		if _, err := r.GetComment(ctx, NewID()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetComment of a missing comment: %v, want ErrNotFound", err)
+		}
+
+		page, total, err := r.ListComments(ctx, CommentQuery{PostID: post.ID, Limit: 1})
+		if err != nil || total != 2 || len(page) != 1 || page[0].ID != first.ID {
+			t.Fatalf("first page = %+v, %d, %v", page, total, err)
+		}
+		cursor := page[0].Cursor()
+		page, total, err = r.ListComments(ctx, CommentQuery{PostID: post.ID, Limit: 1, After: &cursor})
+		if err != nil || total != 2 || len(page) != 1 || page[0].ID != second.ID {
+			t.Errorf("second page = %+v, %d, %v", page, total, err)
+		}
+		replies, total, err := r.ListComments(ctx, CommentQuery{PostID: post.ID, ParentID: first.ID})
+		if err != nil || total != 1 || len(replies) != 1 || replies[0].ID != reply.ID || replies[0].ParentID != first.ID || replies[0].Depth != 1 {
+			t.Errorf("replies = %+v, %d, %v", replies, total, err)
+		}
+
+		editedAt := base.Add(time.Hour)
+		if err := r.EditComment(ctx, second.ID, second.Content, Edit{Content: "edited", At: editedAt}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.EditComment(ctx, second.ID, second.Content, Edit{Content: "lost", At: editedAt}); !errors.Is(err, ErrConflict) {
+			t.Errorf("stale comment edit: %v, want ErrConflict", err)
+		}
+		revisions, err := r.CommentRevisions(ctx, second.ID)
+		if err != nil || len(revisions) != 1 || revisions[0].Content != second.Content {
+			t.Errorf("comment revisions = %+v, %v", revisions, err)
+		}
+
+		deleted, err := r.DeleteComment(ctx, reply.ID, editedAt)
+		if err != nil || !deleted {
+			t.Errorf("DeleteComment = %v, %v", deleted, err)
+		}
+		if deleted, _ := r.DeleteComment(ctx, reply.ID, editedAt); deleted {
+			t.Errorf("comment deleted twice")
+		}
+		if got, _ := r.GetComment(ctx, first.ID); got.RepliesCount != 0 {
+			t.Errorf("replies after delete = %d", got.RepliesCount)
+		}
+		if got, _ := r.GetComment(ctx, reply.ID); got.DeletedAt == nil || got.Visible() {
+			t.Errorf("deleted comment = %+v", got)
+		}
+		if got := getPost(t, r, post.ID); got.CommentsCount != 2 {
+			t.Errorf("comments after delete = %d", got.CommentsCount)
+		}
+	})
+
+	t.Run("follows", func(t *testing.T) {
+		r := newRepo(t)
+		steps := []struct {
+			follower, followee uint
+			follow, changed    bool
+			followers          int64
+		}{
+			{1, 2, true, true, 1},
+			{1, 2, true, false, 1},
+			{3, 2, true, true, 2},
+			{2, 3, true, true, 1},
+		}
+		for _, s := range steps {
+			changed, followers, err := r.SetFollow(ctx, s.follower, s.followee, s.follow)
+			if err != nil || changed != s.changed || followers != s.followers {
+				t.Errorf("SetFollow(%d, %d) = %v, %d, %v, want %v, %d", s.follower, s.followee, changed, followers, err, s.changed, s.followers)
+			}
+		}
+
+		followees, err := r.Followees(ctx, 1, 10)
+		if err != nil || !reflect.DeepEqual(followees, []uint{2}) {
+			t.Errorf("Followees = %v, %v", followees, err)
+		}
+		if followees, err := r.Followees(ctx, 3, 10); err != nil || followees == nil {
+			t.Errorf("Followees of a user following one = %v, %v", followees, err)
+		}
+
+		page, total, err := r.ListFollows(ctx, FollowQuery{UserID: 2, Followers: true, Limit: 1})
+		if err != nil || total != 2 || len(page) != 1 || page[0].FollowerID != 3 {
+			t.Fatalf("followers = %+v, %d, %v", page, total, err)
+		}
+		cursor := page[0].Cursor()
+		page, _, err = r.ListFollows(ctx, FollowQuery{UserID: 2, Followers: true, Limit: 1, After: &cursor})
+		if err != nil || len(page) != 1 || page[0].FollowerID != 1 {
+			t.Errorf("followers page 2 = %+v, %v", page, err)
+		}
+		following, total, err := r.ListFollows(ctx, FollowQuery{UserID: 2})
+		if err != nil || total != 1 || len(following) != 1 || following[0].FolloweeID != 3 {
+			t.Errorf("following = %+v, %d, %v", following, total, err)
+		}
+
+		if changed, followers, err := r.SetFollow(ctx, 1, 2, false); err != nil || !changed || followers != 1 {
+			t.Errorf("unfollow = %v, %d, %v", changed, followers, err)
+		}
+		if changed, _, _ := r.SetFollow(ctx, 1, 2, false); changed {
+			t.Errorf("unfollowed twice")
+		}
+		if followees, err := r.Followees(ctx, 1, 10); err != nil || followees == nil || len(followees) != 0 {
+			t.Errorf("Followees after unfollow = %#v, %v", followees, err)
+		}
+	})
+
+	t.Run("moderation", func(t *testing.T) {
+		r := newRepo(t)
+		post := newPost(t, r, 1, base)
+		comment := newComment(t, r, post, nil, 2, base.Add(time.Minute))
+
+		report := func(kind, id string, reporter, threshold int) (bool, error) {
+			return r.CreateReport(ctx, &Report{
+				TargetType:     kind,
+				TargetID:       id,
+				ReporterWallet: fmt.Sprintf("0x%040d", reporter),
+				Reason:         "spam",
+				CreatedAt:      base.Add(time.Duration(reporter) * time.Minute),
+			}, threshold)
+		}
+		for i, want := range []bool{false, true, false} {
+			held, err := report(KindPost, post.ID, i+1, 2)
+			if err != nil || held != want {
+				t.Errorf("report %d held = %v, %v, want %v", i+1, held, err, want)
+			}
+		}
+		if _, err := report(KindPost, post.ID, 1, 2); !errors.Is(err, ErrAlreadyReported) {
+			t.Errorf("second report by a wallet: %v, want ErrAlreadyReported", err)
+		}
+		if _, err := report(KindPost, NewID(), 1, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("report of a missing post: %v, want ErrNotFound", err)
+		}
+		if held, err := report(KindComment, comment.ID, 1, 1); err != nil || !held {
+			t.Errorf("comment report held = %v, %v", held, err)
+		}
+		if reports, err := r.OpenReports(ctx, post.ID); err != nil || len(reports) != 3 || reports[0].ReporterWallet != fmt.Sprintf("0x%040d", 1) {
+			t.Errorf("OpenReports = %+v, %v", reports, err)
+		}
+
+		heldPosts, err := r.HeldPosts(ctx, 10)
+		if err != nil || len(heldPosts) != 1 || heldPosts[0].ID != post.ID || heldPosts[0].Moderation.OpenReports != 3 {
+			t.Errorf("HeldPosts = %+v, %v", heldPosts, err)
+		}
+		heldComments, err := r.HeldComments(ctx, 10)
+		if err != nil || len(heldComments) != 1 || heldComments[0].ID != comment.ID {
+			t.Errorf("HeldComments = %+v, %v", heldComments, err)
+		}
+
+		review := Review{Status: moderation.StatusVisible, By: "admin:root", Note: "fine", At: base.Add(time.Hour)}
+		previous, err := r.Review(ctx, KindPost, post.ID, review)
+		if err != nil || previous != moderation.StatusPendingReview {
+			t.Errorf("Review = %q, %v", previous, err)
+		}
+		got := getPost(t, r, post.ID)
+		if !got.Visible() || got.Moderation == nil || got.Moderation.OpenReports != 0 || got.Moderation.ReviewedBy != "admin:root" ||
+			got.Moderation.ReviewNote != "fine" || got.Moderation.ReviewedAt == nil || !got.Moderation.ReviewedAt.Equal(review.At) {
+			t.Errorf("reviewed post = %+v, moderation %+v", got, got.Moderation)
+		}
+		if heldPosts, _ := r.HeldPosts(ctx, 10); len(heldPosts) != 0 {
+			t.Errorf("reviewed post still held")
+		}
+		if resolved, err := r.ResolveReports(ctx, post.ID, review); err != nil || resolved != 3 {
+			t.Errorf("ResolveReports = %d, %v, want 3", resolved, err)
+		}
+		if reports, err := r.OpenReports(ctx, post.ID); err != nil || len(reports) != 0 {
+			t.Errorf("OpenReports after resolving = %+v, %v", reports, err)
+		}
+		resolved, err := r.ListReports(ctx, ReportResolved, 10)
+		if err != nil || len(resolved) != 3 || resolved[0].Resolution != moderation.StatusVisible || resolved[0].ResolvedBy != "admin:root" {
+			t.Errorf("ListReports(resolved) = %+v, %v", resolved, err)
+		}
+		if open, err := r.ListReports(ctx, ReportOpen, 10); err != nil || len(open) != 1 || open[0].TargetID != comment.ID {
+			t.Errorf("ListReports(open) = %+v, %v", open, err)
+		}
+
+		if _, _, err := r.DeletePost(ctx, post.ID, base); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Review(ctx, KindPost, post.ID, review); !errors.Is(err, ErrNotFound) {
+			t.Errorf("review of a deleted post: %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		r := newRepo(t)
+		wallet := fmt.Sprintf("0x%040d", 1)
+		var ids []string
+		for i := 0; i < 3; i++ {
+			a := &Attachment{UploaderWallet: wallet, ContentType: "image/png", Key: fmt.Sprintf("community/%d.png", i), CreatedAt: base}
+			if err := r.CreateAttachment(ctx, a); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, a.ID)
+		}
+		other := &Attachment{UploaderWallet: fmt.Sprintf("0x%040d", 2), Key: "community/other.png"}
+		if err := r.CreateAttachment(ctx, other); err != nil {
+			t.Fatal(err)
+		}
+
+		// Someone else's upload fails the whole claim
+		first := NewID()
+		if _, err := r.ClaimAttachments(ctx, wallet, first, []string{ids[0], other.ID}); !errors.Is(err, ErrAttachmentUnavailable) {
+			t.Errorf("claim with another's upload: %v, want ErrAttachmentUnavailable", err)
+		}
+
+		claimed, err := r.ClaimAttachments(ctx, wallet, first, []string{ids[2], ids[0], ids[2]})
+		if err != nil || len(claimed) != 2 || claimed[0].ID != ids[2] || claimed[1].ID != ids[0] || claimed[0].Key != "community/2.png" {
+			t.Errorf("ClaimAttachments = %+v, %v", claimed, err)
+		}
+		second := NewID()
+		if _, err := r.ClaimAttachments(ctx, wallet, second, []string{ids[0]}); !errors.Is(err, ErrAttachmentUnavailable) {
+			t.Errorf("claim of a claimed upload: %v, want ErrAttachmentUnavailable", err)
+		}
+
+		if err := r.ReleaseAttachments(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		claimed, err = r.ClaimAttachments(ctx, wallet, second, []string{ids[0], ids[1]})
+		if err != nil || len(claimed) != 2 {
+			t.Fatalf("claim after release = %+v, %v", claimed, err)
+		}
+
+		// Posts keep the blob keys of their attachments
+		post := &Post{ID: second, UserID: 1, WalletAddress: wallet, Content: "pictures", Attachments: claimed, CreatedAt: base}
+		if err := r.CreatePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.GetPost(ctx, second)
+		if err != nil || len(got.Attachments) != 2 || got.Attachments[0].Key != "community/0.png" || got.Attachments[1].ID != ids[1] {
+			t.Errorf("post attachments = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("tag activity", func(t *testing.T) {
+		r := newRepo(t)
+		since := base.Add(-time.Hour)
+		newPost(t, r, 1, since.Add(-time.Minute), "old")
+		recent := newPost(t, r, 1, base, "rice", "tea")
+		newPost(t, r, 2, base)
+		held := newPost(t, r, 2, base, "hidden")
+		if _, err := r.Review(ctx, KindPost, held.ID, Review{Status: moderation.StatusHidden, At: base}); err != nil {
+			t.Fatal(err)
+		}
+		comment := &Comment{PostID: recent.ID, UserID: 2, Content: "#rice", Tags: []string{"rice"}, CreatedAt: base.Add(time.Minute)}
+		if err := r.CreateComment(ctx, comment); err != nil {
+			t.Fatal(err)
+		}
+
+		activity, err := r.TagActivity(ctx, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var posts, comments int
+		for _, a := range activity {
+			if a.Comment {
+				comments++
+				if !reflect.DeepEqual(a.Tags, []string{"rice"}) || !a.At.Equal(comment.CreatedAt) {
+					t.Errorf("comment activity = %+v", a)
+				}
+			} else {
+				posts++
+				if !reflect.DeepEqual(a.Tags, []string{"rice", "tea"}) || !a.At.Equal(base) {
+					t.Errorf("post activity = %+v", a)
+				}
+			}
+		}
+		if posts != 1 || comments != 1 {
+			t.Errorf("activity = %+v, want one post and one comment", activity)
+		}
+	})
+}
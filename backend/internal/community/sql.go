@@ -0,0 +1,978 @@
+package community
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/moderation"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SQLRepository keeps community content in MySQL, in the tables of
+// models.Post, Comment, PostTag, PostLike, Follow, ContentRevision,
+// Attachment and ContentReport
+type SQLRepository struct {
+	DB *gorm.DB
+}
+
+// NewSQLRepository returns a repository on db, whose tables Migrate creates
+func NewSQLRepository(db *gorm.DB) *SQLRepository {
+	return &SQLRepository{DB: db}
+}
+
+// Migrate creates the store's tables, first moving a posts table from
+// before the store existed out of the way to legacy_posts
+func (r *SQLRepository) Migrate(ctx context.Context) error {
+	m := r.DB.Migrator()
+	if legacyPostsTable(r.DB) == "posts" {
+		// Constraint names are unique per schema, and the new table uses it
+		if m.HasConstraint(&models.Post{}, "fk_posts_user") {
+			if err := m.DropConstraint(&models.Post{}, "fk_posts_user"); err != nil {
+				return err
+			}
+		}
+		if err := m.RenameTable("posts", "legacy_posts"); err != nil {
+			return err
+		}
+	}
+
+	return r.DB.WithContext(ctx).AutoMigrate(
+		&models.Post{},
+		&models.Comment{},
+		&models.PostTag{},
+		&models.PostLike{},
+		&models.Follow{},
+		&models.ContentRevision{},
+		&models.Attachment{},
+		&models.ContentReport{},
+	)
+}
+
+var visibleStatuses = []string{"", moderation.StatusVisible}
+
+// CreatePost implements Repository
+func (r *SQLRepository) CreatePost(ctx context.Context, post *Post) error {
+	preparePost(post)
+	row := postRow(post)
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&row).Error; err != nil {
+			return err
+		}
+		return insertPostTags(tx, post.ID, post.Tags, post.CreatedAt)
+	})
+}
+
+func insertPostTags(tx *gorm.DB, postID string, tags []string, createdAt time.Time) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]models.PostTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, models.PostTag{PostID: postID, Tag: tag, CreatedAt: createdAt})
+	}
+	return tx.Create(&rows).Error
+}
+
+// GetPost implements Repository
+func (r *SQLRepository) GetPost(ctx context.Context, id string) (*Post, error) {
+	var row models.Post
+	if err := r.DB.WithContext(ctx).First(&row, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	post := postFromRow(row)
+	return &post, nil
+}
+
+// ListPosts implements Repository
+func (r *SQLRepository) ListPosts(ctx context.Context, q PostQuery) ([]Post, error) {
+	if q.Authors != nil && len(q.Authors) == 0 {
+		return nil, nil
+	}
+	db := r.DB.WithContext(ctx)
+	query := db.Where("deleted_at IS NULL AND status IN ?", visibleStatuses)
+	if q.Tag != "" {
+		query = query.Where("id IN (?)", db.Model(&models.PostTag{}).Select("post_id").Where("tag = ?", q.Tag))
+	}
+	if q.Authors != nil {
+		query = query.Where("user_id IN ?", q.Authors)
+	}
+
+	field := "created_at"
+	if q.Sort == SortHot {
+		field = "hot_score"
+	}
+	if q.After != nil {
+		var key interface{} = q.After.Key
+		if field == "created_at" {
+			key = q.After.Time()
+		}
+		query = query.Where("("+field+" < ? OR ("+field+" = ? AND id < ?))", key, key, q.After.ID)
+	}
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var rows []models.Post
+	if err := query.Order(field + " DESC").Order("id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	posts := make([]Post, 0, len(rows))
+	for _, row := range rows {
+		posts = append(posts, postFromRow(row))
+	}
+	return posts, nil
+}
+
+// EditPost implements Repository
+func (r *SQLRepository) EditPost(ctx context.Context, id, previous string, e Edit) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.Post
+		if err := tx.Select("created_at").First(&row, "id = ?", id).Error; err != nil {
+			return notFound(err)
+		}
+
+		at := stamp(e.At)
+		updates := editUpdates(e, at)
+		updates["updated_at"] = at
+		result := tx.Model(&models.Post{}).
+			Where("id = ? AND content = ? AND deleted_at IS NULL", id, previous).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if err := tx.Where("post_id = ?", id).Delete(&models.PostTag{}).Error; err != nil {
+			return err
+		}
+		if err := insertPostTags(tx, id, e.Tags, row.CreatedAt); err != nil {
+			return err
+		}
+		return tx.Create(&models.ContentRevision{TargetType: KindPost, TargetID: id, Content: previous, EditedAt: at}).Error
+	})
+}
+
+func editUpdates(e Edit, at time.Time) map[string]interface{} {
+	updates := map[string]interface{}{
+		"content":   e.Content,
+		"tags":      encodeList(e.Tags),
+		"mentions":  encodeList(e.Mentions),
+		"edited_at": at,
+	}
+	if e.Hold {
+		updates["status"] = moderation.StatusPendingReview
+		updates["moderation_flagged_terms"] = encodeList(e.FlaggedTerms)
+	}
+	return updates
+}
+
+// DeletePost implements Repository
+func (r *SQLRepository) DeletePost(ctx context.Context, id string, at time.Time) (comments, likes int64, err error) {
+	at = stamp(at)
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Post{}).Where("id = ? AND deleted_at IS NULL", id).
+			Updates(map[string]interface{}{"deleted_at": at, "updated_at": at})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		result = tx.Model(&models.Comment{}).Where("post_id = ? AND deleted_at IS NULL", id).Update("deleted_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		comments = result.RowsAffected
+
+		result = tx.Model(&models.PostLike{}).Where("post_id = ? AND deleted_at IS NULL", id).Update("deleted_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		likes = result.RowsAffected
+		return nil
+	})
+	return comments, likes, err
+}
+
+// PostRevisions implements Repository
+func (r *SQLRepository) PostRevisions(ctx context.Context, id string) ([]Revision, error) {
+	return r.revisions(ctx, KindPost, id)
+}
+
+func (r *SQLRepository) revisions(ctx context.Context, kind, id string) ([]Revision, error) {
+	var rows []models.ContentRevision
+	if err := r.DB.WithContext(ctx).Where("target_type = ? AND target_id = ?", kind, id).
+		Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0, len(rows))
+	for _, row := range rows {
+		revisions = append(revisions, Revision{Content: row.Content, EditedAt: row.EditedAt})
+	}
+	return revisions, nil
+}
+
+// SetLike implements Repository
+func (r *SQLRepository) SetLike(ctx context.Context, postID string, userID uint, like bool) (changed bool, likes int, err error) {
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		delta := 0
+		if like {
+			result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.PostLike{PostID: postID, UserID: userID, CreatedAt: stamp(time.Now())})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				delta = 1
+			}
+		} else {
+			result := tx.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&models.PostLike{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				delta = -1
+			}
+		}
+
+		if delta != 0 {
+			changed = true
+			query := tx.Model(&models.Post{}).Where("id = ?", postID)
+			if delta < 0 {
+				query = query.Where("likes_count > 0")
+			}
+			if err := query.Updates(map[string]interface{}{
+				"likes_count": gorm.Expr("likes_count + ?", delta),
+				"updated_at":  stamp(time.Now()),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		post, err := refreshSQLHotScore(tx, postID)
+		if err != nil {
+			return err
+		}
+		likes = post.LikesCount
+		return nil
+	})
+	return changed, likes, err
+}
+
+// refreshSQLHotScore recomputes a post's hot score from its counts and
+// returns the post's counts
+func refreshSQLHotScore(tx *gorm.DB, postID string) (*models.Post, error) {
+	var row models.Post
+	if err := tx.Select("likes_count", "comments_count", "created_at").First(&row, "id = ?", postID).Error; err != nil {
+		return nil, notFound(err)
+	}
+	err := tx.Model(&models.Post{}).Where("id = ?", postID).
+		Update("hot_score", HotScore(row.LikesCount, row.CommentsCount, row.CreatedAt)).Error
+	return &row, err
+}
+
+// LikedPosts implements Repository
+func (r *SQLRepository) LikedPosts(ctx context.Context, userID uint, postIDs []string) (map[string]bool, error) {
+	liked := map[string]bool{}
+	if userID == 0 || len(postIDs) == 0 {
+		return liked, nil
+	}
+	var ids []string
+	if err := r.DB.WithContext(ctx).Model(&models.PostLike{}).
+		Where("user_id = ? AND post_id IN ? AND deleted_at IS NULL", userID, postIDs).
+		Pluck("post_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return liked, nil
+}
+
+// CreateComment implements Repository
+func (r *SQLRepository) CreateComment(ctx context.Context, comment *Comment) error {
+	prepareComment(comment)
+	row := commentRow(comment)
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&row).Error; err != nil {
+			return err
+		}
+		if comment.ParentID != "" {
+			if err := tx.Model(&models.Comment{}).Where("id = ?", comment.ParentID).
+				Update("replies_count", gorm.Expr("replies_count + 1")).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Post{}).Where("id = ?", comment.PostID).Updates(map[string]interface{}{
+			"comments_count": gorm.Expr("comments_count + 1"),
+			"updated_at":     comment.CreatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		_, err := refreshSQLHotScore(tx, comment.PostID)
+		return err
+	})
+}
+
+// GetComment implements Repository
+func (r *SQLRepository) GetComment(ctx context.Context, id string) (*Comment, error) {
+	var row models.Comment
+	if err := r.DB.WithContext(ctx).First(&row, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	comment := commentFromRow(row)
+	return &comment, nil
+}
+
+// ListComments implements Repository
+func (r *SQLRepository) ListComments(ctx context.Context, q CommentQuery) ([]Comment, int64, error) {
+	query := r.DB.WithContext(ctx).Model(&models.Comment{}).Where("post_id = ?", q.PostID)
+	if q.ParentID != "" {
+		query = query.Where("parent_id = ?", q.ParentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if q.After != nil {
+		at := q.After.Time()
+		query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", at, at, q.After.ID)
+	}
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var rows []models.Comment
+	if err := query.Order("created_at ASC").Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	comments := make([]Comment, 0, len(rows))
+	for _, row := range rows {
+		comments = append(comments, commentFromRow(row))
+	}
+	return comments, total, nil
+}
+
+// EditComment implements Repository
+func (r *SQLRepository) EditComment(ctx context.Context, id, previous string, e Edit) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		at := stamp(e.At)
+		result := tx.Model(&models.Comment{}).
+			Where("id = ? AND content = ? AND deleted_at IS NULL", id, previous).
+			Updates(editUpdates(e, at))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		return tx.Create(&models.ContentRevision{TargetType: KindComment, TargetID: id, Content: previous, EditedAt: at}).Error
+	})
+}
+
+// DeleteComment implements Repository
+func (r *SQLRepository) DeleteComment(ctx context.Context, id string, at time.Time) (bool, error) {
+	deleted := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.Comment
+		if err := tx.Select("post_id", "parent_id").First(&row, "id = ?", id).Error; err != nil {
+			return notFound(err)
+		}
+		at := stamp(at)
+		result := tx.Model(&models.Comment{}).Where("id = ? AND deleted_at IS NULL", id).Update("deleted_at", at)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = true
+
+		if row.ParentID != nil {
+			if err := tx.Model(&models.Comment{}).Where("id = ? AND replies_count > 0", *row.ParentID).
+				Update("replies_count", gorm.Expr("replies_count - 1")).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Post{}).Where("id = ? AND comments_count > 0", row.PostID).Updates(map[string]interface{}{
+			"comments_count": gorm.Expr("comments_count - 1"),
+			"updated_at":     at,
+		}).Error; err != nil {
+			return err
+		}
+		_, err := refreshSQLHotScore(tx, row.PostID)
+		return err
+	})
+	return deleted, err
+}
+
+// CommentRevisions implements Repository
+func (r *SQLRepository) CommentRevisions(ctx context.Context, id string) ([]Revision, error) {
+	return r.revisions(ctx, KindComment, id)
+}
+
+// SetFollow implements Repository
+func (r *SQLRepository) SetFollow(ctx context.Context, followerID, followeeID uint, follow bool) (changed bool, followers int64, err error) {
+	db := r.DB.WithContext(ctx)
+	var result *gorm.DB
+	if follow {
+		result = db.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Follow{
+			ID:         NewID(),
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+			CreatedAt:  stamp(time.Now()),
+		})
+	} else {
+		result = db.Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&models.Follow{})
+	}
+	if result.Error != nil {
+		return false, 0, result.Error
+	}
+
+	if err := db.Model(&models.Follow{}).Where("followee_id = ?", followeeID).Count(&followers).Error; err != nil {
+		return false, 0, err
+	}
+	return result.RowsAffected > 0, followers, nil
+}
+
+// Followees implements Repository
+func (r *SQLRepository) Followees(ctx context.Context, userID uint, limit int) ([]uint, error) {
+	ids := []uint{}
+	err := r.DB.WithContext(ctx).Model(&models.Follow{}).Where("follower_id = ?", userID).
+		Order("created_at DESC").Limit(limit).Pluck("followee_id", &ids).Error
+	return ids, err
+}
+
+// ListFollows implements Repository
+func (r *SQLRepository) ListFollows(ctx context.Context, q FollowQuery) ([]Follow, int64, error) {
+	query := r.DB.WithContext(ctx).Model(&models.Follow{})
+	if q.Followers {
+		query = query.Where("followee_id = ?", q.UserID)
+	} else {
+		query = query.Where("follower_id = ?", q.UserID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if q.After != nil {
+		at := q.After.Time()
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", at, at, q.After.ID)
+	}
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var rows []models.Follow
+	if err := query.Order("created_at DESC").Order("id DESC").Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	follows := make([]Follow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, Follow{ID: row.ID, FollowerID: row.FollowerID, FolloweeID: row.FolloweeID, CreatedAt: row.CreatedAt})
+	}
+	return follows, total, nil
+}
+
+// contentModel returns the row type of a kind of content
+func contentModel(kind string) (interface{}, error) {
+	switch kind {
+	case KindPost:
+		return &models.Post{}, nil
+	case KindComment:
+		return &models.Comment{}, nil
+	}
+	return nil, ErrNotFound
+}
+
+// CreateAttachment implements Repository
+func (r *SQLRepository) CreateAttachment(ctx context.Context, a *Attachment) error {
+	if a.ID == "" {
+		a.ID = NewID()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	a.CreatedAt = stamp(a.CreatedAt)
+	return r.DB.WithContext(ctx).Create(&models.Attachment{
+		ID:             a.ID,
+		UploaderWallet: a.UploaderWallet,
+		ContentType:    a.ContentType,
+		Key:            a.Key,
+		ThumbnailKey:   a.ThumbnailKey,
+		Size:           a.Size,
+		Width:          a.Width,
+		Height:         a.Height,
+		CreatedAt:      a.CreatedAt,
+	}).Error
+}
+
+// ClaimAttachments implements Repository
+func (r *SQLRepository) ClaimAttachments(ctx context.Context, wallet, postID string, ids []string) ([]Attachment, error) {
+	unique := make([]string, 0, len(ids))
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+
+	var rows []models.Attachment
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Attachment{}).
+			Where("id IN ? AND uploader_wallet = ? AND post_id IS NULL", unique, wallet).
+			Update("post_id", postID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(unique)) {
+			return ErrAttachmentUnavailable
+		}
+		return tx.Where("id IN ?", unique).Find(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]models.Attachment{}
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+	claimed := make([]Attachment, 0, len(unique))
+	for _, id := range unique {
+		claimed = append(claimed, attachmentFromRow(byID[id]))
+	}
+	return claimed, nil
+}
+
+// ReleaseAttachments implements Repository
+func (r *SQLRepository) ReleaseAttachments(ctx context.Context, postID string) error {
+	return r.DB.WithContext(ctx).Model(&models.Attachment{}).
+		Where("post_id = ?", postID).Update("post_id", nil).Error
+}
+
+// CreateReport implements Repository. The report is stored and counted in
+// one transaction.
+func (r *SQLRepository) CreateReport(ctx context.Context, report *Report, threshold int) (bool, error) {
+	model, err := contentModel(report.TargetType)
+	if err != nil {
+		return false, err
+	}
+	if report.ID == "" {
+		report.ID = NewID()
+	}
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	report.CreatedAt = stamp(report.CreatedAt)
+	report.Status = ReportOpen
+
+	held := false
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.ContentReport{}).
+			Where("target_id = ? AND reporter_wallet = ?", report.TargetID, report.ReporterWallet).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyReported
+		}
+		if err := tx.Create(&models.ContentReport{
+			ID:             report.ID,
+			TargetType:     report.TargetType,
+			TargetID:       report.TargetID,
+			ReporterWallet: report.ReporterWallet,
+			Reason:         report.Reason,
+			Note:           report.Note,
+			Status:         report.Status,
+			CreatedAt:      report.CreatedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		held, err = countReport(tx, model, report.TargetID, threshold)
+		return err
+	})
+	return held, err
+}
+
+// countReport counts an open report on live content and holds visible
+// content for review once it has threshold open reports
+func countReport(tx *gorm.DB, model interface{}, id string, threshold int) (bool, error) {
+	result := tx.Model(model).Where("id = ? AND deleted_at IS NULL", id).
+		Update("moderation_open_reports", gorm.Expr("moderation_open_reports + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, ErrNotFound
+	}
+
+	var state struct {
+		Status      string
+		OpenReports int `gorm:"column:moderation_open_reports"`
+	}
+	if err := tx.Model(model).Select("status", "moderation_open_reports").Where("id = ?", id).Take(&state).Error; err != nil {
+		return false, err
+	}
+	if state.OpenReports < threshold || !visible(state.Status) {
+		return false, nil
+	}
+	return true, tx.Model(model).Where("id = ?", id).Update("status", moderation.StatusPendingReview).Error
+}
+
+// OpenReports implements Repository
+func (r *SQLRepository) OpenReports(ctx context.Context, targetID string) ([]Report, error) {
+	return r.findReports(r.DB.WithContext(ctx).Where("target_id = ? AND status = ?", targetID, ReportOpen), 0)
+}
+
+// ListReports implements Repository
+func (r *SQLRepository) ListReports(ctx context.Context, status string, limit int) ([]Report, error) {
+	return r.findReports(r.DB.WithContext(ctx).Where("status = ?", status), limit)
+}
+
+func (r *SQLRepository) findReports(query *gorm.DB, limit int) ([]Report, error) {
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var rows []models.ContentReport
+	if err := query.Order("created_at ASC").Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	reports := make([]Report, 0, len(rows))
+	for _, row := range rows {
+		reports = append(reports, reportFromRow(row))
+	}
+	return reports, nil
+}
+
+// ResolveReports implements Repository
+func (r *SQLRepository) ResolveReports(ctx context.Context, targetID string, review Review) (int64, error) {
+	result := r.DB.WithContext(ctx).Model(&models.ContentReport{}).
+		Where("target_id = ? AND status = ?", targetID, ReportOpen).
+		Updates(map[string]interface{}{
+			"status":      ReportResolved,
+			"resolution":  review.Status,
+			"resolved_by": review.By,
+			"resolved_at": stamp(review.At),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Review implements Repository
+func (r *SQLRepository) Review(ctx context.Context, kind, id string, review Review) (string, error) {
+	model, err := contentModel(kind)
+	if err != nil {
+		return "", err
+	}
+	var previous string
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var statuses []string
+		if err := tx.Model(model).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at IS NULL", id).Pluck("status", &statuses).Error; err != nil {
+			return err
+		}
+		if len(statuses) == 0 {
+			return ErrNotFound
+		}
+		previous = statuses[0]
+		at := stamp(review.At)
+		return tx.Model(model).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":                  review.Status,
+			"moderation_open_reports": 0,
+			"moderation_reviewed_by":  review.By,
+			"moderation_review_note":  review.Note,
+			"moderation_reviewed_at":  at,
+		}).Error
+	})
+	return previous, err
+}
+
+// HeldPosts implements Repository
+func (r *SQLRepository) HeldPosts(ctx context.Context, limit int) ([]Post, error) {
+	var rows []models.Post
+	if err := r.DB.WithContext(ctx).Where("status = ? AND deleted_at IS NULL", moderation.StatusPendingReview).
+		Order("created_at ASC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	posts := make([]Post, 0, len(rows))
+	for _, row := range rows {
+		posts = append(posts, postFromRow(row))
+	}
+	return posts, nil
+}
+
+// HeldComments implements Repository
+func (r *SQLRepository) HeldComments(ctx context.Context, limit int) ([]Comment, error) {
+	var rows []models.Comment
+	if err := r.DB.WithContext(ctx).Where("status = ? AND deleted_at IS NULL", moderation.StatusPendingReview).
+		Order("created_at ASC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	comments := make([]Comment, 0, len(rows))
+	for _, row := range rows {
+		comments = append(comments, commentFromRow(row))
+	}
+	return comments, nil
+}
+
+// TagActivity implements Repository
+func (r *SQLRepository) TagActivity(ctx context.Context, since time.Time) ([]Activity, error) {
+	var activity []Activity
+	for _, model := range []interface{}{&models.Post{}, &models.Comment{}} {
+		var rows []struct {
+			Tags      string
+			CreatedAt time.Time
+		}
+		if err := r.DB.WithContext(ctx).Model(model).Select("tags", "created_at").
+			Where("created_at >= ? AND tags <> '' AND deleted_at IS NULL AND status IN ?", stamp(since), visibleStatuses).
+			Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		_, comment := model.(*models.Comment)
+		for _, row := range rows {
+			var tags []string
+			decodeList(row.Tags, &tags)
+			activity = append(activity, Activity{Tags: tags, At: row.CreatedAt, Comment: comment})
+		}
+	}
+	return activity, nil
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// encodeList stores a slice as JSON, and an empty one as ""
+func encodeList[T any](list []T) string {
+	if len(list) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(list)
+	return string(data)
+}
+
+func decodeList[T any](data string, list *[]T) {
+	if data != "" {
+		json.Unmarshal([]byte(data), list)
+	}
+}
+
+func postRow(p *Post) models.Post {
+	return models.Post{
+		ID:            p.ID,
+		UserID:        p.UserID,
+		Username:      p.Username,
+		WalletAddress: p.WalletAddress,
+		Content:       p.Content,
+		LikesCount:    p.LikesCount,
+		CommentsCount: p.CommentsCount,
+		HotScore:      p.HotScore,
+		Attachments:   encodeAttachments(p.Attachments),
+		Tags:          encodeList(p.Tags),
+		Mentions:      encodeList(p.Mentions),
+		Status:        p.Status,
+		Moderation:    moderationRow(p.Moderation),
+		EditedAt:      p.EditedAt,
+		DeletedAt:     p.DeletedAt,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+}
+
+func postFromRow(row models.Post) Post {
+	p := Post{
+		ID:            row.ID,
+		UserID:        row.UserID,
+		Username:      row.Username,
+		WalletAddress: row.WalletAddress,
+		Content:       row.Content,
+		LikesCount:    row.LikesCount,
+		CommentsCount: row.CommentsCount,
+		HotScore:      row.HotScore,
+		Status:        row.Status,
+		Moderation:    moderationFromRow(row.Moderation),
+		EditedAt:      row.EditedAt,
+		DeletedAt:     row.DeletedAt,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}
+	p.Attachments = decodeAttachments(row.Attachments)
+	decodeList(row.Tags, &p.Tags)
+	decodeList(row.Mentions, &p.Mentions)
+	return p
+}
+
+func commentRow(c *Comment) models.Comment {
+	row := models.Comment{
+		ID:            c.ID,
+		PostID:        c.PostID,
+		Depth:         c.Depth,
+		UserID:        c.UserID,
+		Username:      c.Username,
+		WalletAddress: c.WalletAddress,
+		Content:       c.Content,
+		RepliesCount:  c.RepliesCount,
+		Tags:          encodeList(c.Tags),
+		Mentions:      encodeList(c.Mentions),
+		Status:        c.Status,
+		Moderation:    moderationRow(c.Moderation),
+		EditedAt:      c.EditedAt,
+		DeletedAt:     c.DeletedAt,
+		CreatedAt:     c.CreatedAt,
+	}
+	if c.ParentID != "" {
+		parentID := c.ParentID
+		row.ParentID = &parentID
+	}
+	return row
+}
+
+func commentFromRow(row models.Comment) Comment {
+	c := Comment{
+		ID:            row.ID,
+		PostID:        row.PostID,
+		Depth:         row.Depth,
+		UserID:        row.UserID,
+		Username:      row.Username,
+		WalletAddress: row.WalletAddress,
+		Content:       row.Content,
+		RepliesCount:  row.RepliesCount,
+		Status:        row.Status,
+		Moderation:    moderationFromRow(row.Moderation),
+		EditedAt:      row.EditedAt,
+		DeletedAt:     row.DeletedAt,
+		CreatedAt:     row.CreatedAt,
+	}
+	if row.ParentID != nil {
+		c.ParentID = *row.ParentID
+	}
+	decodeList(row.Tags, &c.Tags)
+	decodeList(row.Mentions, &c.Mentions)
+	return c
+}
+
+// attachmentColumn is how a post's attachments are kept in its JSON column,
+// with the blob keys that the API leaves out
+type attachmentColumn struct {
+	ID           string    `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Key          string    `json:"key"`
+	ThumbnailKey string    `json:"thumbnail_key"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func encodeAttachments(attachments []Attachment) string {
+	columns := make([]attachmentColumn, 0, len(attachments))
+	for _, a := range attachments {
+		columns = append(columns, attachmentColumn{
+			ID:           a.ID,
+			ContentType:  a.ContentType,
+			Key:          a.Key,
+			ThumbnailKey: a.ThumbnailKey,
+			Size:         a.Size,
+			Width:        a.Width,
+			Height:       a.Height,
+			CreatedAt:    a.CreatedAt,
+		})
+	}
+	return encodeList(columns)
+}
+
+func decodeAttachments(data string) []Attachment {
+	var columns []attachmentColumn
+	decodeList(data, &columns)
+	var attachments []Attachment
+	for _, c := range columns {
+		attachments = append(attachments, Attachment{
+			ID:           c.ID,
+			ContentType:  c.ContentType,
+			Key:          c.Key,
+			ThumbnailKey: c.ThumbnailKey,
+			Size:         c.Size,
+			Width:        c.Width,
+			Height:       c.Height,
+			CreatedAt:    c.CreatedAt,
+		})
+	}
+	return attachments
+}
+
+func attachmentFromRow(row models.Attachment) Attachment {
+	return Attachment{
+		ID:             row.ID,
+		UploaderWallet: row.UploaderWallet,
+		ContentType:    row.ContentType,
+		Key:            row.Key,
+		ThumbnailKey:   row.ThumbnailKey,
+		Size:           row.Size,
+		Width:          row.Width,
+		Height:         row.Height,
+		CreatedAt:      row.CreatedAt,
+	}
+}
+
+func reportFromRow(row models.ContentReport) Report {
+	return Report{
+		ID:             row.ID,
+		TargetType:     row.TargetType,
+		TargetID:       row.TargetID,
+		ReporterWallet: row.ReporterWallet,
+		Reason:         row.Reason,
+		Note:           row.Note,
+		Status:         row.Status,
+		Resolution:     row.Resolution,
+		ResolvedBy:     row.ResolvedBy,
+		ResolvedAt:     row.ResolvedAt,
+		CreatedAt:      row.CreatedAt,
+	}
+}
+
+func moderationRow(m *Moderation) models.ContentModeration {
+	if m == nil {
+		return models.ContentModeration{}
+	}
+	return models.ContentModeration{
+		FlaggedTerms: encodeList(m.FlaggedTerms),
+		OpenReports:  m.OpenReports,
+		ReviewedBy:   m.ReviewedBy,
+		ReviewNote:   m.ReviewNote,
+		ReviewedAt:   m.ReviewedAt,
+	}
+}
+
+// moderationFromRow returns nil for content never flagged, reported or
+// reviewed
+func moderationFromRow(row models.ContentModeration) *Moderation {
+	if row == (models.ContentModeration{}) {
+		return nil
+	}
+	m := &Moderation{
+		OpenReports: row.OpenReports,
+		ReviewedBy:  row.ReviewedBy,
+		ReviewNote:  row.ReviewNote,
+		ReviewedAt:  row.ReviewedAt,
+	}
+	decodeList(row.FlaggedTerms, &m.FlaggedTerms)
+	return m
+}
@@ -0,0 +1,132 @@
+package community
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+)
+
+// Limits on what one post or comment can carry
+const (
+	MaxTags     = 10
+	MaxMentions = 10
+	maxTagRunes = 30
+)
+
+// mentionPattern matches @username and @0x wallet addresses. A mention must
+// start the text or follow a character that cannot be part of an email
+// address, so "a@b.com" is not a mention.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@(0x[0-9a-fA-F]{40}|[\p{L}\p{N}_]{2,30})`)
+
+// ParseTags returns the distinct #topics in text, lowercased, in order of
+// appearance. Tags are letters, digits and underscores after a "#", may be
+// closed Weibo-style by a second "#", and cannot be all digits.
+func ParseTags(text string) []string {
+	runes := []rune(text)
+	var tags []string
+	seen := map[string]bool{}
+	for i := 0; i < len(runes) && len(tags) < MaxTags; i++ {
+		if runes[i] != '#' || (i > 0 && isTagRune(runes[i-1])) {
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && isTagRune(runes[j]) {
+			j++
+		}
+		tag := strings.ToLower(string(runes[i+1 : j]))
+		n := j - i - 1
+		if n > 0 && n <= maxTagRunes && !allDigits(tag) && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		if n > 0 && j < len(runes) && runes[j] == '#' {
+			// Closing "#" of a Weibo-style tag
+			j++
+		}
+		i = j - 1
+	}
+	return tags
+}
+
+// NormalizeTag lowercases a tag and strips a leading "#", returning "" if
+// it is not a valid tag
+func NormalizeTag(tag string) string {
+	tag = strings.TrimPrefix(tag, "#")
+	n := 0
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return ""
+		}
+		n++
+	}
+	if n == 0 || n > maxTagRunes || allDigits(tag) {
+		return ""
+	}
+	return strings.ToLower(tag)
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseMentions returns the distinct @handles in text without the "@", in
+// order of appearance. Wallet addresses are lowercased.
+func ParseMentions(text string) []string {
+	var handles []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		handle := m[1]
+		if strings.HasPrefix(handle, "0x") {
+			handle = strings.ToLower(handle)
+		}
+		key := strings.ToLower(handle)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		handles = append(handles, handle)
+		if len(handles) == MaxMentions {
+			break
+		}
+	}
+	return handles
+}
+
+// ResolveMentions looks up the users behind handles: wallet addresses match
+// exactly, usernames only when a single user has that name. Unknown and
+// ambiguous handles are dropped.
+func ResolveMentions(db *gorm.DB, handles []string) ([]models.User, error) {
+	var users []models.User
+	seen := map[uint]bool{}
+	for _, handle := range handles {
+		var matches []models.User
+		query := db.Select("id", "wallet_address", "username").Limit(2)
+		if strings.HasPrefix(handle, "0x") {
+			query = query.Where("LOWER(wallet_address) = ?", handle)
+		} else {
+			query = query.Where("username = ?", handle)
+		}
+		if err := query.Find(&matches).Error; err != nil {
+			return nil, err
+		}
+		if len(matches) != 1 || seen[matches[0].ID] {
+			continue
+		}
+		seen[matches[0].ID] = true
+		users = append(users, matches[0])
+	}
+	return users, nil
+}
@@ -0,0 +1,68 @@
+package community
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Activity is one post or comment using some tags
+type Activity struct {
+	Tags    []string
+	At      time.Time
+	Comment bool
+}
+
+// TopicScore is a tag's recency-weighted activity
+type TopicScore struct {
+	Tag      string  `json:"tag"`
+	Score    float64 `json:"score"`
+	Posts    int     `json:"posts"`
+	Comments int     `json:"comments"`
+}
+
+// Trending ranks tags by activity, each post or comment counting 1 when new
+// and half as much every halfLife after. Ties go to the tag with more posts,
+// then alphabetically.
+func Trending(activity []Activity, now time.Time, halfLife time.Duration, limit int) []TopicScore {
+	scores := map[string]*TopicScore{}
+	for _, a := range activity {
+		age := now.Sub(a.At)
+		if age < 0 {
+			age = 0
+		}
+		weight := math.Exp2(-age.Hours() / halfLife.Hours())
+		for _, tag := range a.Tags {
+			s, ok := scores[tag]
+			if !ok {
+				s = &TopicScore{Tag: tag}
+				scores[tag] = s
+			}
+			s.Score += weight
+			if a.Comment {
+				s.Comments++
+			} else {
+				s.Posts++
+			}
+		}
+	}
+
+	ranked := make([]TopicScore, 0, len(scores))
+	for _, s := range scores {
+		s.Score = math.Round(s.Score*1000) / 1000
+		ranked = append(ranked, *s)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if ranked[i].Posts != ranked[j].Posts {
+			return ranked[i].Posts > ranked[j].Posts
+		}
+		return ranked[i].Tag < ranked[j].Tag
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
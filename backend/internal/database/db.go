@@ -45,15 +45,40 @@ func Initialize(cfg *config.Config) error {
 
 // autoMigrate runs database migrations
 func autoMigrate() error {
-	return DB.AutoMigrate(
+	if err := migrateLegacyProductColumns(); err != nil {
+		return err
+	}
+	if err := migrateMarketDataIndex(); err != nil {
+		return err
+	}
+
+	if err := DB.AutoMigrate(
 		&models.User{},
 		&models.News{},
 		&models.MarketData{},
-		&models.Post{},
+		&models.MarketCandle{},
+		&models.WatchlistItem{},
+		&models.AlertRule{},
+		&models.NotificationEndpoint{},
+		&models.BlockedTerm{},
 		&models.Product{},
 		&models.Transaction{},
 		&models.UserAsset{},
-	)
+		&models.NFTCustody{},
+		&models.NFTTransfer{},
+		&models.Listing{},
+		&models.Offer{},
+		&models.StockReservation{},
+		&models.YieldAccrual{},
+		&models.RiskProfile{},
+		&models.RiskAcknowledgement{},
+		&models.KYCSubmission{},
+		&models.KYCDecision{},
+	); err != nil {
+		return err
+	}
+
+	return backfillProductPricing()
 }
 
 // GetDB returns the database instance
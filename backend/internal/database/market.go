@@ -0,0 +1,46 @@
+package database
+
+import (
+	"log"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+const marketDataIndex = "idx_market_product_time"
+
+// migrateMarketDataIndex makes the (product_name, timestamp) index of
+// market_data unique. AutoMigrate does not change an existing index, so the
+// old non-unique one is dropped here after removing the duplicate quotes it
+// let through, keeping the first of each. It is a no-op once done.
+func migrateMarketDataIndex() error {
+	m := DB.Migrator()
+	if !m.HasTable(&models.MarketData{}) || !m.HasIndex(&models.MarketData{}, marketDataIndex) {
+		return nil
+	}
+
+	indexes, err := m.GetIndexes(&models.MarketData{})
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		if idx.Name() != marketDataIndex {
+			continue
+		}
+		if unique, _ := idx.Unique(); unique {
+			return nil
+		}
+	}
+
+	// The derived table lets MySQL read the table it deletes from
+	result := DB.Exec(`DELETE FROM market_data WHERE id NOT IN (
+		SELECT id FROM (SELECT MIN(id) AS id FROM market_data GROUP BY product_name, timestamp) AS keep
+	)`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Removed %d duplicate market data rows", result.RowsAffected)
+	}
+
+	return m.DropIndex(&models.MarketData{}, marketDataIndex)
+}
@@ -8,15 +8,20 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID            uint           `gorm:"primarykey" json:"id"`
-	WalletAddress string         `gorm:"uniqueIndex;size:42" json:"wallet_address"`
-	Username      string         `gorm:"size:100" json:"username"`
-	Email         string         `gorm:"uniqueIndex;size:100" json:"email"`
-	Password      string         `gorm:"size:255" json:"-"` // Password hash, not returned in JSON
-	Balance       float64        `gorm:"default:0" json:"balance"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primarykey" json:"id"`
+	WalletAddress  string         `gorm:"uniqueIndex;size:42" json:"wallet_address"`
+	Username       string         `gorm:"size:100" json:"username"`
+	Email          string         `gorm:"uniqueIndex;size:100" json:"email"`
+	Password       string         `gorm:"size:255" json:"-"` // Password hash, not returned in JSON
+	Balance        float64        `gorm:"default:0" json:"balance"`
+	RiskScore      int            `gorm:"default:0" json:"risk_score"`
+	RiskTier       string         `gorm:"size:20" json:"risk_tier"` // "", "conservative", "balanced", "aggressive"
+	RiskAssessedAt *time.Time     `json:"risk_assessed_at,omitempty"`
+	KYCStatus      string         `gorm:"size:20;default:'none'" json:"kyc_status"` // "none", "pending", "verified", "rejected"
+	KYCTier        int            `gorm:"default:0" json:"kyc_tier"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // News represents news or policy articles
@@ -34,23 +39,197 @@ type News struct {
 // MarketData represents commodity price data
 type MarketData struct {
 	ID            uint      `gorm:"primarykey" json:"id"`
-	ProductName   string    `gorm:"size:100;index" json:"product_name"`
+	ProductName   string    `gorm:"size:100;index;uniqueIndex:idx_market_product_time,priority:1" json:"product_name"`
 	Icon          string    `gorm:"size:50" json:"icon"`
 	Price         float64   `json:"price"`
+	Volume        float64   `gorm:"default:0" json:"volume"`
 	Change        float64   `json:"change"`
 	ChangePercent string    `gorm:"size:20" json:"change_percent"`
-	Timestamp     time.Time `gorm:"index" json:"timestamp"`
+	Timestamp     time.Time `gorm:"index;uniqueIndex:idx_market_product_time,priority:2" json:"timestamp"`
 }
 
-// Post represents a community post
+// MarketCandle is an OHLC rollup of MarketData ticks over one interval
+type MarketCandle struct {
+	ID          uint      `gorm:"primarykey" json:"-"`
+	ProductName string    `gorm:"size:100;uniqueIndex:idx_candle_key,priority:1" json:"product_name"`
+	Interval    string    `gorm:"size:4;uniqueIndex:idx_candle_key,priority:2" json:"interval"` // "1h", "1d", "1w"
+	BucketStart time.Time `gorm:"uniqueIndex:idx_candle_key,priority:3" json:"time"`
+	Open        float64   `json:"open"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Close       float64   `json:"close"`
+	Volume      float64   `json:"volume"`
+	Ticks       int       `json:"ticks"`
+	OpenAt      time.Time `json:"-"` // Timestamps of the ticks that set Open and Close
+	CloseAt     time.Time `json:"-"`
+}
+
+// WatchlistItem is a product a user follows
+type WatchlistItem struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_watch_user_product,priority:1" json:"user_id"`
+	ProductName string    `gorm:"size:100;uniqueIndex:idx_watch_user_product,priority:2" json:"product_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AlertRule notifies a user when a product's price crosses a threshold or
+// moves more than a percentage in a day
+type AlertRule struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	UserID          uint       `gorm:"index" json:"user_id"`
+	ProductName     string     `gorm:"size:100;index" json:"product_name"`
+	Kind            string     `gorm:"size:20" json:"kind"` // "above", "below", "day_move"
+	Threshold       float64    `json:"threshold"`           // Price, or percent for day_move
+	Active          bool       `gorm:"default:true" json:"active"`
+	LastPrice       float64    `json:"last_price"` // Price seen at the last evaluation
+	LastPriceAt     *time.Time `json:"last_price_at,omitempty"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// NotificationEndpoint is a webhook URL or push token notifications are
+// delivered to
+type NotificationEndpoint struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Kind      string    `gorm:"size:20" json:"kind"` // "webhook" or "push"
+	Target    string    `gorm:"size:512" json:"target"`
+	Platform  string    `gorm:"size:20" json:"platform,omitempty"` // Push only: "ios", "android", "web"
+	Secret    string    `gorm:"size:64" json:"-"`                  // Webhook only: signs deliveries
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Post is a community post in the MySQL community store. IDs come from
+// community.NewID, as in the MongoDB store, and list columns hold JSON.
 type Post struct {
-	ID            uint      `gorm:"primarykey" json:"id"`
-	UserID        uint      `gorm:"index" json:"user_id"`
-	User          User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Content       string    `gorm:"type:text" json:"content"`
-	LikesCount    int       `gorm:"default:0" json:"likes_count"`
-	CommentsCount int       `gorm:"default:0" json:"comments_count"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            string            `gorm:"primaryKey;size:24" json:"id"`
+	UserID        uint              `gorm:"index:idx_post_user_time,priority:1" json:"user_id"`
+	User          User              `gorm:"foreignKey:UserID" json:"-"`
+	Username      string            `gorm:"size:100" json:"username"`
+	WalletAddress string            `gorm:"size:42" json:"wallet_address"`
+	Content       string            `gorm:"type:text" json:"content"`
+	LikesCount    int               `gorm:"default:0" json:"likes_count"`
+	CommentsCount int               `gorm:"default:0" json:"comments_count"`
+	HotScore      float64           `gorm:"index:idx_post_hot" json:"-"`
+	Attachments   string            `gorm:"type:text" json:"-"`
+	Tags          string            `gorm:"type:text" json:"-"` // Also in PostTag
+	Mentions      string            `gorm:"type:text" json:"-"`
+	Status        string            `gorm:"size:20;index:idx_post_status_time,priority:1" json:"status"`
+	Moderation    ContentModeration `gorm:"embedded;embeddedPrefix:moderation_" json:"-"`
+	EditedAt      *time.Time        `json:"edited_at,omitempty"`
+	DeletedAt     *time.Time        `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt     time.Time         `gorm:"index;index:idx_post_user_time,priority:2;index:idx_post_status_time,priority:2" json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// Comment is a comment on a community post in the MySQL community store
+type Comment struct {
+	ID            string            `gorm:"primaryKey;size:24" json:"id"`
+	PostID        string            `gorm:"size:24;index:idx_comment_thread,priority:1" json:"post_id"`
+	ParentID      *string           `gorm:"size:24;index:idx_comment_thread,priority:2" json:"parent_id"`
+	Depth         int               `gorm:"default:0" json:"depth"`
+	UserID        uint              `gorm:"index" json:"user_id"`
+	User          User              `gorm:"foreignKey:UserID" json:"-"`
+	Username      string            `gorm:"size:100" json:"username"`
+	WalletAddress string            `gorm:"size:42" json:"wallet_address"`
+	Content       string            `gorm:"type:text" json:"content"`
+	RepliesCount  int               `gorm:"default:0" json:"replies_count"`
+	Tags          string            `gorm:"type:text" json:"-"`
+	Mentions      string            `gorm:"type:text" json:"-"`
+	Status        string            `gorm:"size:20;index:idx_comment_status_time,priority:1" json:"status"`
+	Moderation    ContentModeration `gorm:"embedded;embeddedPrefix:moderation_" json:"-"`
+	EditedAt      *time.Time        `json:"edited_at,omitempty"`
+	DeletedAt     *time.Time        `json:"deleted_at,omitempty"`
+	CreatedAt     time.Time         `gorm:"index:idx_comment_thread,priority:3;index:idx_comment_status_time,priority:2" json:"created_at"`
+}
+
+// ContentModeration is the moderation state of a post or comment
+type ContentModeration struct {
+	FlaggedTerms string     `gorm:"type:text" json:"flagged_terms"`
+	OpenReports  int        `gorm:"default:0" json:"open_reports"`
+	ReviewedBy   string     `gorm:"size:100" json:"reviewed_by"`
+	ReviewNote   string     `gorm:"size:500" json:"review_note"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+}
+
+// PostTag indexes a post under one of its #topics
+type PostTag struct {
+	PostID    string    `gorm:"primaryKey;size:24" json:"post_id"`
+	Tag       string    `gorm:"primaryKey;size:30;index:idx_post_tag_time,priority:1" json:"tag"`
+	CreatedAt time.Time `gorm:"index:idx_post_tag_time,priority:2" json:"created_at"` // The post's
+}
+
+// PostLike records that a user liked a post
+type PostLike struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	PostID    string     `gorm:"size:24;uniqueIndex:idx_post_like,priority:1" json:"post_id"`
+	UserID    uint       `gorm:"uniqueIndex:idx_post_like,priority:2" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // Set when the post is deleted
+}
+
+// Follow records that a user follows another's posts
+type Follow struct {
+	ID         string    `gorm:"primaryKey;size:24" json:"id"`
+	FollowerID uint      `gorm:"uniqueIndex:idx_follow,priority:1" json:"follower_id"`
+	Follower   User      `gorm:"foreignKey:FollowerID" json:"-"`
+	FolloweeID uint      `gorm:"uniqueIndex:idx_follow,priority:2;index:idx_followee_time,priority:1" json:"followee_id"`
+	Followee   User      `gorm:"foreignKey:FolloweeID" json:"-"`
+	CreatedAt  time.Time `gorm:"index:idx_followee_time,priority:2" json:"created_at"`
+}
+
+// ContentRevision is the content of a post or comment before an edit
+type ContentRevision struct {
+	ID         uint      `gorm:"primarykey" json:"-"`
+	TargetType string    `gorm:"size:10;index:idx_revision_target,priority:1" json:"-"` // "post" or "comment"
+	TargetID   string    `gorm:"size:24;index:idx_revision_target,priority:2" json:"-"`
+	Content    string    `gorm:"type:text" json:"content"`
+	EditedAt   time.Time `json:"edited_at"`
+}
+
+// ContentReport is a user's report of a post or comment in the MySQL
+// community store
+type ContentReport struct {
+	ID             string     `gorm:"primaryKey;size:24" json:"id"`
+	TargetType     string     `gorm:"size:10" json:"target_type"` // "post" or "comment"
+	TargetID       string     `gorm:"size:24;uniqueIndex:idx_report_reporter,priority:1" json:"target_id"`
+	ReporterWallet string     `gorm:"size:64;uniqueIndex:idx_report_reporter,priority:2" json:"reporter_wallet"`
+	Reason         string     `gorm:"size:20" json:"reason"`
+	Note           string     `gorm:"size:500" json:"note"`
+	Status         string     `gorm:"size:20;index:idx_report_status_time,priority:1" json:"status"` // "open" or "resolved"
+	Resolution     string     `gorm:"size:20" json:"resolution"`                                     // Status given to the target
+	ResolvedBy     string     `gorm:"size:100" json:"resolved_by"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	CreatedAt      time.Time  `gorm:"index:idx_report_status_time,priority:2" json:"created_at"`
+}
+
+// Attachment is an uploaded image in the MySQL community store. It has no
+// post until one claims it.
+type Attachment struct {
+	ID             string    `gorm:"primaryKey;size:24" json:"id"`
+	UploaderWallet string    `gorm:"size:64;index" json:"-"`
+	PostID         *string   `gorm:"size:24;index" json:"-"`
+	ContentType    string    `gorm:"size:50" json:"content_type"`
+	Key            string    `gorm:"size:255" json:"-"` // Blob store key of the image
+	ThumbnailKey   string    `gorm:"size:255" json:"-"` // Blob store key of the JPEG thumbnail
+	Size           int64     `json:"size"`
+	Width          int       `json:"width"`
+	Height         int       `json:"height"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// BlockedTerm is a community blocklist rule: a keyword or regular
+// expression, and whether matching content is refused or held for review
+type BlockedTerm struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Kind      string    `gorm:"size:20" json:"kind"` // "keyword" or "regex"
+	Pattern   string    `gorm:"size:255" json:"pattern"`
+	Action    string    `gorm:"size:20" json:"action"` // "block" or "review"
+	Note      string    `gorm:"size:255" json:"note,omitempty"`
+	CreatedBy string    `gorm:"size:100" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Product represents an RWA investment product
@@ -59,13 +238,18 @@ type Product struct {
 	Name            string    `gorm:"size:255" json:"name"`
 	Description     string    `gorm:"type:text" json:"description"`
 	Icon            string    `gorm:"size:50" json:"icon"`
-	YieldRate       string    `gorm:"size:20" json:"yield_rate"`
-	Price           string    `gorm:"size:50" json:"price"`
-	Duration        string    `gorm:"size:50" json:"duration"`
-	RiskLevel       string    `gorm:"size:20" json:"risk_level"` // "low", "medium", "high"
-	ProductType     string    `gorm:"size:20;default:'digital'" json:"product_type"` // "digital", "physical"
-	Category        string    `gorm:"size:50" json:"category"` // "music", "art", "game", "video", etc.
-	Stock           int       `gorm:"default:0" json:"stock"` // Available quantity for digital goods
+	Price           float64   `gorm:"type:decimal(18,2);default:0" json:"price"`          // Unit price in yuan, 0 for open amounts
+	MinInvestment   float64   `gorm:"type:decimal(18,2);default:0" json:"min_investment"` // Minimum ticket in yuan
+	AnnualYield     float64   `gorm:"type:decimal(8,4);default:0" json:"annual_yield"`    // Percent per year
+	TermDays        int       `gorm:"default:0" json:"term_days"`                         // 0 for open-ended products
+	PriceLabel      string    `gorm:"size:50" json:"price_label"`                         // Display text, e.g. "¥299"
+	YieldLabel      string    `gorm:"size:20" json:"yield_label"`                         // Display text, e.g. "8.5%" or "Limited Edition"
+	DurationLabel   string    `gorm:"size:50" json:"duration_label"`                      // Display text, e.g. "12个月"
+	RiskLevel       string    `gorm:"size:20;index" json:"risk_level"`                    // "low", "medium", "high"
+	ProductType     string    `gorm:"size:20;default:'digital'" json:"product_type"`      // "digital", "physical"
+	Category        string    `gorm:"size:50" json:"category"`                            // "music", "art", "game", "video", etc.
+	Stock           int       `gorm:"default:0" json:"stock"`                             // Available quantity for digital goods; 0 is unlimited, -1 sold out
+	Commodity       string    `gorm:"size:100;index" json:"commodity,omitempty"`          // MarketData product name the value tracks
 	ContractAddress string    `gorm:"size:42" json:"contract_address"`
 	CreatedAt       time.Time `json:"created_at"`
 }
@@ -85,16 +269,168 @@ type Transaction struct {
 
 // UserAsset represents a user's purchased product/asset
 type UserAsset struct {
-	ID               uint      `gorm:"primarykey" json:"id"`
-	WalletAddress    string    `gorm:"size:42;index" json:"wallet_address"`
-	ProductID        uint      `gorm:"index" json:"product_id"`
-	Product          Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
-	TokenID          string    `gorm:"size:100" json:"token_id"`
-	NFTAddress       string    `gorm:"size:42" json:"nft_address"`
-	InvestmentAmount float64   `json:"investment_amount"`
-	PurchaseDate     time.Time `json:"purchase_date"`
-	Status           string    `gorm:"size:20;default:'active'" json:"status"` // "active", "sold", "matured"
-	TxHash           string    `gorm:"size:66" json:"tx_hash"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               uint       `gorm:"primarykey" json:"id"`
+	WalletAddress    string     `gorm:"size:42;index" json:"wallet_address"`
+	ProductID        uint       `gorm:"index" json:"product_id"`
+	Product          Product    `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	TokenID          string     `gorm:"size:100" json:"token_id"`
+	NFTAddress       string     `gorm:"size:42" json:"nft_address"`
+	InvestmentAmount float64    `json:"investment_amount"`
+	PurchaseDate     time.Time  `json:"purchase_date"`
+	TermStartDate    *time.Time `json:"term_start_date,omitempty"`              // Start of the product term when bought on resale
+	Status           string     `gorm:"size:20;default:'active'" json:"status"` // "active", "settling", "sold", "matured"
+	TxHash           string     `gorm:"size:66" json:"tx_hash"`
+	AccruedYield     float64    `gorm:"default:0" json:"accrued_yield"`
+	PaidYield        float64    `gorm:"default:0" json:"paid_yield"`
+	AccruedThrough   *time.Time `json:"accrued_through,omitempty"` // Accrual covers days before this date
+	LastPayoutAt     *time.Time `json:"last_payout_at,omitempty"`
+	MaturedAt        *time.Time `json:"matured_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// YieldAccrual is one day of yield accrued on a user asset
+type YieldAccrual struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	AssetID       uint      `gorm:"uniqueIndex:idx_accrual_asset_date" json:"asset_id"`
+	WalletAddress string    `gorm:"size:42;index" json:"wallet_address"`
+	AccrualDate   time.Time `gorm:"type:date;uniqueIndex:idx_accrual_asset_date" json:"accrual_date"`
+	Amount        float64   `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NFTCustody records one holder's custody period of an NFT
+type NFTCustody struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	NFTAddress string     `gorm:"size:64;index:idx_custody_token,priority:1" json:"nft_address"`
+	TokenID    string     `gorm:"size:100;index:idx_custody_token,priority:2" json:"token_id"`
+	Holder     string     `gorm:"size:64;index" json:"holder"`
+	TxHash     string     `gorm:"size:66" json:"tx_hash"`
+	AcquiredAt time.Time  `json:"acquired_at"`
+	ReleasedAt *time.Time `json:"released_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NFTTransfer is a relayed transfer of an NFT between wallets. The asset and
+// custody records move only once it is confirmed on chain.
+type NFTTransfer struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	UserID     uint      `gorm:"index" json:"user_id"`
+	AssetID    *uint     `json:"asset_id,omitempty"` // Sender's active asset for the token, if any
+	NFTAddress string    `gorm:"size:64" json:"nft_address"`
+	TokenID    string    `gorm:"size:100" json:"token_id"`
+	FromWallet string    `gorm:"size:64" json:"from_wallet"`
+	ToWallet   string    `gorm:"size:64" json:"to_wallet"`
+	TxHash     string    `gorm:"size:66;uniqueIndex" json:"tx_hash"`
+	Status     string    `gorm:"size:20;index;default:'pending'" json:"status"` // "pending", "confirmed", "failed"
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Listing is a UserAsset offered for resale on the secondary marketplace
+type Listing struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	AssetID      uint       `gorm:"index" json:"asset_id"`
+	Asset        UserAsset  `gorm:"foreignKey:AssetID" json:"asset,omitempty"`
+	SellerID     uint       `gorm:"index" json:"seller_id"`
+	Price        float64    `gorm:"type:decimal(18,2)" json:"price"`
+	Status       string     `gorm:"size:20;index;default:'open'" json:"status"` // "open", "settling", "sold", "cancelled"
+	BuyerID      *uint      `gorm:"index" json:"buyer_id,omitempty"`
+	OfferID      *uint      `json:"offer_id,omitempty"`                             // Accepted offer being settled, if any
+	SalePrice    float64    `gorm:"type:decimal(18,2)" json:"sale_price,omitempty"` // Price the buyer pays; set once settling starts
+	SettlementTx string     `gorm:"size:66" json:"settlement_tx,omitempty"`
+	SoldAt       *time.Time `json:"sold_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Offer is a bid on a listing; the amount is held in escrow from the
+// buyer's balance until the offer is accepted, rejected or withdrawn
+type Offer struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ListingID uint      `gorm:"index" json:"listing_id"`
+	BuyerID   uint      `gorm:"index" json:"buyer_id"`
+	Amount    float64   `gorm:"type:decimal(18,2)" json:"amount"`
+	Status    string    `gorm:"size:20;index;default:'pending'" json:"status"` // "pending", "accepted", "rejected", "withdrawn"
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockReservation holds product stock for a purchase until it is paid
+// for or expires
+type StockReservation struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	ProductID     uint      `gorm:"index" json:"product_id"`
+	WalletAddress string    `gorm:"size:64;index" json:"wallet_address"`
+	Quantity      int       `json:"quantity"`
+	Status        string    `gorm:"size:20;index" json:"status"` // "pending", "confirmed", "released", "expired"
+	TransactionID *uint     `gorm:"index" json:"transaction_id,omitempty"`
+	Unlimited     bool      `json:"unlimited,omitempty"` // Product stock is unlimited, so none is held
+	ExpiresAt     time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RiskProfile is one completed risk questionnaire
+type RiskProfile struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Answers   string    `gorm:"type:text" json:"answers"` // JSON object of question ID to option ID
+	Score     int       `json:"score"`
+	Tier      string    `gorm:"size:20" json:"tier"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RiskAcknowledgement records a user accepting a product riskier than their
+// tier, for compliance review
+type RiskAcknowledgement struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	UserID        uint      `gorm:"index" json:"user_id"`
+	WalletAddress string    `gorm:"size:42;index" json:"wallet_address"`
+	ProductID     uint      `gorm:"index" json:"product_id"`
+	ProductRisk   string    `gorm:"size:20" json:"product_risk"`
+	RiskTier      string    `gorm:"size:20" json:"risk_tier"`
+	Amount        float64   `json:"amount"`
+	Source        string    `gorm:"size:20" json:"source"` // "invest", "record", "marketplace", "marketplace_offer"
+	IPAddress     string    `gorm:"size:45" json:"ip_address"`
+	UserAgent     string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// KYCSubmission is one set of identity documents submitted for verification
+type KYCSubmission struct {
+	ID                uint       `gorm:"primarykey" json:"id"`
+	UserID            uint       `gorm:"index" json:"user_id"`
+	FullName          string     `gorm:"size:100" json:"full_name"`
+	IDType            string     `gorm:"size:20" json:"id_type"` // "resident_id", "passport"
+	IDNumberMasked    string     `gorm:"size:32" json:"id_number"`
+	IDNumberHash      string     `gorm:"size:64;index" json:"-"`
+	FrontPath         string     `gorm:"size:255" json:"-"`
+	BackPath          string     `gorm:"size:255" json:"-"`
+	SelfiePath        string     `gorm:"size:255" json:"-"`
+	RequestedTier     int        `json:"requested_tier"`
+	Status            string     `gorm:"size:20;index" json:"status"` // "pending", "verified", "rejected"
+	VerifierName      string     `gorm:"size:50" json:"verifier_name"`
+	VerifierDecision  string     `gorm:"size:20" json:"verifier_decision"` // "approve", "reject", "manual"
+	VerifierReason    string     `gorm:"size:255" json:"verifier_reason"`
+	VerifierReference string     `gorm:"size:100" json:"verifier_reference,omitempty"`
+	ApprovedTier      int        `json:"approved_tier"`
+	ReviewedBy        string     `gorm:"size:100" json:"reviewed_by,omitempty"` // "admin:<name>" or "verifier:<name>"
+	ReviewNote        string     `gorm:"size:500" json:"review_note,omitempty"`
+	ReviewedAt        *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// KYCDecision is an audit record of one KYC status change and who made it
+type KYCDecision struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	SubmissionID uint      `gorm:"index" json:"submission_id"`
+	UserID       uint      `gorm:"index" json:"user_id"`
+	FromStatus   string    `gorm:"size:20" json:"from_status"`
+	ToStatus     string    `gorm:"size:20" json:"to_status"`
+	Tier         int       `json:"tier"`
+	Actor        string    `gorm:"size:100" json:"actor"`
+	Note         string    `gorm:"size:500" json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
 }
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"log"
+	"strings"
+
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/pricing"
+)
+
+// legacyProductColumns maps the free-form string columns products used to
+// have to the label columns that now keep their display text
+var legacyProductColumns = []struct{ from, to string }{
+	{"yield_rate", "yield_label"},
+	{"duration", "duration_label"},
+}
+
+// migrateLegacyProductColumns renames the legacy string columns out of the
+// way before AutoMigrate creates the typed ones. It is a no-op once done.
+func migrateLegacyProductColumns() error {
+	m := DB.Migrator()
+	if !m.HasTable(&models.Product{}) {
+		return nil
+	}
+
+	for _, c := range legacyProductColumns {
+		if m.HasColumn(&models.Product{}, c.from) && !m.HasColumn(&models.Product{}, c.to) {
+			if err := m.RenameColumn(&models.Product{}, c.from, c.to); err != nil {
+				return err
+			}
+			log.Printf("Renamed products.%s to %s", c.from, c.to)
+		}
+	}
+
+	// price keeps its name but changes from text like "¥299" to a decimal
+	columns, err := m.ColumnTypes(&models.Product{})
+	if err != nil {
+		return err
+	}
+	for _, col := range columns {
+		if col.Name() != "price" || !strings.Contains(strings.ToUpper(col.DatabaseTypeName()), "CHAR") {
+			continue
+		}
+		if m.HasColumn(&models.Product{}, "price_label") {
+			break
+		}
+		if err := m.RenameColumn(&models.Product{}, "price", "price_label"); err != nil {
+			return err
+		}
+		log.Println("Renamed products.price to price_label")
+	}
+	return nil
+}
+
+// backfillProductPricing fills the typed price, yield rate and term of
+// products that only have the legacy display strings, and links products to
+// the commodity their name refers to so they are marked to market
+func backfillProductPricing() error {
+	var commodities []string
+	if err := DB.Model(&models.MarketData{}).Distinct("product_name").Order("product_name").
+		Pluck("product_name", &commodities).Error; err != nil {
+		return err
+	}
+
+	var products []models.Product
+	if err := DB.Where("(price = 0 AND price_label <> '') OR (annual_yield = 0 AND term_days = 0) OR commodity = '' OR commodity IS NULL").
+		Find(&products).Error; err != nil {
+		return err
+	}
+
+	updated := 0
+	for _, p := range products {
+		updates := map[string]interface{}{}
+		if p.Price == 0 {
+			if price := pricing.ParsePrice(p.PriceLabel); price > 0 {
+				updates["price"] = price
+			}
+		}
+		if p.AnnualYield == 0 && p.TermDays == 0 {
+			if rate := pricing.ParseRate(p.YieldLabel); rate > 0 {
+				updates["annual_yield"] = rate
+			}
+			if term := pricing.ParseTerm(p.DurationLabel); term > 0 {
+				updates["term_days"] = term
+			}
+		}
+		if p.Commodity == "" {
+			if commodity := pricing.MatchCommodity(p.Name, commodities); commodity != "" {
+				updates["commodity"] = commodity
+			}
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		if err := DB.Model(&models.Product{}).Where("id = ?", p.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		updated++
+	}
+
+	if updated > 0 {
+		log.Printf("Backfilled typed pricing for %d products", updated)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB points the package DB at a fresh SQLite database
+func useTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "products.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Product{}, &models.MarketData{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+}
+
+func TestBackfillProductCommodity(t *testing.T) {
+	useTestDB(t)
+
+	at := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	DB.Create(&[]models.MarketData{
+		{ProductName: "Wheat (Soft Red)", Price: 235.5, Timestamp: at},
+		{ProductName: "Wheat (Soft Red)", Price: 236, Timestamp: at.Add(time.Minute)},
+		{ProductName: "Soybeans", Price: 450, Timestamp: at},
+	})
+	products := []models.Product{
+		{Name: "Wheat Harvest Share", AnnualYield: 6.5, TermDays: 180},
+		{Name: "Soybean Cooperative Note", AnnualYield: 7.2, TermDays: 270, Commodity: "Soybeans (Organic)"},
+		{Name: "Digital Art Collection", Price: 599, PriceLabel: "¥599"},
+	}
+	DB.Create(&products)
+
+	if err := backfillProductPricing(); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	want := map[string]string{
+		"Wheat Harvest Share":      "Wheat (Soft Red)",
+		"Soybean Cooperative Note": "Soybeans (Organic)", // An existing commodity is kept
+		"Digital Art Collection":   "",
+	}
+	for _, p := range products {
+		var got models.Product
+		DB.First(&got, p.ID)
+		if got.Commodity != want[p.Name] {
+			t.Errorf("%s: commodity = %q, want %q", p.Name, got.Commodity, want[p.Name])
+		}
+	}
+}
+
+func TestMigrateLegacyProductColumns(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "legacy.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+
+	// The products table as it was before typed pricing
+	if err := DB.Exec("CREATE TABLE `products` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` varchar(255),`yield_rate` varchar(20),`price` varchar(50),`duration` varchar(50),`created_at` datetime)").Error; err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if err := DB.Exec(`INSERT INTO products (name, yield_rate, price, duration) VALUES
+		('Tea garden', '8.5%', '¥1,000', '12个月'),
+		('E-Book Bundle', 'Complete Series', '¥149', 'Permanent')`).Error; err != nil {
+		t.Fatalf("insert legacy rows: %v", err)
+	}
+
+	// Running twice must be harmless
+	for i := 0; i < 2; i++ {
+		if err := migrateLegacyProductColumns(); err != nil {
+			t.Fatalf("migrate legacy columns (run %d): %v", i+1, err)
+		}
+	}
+	if err := DB.AutoMigrate(&models.Product{}, &models.MarketData{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	if err := backfillProductPricing(); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	want := map[string]models.Product{
+		"Tea garden":    {PriceLabel: "¥1,000", YieldLabel: "8.5%", DurationLabel: "12个月", Price: 1000, AnnualYield: 8.5, TermDays: 365},
+		"E-Book Bundle": {PriceLabel: "¥149", YieldLabel: "Complete Series", DurationLabel: "Permanent", Price: 149},
+	}
+	var products []models.Product
+	DB.Find(&products)
+	if len(products) != len(want) {
+		t.Fatalf("got %d products, want %d", len(products), len(want))
+	}
+	for _, p := range products {
+		w := want[p.Name]
+		if p.PriceLabel != w.PriceLabel || p.YieldLabel != w.YieldLabel || p.DurationLabel != w.DurationLabel {
+			t.Errorf("%s: labels = %q, %q, %q, want %q, %q, %q", p.Name,
+				p.PriceLabel, p.YieldLabel, p.DurationLabel, w.PriceLabel, w.YieldLabel, w.DurationLabel)
+		}
+		if p.Price != w.Price || p.AnnualYield != w.AnnualYield || p.TermDays != w.TermDays {
+			t.Errorf("%s: price %v, yield %v, term %d, want %v, %v, %d", p.Name,
+				p.Price, p.AnnualYield, p.TermDays, w.Price, w.AnnualYield, w.TermDays)
+		}
+	}
+}
+
+func TestBackfillKeepsTypedPricing(t *testing.T) {
+	useTestDB(t)
+
+	product := models.Product{Name: "Orchard", Price: 500, PriceLabel: "¥450", AnnualYield: 7, TermDays: 180, YieldLabel: "9%", DurationLabel: "12个月"}
+	DB.Create(&product)
+
+	if err := backfillProductPricing(); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	var got models.Product
+	DB.First(&got, product.ID)
+	if got.Price != 500 || got.AnnualYield != 7 || got.TermDays != 180 {
+		t.Errorf("typed pricing overwritten: price %v, yield %v, term %d", got.Price, got.AnnualYield, got.TermDays)
+	}
+}
@@ -0,0 +1,225 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSoldOut is returned when a product has less stock than requested
+	ErrSoldOut = errors.New("product sold out")
+	// ErrProductNotFound is returned when reserving stock of a missing product
+	ErrProductNotFound = errors.New("product not found")
+	// ErrReservationClosed is returned when a reservation is no longer pending
+	ErrReservationClosed = errors.New("reservation is no longer pending")
+)
+
+// How often stale reservations are released
+const expiryInterval = time.Minute
+
+var reservationTTL = 15 * time.Minute
+
+// Initialize applies the reservation TTL and starts the expiry worker
+func Initialize(cfg *config.Config) {
+	if cfg.ReservationTTLMinutes > 0 {
+		reservationTTL = time.Duration(cfg.ReservationTTLMinutes) * time.Minute
+	}
+	StartExpiryWorker(context.Background(), database.GetDB(), expiryInterval)
+	log.Printf("Inventory expiry worker started (reservation TTL %s)", reservationTTL)
+}
+
+// ReservationTTL returns how long pending reservations hold stock
+func ReservationTTL() time.Duration {
+	return reservationTTL
+}
+
+// Product stock levels with a special meaning. A limited product that sells
+// its last unit becomes StockSoldOut rather than 0, which would read as
+// unlimited.
+const (
+	StockUnlimited = 0
+	StockSoldOut   = -1
+)
+
+// Reserve atomically takes quantity units of stock for wallet and records a
+// pending reservation that expires after ttl. The decrement is a single
+// conditional UPDATE so concurrent buyers can never oversell. Products with
+// unlimited stock get a reservation that holds none.
+func Reserve(db *gorm.DB, productID uint, wallet string, quantity int, ttl time.Duration) (*models.StockReservation, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	var reservation *models.StockReservation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Select("id", "stock").First(&product, productID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+
+		unlimited := product.Stock == StockUnlimited
+		if !unlimited {
+			result := tx.Model(&models.Product{}).
+				Where("id = ? AND stock >= ?", productID, quantity).
+				Update("stock", gorm.Expr("CASE WHEN stock = ? THEN ? ELSE stock - ? END", quantity, StockSoldOut, quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrSoldOut
+			}
+		}
+
+		reservation = &models.StockReservation{
+			ProductID:     productID,
+			WalletAddress: wallet,
+			Quantity:      quantity,
+			Status:        "pending",
+			Unlimited:     unlimited,
+			ExpiresAt:     time.Now().Add(ttl),
+		}
+		return tx.Create(reservation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// Confirm marks a pending reservation as paid; its stock stays taken. A
+// reservation past its expiry can no longer be confirmed, even before the
+// expiry worker has released it.
+func Confirm(db *gorm.DB, reservationID uint) error {
+	result := db.Model(&models.StockReservation{}).
+		Where("id = ? AND status = ? AND expires_at > ?", reservationID, "pending", time.Now()).
+		Update("status", "confirmed")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReservationClosed
+	}
+	return nil
+}
+
+// Release cancels a pending reservation and returns its stock. status is
+// the final state to record, "released" or "expired".
+func Release(db *gorm.DB, reservationID uint, status string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var reservation models.StockReservation
+		if err := tx.First(&reservation, reservationID).Error; err != nil {
+			return err
+		}
+
+		// Guard on the current status so a reservation is only ever released once
+		result := tx.Model(&models.StockReservation{}).
+			Where("id = ? AND status = ?", reservationID, "pending").
+			Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrReservationClosed
+		}
+
+		// Unlimited products held nothing; a product made unlimited since
+		// stays unlimited
+		if !reservation.Unlimited {
+			if err := tx.Model(&models.Product{}).Where("id = ?", reservation.ProductID).
+				Update("stock", gorm.Expr("CASE WHEN stock = ? THEN ? WHEN stock = ? THEN stock ELSE stock + ? END",
+					StockSoldOut, reservation.Quantity, StockUnlimited, reservation.Quantity)).Error; err != nil {
+				return err
+			}
+		}
+
+		if reservation.TransactionID != nil {
+			return tx.Model(&models.Transaction{}).
+				Where("id = ? AND status = ?", *reservation.TransactionID, "pending").
+				Update("status", "failed").Error
+		}
+		return nil
+	})
+}
+
+// ExpireStale releases every pending reservation whose expiry has passed
+func ExpireStale(db *gorm.DB, now time.Time) (int, error) {
+	var ids []uint
+	if err := db.Model(&models.StockReservation{}).
+		Where("status = ? AND expires_at <= ?", "pending", now).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := Release(db, id, "expired")
+		if errors.Is(err, ErrReservationClosed) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+		notifyExpired(db, id)
+	}
+	return expired, nil
+}
+
+// notifyExpired tells the buyer that an unpaid reservation lapsed and its
+// pending transaction failed
+func notifyExpired(db *gorm.DB, reservationID uint) {
+	var reservation models.StockReservation
+	if err := db.First(&reservation, reservationID).Error; err != nil || reservation.TransactionID == nil {
+		return
+	}
+	userID, err := notify.UserIDForWallet(db, reservation.WalletAddress)
+	if err != nil || userID == 0 {
+		return
+	}
+	notify.Publish(notify.Message{
+		UserID: userID,
+		Type:   notify.TypeTransaction,
+		Title:  "Investment cancelled",
+		Body:   "Payment was not received before your reservation expired, so the pending investment was cancelled",
+		Data: map[string]interface{}{
+			"transaction_id": *reservation.TransactionID,
+			"reservation_id": reservation.ID,
+			"status":         "failed",
+		},
+	})
+}
+
+// StartExpiryWorker expires stale reservations every interval until ctx is
+// cancelled
+func StartExpiryWorker(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				n, err := ExpireStale(db, now)
+				if err != nil {
+					log.Printf("Failed to expire stock reservations: %v", err)
+				} else if n > 0 {
+					log.Printf("Expired %d stock reservations", n)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,212 @@
+package inventory
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "inventory.db") + "?_busy_timeout=10000&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Product{}, &models.Transaction{}, &models.StockReservation{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestReserveNeverOversells(t *testing.T) {
+	db := openTestDB(t)
+
+	const stock, buyers = 5, 40
+	product := models.Product{Name: "Limited edition", Stock: stock}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+		soldOut  int
+	)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Reserve(db, product.ID, "buyer", 1, time.Minute)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, ErrSoldOut):
+				soldOut++
+			default:
+				t.Errorf("reserve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != stock {
+		t.Errorf("reserved %d units, want %d", reserved, stock)
+	}
+	if soldOut != buyers-stock {
+		t.Errorf("got %d sold-out errors, want %d", soldOut, buyers-stock)
+	}
+
+	var remaining models.Product
+	db.First(&remaining, product.ID)
+	if remaining.Stock != StockSoldOut {
+		t.Errorf("remaining stock = %d, want %d", remaining.Stock, StockSoldOut)
+	}
+
+	var count int64
+	db.Model(&models.StockReservation{}).Where("status = ?", "pending").Count(&count)
+	if count != stock {
+		t.Errorf("pending reservations = %d, want %d", count, stock)
+	}
+}
+
+func TestReserveUnknownProduct(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := Reserve(db, 42, "buyer", 1, time.Minute); !errors.Is(err, ErrProductNotFound) {
+		t.Fatalf("err = %v, want ErrProductNotFound", err)
+	}
+}
+
+func TestExpireStaleReturnsStock(t *testing.T) {
+	db := openTestDB(t)
+
+	product := models.Product{Name: "Album", Stock: 3}
+	db.Create(&product)
+
+	expiring, err := Reserve(db, product.ID, "late-payer", 2, time.Minute)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	pendingTx := models.Transaction{Type: "investment", Status: "pending", TxHash: "0xpending"}
+	db.Create(&pendingTx)
+	db.Model(expiring).Update("transaction_id", pendingTx.ID)
+
+	paid, err := Reserve(db, product.ID, "payer", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	if err := Confirm(db, paid.ID); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+
+	n, err := ExpireStale(db, time.Now().Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expired %d reservations, want 1", n)
+	}
+
+	var remaining models.Product
+	db.First(&remaining, product.ID)
+	if remaining.Stock != 2 {
+		t.Errorf("remaining stock = %d, want 2", remaining.Stock)
+	}
+
+	var tx models.Transaction
+	db.First(&tx, pendingTx.ID)
+	if tx.Status != "failed" {
+		t.Errorf("transaction status = %q, want failed", tx.Status)
+	}
+
+	if err := Confirm(db, expiring.ID); !errors.Is(err, ErrReservationClosed) {
+		t.Errorf("confirm expired reservation: err = %v, want ErrReservationClosed", err)
+	}
+}
+
+func TestUnlimitedStock(t *testing.T) {
+	db := openTestDB(t)
+
+	product := models.Product{Name: "E-Book Bundle", Stock: StockUnlimited}
+	db.Create(&product)
+
+	var reservations []*models.StockReservation
+	for i := 0; i < 3; i++ {
+		r, err := Reserve(db, product.ID, "buyer", 10, time.Minute)
+		if err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+		if !r.Unlimited {
+			t.Errorf("reservation %d holds stock of an unlimited product", r.ID)
+		}
+		reservations = append(reservations, r)
+	}
+	if err := Confirm(db, reservations[0].ID); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if err := Release(db, reservations[1].ID, "released"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	var remaining models.Product
+	db.First(&remaining, product.ID)
+	if remaining.Stock != StockUnlimited {
+		t.Errorf("stock = %d, want unlimited", remaining.Stock)
+	}
+}
+
+func TestSoldOutIsNotUnlimited(t *testing.T) {
+	db := openTestDB(t)
+
+	product := models.Product{Name: "Art print", Stock: 2}
+	db.Create(&product)
+
+	last, err := Reserve(db, product.ID, "buyer", 2, time.Minute)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	if _, err := Reserve(db, product.ID, "late", 1, time.Minute); !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("err = %v, want ErrSoldOut", err)
+	}
+
+	if err := Release(db, last.ID, "released"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	var remaining models.Product
+	db.First(&remaining, product.ID)
+	if remaining.Stock != 2 {
+		t.Errorf("stock after release = %d, want 2", remaining.Stock)
+	}
+}
+
+func TestConfirmRejectsExpiredReservation(t *testing.T) {
+	db := openTestDB(t)
+
+	product := models.Product{Name: "Album", Stock: 5}
+	db.Create(&product)
+
+	reservation, err := Reserve(db, product.ID, "buyer", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	// Expired but not yet released by the worker
+	db.Model(reservation).Update("expires_at", time.Now().Add(-time.Second))
+
+	if err := Confirm(db, reservation.ID); !errors.Is(err, ErrReservationClosed) {
+		t.Fatalf("err = %v, want ErrReservationClosed", err)
+	}
+}
@@ -0,0 +1,338 @@
+package kyc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+)
+
+// Verification states of a user and of a submission
+const (
+	StatusNone     = "none"
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+	StatusRejected = "rejected"
+)
+
+// Supported identity documents
+const (
+	IDTypeResident = "resident_id"
+	IDTypePassport = "passport"
+)
+
+// Tiers granted on verification. Tier 0 is every unverified user.
+const (
+	TierNone     = 0
+	TierBasic    = 1 // Identity document
+	TierAdvanced = 2 // Identity document and selfie
+)
+
+// Limit kinds
+const (
+	LimitDeposit    = "deposit"
+	LimitInvestment = "investment"
+)
+
+var (
+	// ErrInvalidTransition is returned for a status change the state machine
+	// does not allow
+	ErrInvalidTransition = errors.New("invalid KYC status transition")
+	// ErrLimitExceeded is returned when an amount exceeds the tier's daily limit
+	ErrLimitExceeded = errors.New("KYC limit exceeded")
+)
+
+// transitions lists the statuses each status may move to
+var transitions = map[string][]string{
+	StatusNone:     {StatusPending},
+	StatusRejected: {StatusPending},
+	StatusPending:  {StatusVerified, StatusRejected},
+}
+
+// Limits are the daily deposit and investment totals allowed per tier, in yuan
+var Limits = map[int]map[string]float64{
+	TierNone:     {LimitDeposit: 1000, LimitInvestment: 1000},
+	TierBasic:    {LimitDeposit: 50000, LimitInvestment: 50000},
+	TierAdvanced: {LimitDeposit: 500000, LimitInvestment: 1000000},
+}
+
+// Transaction types counted against each limit. Marketplace purchases are
+// investments as much as primary ones.
+var limitTransactionTypes = map[string][]string{
+	LimitDeposit:    {"deposit"},
+	LimitInvestment: {"investment", "marketPurchase"},
+}
+
+var (
+	verifier    Verifier = LocalVerifier{}
+	documentDir          = "./uploads/kyc"
+)
+
+// Initialize registers the built-in verifiers and selects the configured one
+func Initialize(cfg *config.Config) {
+	Register(LocalVerifier{AutoApprove: cfg.KYCAutoApprove})
+
+	if v, ok := lookupVerifier(cfg.KYCVerifier); ok {
+		verifier = v
+	} else {
+		log.Printf("Warning: unknown KYC verifier %q, using local", cfg.KYCVerifier)
+		verifier, _ = lookupVerifier("local")
+	}
+	if cfg.KYCDocumentDir != "" {
+		documentDir = cfg.KYCDocumentDir
+	}
+	log.Printf("KYC verifier: %s", verifier.Name())
+}
+
+// DocumentDir returns where submitted documents are stored. It must not be
+// publicly served.
+func DocumentDir() string {
+	return documentDir
+}
+
+// CanTransition reports whether a status may change from one state to another
+func CanTransition(from, to string) bool {
+	if from == "" {
+		from = StatusNone
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskIDNumber keeps the first and last four characters of a document number
+func MaskIDNumber(number string) string {
+	if len(number) <= 8 {
+		return strings.Repeat("*", len(number))
+	}
+	return number[:4] + strings.Repeat("*", len(number)-8) + number[len(number)-4:]
+}
+
+// HashIDNumber returns a stable digest of a document number, used to spot
+// one document on several accounts without storing it in clear
+func HashIDNumber(number string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(number)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Submit moves the user to pending, stores the submission and runs the
+// verifier on it. An approve or reject decision is applied immediately;
+// otherwise the submission waits for admin review.
+func Submit(ctx context.Context, db *gorm.DB, user *models.User, submission *models.KYCSubmission, idNumber string) error {
+	if !CanTransition(user.KYCStatus, StatusPending) {
+		return ErrInvalidTransition
+	}
+
+	submission.UserID = user.ID
+	submission.Status = StatusPending
+	submission.IDNumberMasked = MaskIDNumber(idNumber)
+	submission.IDNumberHash = HashIDNumber(idNumber)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(submission).Error; err != nil {
+			return err
+		}
+		return setStatus(tx, user, submission, StatusPending, user.KYCTier, "user", "submitted")
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := verifier.Verify(ctx, submission, idNumber)
+	if err != nil {
+		// The submission stays pending for manual review
+		log.Printf("KYC verifier %s failed on submission %d: %v", verifier.Name(), submission.ID, err)
+		result = Result{Decision: DecisionManual, Reason: "verifier error: " + err.Error()}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		submission.VerifierName = verifier.Name()
+		submission.VerifierDecision = result.Decision
+		submission.VerifierReason = result.Reason
+		submission.VerifierReference = result.Reference
+		if err := tx.Model(submission).Updates(map[string]interface{}{
+			"verifier_name":      submission.VerifierName,
+			"verifier_decision":  submission.VerifierDecision,
+			"verifier_reason":    submission.VerifierReason,
+			"verifier_reference": submission.VerifierReference,
+		}).Error; err != nil {
+			return err
+		}
+
+		actor := "verifier:" + verifier.Name()
+		switch result.Decision {
+		case DecisionApprove:
+			return decide(tx, user, submission, StatusVerified, result.Tier, actor, result.Reason)
+		case DecisionReject:
+			return decide(tx, user, submission, StatusRejected, user.KYCTier, actor, result.Reason)
+		default:
+			return nil
+		}
+	})
+}
+
+// Review applies an admin decision to a pending submission. reviewer is
+// recorded as the decision maker.
+func Review(db *gorm.DB, submissionID uint, reviewer string, approve bool, tier int, note string) (*models.KYCSubmission, error) {
+	var submission models.KYCSubmission
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&submission, submissionID).Error; err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, submission.UserID).Error; err != nil {
+			return err
+		}
+
+		if !approve {
+			return decide(tx, &user, &submission, StatusRejected, user.KYCTier, "admin:"+reviewer, note)
+		}
+		if tier == TierNone {
+			tier = submission.RequestedTier
+		}
+		if tier < TierBasic || tier > TierAdvanced {
+			return fmt.Errorf("tier must be %d or %d", TierBasic, TierAdvanced)
+		}
+		return decide(tx, &user, &submission, StatusVerified, tier, "admin:"+reviewer, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// decide closes a pending submission and applies the outcome to the user
+func decide(tx *gorm.DB, user *models.User, submission *models.KYCSubmission, status string, tier int, actor, note string) error {
+	if submission.Status != StatusPending || !CanTransition(user.KYCStatus, status) {
+		return ErrInvalidTransition
+	}
+
+	now := time.Now()
+	submission.Status = status
+	submission.ReviewedBy = actor
+	submission.ReviewNote = note
+	submission.ReviewedAt = &now
+	if status == StatusVerified {
+		submission.ApprovedTier = tier
+	}
+
+	// Guard on the pending status so concurrent decisions cannot both apply
+	result := tx.Model(&models.KYCSubmission{}).
+		Where("id = ? AND status = ?", submission.ID, StatusPending).
+		Updates(map[string]interface{}{
+			"status":        submission.Status,
+			"reviewed_by":   submission.ReviewedBy,
+			"review_note":   submission.ReviewNote,
+			"reviewed_at":   now,
+			"approved_tier": submission.ApprovedTier,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTransition
+	}
+
+	return setStatus(tx, user, submission, status, tier, actor, note)
+}
+
+// setStatus updates the user's KYC status and tier and logs the decision
+func setStatus(tx *gorm.DB, user *models.User, submission *models.KYCSubmission, status string, tier int, actor, note string) error {
+	from := user.KYCStatus
+	if from == "" {
+		from = StatusNone
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"kyc_status": status,
+		"kyc_tier":   tier,
+	}).Error; err != nil {
+		return err
+	}
+	user.KYCStatus = status
+	user.KYCTier = tier
+
+	return tx.Create(&models.KYCDecision{
+		SubmissionID: submission.ID,
+		UserID:       user.ID,
+		FromStatus:   from,
+		ToStatus:     status,
+		Tier:         tier,
+		Actor:        actor,
+		Note:         note,
+	}).Error
+}
+
+// LimitFor returns the daily limit of a kind for a user's tier. Only
+// verified users get their tier's limits.
+func LimitFor(user *models.User, kind string) float64 {
+	tier := TierNone
+	if user.KYCStatus == StatusVerified {
+		tier = user.KYCTier
+	}
+	limits, ok := Limits[tier]
+	if !ok {
+		limits = Limits[TierNone]
+	}
+	return limits[kind]
+}
+
+// UsedToday sums the user's deposits or investments since midnight,
+// ignoring failed transactions. Investments include marketplace purchases,
+// counting those still settling.
+func UsedToday(db *gorm.DB, userID uint, kind string, now time.Time) (float64, error) {
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	var amounts []string
+	if err := db.Model(&models.Transaction{}).
+		Where("user_id = ? AND type IN ? AND status <> ? AND created_at >= ?",
+			userID, limitTransactionTypes[kind], "failed", midnight).
+		Pluck("amount", &amounts).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, a := range amounts {
+		if v, err := strconv.ParseFloat(a, 64); err == nil {
+			total += v
+		}
+	}
+
+	if kind == LimitInvestment {
+		var settling float64
+		if err := db.Model(&models.Listing{}).
+			Where("buyer_id = ? AND status = ?", userID, "settling").
+			Select("COALESCE(SUM(sale_price), 0)").Scan(&settling).Error; err != nil {
+			return 0, err
+		}
+		total += settling
+	}
+	return total, nil
+}
+
+// CheckLimit returns ErrLimitExceeded if amount would take the user past
+// today's limit for kind
+func CheckLimit(db *gorm.DB, user *models.User, kind string, amount float64) error {
+	used, err := UsedToday(db, user.ID, kind, time.Now())
+	if err != nil {
+		return err
+	}
+	limit := LimitFor(user, kind)
+	if used+amount > limit {
+		return fmt.Errorf("%w: daily %s limit is %.2f, %.2f already used", ErrLimitExceeded, kind, limit, used)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package kyc
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "kyc.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.KYCSubmission{}, &models.KYCDecision{}, &models.Transaction{}, &models.Listing{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"", StatusPending, true},
+		{StatusNone, StatusPending, true},
+		{StatusNone, StatusVerified, false},
+		{StatusPending, StatusVerified, true},
+		{StatusPending, StatusRejected, true},
+		{StatusPending, StatusPending, false},
+		{StatusRejected, StatusPending, true},
+		{StatusRejected, StatusVerified, false},
+		{StatusVerified, StatusPending, false},
+		{StatusVerified, StatusRejected, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestValidateIDNumber(t *testing.T) {
+	tests := []struct {
+		name, idType, number string
+		wantErr              bool
+	}{
+		{"valid resident ID", IDTypeResident, "11010519491231002X", false},
+		{"valid numeric check digit", IDTypeResident, "440524188001010014", false},
+		{"wrong check digit", IDTypeResident, "110105194912310021", true},
+		{"lower-case x", IDTypeResident, "11010519491231002x", true},
+		{"too short", IDTypeResident, "1101051949123100", true},
+		{"letters", IDTypeResident, "1101051949123100AX", true},
+		{"passport", IDTypePassport, "E12345678", false},
+		{"short passport", IDTypePassport, "E123", true},
+		{"unknown type", "driver_license", "E12345678", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateIDNumber(tt.idType, tt.number); (err != nil) != tt.wantErr {
+				t.Errorf("validateIDNumber(%q, %q) = %v, wantErr %v", tt.idType, tt.number, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubmitAndReview(t *testing.T) {
+	db := openTestDB(t)
+	verifier = LocalVerifier{}
+
+	user := models.User{WalletAddress: "cfx:user", Email: "user@example.com", KYCStatus: StatusNone}
+	db.Create(&user)
+
+	submission := models.KYCSubmission{FullName: "Zhang San", IDType: IDTypeResident, RequestedTier: TierBasic}
+	if err := Submit(context.Background(), db, &user, &submission, "11010519491231002X"); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if user.KYCStatus != StatusPending || submission.VerifierDecision != DecisionManual {
+		t.Fatalf("after submit: status %q, decision %q", user.KYCStatus, submission.VerifierDecision)
+	}
+
+	// A pending user cannot submit again
+	again := models.KYCSubmission{FullName: "Zhang San", IDType: IDTypeResident}
+	if err := Submit(context.Background(), db, &user, &again, "11010519491231002X"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("second submit: err = %v, want ErrInvalidTransition", err)
+	}
+
+	if _, err := Review(db, submission.ID, "admin", true, TierAdvanced, "ok"); err != nil {
+		t.Fatalf("review: %v", err)
+	}
+	db.First(&user, user.ID)
+	if user.KYCStatus != StatusVerified || user.KYCTier != TierAdvanced {
+		t.Errorf("after approval: status %q, tier %d", user.KYCStatus, user.KYCTier)
+	}
+
+	// A decided submission cannot be decided again
+	if _, err := Review(db, submission.ID, "admin", false, 0, "changed my mind"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("second review: err = %v, want ErrInvalidTransition", err)
+	}
+
+	var decisions []models.KYCDecision
+	db.Where("user_id = ?", user.ID).Order("id").Find(&decisions)
+	if len(decisions) != 2 || decisions[0].ToStatus != StatusPending || decisions[1].ToStatus != StatusVerified {
+		t.Errorf("decisions = %+v, want pending then verified", decisions)
+	}
+}
+
+func TestSubmitRejectsBadChecksum(t *testing.T) {
+	db := openTestDB(t)
+	verifier = LocalVerifier{AutoApprove: true}
+	t.Cleanup(func() { verifier = LocalVerifier{} })
+
+	user := models.User{WalletAddress: "cfx:user", Email: "user@example.com"}
+	db.Create(&user)
+
+	submission := models.KYCSubmission{FullName: "Zhang San", IDType: IDTypeResident, RequestedTier: TierBasic}
+	if err := Submit(context.Background(), db, &user, &submission, "110105194912310021"); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if user.KYCStatus != StatusRejected || submission.Status != StatusRejected {
+		t.Errorf("status %q, submission %q, want rejected", user.KYCStatus, submission.Status)
+	}
+	if !CanTransition(user.KYCStatus, StatusPending) {
+		t.Error("a rejected user must be able to resubmit")
+	}
+}
+
+func TestUsedTodayCountsMarketplacePurchases(t *testing.T) {
+	db := openTestDB(t)
+
+	now := time.Now()
+	user := models.User{WalletAddress: "cfx:user", Email: "user@example.com"}
+	db.Create(&user)
+	db.Create(&[]models.Transaction{
+		{UserID: user.ID, Type: "investment", Amount: "300.00", Status: "success", TxHash: "0x1", CreatedAt: now},
+		{UserID: user.ID, Type: "marketPurchase", Amount: "200.00", Status: "success", TxHash: "0x2", CreatedAt: now},
+		{UserID: user.ID, Type: "investment", Amount: "900.00", Status: "failed", TxHash: "0x3", CreatedAt: now},
+		{UserID: user.ID, Type: "investment", Amount: "900.00", Status: "success", TxHash: "0x4", CreatedAt: now.AddDate(0, 0, -1)},
+		{UserID: user.ID, Type: "deposit", Amount: "5000.00", Status: "success", TxHash: "0x5", CreatedAt: now},
+	})
+	buyer := user.ID
+	db.Create(&models.Listing{AssetID: 1, SellerID: 99, BuyerID: &buyer, Price: 150, SalePrice: 150, Status: "settling"})
+
+	used, err := UsedToday(db, user.ID, LimitInvestment, now)
+	if err != nil {
+		t.Fatalf("used today: %v", err)
+	}
+	if used != 650 {
+		t.Errorf("investment used = %.2f, want 650", used)
+	}
+
+	// Tier 0 allows 1000 a day
+	if err := CheckLimit(db, &user, LimitInvestment, 350); err != nil {
+		t.Errorf("350 more: %v", err)
+	}
+	if err := CheckLimit(db, &user, LimitInvestment, 351); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("351 more: err = %v, want ErrLimitExceeded", err)
+	}
+}
@@ -0,0 +1,111 @@
+package kyc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+// Verifier decisions
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+	DecisionManual  = "manual" // Leave the submission pending for admin review
+)
+
+// Result is a verifier's decision on a submission
+type Result struct {
+	Decision  string
+	Tier      int    // Tier to grant when approving
+	Reason    string // Why the decision was made
+	Reference string // Provider reference for follow-up
+}
+
+// Verifier checks a KYC submission, typically by calling an identity
+// verification provider
+type Verifier interface {
+	Name() string
+	Verify(ctx context.Context, submission *models.KYCSubmission, idNumber string) (Result, error)
+}
+
+var (
+	verifiersMu sync.RWMutex
+	verifiers   = map[string]Verifier{}
+)
+
+// Register makes a verifier available under its name for KYC_VERIFIER
+func Register(v Verifier) {
+	verifiersMu.Lock()
+	defer verifiersMu.Unlock()
+	verifiers[v.Name()] = v
+}
+
+func lookupVerifier(name string) (Verifier, bool) {
+	verifiersMu.RLock()
+	defer verifiersMu.RUnlock()
+	v, ok := verifiers[name]
+	return v, ok
+}
+
+var (
+	passportPattern   = regexp.MustCompile(`^[A-Z0-9]{5,20}$`)
+	residentIDPattern = regexp.MustCompile(`^\d{17}[\dX]$`)
+)
+
+// residentIDWeights are the GB 11643 checksum weights of the first 17 digits
+var residentIDWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+const residentIDCheckDigits = "10X98765432"
+
+// LocalVerifier is a stub verifier for development. It only checks the
+// document number format: malformed numbers are rejected, valid ones are
+// approved when AutoApprove is set and otherwise left for admin review.
+type LocalVerifier struct {
+	AutoApprove bool
+}
+
+// Name implements Verifier
+func (LocalVerifier) Name() string { return "local" }
+
+// Verify implements Verifier
+func (v LocalVerifier) Verify(_ context.Context, submission *models.KYCSubmission, idNumber string) (Result, error) {
+	if strings.TrimSpace(submission.FullName) == "" {
+		return Result{Decision: DecisionReject, Reason: "full name is missing"}, nil
+	}
+	if err := validateIDNumber(submission.IDType, idNumber); err != nil {
+		return Result{Decision: DecisionReject, Reason: err.Error()}, nil
+	}
+	if !v.AutoApprove {
+		return Result{Decision: DecisionManual, Reason: "document format valid, awaiting review"}, nil
+	}
+	return Result{Decision: DecisionApprove, Tier: submission.RequestedTier, Reason: "document format valid"}, nil
+}
+
+// validateIDNumber checks a document number against its type's format
+func validateIDNumber(idType, number string) error {
+	switch idType {
+	case IDTypeResident:
+		if !residentIDPattern.MatchString(number) {
+			return fmt.Errorf("resident ID must be 17 digits followed by a digit or X")
+		}
+		sum := 0
+		for i, w := range residentIDWeights {
+			sum += int(number[i]-'0') * w
+		}
+		if residentIDCheckDigits[sum%11] != number[17] {
+			return fmt.Errorf("resident ID checksum does not match")
+		}
+		return nil
+	case IDTypePassport:
+		if !passportPattern.MatchString(number) {
+			return fmt.Errorf("passport number must be 5 to 20 letters or digits")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported ID type %q", idType)
+	}
+}
@@ -0,0 +1,198 @@
+package marketdata
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Candle intervals kept in the rollup table
+const (
+	Interval1h = "1h"
+	Interval1d = "1d"
+	Interval1w = "1w"
+)
+
+// Intervals lists every rolled-up interval
+var Intervals = []string{Interval1h, Interval1d, Interval1w}
+
+// BucketStart returns the start of the candle containing t. Days start at
+// local midnight and weeks on Monday.
+func BucketStart(interval string, t time.Time) (time.Time, error) {
+	switch interval {
+	case Interval1h:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case Interval1d:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	case Interval1w:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		offset := (int(day.Weekday()) + 6) % 7 // Days since Monday
+		return day.AddDate(0, 0, -offset), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown interval %q", interval)
+}
+
+// NextBucket returns the start of the candle after the one starting at start
+func NextBucket(interval string, start time.Time) time.Time {
+	switch interval {
+	case Interval1h:
+		return start.Add(time.Hour)
+	case Interval1w:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// updateCandles folds one tick into its candle of every interval
+func updateCandles(tx *gorm.DB, row *models.MarketData) error {
+	for _, interval := range Intervals {
+		start, err := BucketStart(interval, row.Timestamp)
+		if err != nil {
+			return err
+		}
+
+		var candle models.MarketCandle
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_name = ? AND `interval` = ? AND bucket_start = ?", row.ProductName, interval, start).
+			First(&candle).Error
+		if err == gorm.ErrRecordNotFound {
+			candle = models.MarketCandle{
+				ProductName: row.ProductName,
+				Interval:    interval,
+				BucketStart: start,
+				Open:        row.Price,
+				High:        row.Price,
+				Low:         row.Price,
+				Close:       row.Price,
+				Volume:      row.Volume,
+				Ticks:       1,
+				OpenAt:      row.Timestamp,
+				CloseAt:     row.Timestamp,
+			}
+			if err := tx.Create(&candle).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if row.Price > candle.High {
+			candle.High = row.Price
+		}
+		if row.Price < candle.Low {
+			candle.Low = row.Price
+		}
+		// Ticks can arrive out of order, so open and close follow the timestamps
+		if row.Timestamp.Before(candle.OpenAt) {
+			candle.Open = row.Price
+			candle.OpenAt = row.Timestamp
+		}
+		if !row.Timestamp.Before(candle.CloseAt) {
+			candle.Close = row.Price
+			candle.CloseAt = row.Timestamp
+		}
+		candle.Volume += row.Volume
+		candle.Ticks++
+
+		if err := tx.Save(&candle).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillCandles rebuilds the rollup from the stored ticks unless it
+// already accounts for all of them, so history recorded before the rollup
+// existed is charted too. Candles are upserted from scratch, so a rebuild
+// cut short by a restart is simply redone on the next start.
+func BackfillCandles(db *gorm.DB) error {
+	var ticks, rolledUp int64
+	if err := db.Model(&models.MarketData{}).Count(&ticks).Error; err != nil {
+		return err
+	}
+	// Every tick is counted once in its weekly candle
+	if err := db.Model(&models.MarketCandle{}).Where("`interval` = ?", Interval1w).
+		Select("COALESCE(SUM(ticks), 0)").Scan(&rolledUp).Error; err != nil {
+		return err
+	}
+	if ticks == 0 || ticks == rolledUp {
+		return nil
+	}
+
+	type key struct {
+		product  string
+		interval string
+		start    int64
+	}
+	candles := map[key]*models.MarketCandle{}
+	var rows []models.MarketData
+	// FindInBatches pages on the primary key, so rows come in ID order and
+	// open and close follow the timestamps as in updateCandles
+	err := db.FindInBatches(&rows, 500, func(_ *gorm.DB, _ int) error {
+		for _, row := range rows {
+			for _, interval := range Intervals {
+				start, err := BucketStart(interval, row.Timestamp)
+				if err != nil {
+					return err
+				}
+				k := key{row.ProductName, interval, start.Unix()}
+				candle, ok := candles[k]
+				if !ok {
+					candles[k] = &models.MarketCandle{
+						ProductName: row.ProductName,
+						Interval:    interval,
+						BucketStart: start,
+						Open:        row.Price,
+						High:        row.Price,
+						Low:         row.Price,
+						Close:       row.Price,
+						Volume:      row.Volume,
+						Ticks:       1,
+						OpenAt:      row.Timestamp,
+						CloseAt:     row.Timestamp,
+					}
+					continue
+				}
+				candle.High = max(candle.High, row.Price)
+				candle.Low = min(candle.Low, row.Price)
+				if row.Timestamp.Before(candle.OpenAt) {
+					candle.Open = row.Price
+					candle.OpenAt = row.Timestamp
+				}
+				if !row.Timestamp.Before(candle.CloseAt) {
+					candle.Close = row.Price
+					candle.CloseAt = row.Timestamp
+				}
+				candle.Volume += row.Volume
+				candle.Ticks++
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	batch := make([]*models.MarketCandle, 0, len(candles))
+	for _, candle := range candles {
+		batch = append(batch, candle)
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_name"}, {Name: "interval"}, {Name: "bucket_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "ticks", "open_at", "close_at"}),
+	}).CreateInBatches(batch, 500).Error
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Backfilled %d market candles from %d ticks", len(batch), ticks)
+	return nil
+}
@@ -0,0 +1,155 @@
+package marketdata
+
+import (
+	"sync"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+// Hub fans new MarketData rows out to subscribers. Publishing never blocks:
+// a subscriber whose buffer is full is closed with Lagged set, and should
+// resubscribe and resume from its last seen timestamp.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives the rows of its products, or of every product when
+// it has none
+type Subscription struct {
+	C <-chan models.MarketData
+
+	hub      *Hub
+	ch       chan models.MarketData
+	mu       sync.RWMutex
+	products map[string]bool
+	closed   bool
+	lagged   bool
+}
+
+// NewHub returns an empty hub
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+var (
+	hub = NewHub()
+
+	listenersMu sync.RWMutex
+	listeners   []func(models.MarketData)
+)
+
+// OnStored registers fn to be called with every row Store writes, after it
+// is committed. fn runs on the ingestion goroutine and should hand slow work
+// off.
+func OnStored(fn func(models.MarketData)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// publishStored publishes a committed row to the hub and the listeners
+func publishStored(row models.MarketData) {
+	hub.Publish(row)
+
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(row)
+	}
+}
+
+// Subscribe subscribes to the package hub, which Store publishes to
+func Subscribe(products []string, buffer int) *Subscription {
+	return hub.Subscribe(products, buffer)
+}
+
+// Subscribe registers a subscription with room for buffer pending rows
+func (h *Hub) Subscribe(products []string, buffer int) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan models.MarketData, buffer)
+	sub := &Subscription{C: ch, hub: h, ch: ch}
+	sub.SetProducts(products)
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Publish delivers a row to every subscription that wants its product
+func (h *Hub) Publish(row models.MarketData) {
+	var lagging []*Subscription
+
+	h.mu.RLock()
+	for sub := range h.subs {
+		if !sub.Wants(row.ProductName) {
+			continue
+		}
+		select {
+		case sub.ch <- row:
+		default:
+			lagging = append(lagging, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range lagging {
+		sub.mu.Lock()
+		sub.lagged = true
+		sub.mu.Unlock()
+		sub.Close()
+	}
+}
+
+// Len returns the number of open subscriptions
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// SetProducts replaces the products the subscription receives; none means
+// every product
+func (s *Subscription) SetProducts(products []string) {
+	set := map[string]bool{}
+	for _, p := range products {
+		if p != "" {
+			set[p] = true
+		}
+	}
+
+	s.mu.Lock()
+	s.products = set
+	s.mu.Unlock()
+}
+
+// Wants reports whether the subscription receives rows of product
+func (s *Subscription) Wants(product string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.products) == 0 || s.products[product]
+}
+
+// Lagged reports whether the hub closed the subscription because it fell
+// behind
+func (s *Subscription) Lagged() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lagged
+}
+
+// Close unregisters the subscription and closes C. It is safe to call more
+// than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.hub.subs, s)
+	close(s.ch)
+}
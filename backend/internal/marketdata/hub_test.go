@@ -0,0 +1,89 @@
+package marketdata
+
+import (
+	"sync"
+	"testing"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+func TestHubFiltersByProduct(t *testing.T) {
+	h := NewHub()
+	corn := h.Subscribe([]string{"Corn"}, 4)
+	all := h.Subscribe(nil, 4)
+	defer corn.Close()
+	defer all.Close()
+
+	h.Publish(models.MarketData{ProductName: "Wheat"})
+	h.Publish(models.MarketData{ProductName: "Corn"})
+
+	if got := (<-corn.C).ProductName; got != "Corn" {
+		t.Errorf("corn subscription got %s", got)
+	}
+	if len(corn.C) != 0 {
+		t.Errorf("corn subscription received another product")
+	}
+	if len(all.C) != 2 {
+		t.Errorf("unfiltered subscription has %d rows, want 2", len(all.C))
+	}
+}
+
+func TestHubDropsLaggingSubscriber(t *testing.T) {
+	h := NewHub()
+	slow := h.Subscribe(nil, 1)
+
+	h.Publish(models.MarketData{ProductName: "Corn"})
+	h.Publish(models.MarketData{ProductName: "Corn"})
+
+	if !slow.Lagged() || h.Len() != 0 {
+		t.Fatalf("lagged = %v, subscribers = %d", slow.Lagged(), h.Len())
+	}
+	<-slow.C
+	if _, ok := <-slow.C; ok {
+		t.Error("channel of a lagging subscriber is still open")
+	}
+	slow.Close()
+}
+
+func TestHubConcurrentSubscribers(t *testing.T) {
+	h := NewHub()
+	const subscribers, rows = 50, 100
+
+	var wg sync.WaitGroup
+	ready := make(chan struct{}, subscribers)
+	for i := 0; i < subscribers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub := h.Subscribe(nil, rows)
+			defer sub.Close()
+			ready <- struct{}{}
+			for n := 0; n < rows; n++ {
+				if _, ok := <-sub.C; !ok {
+					t.Error("subscription closed early")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < subscribers; i++ {
+		<-ready
+	}
+
+	var pub sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		pub.Add(1)
+		go func() {
+			defer pub.Done()
+			for n := 0; n < rows/4; n++ {
+				h.Publish(models.MarketData{ProductName: "Corn"})
+			}
+		}()
+	}
+	pub.Wait()
+	wg.Wait()
+
+	if h.Len() != 0 {
+		t.Errorf("%d subscribers left after closing", h.Len())
+	}
+}
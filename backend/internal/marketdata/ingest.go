@@ -0,0 +1,308 @@
+package marketdata
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Alerter is told when a source stops or resumes delivering quotes
+type Alerter interface {
+	SourceStale(source string, lastQuote time.Time, staleFor time.Duration)
+	SourceRecovered(source string)
+}
+
+// LogAlerter writes source alerts to the log
+type LogAlerter struct{}
+
+// SourceStale implements Alerter
+func (LogAlerter) SourceStale(source string, lastQuote time.Time, staleFor time.Duration) {
+	if lastQuote.IsZero() {
+		log.Printf("ALERT: market data source %s has not delivered any quotes", source)
+		return
+	}
+	log.Printf("ALERT: market data source %s is stale, no new quotes for %s (last at %s)",
+		source, staleFor.Round(time.Second), lastQuote.Format(time.RFC3339))
+}
+
+// SourceRecovered implements Alerter
+func (LogAlerter) SourceRecovered(source string) {
+	log.Printf("Market data source %s recovered", source)
+}
+
+// SourceStatus is the health of one source
+type SourceStatus struct {
+	Name      string    `json:"name"`
+	LastQuote time.Time `json:"last_quote"` // When the source last delivered a new quote
+	LastError string    `json:"last_error,omitempty"`
+	Stale     bool      `json:"stale"`
+}
+
+// Ingester polls sources and stores their quotes as MarketData rows
+type Ingester struct {
+	DB         *gorm.DB
+	Sources    []Source
+	Alerter    Alerter
+	StaleAfter time.Duration
+
+	mu       sync.Mutex
+	started  time.Time
+	statuses map[string]*SourceStatus
+}
+
+var (
+	ingester  *Ingester
+	heartbeat = 15 * time.Second
+)
+
+// Initialize backfills the candle rollup, then builds the configured
+// sources and starts polling them
+func Initialize(cfg *config.Config) {
+	if cfg.MarketStreamHeartbeatSeconds > 0 {
+		heartbeat = time.Duration(cfg.MarketStreamHeartbeatSeconds) * time.Second
+	}
+	if err := BackfillCandles(database.GetDB()); err != nil {
+		log.Printf("Warning: failed to backfill market candles: %v", err)
+	}
+
+	var sources []Source
+	for _, name := range strings.Split(cfg.MarketSources, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "csv":
+			sources = append(sources, &CSVDropSource{Dir: cfg.MarketCSVDir})
+		case "http":
+			if cfg.MarketHTTPURL == "" {
+				log.Println("Warning: MARKET_HTTP_URL not set, skipping http market source")
+				continue
+			}
+			sources = append(sources, &HTTPJSONSource{URL: cfg.MarketHTTPURL})
+		case "fake":
+			sources = append(sources, NewFakeSource(map[string]float64{
+				"Wheat (Soft Red)": 235.50,
+				"Corn (Yellow)":    188.20,
+				"Soybeans":         450.00,
+			}, time.Now().UnixNano()))
+		default:
+			log.Printf("Warning: unknown market source %q", name)
+		}
+	}
+	if len(sources) == 0 {
+		log.Println("No market data sources configured")
+		return
+	}
+
+	ingester = &Ingester{
+		DB:         database.GetDB(),
+		Sources:    sources,
+		Alerter:    LogAlerter{},
+		StaleAfter: time.Duration(cfg.MarketStaleMinutes) * time.Minute,
+	}
+	ingester.Start(context.Background(), time.Duration(cfg.MarketPollSeconds)*time.Second)
+	log.Printf("Market data ingestion started with %d sources", len(sources))
+}
+
+// HeartbeatInterval returns how often price streams send heartbeats
+func HeartbeatInterval() time.Duration {
+	return heartbeat
+}
+
+// Statuses returns the health of the running ingester's sources
+func Statuses() []SourceStatus {
+	if ingester == nil {
+		return []SourceStatus{}
+	}
+	return ingester.Statuses()
+}
+
+// Start polls every source each interval until ctx is cancelled
+func (in *Ingester) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			in.Poll(ctx, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Poll fetches and stores quotes from every source once, then checks for
+// stale sources
+func (in *Ingester) Poll(ctx context.Context, now time.Time) {
+	in.mu.Lock()
+	if in.statuses == nil {
+		in.statuses = map[string]*SourceStatus{}
+		in.started = now
+	}
+	in.mu.Unlock()
+
+	for _, src := range in.Sources {
+		quotes, err := src.Fetch(ctx)
+		stored := 0
+		if err == nil {
+			stored, err = Store(in.DB, quotes)
+		}
+		if ack, ok := src.(Acknowledger); ok && err == nil {
+			err = ack.Ack()
+		}
+		if err != nil {
+			log.Printf("Market data source %s failed: %v", src.Name(), err)
+		}
+		in.record(src.Name(), stored, err, now)
+	}
+}
+
+// record updates a source's status and raises or clears its stale alert
+func (in *Ingester) record(name string, stored int, err error, now time.Time) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	status, ok := in.statuses[name]
+	if !ok {
+		status = &SourceStatus{Name: name}
+		in.statuses[name] = status
+	}
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	if stored > 0 {
+		status.LastQuote = now
+	}
+
+	if in.StaleAfter <= 0 {
+		return
+	}
+	since := status.LastQuote
+	if since.IsZero() {
+		since = in.started
+	}
+	stale := now.Sub(since) >= in.StaleAfter
+	if stale && !status.Stale && in.Alerter != nil {
+		in.Alerter.SourceStale(name, status.LastQuote, now.Sub(since))
+	}
+	if !stale && status.Stale && in.Alerter != nil {
+		in.Alerter.SourceRecovered(name)
+	}
+	status.Stale = stale
+}
+
+// Statuses returns a snapshot of every source's health
+func (in *Ingester) Statuses() []SourceStatus {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	result := make([]SourceStatus, 0, len(in.Sources))
+	for _, src := range in.Sources {
+		if s, ok := in.statuses[src.Name()]; ok {
+			result = append(result, *s)
+		} else {
+			result = append(result, SourceStatus{Name: src.Name()})
+		}
+	}
+	return result
+}
+
+// Store writes quotes as MarketData rows, skipping any whose product and
+// timestamp are already stored, as enforced by a unique index. Change and
+// ChangePercent are computed against the previous close, the last price
+// before the quote's day, and each row is folded into its candles in the
+// same transaction, then published to stream subscribers and OnStored
+// listeners. Quotes are stored in time order so later quotes see earlier
+// ones.
+func Store(db *gorm.DB, quotes []Quote) (int, error) {
+	sortQuotes(quotes)
+
+	stored := 0
+	for _, q := range quotes {
+		q.Product = strings.TrimSpace(q.Product)
+		if q.Product == "" || q.Price <= 0 || q.Timestamp.IsZero() {
+			continue
+		}
+
+		row := models.MarketData{
+			ProductName: q.Product,
+			Icon:        q.Icon,
+			Price:       q.Price,
+			Volume:      q.Volume,
+			Timestamp:   q.Timestamp,
+		}
+
+		prevClose, icon, err := previousClose(db, q.Product, q.Timestamp)
+		if err != nil {
+			return stored, err
+		}
+		if row.Icon == "" {
+			row.Icon = icon
+		}
+		if prevClose > 0 {
+			row.Change = q.Price - prevClose
+			row.ChangePercent = fmt.Sprintf("%+.2f%%", row.Change/prevClose*100)
+		} else {
+			row.ChangePercent = "+0.00%"
+		}
+
+		// The unique (product, timestamp) index drops quotes already stored,
+		// including ones a concurrent Store inserted first
+		inserted := false
+		err = db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			inserted = true
+			return updateCandles(tx, &row)
+		})
+		if err != nil {
+			return stored, err
+		}
+		if !inserted {
+			continue
+		}
+		publishStored(row)
+		stored++
+	}
+	return stored, nil
+}
+
+// previousClose returns the last price of a product before the day of at,
+// falling back to its latest earlier price on the first day of data. The
+// icon of that row is returned so feeds without icons keep the existing one.
+func previousClose(db *gorm.DB, product string, at time.Time) (float64, string, error) {
+	y, m, d := at.Date()
+	dayStart := time.Date(y, m, d, 0, 0, 0, 0, at.Location())
+
+	for _, before := range []time.Time{dayStart, at} {
+		var prev []models.MarketData
+		if err := db.Where("product_name = ? AND timestamp < ?", product, before).
+			Order("timestamp DESC").Limit(1).Find(&prev).Error; err != nil {
+			return 0, "", err
+		}
+		if len(prev) > 0 {
+			return prev[0].Price, prev[0].Icon, nil
+		}
+	}
+	return 0, "", nil
+}
+
+func sortQuotes(quotes []Quote) {
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Timestamp.Before(quotes[j].Timestamp) })
+}
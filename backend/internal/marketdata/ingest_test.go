@@ -0,0 +1,271 @@
+package marketdata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "market.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.MarketData{}, &models.MarketCandle{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+type recordingAlerter struct {
+	stale, recovered []string
+}
+
+func (a *recordingAlerter) SourceStale(source string, _ time.Time, _ time.Duration) {
+	a.stale = append(a.stale, source)
+}
+
+func (a *recordingAlerter) SourceRecovered(source string) {
+	a.recovered = append(a.recovered, source)
+}
+
+func TestStoreComputesChangeAgainstPreviousClose(t *testing.T) {
+	db := openTestDB(t)
+
+	yesterday := time.Date(2024, 3, 1, 15, 0, 0, 0, time.Local)
+	today := yesterday.AddDate(0, 0, 1)
+	quotes := []Quote{
+		{Product: "Corn", Price: 105, Timestamp: today.Add(-5 * time.Hour)},
+		{Product: "Corn", Price: 100, Timestamp: yesterday},
+		{Product: "Corn", Price: 110, Timestamp: today},
+	}
+
+	stored, err := Store(db, quotes)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if stored != 3 {
+		t.Fatalf("stored %d quotes, want 3", stored)
+	}
+
+	var latest models.MarketData
+	db.Where("product_name = ?", "Corn").Order("timestamp DESC").First(&latest)
+	if latest.Change != 10 || latest.ChangePercent != "+10.00%" {
+		t.Errorf("change = %v %s, want 10 +10.00%%", latest.Change, latest.ChangePercent)
+	}
+}
+
+func TestStoreSkipsDuplicates(t *testing.T) {
+	db := openTestDB(t)
+
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	quote := Quote{Product: "Soybeans", Price: 450, Timestamp: at}
+
+	for i := 0; i < 2; i++ {
+		if _, err := Store(db, []Quote{quote, quote}); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+	}
+
+	var count int64
+	db.Model(&models.MarketData{}).Count(&count)
+	if count != 1 {
+		t.Errorf("stored %d rows, want 1", count)
+	}
+
+	// The index itself rejects a duplicate written around Store
+	if err := db.Create(&models.MarketData{ProductName: "Soybeans", Price: 451, Timestamp: at}).Error; err == nil {
+		t.Error("duplicate product and timestamp was inserted")
+	}
+}
+
+func TestCSVDropSourceKeepsFilesUntilStored(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.csv", "product,price,timestamp\nWheat,235.5,2024-03-01 09:30:00\n")
+	write("bad.csv", "product,timestamp\nWheat,2024-03-01\n")
+
+	// A database without the market_data table makes Store fail
+	broken, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "empty.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	src := &CSVDropSource{Dir: dir}
+	(&Ingester{DB: broken, Sources: []Source{src}, Alerter: &recordingAlerter{}}).Poll(context.Background(), time.Now())
+
+	if _, err := os.Stat(filepath.Join(dir, "a.csv")); err != nil {
+		t.Fatalf("file moved although its quotes were not stored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "failed", "bad.csv")); err != nil {
+		t.Errorf("unreadable file not moved to failed/: %v", err)
+	}
+
+	db := openTestDB(t)
+	(&Ingester{DB: db, Sources: []Source{src}, Alerter: &recordingAlerter{}}).Poll(context.Background(), time.Now())
+
+	if _, err := os.Stat(filepath.Join(dir, "processed", "a.csv")); err != nil {
+		t.Errorf("stored file not moved to processed/: %v", err)
+	}
+	var count int64
+	db.Model(&models.MarketData{}).Count(&count)
+	if count != 1 {
+		t.Errorf("stored %d rows, want 1", count)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	quotes, err := parseCSV(strings.NewReader("product,price,timestamp,volume\nWheat,235.5,2024-03-01 09:30:00,1200\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(quotes) != 1 || quotes[0].Product != "Wheat" || quotes[0].Price != 235.5 || quotes[0].Volume != 1200 {
+		t.Errorf("unexpected quotes %+v", quotes)
+	}
+
+	if _, err := parseCSV(strings.NewReader("product,timestamp\nWheat,2024-03-01\n")); err == nil {
+		t.Error("expected an error for a missing price column")
+	}
+}
+
+func TestPollAlertsOnStaleSource(t *testing.T) {
+	db := openTestDB(t)
+	alerter := &recordingAlerter{}
+	empty := NewFakeSource(nil, 1)
+	empty.Label = "empty"
+	live := NewFakeSource(map[string]float64{"Corn": 188.2}, 1)
+	live.Label = "live"
+
+	start := time.Now()
+	live.now = func() time.Time { return start }
+	in := &Ingester{DB: db, Sources: []Source{empty, live}, Alerter: alerter, StaleAfter: 10 * time.Minute}
+
+	in.Poll(context.Background(), start)
+	if len(alerter.stale) != 0 {
+		t.Fatalf("unexpected alerts %v", alerter.stale)
+	}
+
+	// The live source keeps delivering new timestamps; the empty one never does
+	live.now = func() time.Time { return start.Add(11 * time.Minute) }
+	in.Poll(context.Background(), start.Add(11*time.Minute))
+	if len(alerter.stale) != 1 || alerter.stale[0] != "fake:empty" {
+		t.Fatalf("stale alerts = %v, want fake:empty", alerter.stale)
+	}
+
+	in.Poll(context.Background(), start.Add(12*time.Minute))
+	if len(alerter.stale) != 1 {
+		t.Errorf("stale source alerted again: %v", alerter.stale)
+	}
+
+	empty.Push(Quote{Product: "Wheat", Price: 230, Timestamp: start.Add(12 * time.Minute)})
+	in.Poll(context.Background(), start.Add(13*time.Minute))
+	if len(alerter.recovered) != 1 {
+		t.Errorf("recovered = %v, want one", alerter.recovered)
+	}
+}
+
+func TestStoreRollsUpCandles(t *testing.T) {
+	db := openTestDB(t)
+
+	// Wednesday morning; quotes arrive out of order
+	at := time.Date(2024, 3, 6, 9, 0, 0, 0, time.Local)
+	quotes := []Quote{
+		{Product: "Corn", Price: 104, Volume: 5, Timestamp: at.Add(40 * time.Minute)},
+		{Product: "Corn", Price: 100, Volume: 10, Timestamp: at},
+		{Product: "Corn", Price: 98, Volume: 2, Timestamp: at.Add(20 * time.Minute)},
+		{Product: "Corn", Price: 101, Volume: 1, Timestamp: at.Add(2 * time.Hour)},
+	}
+	if _, err := Store(db, quotes[:2]); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if _, err := Store(db, quotes[2:]); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	var hour models.MarketCandle
+	db.Where("`interval` = ? AND bucket_start = ?", Interval1h, at).First(&hour)
+	if hour.Open != 100 || hour.High != 104 || hour.Low != 98 || hour.Close != 104 || hour.Volume != 17 || hour.Ticks != 3 {
+		t.Errorf("hour candle = %+v", hour)
+	}
+
+	var week models.MarketCandle
+	monday := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+	db.Where("`interval` = ? AND bucket_start = ?", Interval1w, monday).First(&week)
+	if week.Open != 100 || week.Close != 101 || week.Ticks != 4 {
+		t.Errorf("week candle = %+v", week)
+	}
+}
+
+func TestBackfillCandlesRebuildsPartialRollup(t *testing.T) {
+	db := openTestDB(t)
+
+	at := time.Date(2024, 3, 6, 9, 0, 0, 0, time.Local)
+	var ticks []models.MarketData
+	for i, price := range []float64{100, 104, 98, 101} {
+		ticks = append(ticks, models.MarketData{ProductName: "Corn", Price: price, Volume: 1, Timestamp: at.Add(time.Duration(i) * 40 * time.Minute)})
+	}
+	db.Create(&ticks)
+
+	// A previous backfill stopped after the first tick
+	if err := updateCandles(db, &ticks[0]); err != nil {
+		t.Fatalf("update candles: %v", err)
+	}
+
+	for run := 0; run < 2; run++ {
+		if err := BackfillCandles(db); err != nil {
+			t.Fatalf("backfill: %v", err)
+		}
+
+		var hour, week models.MarketCandle
+		db.Where("`interval` = ? AND bucket_start = ?", Interval1h, at).First(&hour)
+		if hour.Open != 100 || hour.High != 104 || hour.Low != 100 || hour.Close != 104 || hour.Ticks != 2 {
+			t.Errorf("run %d: hour candle = %+v", run, hour)
+		}
+		db.Where("`interval` = ?", Interval1w).First(&week)
+		if week.Open != 100 || week.High != 104 || week.Low != 98 || week.Close != 101 || week.Volume != 4 || week.Ticks != 4 {
+			t.Errorf("run %d: week candle = %+v", run, week)
+		}
+	}
+}
+
+func TestBackfillCandlesOutOfOrderTicks(t *testing.T) {
+	db := openTestDB(t)
+
+	// Stored newest first across several batches
+	at := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+	const n = 1200
+	ticks := make([]models.MarketData, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		ticks = append(ticks, models.MarketData{ProductName: "Corn", Price: float64(100 + i), Volume: 1, Timestamp: at.Add(time.Duration(i) * time.Minute)})
+	}
+	db.CreateInBatches(&ticks, 100)
+
+	if err := BackfillCandles(db); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	var week models.MarketCandle
+	db.Where("`interval` = ?", Interval1w).First(&week)
+	if week.Ticks != n || week.Open != 100 || week.Close != 100+n-1 || week.Low != 100 || week.High != 100+n-1 {
+		t.Errorf("week candle = %+v", week)
+	}
+	var hour models.MarketCandle
+	db.Where("`interval` = ? AND bucket_start = ?", Interval1h, at.Add(time.Hour)).First(&hour)
+	if hour.Ticks != 60 || hour.Open != 160 || hour.Close != 219 {
+		t.Errorf("hour candle = %+v", hour)
+	}
+}
@@ -0,0 +1,306 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quote is one price observation from a source
+type Quote struct {
+	Product   string    `json:"product"`
+	Icon      string    `json:"icon,omitempty"`
+	Price     float64   `json:"price"`
+	Volume    float64   `json:"volume,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Source supplies commodity quotes. Fetch returns the quotes that became
+// available since the previous call; re-sending old quotes is harmless
+// because ingestion de-duplicates by product and timestamp.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Quote, error)
+}
+
+// Acknowledger is implemented by sources that must be told once the quotes
+// of the last Fetch are stored, so they can consume them. Until Ack is
+// called the same quotes are fetched again.
+type Acknowledger interface {
+	Ack() error
+}
+
+// CSVDropSource reads quotes from CSV files dropped into a folder. Each file
+// has a header with product, price and timestamp columns and optional
+// volume and icon columns. Unreadable files move to failed/ straight away;
+// the others move to processed/ only once their quotes are stored, so a
+// failed store reads them again on the next poll.
+type CSVDropSource struct {
+	Dir string
+
+	fetched []string // Files read by the last Fetch, awaiting Ack
+}
+
+// Name implements Source
+func (s *CSVDropSource) Name() string { return "csv:" + s.Dir }
+
+// Fetch implements Source
+func (s *CSVDropSource) Fetch(_ context.Context) ([]Quote, error) {
+	s.fetched = nil
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var quotes []Quote
+	for _, file := range files {
+		fileQuotes, err := readCSVFile(file)
+		if err != nil {
+			log.Printf("Market data: skipping %s: %v", file, err)
+			if err := moveInto(file, filepath.Join(s.Dir, "failed")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		s.fetched = append(s.fetched, file)
+		quotes = append(quotes, fileQuotes...)
+	}
+	return quotes, nil
+}
+
+// Ack implements Acknowledger by moving the fetched files to processed/
+func (s *CSVDropSource) Ack() error {
+	for len(s.fetched) > 0 {
+		if err := moveInto(s.fetched[0], filepath.Join(s.Dir, "processed")); err != nil {
+			return err
+		}
+		s.fetched = s.fetched[1:]
+	}
+	return nil
+}
+
+func readCSVFile(path string) ([]Quote, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCSV(f)
+}
+
+// parseCSV reads quotes from CSV with a header row
+func parseCSV(r io.Reader) ([]Quote, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"product", "price", "timestamp"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var quotes []Quote
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		price, err := strconv.ParseFloat(field("price"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid price %q", line, field("price"))
+		}
+		ts, err := parseTimestamp(field("timestamp"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		var volume float64
+		if v := field("volume"); v != "" {
+			if volume, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid volume %q", line, v)
+			}
+		}
+
+		quotes = append(quotes, Quote{
+			Product:   field("product"),
+			Icon:      field("icon"),
+			Price:     price,
+			Volume:    volume,
+			Timestamp: ts,
+		})
+	}
+	return quotes, nil
+}
+
+// Accepted timestamp layouts besides Unix seconds
+var timestampLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseTimestamp(s string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+func moveInto(file, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.Rename(file, filepath.Join(dir, filepath.Base(file)))
+}
+
+// HTTPJSONSource polls a JSON feed that returns either an array of quotes
+// or an object with the array under "data"
+type HTTPJSONSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name implements Source
+func (s *HTTPJSONSource) Name() string { return "http:" + s.URL }
+
+// Fetch implements Source
+func (s *HTTPJSONSource) Fetch(ctx context.Context) ([]Quote, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	return parseJSONQuotes(body)
+}
+
+func parseJSONQuotes(body []byte) ([]Quote, error) {
+	var quotes []Quote
+	if err := json.Unmarshal(body, &quotes); err == nil {
+		return quotes, nil
+	}
+
+	var wrapped struct {
+		Data []Quote `json:"data"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, fmt.Errorf("decode feed: %w", err)
+	}
+	return wrapped.Data, nil
+}
+
+// FakeSource generates a random walk for each product, for development and
+// tests. Quotes added with Push are returned by the next Fetch as well.
+type FakeSource struct {
+	// Label distinguishes several fake sources; Name is "fake" without one
+	Label string
+
+	mu     sync.Mutex
+	prices map[string]float64
+	rng    *rand.Rand
+	queued []Quote
+	now    func() time.Time
+}
+
+// NewFakeSource starts a random walk at the given prices
+func NewFakeSource(start map[string]float64, seed int64) *FakeSource {
+	prices := make(map[string]float64, len(start))
+	for p, v := range start {
+		prices[p] = v
+	}
+	return &FakeSource{prices: prices, rng: rand.New(rand.NewSource(seed)), now: time.Now}
+}
+
+// Name implements Source
+func (s *FakeSource) Name() string {
+	if s.Label != "" {
+		return "fake:" + s.Label
+	}
+	return "fake"
+}
+
+// Push queues quotes for the next Fetch
+func (s *FakeSource) Push(quotes ...Quote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued = append(s.queued, quotes...)
+}
+
+// Fetch implements Source
+func (s *FakeSource) Fetch(_ context.Context) ([]Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quotes := s.queued
+	s.queued = nil
+
+	now := s.now().Truncate(time.Second)
+	products := make([]string, 0, len(s.prices))
+	for p := range s.prices {
+		products = append(products, p)
+	}
+	sort.Strings(products)
+	for _, p := range products {
+		// Move up to ±1% per tick
+		price := s.prices[p] * (1 + (s.rng.Float64()*2-1)/100)
+		price = math.Round(price*100) / 100
+		s.prices[p] = price
+		quotes = append(quotes, Quote{
+			Product:   p,
+			Price:     price,
+			Volume:    math.Round(s.rng.Float64() * 1000),
+			Timestamp: now,
+		})
+	}
+	return quotes, nil
+}
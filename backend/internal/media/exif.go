@@ -0,0 +1,183 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var errMalformed = errors.New("malformed image metadata")
+
+// EXIF tags read or scrubbed
+const (
+	tagOrientation = 0x0112
+	tagGPSInfo     = 0x8825
+)
+
+// tiffTypeSizes are the byte sizes of the TIFF field types
+var tiffTypeSizes = map[uint16]int64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// scrubJPEG removes GPS data from the EXIF segments of a JPEG in place and
+// returns the EXIF orientation (0 when there is none). An EXIF segment that
+// cannot be parsed is blanked, since its GPS data cannot be located.
+func scrubJPEG(data []byte) (int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, errMalformed
+	}
+
+	orientation := 0
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return 0, errMalformed
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			i++
+			continue
+		case marker == 0xD9 || marker == 0xDA:
+			// End of image or start of scan: no metadata follows
+			return orientation, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Markers without a length
+			i += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 0, errMalformed
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			o, err := scrubTIFF(segment[6:])
+			if err != nil {
+				clear(segment)
+			} else if o != 0 {
+				orientation = o
+			}
+		}
+		i += 2 + length
+	}
+	return orientation, nil
+}
+
+// scrubPNG removes GPS data from the eXIf chunks of a PNG in place and
+// returns the EXIF orientation
+func scrubPNG(data []byte) (int, error) {
+	if len(data) < 8 || !bytes.Equal(data[:8], []byte("\x89PNG\r\n\x1a\n")) {
+		return 0, errMalformed
+	}
+
+	orientation := 0
+	i := 8
+	for i+12 <= len(data) {
+		length := int64(binary.BigEndian.Uint32(data[i:]))
+		end := int64(i) + 12 + length
+		if end > int64(len(data)) {
+			return 0, errMalformed
+		}
+		chunk := data[i+4 : end-4] // Type and data, covered by the CRC
+		if string(chunk[:4]) == "eXIf" {
+			o, err := scrubTIFF(chunk[4:])
+			if err != nil {
+				clear(chunk[4:])
+			} else if o != 0 {
+				orientation = o
+			}
+			binary.BigEndian.PutUint32(data[end-4:], crc32.ChecksumIEEE(chunk))
+		}
+		if string(chunk[:4]) == "IEND" {
+			break
+		}
+		i = int(end)
+	}
+	return orientation, nil
+}
+
+// scrubTIFF empties the GPS directory of TIFF-structured EXIF data in place,
+// zeroing its values, and returns the orientation from the first directory
+func scrubTIFF(b []byte) (int, error) {
+	if len(b) < 8 {
+		return 0, errMalformed
+	}
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, errMalformed
+	}
+	if order.Uint16(b[2:]) != 42 {
+		return 0, errMalformed
+	}
+
+	ifd := int64(order.Uint32(b[4:]))
+	count, err := ifdEntries(b, order, ifd)
+	if err != nil {
+		return 0, err
+	}
+
+	orientation := 0
+	for n := int64(0); n < count; n++ {
+		entry := ifd + 2 + 12*n
+		switch order.Uint16(b[entry:]) {
+		case tagOrientation:
+			orientation = int(order.Uint16(b[entry+8:]))
+		case tagGPSInfo:
+			if err := clearIFD(b, order, int64(order.Uint32(b[entry+8:]))); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if orientation < 1 || orientation > 8 {
+		orientation = 0
+	}
+	return orientation, nil
+}
+
+// ifdEntries returns the entry count of the directory at offset, checking
+// that its entries lie within b
+func ifdEntries(b []byte, order binary.ByteOrder, offset int64) (int64, error) {
+	if offset < 8 || offset+2 > int64(len(b)) {
+		return 0, errMalformed
+	}
+	count := int64(order.Uint16(b[offset:]))
+	if offset+2+12*count > int64(len(b)) {
+		return 0, errMalformed
+	}
+	return count, nil
+}
+
+// clearIFD zeroes every entry of a directory and the values they point to,
+// leaving an empty directory with no successor
+func clearIFD(b []byte, order binary.ByteOrder, offset int64) error {
+	count, err := ifdEntries(b, order, offset)
+	if err != nil {
+		return err
+	}
+
+	for n := int64(0); n < count; n++ {
+		entry := offset + 2 + 12*n
+		size := tiffTypeSizes[order.Uint16(b[entry+2:])] * int64(order.Uint32(b[entry+4:]))
+		if size > 4 {
+			value := int64(order.Uint32(b[entry+8:]))
+			if value >= 0 && value+size <= int64(len(b)) {
+				clear(b[value : value+size])
+			}
+		}
+	}
+	clear(b[offset : offset+2+12*count])
+	// The next directory offset now reads as zero from the cleared entries
+	if offset+2+12*count+4 <= int64(len(b)) {
+		clear(b[offset+2+12*count : offset+2+12*count+4])
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrUnsupportedType is returned for uploads that are not JPEG, PNG or
+	// GIF images
+	ErrUnsupportedType = errors.New("only JPEG, PNG and GIF images are supported")
+	// ErrTooLarge is returned for uploads over the size or pixel limits
+	ErrTooLarge = errors.New("image is too large")
+)
+
+// Largest accepted image, in pixels, to bound decoding memory
+const maxPixels = 50_000_000
+
+// Longest side of generated thumbnails
+const ThumbnailSize = 320
+
+// extensions are the stored file extensions of the supported types
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is a processed upload: the original with GPS data removed and a
+// JPEG thumbnail
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int // As displayed, after applying the EXIF orientation
+	Height      int
+	Thumbnail   []byte
+}
+
+// Upload describes a stored image
+type Upload struct {
+	ContentType  string
+	Key          string
+	ThumbnailKey string
+	Size         int64
+	Width        int
+	Height       int
+}
+
+// Process validates an uploaded image, strips GPS data from its EXIF
+// metadata and renders a thumbnail. data is modified in place.
+func Process(data []byte) (*Image, error) {
+	if int64(len(data)) > maxUploadBytes {
+		return nil, ErrTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupportedType
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	orientation := 0
+	switch contentType {
+	case "image/jpeg":
+		orientation, err = scrubJPEG(data)
+	case "image/png":
+		orientation, err = scrubPNG(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	var src image.Image
+	switch contentType {
+	case "image/jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	thumb := orient(Thumbnail(src, ThumbnailSize), orientation)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	width, height := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		width, height = height, width
+	}
+	return &Image{
+		ContentType: contentType,
+		Data:        data,
+		Width:       width,
+		Height:      height,
+		Thumbnail:   buf.Bytes(),
+	}, nil
+}
+
+// Save processes an uploaded image and stores it with its thumbnail under
+// prefix, e.g. "community"
+func Save(ctx context.Context, s Store, prefix string, data []byte) (*Upload, error) {
+	img, err := Process(data)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("%s/%s/%s", prefix, time.Now().Format("2006/01"), name)
+	upload := &Upload{
+		ContentType:  img.ContentType,
+		Key:          base + extensions[img.ContentType],
+		ThumbnailKey: base + "-thumb.jpg",
+		Size:         int64(len(img.Data)),
+		Width:        img.Width,
+		Height:       img.Height,
+	}
+
+	if err := s.Put(ctx, upload.Key, img.ContentType, bytes.NewReader(img.Data)); err != nil {
+		return nil, err
+	}
+	if err := s.Put(ctx, upload.ThumbnailKey, "image/jpeg", bytes.NewReader(img.Thumbnail)); err != nil {
+		s.Delete(ctx, upload.Key)
+		return nil, err
+	}
+	return upload, nil
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Thumbnail scales src down to fit within size×size by averaging the source
+// pixels under each thumbnail pixel. Smaller images are copied unscaled.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return rgba
+	}
+	tw, th := size, size
+	if w > h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, max((ty+1)*h/th, ty*h/th+1)
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, max((tx+1)*w/tw, tx*w/tw+1)
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				row := rgba.Pix[y*rgba.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[ty*dst.Stride+tx*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation, so the image displays upright
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // Rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // Mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // Transposed
+				dx, dy = y, x
+			case 6: // Needs a 90° clockwise turn
+				dx, dy = h-1-y, x
+			case 7: // Transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // Needs a 90° counter-clockwise turn
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+	return dst
+}
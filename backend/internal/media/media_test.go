@@ -0,0 +1,230 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// Offsets within testEXIF
+const (
+	gpsIFDOffset   = 38
+	gpsValueOffset = 68
+	exifLength     = 92
+)
+
+// testEXIF builds little-endian TIFF data with an orientation tag and a GPS
+// directory holding a latitude
+func testEXIF(orientation uint16) []byte {
+	b := make([]byte, exifLength)
+	le := binary.LittleEndian
+	copy(b, "II")
+	le.PutUint16(b[2:], 42)
+	le.PutUint32(b[4:], 8)
+
+	// IFD0: orientation and GPS pointer
+	le.PutUint16(b[8:], 2)
+	le.PutUint16(b[10:], tagOrientation)
+	le.PutUint16(b[12:], 3)
+	le.PutUint32(b[14:], 1)
+	le.PutUint16(b[18:], orientation)
+	le.PutUint16(b[22:], tagGPSInfo)
+	le.PutUint16(b[24:], 4)
+	le.PutUint32(b[26:], 1)
+	le.PutUint32(b[30:], gpsIFDOffset)
+
+	// GPS IFD: latitude ref and latitude
+	le.PutUint16(b[gpsIFDOffset:], 2)
+	le.PutUint16(b[40:], 1)
+	le.PutUint16(b[42:], 2)
+	le.PutUint32(b[44:], 2)
+	copy(b[48:], "N")
+	le.PutUint16(b[52:], 2)
+	le.PutUint16(b[54:], 5)
+	le.PutUint32(b[56:], 3)
+	le.PutUint32(b[60:], gpsValueOffset)
+	for i, v := range []uint32{31, 1, 14, 1, 5, 1} {
+		le.PutUint32(b[gpsValueOffset+4*i:], v)
+	}
+	return b
+}
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	return img
+}
+
+// jpegWithEXIF encodes a JPEG and inserts an EXIF segment after SOI
+func jpegWithEXIF(t *testing.T, w, h int, exif []byte) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	segment := append([]byte("Exif\x00\x00"), exif...)
+	header := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(segment)+2))
+
+	out := append([]byte{}, encoded[:2]...)
+	out = append(out, header...)
+	out = append(out, segment...)
+	return append(out, encoded[2:]...)
+}
+
+// pngWithEXIF encodes a PNG and inserts an eXIf chunk after IHDR
+func pngWithEXIF(t *testing.T, w, h int, exif []byte) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	ihdrEnd := 8 + 12 + 13
+
+	chunk := make([]byte, 4, 12+len(exif))
+	binary.BigEndian.PutUint32(chunk, uint32(len(exif)))
+	chunk = append(chunk, "eXIf"...)
+	chunk = append(chunk, exif...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := append([]byte{}, encoded[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, encoded[ihdrEnd:]...)
+}
+
+func checkGPSCleared(t *testing.T, data []byte) {
+	t.Helper()
+	at := bytes.Index(data, []byte("II*\x00"))
+	if at < 0 {
+		t.Fatal("EXIF data missing after processing")
+	}
+	tiff := data[at : at+exifLength]
+	if got := binary.LittleEndian.Uint16(tiff[18:]); got != 6 {
+		t.Errorf("orientation = %d, want it kept as 6", got)
+	}
+	for i, b := range tiff[gpsIFDOffset:] {
+		if b != 0 {
+			t.Fatalf("GPS byte %d = %#x, want 0", gpsIFDOffset+i, b)
+		}
+	}
+}
+
+func TestProcessStripsGPSFromJPEG(t *testing.T) {
+	img, err := Process(jpegWithEXIF(t, 40, 20, testEXIF(6)))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.ContentType != "image/jpeg" {
+		t.Errorf("content type = %s", img.ContentType)
+	}
+	checkGPSCleared(t, img.Data)
+	if _, err := jpeg.Decode(bytes.NewReader(img.Data)); err != nil {
+		t.Errorf("scrubbed JPEG does not decode: %v", err)
+	}
+
+	// Orientation 6 turns the 40x20 image upright as 20x40
+	if img.Width != 20 || img.Height != 40 {
+		t.Errorf("size = %dx%d, want 20x40", img.Width, img.Height)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail does not decode: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Errorf("thumbnail size = %dx%d, want 20x40", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessStripsGPSFromPNG(t *testing.T) {
+	img, err := Process(pngWithEXIF(t, 30, 30, testEXIF(6)))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	checkGPSCleared(t, img.Data)
+	// The decoder checks chunk CRCs, so this also covers the rewritten CRC
+	if _, err := png.Decode(bytes.NewReader(img.Data)); err != nil {
+		t.Errorf("scrubbed PNG does not decode: %v", err)
+	}
+}
+
+func TestProcessBlanksUnparsableEXIF(t *testing.T) {
+	exif := testEXIF(1)
+	binary.LittleEndian.PutUint32(exif[4:], 5000) // IFD0 past the end
+
+	img, err := Process(jpegWithEXIF(t, 10, 10, exif))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if bytes.Contains(img.Data, []byte("Exif\x00\x00")) {
+		t.Error("unparsable EXIF segment kept")
+	}
+}
+
+func TestProcessRejectsUnsupportedData(t *testing.T) {
+	if _, err := Process([]byte("%PDF-1.4 not an image")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("err = %v, want ErrUnsupportedType", err)
+	}
+
+	saved := maxUploadBytes
+	maxUploadBytes = 100
+	defer func() { maxUploadBytes = saved }()
+	if _, err := Process(jpegWithEXIF(t, 40, 40, testEXIF(1))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestThumbnailFitsWithinSize(t *testing.T) {
+	thumb := Thumbnail(testImage(1000, 500), ThumbnailSize)
+	if b := thumb.Bounds(); b.Dx() != 320 || b.Dy() != 160 {
+		t.Errorf("thumbnail size = %dx%d, want 320x160", b.Dx(), b.Dy())
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	s := &LocalStore{Dir: t.TempDir(), BaseURL: "/media/"}
+
+	upload, err := Save(ctx, s, "community", jpegWithEXIF(t, 400, 200, testEXIF(1)))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, key := range []string{upload.Key, upload.ThumbnailKey} {
+		r, err := s.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("Open %s: %v", key, err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if len(data) == 0 {
+			t.Errorf("%s is empty", key)
+		}
+	}
+	if got := s.URL(upload.Key); got != "/media/"+upload.Key {
+		t.Errorf("URL = %s", got)
+	}
+
+	if err := s.Delete(ctx, upload.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(ctx, upload.Key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after delete: %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"../secret", "/etc/passwd", "a/../../b", "a//b", ""} {
+		if err := s.Put(ctx, key, "", bytes.NewReader(nil)); err == nil {
+			t.Errorf("Put accepted key %q", key)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"conflux-demo/backend/config"
+)
+
+// ErrNotFound is returned when a key has no stored blob
+var ErrNotFound = errors.New("media not found")
+
+// Store keeps uploaded blobs under slash-separated keys such as
+// "community/2024/05/ab12cd.jpg"
+type Store interface {
+	Name() string
+	Put(ctx context.Context, key, contentType string, body io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients fetch the blob
+	URL(key string) string
+}
+
+var (
+	storesMu sync.RWMutex
+	stores   = map[string]Store{}
+)
+
+// Register makes a store available under its name for MEDIA_STORE
+func Register(s Store) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	stores[s.Name()] = s
+}
+
+func lookupStore(name string) (Store, bool) {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	s, ok := stores[name]
+	return s, ok
+}
+
+var (
+	store          Store = &LocalStore{Dir: "./uploads/media", BaseURL: "/media"}
+	maxUploadBytes int64 = 10 << 20
+)
+
+// Initialize registers the built-in stores and selects the configured one
+func Initialize(cfg *config.Config) {
+	Register(&LocalStore{Dir: cfg.MediaDir, BaseURL: cfg.MediaBaseURL})
+
+	if s, ok := lookupStore(cfg.MediaStore); ok {
+		store = s
+	} else {
+		log.Printf("Warning: unknown media store %q, using local", cfg.MediaStore)
+		store, _ = lookupStore("local")
+	}
+	if cfg.MediaMaxUploadMB > 0 {
+		maxUploadBytes = int64(cfg.MediaMaxUploadMB) << 20
+	}
+	log.Printf("Media store: %s", store.Name())
+}
+
+// Default returns the configured store
+func Default() Store {
+	return store
+}
+
+// MaxUploadBytes returns the largest accepted upload
+func MaxUploadBytes() int64 {
+	return maxUploadBytes
+}
+
+// ValidKey reports whether key is a clean relative path without ".."
+// elements, so stores can map it onto a filesystem or bucket safely
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	if path.Clean(key) != key {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." {
+			return false
+		}
+	}
+	return true
+}
+
+// LocalStore keeps blobs on the local filesystem and serves them through
+// the API under BaseURL
+type LocalStore struct {
+	Dir     string
+	BaseURL string
+}
+
+// Name implements Store
+func (*LocalStore) Name() string { return "local" }
+
+func (s *LocalStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid media key %q", key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put implements Store. The blob is written to a temporary file first, so
+// readers never see a partial upload.
+func (s *LocalStore) Put(_ context.Context, key, _ string, body io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Open implements Store
+func (s *LocalStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete implements Store. Deleting a missing blob is not an error.
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// URL implements Store
+func (s *LocalStore) URL(key string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key
+}
@@ -0,0 +1,84 @@
+package moderation
+
+// Automaton finds every occurrence of a set of words in one pass over the
+// text (Aho-Corasick). It matches runes, so Chinese words need no
+// segmentation.
+type Automaton struct {
+	nodes   []acNode
+	lengths []int // Rune length of each word
+}
+
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output []int // Indexes of the words ending at this node
+}
+
+// Match is an occurrence of a word, as rune offsets into the text
+type Match struct {
+	Word  int // Index into the words the automaton was built from
+	Start int
+	End   int // Exclusive
+}
+
+// NewAutomaton builds an automaton for words. Empty words are ignored.
+func NewAutomaton(words []string) *Automaton {
+	a := &Automaton{nodes: []acNode{{next: map[rune]int{}}}, lengths: make([]int, len(words))}
+	for i, word := range words {
+		if word == "" {
+			continue
+		}
+		node := 0
+		for _, r := range word {
+			a.lengths[i]++
+			child, ok := a.nodes[node].next[r]
+			if !ok {
+				child = len(a.nodes)
+				a.nodes = append(a.nodes, acNode{next: map[rune]int{}})
+				a.nodes[node].next[r] = child
+			}
+			node = child
+		}
+		a.nodes[node].output = append(a.nodes[node].output, i)
+	}
+
+	// Breadth-first, so a node's failure link is resolved before its children
+	queue := make([]int, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range a.nodes[node].next {
+			fail := a.nodes[node].fail
+			for fail != 0 && a.nodes[fail].next[r] == 0 {
+				fail = a.nodes[fail].fail
+			}
+			if target, ok := a.nodes[fail].next[r]; ok && target != child {
+				a.nodes[child].fail = target
+			}
+			f := a.nodes[child].fail
+			a.nodes[child].output = append(a.nodes[child].output, a.nodes[f].output...)
+			queue = append(queue, child)
+		}
+	}
+	return a
+}
+
+// FindAll returns every match in text, including overlapping ones, ordered
+// by end position
+func (a *Automaton) FindAll(text []rune) []Match {
+	var matches []Match
+	node := 0
+	for i, r := range text {
+		for node != 0 && a.nodes[node].next[r] == 0 {
+			node = a.nodes[node].fail
+		}
+		node = a.nodes[node].next[r]
+		for _, word := range a.nodes[node].output {
+			matches = append(matches, Match{Word: word, Start: i + 1 - a.lengths[word], End: i + 1})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,158 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+// Verdict is the outcome of checking content against the blocklist
+type Verdict struct {
+	Action string   // ActionAllow, ActionReview or ActionBlock
+	Terms  []string // Patterns that matched
+}
+
+// Filter checks text against keyword and regular expression rules.
+// Keywords are matched after folding case and full-width forms and
+// ignoring spaces, punctuation and symbols, so "敏 感-词" matches "敏感词".
+// Keywords made of ASCII letters and digits only match whole words.
+type Filter struct {
+	automaton *Automaton
+	keywords  []keyword // Indexed like the automaton's words
+	regexes   []regexRule
+}
+
+type keyword struct {
+	pattern   string
+	action    string
+	wholeWord bool
+}
+
+type regexRule struct {
+	pattern string
+	action  string
+	re      *regexp.Regexp
+}
+
+// NewFilter compiles blocklist terms. It fails on an invalid regular
+// expression or an unknown kind or action.
+func NewFilter(terms []models.BlockedTerm) (*Filter, error) {
+	f := &Filter{}
+	var words []string
+	for _, term := range terms {
+		if term.Action != ActionBlock && term.Action != ActionReview {
+			return nil, fmt.Errorf("term %q: unknown action %q", term.Pattern, term.Action)
+		}
+		switch term.Kind {
+		case TermKeyword:
+			normalized, _ := normalize(term.Pattern)
+			if len(normalized) == 0 {
+				continue
+			}
+			words = append(words, string(normalized))
+			f.keywords = append(f.keywords, keyword{
+				pattern:   term.Pattern,
+				action:    term.Action,
+				wholeWord: isASCIIWord(normalized),
+			})
+		case TermRegex:
+			re, err := regexp.Compile("(?i)" + term.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("term %q: %w", term.Pattern, err)
+			}
+			f.regexes = append(f.regexes, regexRule{pattern: term.Pattern, action: term.Action, re: re})
+		default:
+			return nil, fmt.Errorf("term %q: unknown kind %q", term.Pattern, term.Kind)
+		}
+	}
+	f.automaton = NewAutomaton(words)
+	return f, nil
+}
+
+// Check returns the strictest action of the rules text matches
+func (f *Filter) Check(text string) Verdict {
+	verdict := Verdict{Action: ActionAllow}
+	seen := map[string]bool{}
+	hit := func(pattern, action string) {
+		if action == ActionBlock || verdict.Action == ActionAllow {
+			verdict.Action = action
+		}
+		if !seen[pattern] {
+			seen[pattern] = true
+			verdict.Terms = append(verdict.Terms, pattern)
+		}
+	}
+
+	original := []rune(text)
+	normalized, positions := normalize(text)
+	for _, m := range f.automaton.FindAll(normalized) {
+		kw := f.keywords[m.Word]
+		if kw.wholeWord && !atWordBoundaries(original, positions[m.Start], positions[m.End-1]) {
+			continue
+		}
+		hit(kw.pattern, kw.action)
+	}
+	for _, rule := range f.regexes {
+		if rule.re.MatchString(text) {
+			hit(rule.pattern, rule.action)
+		}
+	}
+	return verdict
+}
+
+// normalize folds text for keyword matching and returns, for each rune
+// kept, its index in the original runes
+func normalize(text string) ([]rune, []int) {
+	runes := make([]rune, 0, len(text))
+	positions := make([]int, 0, len(text))
+	i := 0
+	for _, r := range text {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			// Full-width ASCII forms
+			r -= 0xFEE0
+		case r == 0x3000:
+			r = ' '
+		}
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) && !unicode.IsSymbol(r) && !unicode.Is(unicode.Cf, r) {
+			runes = append(runes, unicode.ToLower(r))
+			positions = append(positions, i)
+		}
+		i++
+	}
+	return runes, positions
+}
+
+func isASCIIWord(runes []rune) bool {
+	for _, r := range runes {
+		if !isASCIIWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIIWordRune(r rune) bool {
+	r = unicode.ToLower(r)
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+}
+
+// atWordBoundaries reports whether the original runes from start to end
+// (inclusive) are not preceded or followed by an ASCII letter or digit
+func atWordBoundaries(original []rune, start, end int) bool {
+	if start > 0 && isASCIIWordRune(original[start-1]) {
+		return false
+	}
+	if end+1 < len(original) && isASCIIWordRune(original[end+1]) {
+		return false
+	}
+	return true
+}
+
+// Summary joins matched terms for logs and review notes
+func (v Verdict) Summary() string {
+	return strings.Join(v.Terms, ", ")
+}
@@ -0,0 +1,178 @@
+package moderation
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+)
+
+// Visibility states of posts and comments. Content without a status is
+// visible.
+const (
+	StatusVisible       = "visible"
+	StatusHidden        = "hidden"
+	StatusPendingReview = "pending_review"
+)
+
+// Blocklist actions
+const (
+	ActionAllow  = "allow"
+	ActionReview = "review" // Hold the content for admin review
+	ActionBlock  = "block"  // Refuse the content
+)
+
+// Blocklist term kinds
+const (
+	TermKeyword = "keyword"
+	TermRegex   = "regex"
+)
+
+// Report reasons
+var ReportReasons = []string{"spam", "abuse", "fraud", "illegal", "other"}
+
+// ValidReason reports whether reason is one of ReportReasons
+func ValidReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	filterMu sync.RWMutex
+	filter   = &Filter{automaton: NewAutomaton(nil)}
+
+	postLimiter     = &Limiter{Limit: 10, Window: time.Hour}
+	commentLimiter  = &Limiter{Limit: 60, Window: time.Hour}
+	reportThreshold = 3
+)
+
+// Initialize loads the blocklist and applies the posting limits
+func Initialize(cfg *config.Config, db *gorm.DB) {
+	postLimiter = &Limiter{Limit: cfg.CommunityPostsPerHour, Window: time.Hour}
+	commentLimiter = &Limiter{Limit: cfg.CommunityCommentsPerHour, Window: time.Hour}
+	if cfg.ModerationReportThreshold > 0 {
+		reportThreshold = cfg.ModerationReportThreshold
+	}
+
+	if err := Reload(db); err != nil {
+		log.Printf("Warning: failed to load moderation blocklist: %v", err)
+	}
+}
+
+// Reload rebuilds the filter from the blocked_terms table. Terms that do
+// not compile are skipped with a warning, so one bad pattern does not
+// disable the rest.
+func Reload(db *gorm.DB) error {
+	var terms []models.BlockedTerm
+	if err := db.Order("id ASC").Find(&terms).Error; err != nil {
+		return err
+	}
+
+	valid := terms[:0]
+	for _, term := range terms {
+		if _, err := NewFilter([]models.BlockedTerm{term}); err != nil {
+			log.Printf("Warning: skipping blocked term %d: %v", term.ID, err)
+			continue
+		}
+		valid = append(valid, term)
+	}
+	f, err := NewFilter(valid)
+	if err != nil {
+		return err
+	}
+
+	filterMu.Lock()
+	filter = f
+	filterMu.Unlock()
+	log.Printf("Moderation blocklist: %d terms", len(valid))
+	return nil
+}
+
+// Check runs text through the current blocklist
+func Check(text string) Verdict {
+	filterMu.RLock()
+	f := filter
+	filterMu.RUnlock()
+	return f.Check(text)
+}
+
+// StatusFor returns the status new content gets for a verdict that does
+// not block it
+func StatusFor(v Verdict) string {
+	if v.Action == ActionReview {
+		return StatusPendingReview
+	}
+	return StatusVisible
+}
+
+// AllowPost records a post by a user and reports whether it is within the
+// hourly limit, or how long to wait otherwise
+func AllowPost(userID uint) (bool, time.Duration) {
+	return postLimiter.Allow(strconv.FormatUint(uint64(userID), 10), time.Now())
+}
+
+// AllowComment records a comment by a user and reports whether it is within
+// the hourly limit, or how long to wait otherwise
+func AllowComment(userID uint) (bool, time.Duration) {
+	return commentLimiter.Allow(strconv.FormatUint(uint64(userID), 10), time.Now())
+}
+
+// ReportThreshold returns how many open reports send content to review
+func ReportThreshold() int {
+	return reportThreshold
+}
+
+// Limiter allows Limit events per key in any sliding Window. A Limit of
+// zero or less disables it.
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu        sync.Mutex
+	events    map[string][]time.Time
+	lastSweep time.Time
+}
+
+// Allow records an event for key at now if it is within the limit, and
+// otherwise returns how long until the oldest event leaves the window
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if l.Limit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.events == nil {
+		l.events = map[string][]time.Time{}
+	}
+	cutoff := now.Add(-l.Window)
+	if now.Sub(l.lastSweep) > l.Window {
+		// Drop keys that have gone quiet
+		for k, events := range l.events {
+			if len(events) == 0 || !events[len(events)-1].After(cutoff) {
+				delete(l.events, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	events := l.events[key]
+	for len(events) > 0 && !events[0].After(cutoff) {
+		events = events[1:]
+	}
+	if len(events) >= l.Limit {
+		l.events[key] = events
+		return false, events[0].Sub(cutoff)
+	}
+	l.events[key] = append(events, now)
+	return true, 0
+}
@@ -0,0 +1,136 @@
+package moderation
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAutomatonFindsOverlappingWords(t *testing.T) {
+	words := []string{"he", "she", "his", "hers", "敏感", "感词"}
+	a := NewAutomaton(words)
+
+	var found []string
+	for _, m := range a.FindAll([]rune("ushers 敏感词")) {
+		found = append(found, words[m.Word])
+	}
+	want := []string{"she", "he", "hers", "敏感", "感词"}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("found %v, want %v", found, want)
+	}
+
+	matches := a.FindAll([]rune("敏感"))
+	if len(matches) != 1 || matches[0].Start != 0 || matches[0].End != 2 {
+		t.Errorf("matches = %+v, want one at [0, 2)", matches)
+	}
+}
+
+func TestFilterCheck(t *testing.T) {
+	f, err := NewFilter([]models.BlockedTerm{
+		{Kind: TermKeyword, Pattern: "敏感词", Action: ActionBlock},
+		{Kind: TermKeyword, Pattern: "spam", Action: ActionReview},
+		{Kind: TermKeyword, Pattern: "ass", Action: ActionBlock},
+		{Kind: TermRegex, Pattern: `\b1[3-9]\d{9}\b`, Action: ActionReview},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text   string
+		action string
+	}{
+		{"今天天气不错", ActionAllow},
+		{"这里有敏感词", ActionBlock},
+		{"敏 感-词", ActionBlock},
+		{"敏感\u200b词", ActionBlock},
+		{"Buy SPAM now", ActionReview},
+		{"ｓｐａｍ", ActionReview},
+		{"s.p.a.m", ActionReview},
+		{"a classic harvest", ActionAllow}, // "ass" only as a whole word
+		{"kick ass", ActionBlock},
+		{"call 13812345678", ActionReview},
+		{"spam and 敏感词", ActionBlock},
+	}
+	for _, tt := range tests {
+		if got := f.Check(tt.text); got.Action != tt.action {
+			t.Errorf("Check(%q) = %s %v, want %s", tt.text, got.Action, got.Terms, tt.action)
+		}
+	}
+
+	if got := f.Check("spam spam 敏感词").Terms; !reflect.DeepEqual(got, []string{"spam", "敏感词"}) {
+		t.Errorf("terms = %v", got)
+	}
+}
+
+func TestNewFilterRejectsBadTerms(t *testing.T) {
+	bad := []models.BlockedTerm{
+		{Kind: TermRegex, Pattern: "(", Action: ActionBlock},
+		{Kind: "glob", Pattern: "x", Action: ActionBlock},
+		{Kind: TermKeyword, Pattern: "x", Action: "delete"},
+	}
+	for _, term := range bad {
+		if _, err := NewFilter([]models.BlockedTerm{term}); err == nil {
+			t.Errorf("NewFilter accepted %+v", term)
+		}
+	}
+}
+
+func TestReloadSkipsInvalidTerms(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.BlockedTerm{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&models.BlockedTerm{Kind: TermRegex, Pattern: "(", Action: ActionBlock})
+	db.Create(&models.BlockedTerm{Kind: TermKeyword, Pattern: "违禁", Action: ActionBlock})
+
+	if err := Reload(db); err != nil {
+		t.Fatal(err)
+	}
+	if got := Check("有违禁内容"); got.Action != ActionBlock {
+		t.Errorf("Check = %s, want block", got.Action)
+	}
+	if got := Check("正常内容"); got.Action != ActionAllow {
+		t.Errorf("Check = %s, want allow", got.Action)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := &Limiter{Limit: 2, Window: time.Hour}
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("0xabc", start.Add(time.Duration(i)*time.Minute)); !ok {
+			t.Fatalf("event %d refused", i)
+		}
+	}
+	ok, wait := l.Allow("0xabc", start.Add(10*time.Minute))
+	if ok || wait != 50*time.Minute {
+		t.Errorf("third event = %v, wait %v; want refused, wait 50m", ok, wait)
+	}
+	if ok, _ := l.Allow("0xdef", start.Add(10*time.Minute)); !ok {
+		t.Error("other key refused")
+	}
+	if ok, _ := l.Allow("0xabc", start.Add(61*time.Minute)); !ok {
+		t.Error("event after the window refused")
+	}
+
+	unlimited := &Limiter{}
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.Allow("k", start); !ok {
+			t.Fatal("disabled limiter refused")
+		}
+	}
+}
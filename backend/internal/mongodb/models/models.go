@@ -9,24 +9,116 @@ import (
 // Post represents a community post stored in MongoDB
 type Post struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID        string             `bson:"user_id" json:"user_id"`
+	UserID        uint               `bson:"user_id" json:"user_id"` // The author's models.User ID
 	Username      string             `bson:"username" json:"username"`
 	WalletAddress string             `bson:"wallet_address" json:"wallet_address"`
 	Content       string             `bson:"content" json:"content"`
 	LikesCount    int                `bson:"likes_count" json:"likes_count"`
 	CommentsCount int                `bson:"comments_count" json:"comments_count"`
+	HotScore      float64            `bson:"hot_score" json:"-"` // community.HotScore of the counts above
+	Attachments   []Attachment       `bson:"attachments,omitempty" json:"attachments"`
+	Tags          []string           `bson:"tags,omitempty" json:"tags"` // Lowercased #topics in the content
+	Mentions      []Mention          `bson:"mentions,omitempty" json:"mentions"`
+	Status        string             `bson:"status,omitempty" json:"status"` // "visible", "hidden" or "pending_review"; empty is visible
+	Moderation    *Moderation        `bson:"moderation,omitempty" json:"-"`
+	Revisions     []Revision         `bson:"revisions,omitempty" json:"-"`
+	EditedAt      *time.Time         `bson:"edited_at,omitempty" json:"edited_at,omitempty"`
+	DeletedAt     *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
 	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
-// Comment represents a comment on a post
+// Attachment is an image uploaded for a post. It belongs to its uploader
+// until a post claims it; posts embed a copy of their attachments.
+type Attachment struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UploaderWallet string              `bson:"uploader_wallet" json:"-"`
+	PostID         *primitive.ObjectID `bson:"post_id,omitempty" json:"-"`
+	ContentType    string              `bson:"content_type" json:"content_type"`
+	Key            string              `bson:"key" json:"-"`           // Blob store key of the image
+	ThumbnailKey   string              `bson:"thumbnail_key" json:"-"` // Blob store key of the JPEG thumbnail
+	URL            string              `bson:"-" json:"url"`
+	ThumbnailURL   string              `bson:"-" json:"thumbnail_url"`
+	Size           int64               `bson:"size" json:"size"`
+	Width          int                 `bson:"width" json:"width"`
+	Height         int                 `bson:"height" json:"height"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// Comment represents a comment on a post. Replies point at their parent
+// comment; top-level comments have no parent and depth 0.
 type Comment struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	PostID        primitive.ObjectID  `bson:"post_id" json:"post_id"`
+	ParentID      *primitive.ObjectID `bson:"parent_id" json:"parent_id"`
+	Depth         int                 `bson:"depth" json:"depth"`
+	UserID        uint                `bson:"user_id" json:"user_id"` // As on Post
+	Username      string              `bson:"username" json:"username"`
+	WalletAddress string              `bson:"wallet_address" json:"wallet_address"`
+	Content       string              `bson:"content" json:"content"`
+	RepliesCount  int                 `bson:"replies_count" json:"replies_count"` // Replies not deleted
+	Tags          []string            `bson:"tags,omitempty" json:"tags"`
+	Mentions      []Mention           `bson:"mentions,omitempty" json:"mentions"`
+	Status        string              `bson:"status,omitempty" json:"status"` // As on Post
+	Moderation    *Moderation         `bson:"moderation,omitempty" json:"-"`
+	Revisions     []Revision          `bson:"revisions,omitempty" json:"-"`
+	EditedAt      *time.Time          `bson:"edited_at,omitempty" json:"edited_at,omitempty"`
+	DeletedAt     *time.Time          `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// Mention is a user @mentioned in a post or comment
+type Mention struct {
+	UserID   uint   `bson:"user_id" json:"user_id"`
+	Wallet   string `bson:"wallet" json:"wallet"`
+	Username string `bson:"username" json:"username"`
+}
+
+// Moderation records why content was held and the review decision on it
+type Moderation struct {
+	FlaggedTerms []string   `bson:"flagged_terms,omitempty" json:"flagged_terms,omitempty"` // Blocklist terms that held it
+	OpenReports  int        `bson:"open_reports" json:"open_reports"`
+	ReviewedBy   string     `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewNote   string     `bson:"review_note,omitempty" json:"review_note,omitempty"`
+	ReviewedAt   *time.Time `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+}
+
+// Report is a user's complaint about a post or comment
+type Report struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TargetType     string             `bson:"target_type" json:"target_type"` // "post" or "comment"
+	TargetID       primitive.ObjectID `bson:"target_id" json:"target_id"`
+	ReporterWallet string             `bson:"reporter_wallet" json:"reporter_wallet"`
+	Reason         string             `bson:"reason" json:"reason"`
+	Note           string             `bson:"note,omitempty" json:"note,omitempty"`
+	Status         string             `bson:"status" json:"status"`                             // "open" or "resolved"
+	Resolution     string             `bson:"resolution,omitempty" json:"resolution,omitempty"` // Status given to the target
+	ResolvedBy     string             `bson:"resolved_by,omitempty" json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time         `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Revision is the content of a post or comment before an edit
+type Revision struct {
+	Content  string    `bson:"content" json:"content"`
+	EditedAt time.Time `bson:"edited_at" json:"edited_at"` // When this content was replaced
+}
+
+// PostLike records that a user liked a post
+type PostLike struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	PostID    primitive.ObjectID `bson:"post_id" json:"post_id"`
-	UserID    string             `bson:"user_id" json:"user_id"`
-	Username  string             `bson:"username" json:"username"`
-	Content   string             `bson:"content" json:"content"`
+	UserID    uint               `bson:"user_id" json:"user_id"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	DeletedAt *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // Set when the post is deleted
+}
+
+// Follow records that a user follows another's posts
+type Follow struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FollowerID uint               `bson:"follower_id" json:"follower_id"`
+	FolloweeID uint               `bson:"followee_id" json:"followee_id"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
 }
 
 // News represents a news article stored in MongoDB
@@ -40,3 +132,31 @@ type News struct {
 	Date      time.Time          `bson:"date" json:"date"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 }
+
+// Notification is an in-app inbox message stored in MongoDB
+type Notification struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	UserID    uint                   `bson:"user_id" json:"user_id"`
+	Type      string                 `bson:"type" json:"type"` // e.g. "comment", "transaction", "price_alert"
+	Title     string                 `bson:"title" json:"title"`
+	Body      string                 `bson:"body" json:"body"`
+	Data      map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+	ReadAt    *time.Time             `bson:"read_at" json:"read_at"`
+	CreatedAt time.Time              `bson:"created_at" json:"created_at"`
+}
+
+// NotificationPreferences holds a user's delivery channels per notification
+// type. Types without an entry are delivered on every channel.
+type NotificationPreferences struct {
+	ID        primitive.ObjectID         `bson:"_id,omitempty" json:"-"`
+	UserID    uint                       `bson:"user_id" json:"user_id"`
+	Types     map[string]ChannelSettings `bson:"types" json:"types"`
+	UpdatedAt time.Time                  `bson:"updated_at" json:"updated_at"`
+}
+
+// ChannelSettings enables or disables each delivery channel
+type ChannelSettings struct {
+	Inbox   bool `bson:"inbox" json:"inbox"`
+	Push    bool `bson:"push" json:"push"`
+	Webhook bool `bson:"webhook" json:"webhook"`
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake records messages in memory, for tests and local development
+type Fake struct {
+	Channel string // Name returned by Name, "fake" by default
+	Err     error  // Returned from Notify when set
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Name implements Notifier
+func (f *Fake) Name() string {
+	if f.Channel == "" {
+		return "fake"
+	}
+	return f.Channel
+}
+
+// Notify implements Notifier
+func (f *Fake) Notify(_ context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, msg)
+	return f.Err
+}
+
+// Messages returns the messages received so far
+func (f *Fake) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.messages...)
+}
+
+// MemoryPreferences holds disabled (user, type, channel) combinations in
+// memory, for tests
+type MemoryPreferences struct {
+	mu       sync.Mutex
+	disabled map[string]bool
+}
+
+// Disable turns a channel off for one user and type
+func (p *MemoryPreferences) Disable(userID uint, msgType, channel string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.disabled == nil {
+		p.disabled = map[string]bool{}
+	}
+	p.disabled[prefKey(userID, msgType, channel)] = true
+}
+
+// Enabled implements Preferences
+func (p *MemoryPreferences) Enabled(_ context.Context, userID uint, msgType, channel string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.disabled[prefKey(userID, msgType, channel)], nil
+}
+
+func prefKey(userID uint, msgType, channel string) string {
+	return fmt.Sprintf("%d/%s/%s", userID, msgType, channel)
+}
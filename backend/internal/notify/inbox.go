@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"time"
+
+	mongoModels "conflux-demo/backend/internal/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDB collections of the notification subsystem
+const (
+	InboxCollection       = "notifications"
+	PreferencesCollection = "notification_preferences"
+)
+
+// Inbox stores messages as in-app notifications in MongoDB
+type Inbox struct {
+	Collection *mongo.Collection
+}
+
+// Name implements Notifier
+func (*Inbox) Name() string { return ChannelInbox }
+
+// Notify implements Notifier
+func (i *Inbox) Notify(ctx context.Context, msg Message) error {
+	doc := mongoModels.Notification{
+		UserID:    msg.UserID,
+		Type:      msg.Type,
+		Title:     msg.Title,
+		Body:      msg.Body,
+		Data:      msg.Data,
+		CreatedAt: msg.CreatedAt,
+	}
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	_, err := i.Collection.InsertOne(ctx, doc)
+	return err
+}
+
+// EnsureIndexes creates the indexes the inbox listing, unread counts and
+// preference lookups rely on
+func EnsureIndexes(ctx context.Context, inbox *Inbox, prefs *MongoPreferences) error {
+	_, err := inbox.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "read_at", Value: 1}, {Key: "type", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = prefs.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
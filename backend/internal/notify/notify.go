@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/mongodb"
+
+	"gorm.io/gorm"
+)
+
+// Notification types. Users choose delivery channels per type.
+const (
+	TypeComment      = "comment"
+	TypeTransaction  = "transaction"
+	TypeYieldPayout  = "yield_payout"
+	TypeAssetMatured = "asset_matured"
+	TypePriceAlert   = "price_alert"
+	TypeMention      = "mention"
+)
+
+// Types lists every notification type
+var Types = []string{TypeComment, TypeTransaction, TypeYieldPayout, TypeAssetMatured, TypePriceAlert, TypeMention}
+
+// Delivery channels, matching the Name of their notifier
+const (
+	ChannelInbox   = "inbox"
+	ChannelWebhook = "webhook"
+	ChannelPush    = "push"
+)
+
+// ValidType reports whether t is a known notification type
+func ValidType(t string) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is a notification for one user
+type Message struct {
+	UserID    uint                   `json:"user_id"`
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Dispatcher delivers each message through every channel the user has
+// enabled for its type, logging failures so one broken channel does not
+// stop the others
+type Dispatcher struct {
+	Channels    []Notifier
+	Preferences Preferences // nil delivers on every channel
+}
+
+// Name implements Notifier
+func (*Dispatcher) Name() string { return "dispatcher" }
+
+// Notify implements Notifier. It returns the first error, after trying
+// every channel.
+func (d *Dispatcher) Notify(ctx context.Context, msg Message) error {
+	var first error
+	for _, n := range d.Channels {
+		if d.Preferences != nil {
+			enabled, err := d.Preferences.Enabled(ctx, msg.UserID, msg.Type, n.Name())
+			if err != nil {
+				// Deliver anyway rather than drop a message on a lookup failure
+				log.Printf("Failed to load notification preferences of user %d: %v", msg.UserID, err)
+			} else if !enabled {
+				continue
+			}
+		}
+		if err := n.Notify(ctx, msg); err != nil {
+			log.Printf("Notifier %s failed for user %d: %v", n.Name(), msg.UserID, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+var notifier Notifier = &Dispatcher{}
+
+// Initialize sets up the inbox, webhook and push channels. The inbox and
+// preferences live in MongoDB and are skipped when it is unavailable.
+func Initialize(cfg *config.Config) {
+	var gateway PushGateway = LogGateway{}
+	if cfg.PushGatewayURL != "" {
+		gateway = &HTTPGateway{URL: cfg.PushGatewayURL}
+	}
+
+	db := database.GetDB()
+	dispatcher := &Dispatcher{Channels: []Notifier{
+		&Webhook{DB: db},
+		&Push{DB: db, Gateway: gateway},
+	}}
+
+	if mongoDB := mongodb.GetDB(); mongoDB != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		inbox := &Inbox{Collection: mongoDB.Collection(InboxCollection)}
+		prefs := &MongoPreferences{Collection: mongoDB.Collection(PreferencesCollection)}
+		if err := EnsureIndexes(ctx, inbox, prefs); err != nil {
+			log.Printf("Warning: failed to create notification indexes: %v", err)
+		}
+		dispatcher.Channels = append([]Notifier{inbox}, dispatcher.Channels...)
+		dispatcher.Preferences = prefs
+	} else {
+		log.Println("Warning: MongoDB unavailable, in-app notifications disabled")
+	}
+
+	notifier = dispatcher
+}
+
+// Default returns the notifier set up by Initialize
+func Default() Notifier {
+	return notifier
+}
+
+// Send delivers a message through the default notifier
+func Send(ctx context.Context, msg Message) error {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	return notifier.Notify(ctx, msg)
+}
+
+// Publish delivers a message in the background, for producers that must not
+// wait on webhooks and pushes. Channels log their own failures.
+func Publish(msg Message) {
+	if msg.UserID == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		Send(ctx, msg)
+	}()
+}
+
+// UserIDForWallet returns the ID of the user owning a wallet, or 0 if the
+// wallet has no account
+func UserIDForWallet(db *gorm.DB, wallet string) (uint, error) {
+	var user models.User
+	err := db.Select("id").Where("wallet_address = ?", wallet).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return user.ID, err
+}
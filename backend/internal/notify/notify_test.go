@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDispatcherHonoursPreferences(t *testing.T) {
+	inbox := &Fake{Channel: ChannelInbox}
+	push := &Fake{Channel: ChannelPush}
+	prefs := &MemoryPreferences{}
+	prefs.Disable(1, TypeComment, ChannelPush)
+
+	d := &Dispatcher{Channels: []Notifier{inbox, push}, Preferences: prefs}
+	for _, msg := range []Message{
+		{UserID: 1, Type: TypeComment},
+		{UserID: 1, Type: TypeTransaction},
+		{UserID: 2, Type: TypeComment},
+	} {
+		if err := d.Notify(context.Background(), msg); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+	}
+
+	if n := len(inbox.Messages()); n != 3 {
+		t.Errorf("inbox got %d messages, want 3", n)
+	}
+	if n := len(push.Messages()); n != 2 {
+		t.Errorf("push got %d messages, want 2", n)
+	}
+}
+
+func TestDispatcherContinuesAfterFailure(t *testing.T) {
+	broken := &Fake{Channel: ChannelWebhook, Err: errors.New("down")}
+	inbox := &Fake{Channel: ChannelInbox}
+
+	d := &Dispatcher{Channels: []Notifier{broken, inbox}}
+	if err := d.Notify(context.Background(), Message{UserID: 1, Type: TypeYieldPayout}); err == nil {
+		t.Error("expected the webhook error")
+	}
+	if len(inbox.Messages()) != 1 {
+		t.Error("inbox skipped after a failing channel")
+	}
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	mongoModels "conflux-demo/backend/internal/mongodb/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Preferences decides which channels a user receives each type on
+type Preferences interface {
+	Enabled(ctx context.Context, userID uint, msgType, channel string) (bool, error)
+}
+
+// DefaultSettings enables every channel
+var DefaultSettings = mongoModels.ChannelSettings{Inbox: true, Push: true, Webhook: true}
+
+// SettingEnabled reports whether settings enable a channel. Unknown
+// channels are always enabled.
+func SettingEnabled(s mongoModels.ChannelSettings, channel string) bool {
+	switch channel {
+	case ChannelInbox:
+		return s.Inbox
+	case ChannelPush:
+		return s.Push
+	case ChannelWebhook:
+		return s.Webhook
+	}
+	return true
+}
+
+// MongoPreferences stores one preferences document per user
+type MongoPreferences struct {
+	Collection *mongo.Collection
+}
+
+// Get returns the settings of every type for a user, filling in defaults
+func (p *MongoPreferences) Get(ctx context.Context, userID uint) (map[string]mongoModels.ChannelSettings, error) {
+	settings := map[string]mongoModels.ChannelSettings{}
+	for _, t := range Types {
+		settings[t] = DefaultSettings
+	}
+
+	var doc mongoModels.NotificationPreferences
+	err := p.Collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for t, s := range doc.Types {
+		settings[t] = s
+	}
+	return settings, nil
+}
+
+// Set replaces the settings of the given types, leaving the others as they
+// are
+func (p *MongoPreferences) Set(ctx context.Context, userID uint, types map[string]mongoModels.ChannelSettings) error {
+	set := bson.M{"updated_at": time.Now()}
+	for t, s := range types {
+		set["types."+t] = s
+	}
+	_, err := p.Collection.UpdateOne(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": set, "$setOnInsert": bson.M{"user_id": userID}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// Enabled implements Preferences
+func (p *MongoPreferences) Enabled(ctx context.Context, userID uint, msgType, channel string) (bool, error) {
+	var doc mongoModels.NotificationPreferences
+	err := p.Collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	s, ok := doc.Types[msgType]
+	if !ok {
+		return true, nil
+	}
+	return SettingEnabled(s, channel), nil
+}
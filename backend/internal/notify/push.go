@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+)
+
+// PushGateway sends a message to one device push token
+type PushGateway interface {
+	Send(ctx context.Context, token, platform string, msg Message) error
+}
+
+// Push sends messages to each of the user's registered push tokens
+type Push struct {
+	DB      *gorm.DB
+	Gateway PushGateway
+}
+
+// Name implements Notifier
+func (*Push) Name() string { return ChannelPush }
+
+// Notify implements Notifier
+func (p *Push) Notify(ctx context.Context, msg Message) error {
+	var tokens []models.NotificationEndpoint
+	if err := p.DB.WithContext(ctx).
+		Where("user_id = ? AND kind = ?", msg.UserID, EndpointPush).
+		Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	var first error
+	for _, t := range tokens {
+		if err := p.Gateway.Send(ctx, t.Target, t.Platform, msg); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// LogGateway logs pushes instead of sending them, for development
+type LogGateway struct{}
+
+// Send implements PushGateway
+func (LogGateway) Send(_ context.Context, token, platform string, msg Message) error {
+	log.Printf("Push to %s token %s: %s", platform, token, msg.Title)
+	return nil
+}
+
+// HTTPGateway forwards pushes to a relay service that talks to APNs/FCM,
+// as {"token", "platform", "title", "body", "data"}
+type HTTPGateway struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send implements PushGateway
+func (g *HTTPGateway) Send(ctx context.Context, token, platform string, msg Message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"token":    token,
+		"platform": platform,
+		"title":    msg.Title,
+		"body":     msg.Body,
+		"data":     msg.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := g.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("push gateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/gorm"
+)
+
+// Endpoint kinds
+const (
+	EndpointWebhook = "webhook"
+	EndpointPush    = "push"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed
+// with the endpoint's secret
+const SignatureHeader = "X-Signature-SHA256"
+
+// ErrForbiddenAddress is returned for a webhook host that resolves to a
+// loopback, private, link-local or otherwise internal address
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// Webhook POSTs messages as JSON to each of the user's webhook endpoints.
+// The default client refuses to connect to internal addresses, checked on
+// every connection so DNS changes and redirects cannot reach them either.
+type Webhook struct {
+	DB     *gorm.DB
+	Client *http.Client
+}
+
+// Name implements Notifier
+func (*Webhook) Name() string { return ChannelWebhook }
+
+// Notify implements Notifier
+func (w *Webhook) Notify(ctx context.Context, msg Message) error {
+	var endpoints []models.NotificationEndpoint
+	if err := w.DB.WithContext(ctx).
+		Where("user_id = ? AND kind = ?", msg.UserID, EndpointWebhook).
+		Find(&endpoints).Error; err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	client := w.Client
+	if client == nil {
+		client = publicClient
+	}
+
+	var first error
+	for _, ep := range endpoints {
+		if err := postWebhook(ctx, client, ep, body); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func postWebhook(ctx context.Context, client *http.Client, ep models.NotificationEndpoint, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(ep.Secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", ep.Target, resp.StatusCode)
+	}
+	return nil
+}
+
+// publicClient only dials public addresses
+var publicClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// CheckWebhookURL validates a webhook target: it must be an http or https
+// URL whose host resolves only to public addresses
+func CheckWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, u.Hostname())
+		}
+	}
+	return nil
+}
+
+// publicIP reports whether ip is routable on the internet
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip))
+}
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Sign returns the hex HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random webhook signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+func TestPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+	} {
+		if got := publicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("publicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	for _, raw := range []string{"ftp://example.com/hook", "http://127.0.0.1:8080/hook", "http://localhost/hook", "http://[::1]/hook", "not a url"} {
+		if err := CheckWebhookURL(context.Background(), raw); err == nil {
+			t.Errorf("CheckWebhookURL(%q) accepted", raw)
+		}
+	}
+}
+
+func TestWebhookRefusesInternalAddressAtDelivery(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	// Registered while public, the host now resolves to loopback
+	ep := models.NotificationEndpoint{Target: server.URL, Secret: "secret"}
+	err := postWebhook(context.Background(), publicClient, ep, []byte(`{}`))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("err = %v, want ErrForbiddenAddress", err)
+	}
+	if called {
+		t.Error("webhook reached a loopback server")
+	}
+}
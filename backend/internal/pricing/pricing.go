@@ -0,0 +1,119 @@
+package pricing
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var numberPattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// Day counts used to convert legacy term units
+const (
+	daysPerWeek = 7
+	daysPerYear = 365
+)
+
+// ParseRate extracts an annual percentage from a legacy yield string such as
+// "8.5%" or "年化 8.5%". Strings without a number, like "Limited Edition",
+// parse as 0.
+func ParseRate(s string) float64 {
+	match := numberPattern.FindString(s)
+	if match == "" {
+		return 0
+	}
+	rate, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// ParsePrice extracts an amount in yuan from a legacy price string such as
+// "¥299", "1,299.00元" or "¥1.5万". Strings without a number parse as 0.
+func ParsePrice(s string) float64 {
+	match := numberPattern.FindString(strings.ReplaceAll(s, ",", ""))
+	if match == "" {
+		return 0
+	}
+	price, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0
+	}
+	if strings.Contains(s, "万") {
+		price *= 10000
+	}
+	return price
+}
+
+// ParseTerm converts a legacy duration string such as "12个月", "90天",
+// "1 year" or "6 months" to a number of days. Open-ended terms like
+// "Permanent" or "Lifetime" parse as 0.
+func ParseTerm(s string) int {
+	match := numberPattern.FindString(s)
+	if match == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0
+	}
+
+	unit := strings.ToLower(s)
+	switch {
+	case strings.Contains(unit, "月") || strings.Contains(unit, "month"):
+		return int(math.Round(n * daysPerYear / 12))
+	case strings.Contains(unit, "年") || strings.Contains(unit, "year"):
+		return int(n * daysPerYear)
+	case strings.Contains(unit, "周") || strings.Contains(unit, "week"):
+		return int(n * daysPerWeek)
+	default:
+		// 天, 日, days or a bare number
+		return int(n)
+	}
+}
+
+// MatchCommodity returns the commodity a product named name tracks, or ""
+// if none matches. A commodity such as "Wheat (Soft Red)" matches when every
+// word before the parenthesis appears in the name, ignoring case and a
+// plural "s", so "Wheat Harvest Share" and "Soybean Fund" both match.
+func MatchCommodity(name string, commodities []string) string {
+	words := map[string]bool{}
+	for _, w := range commodityWords(name) {
+		words[w] = true
+	}
+
+	for _, commodity := range commodities {
+		base, _, _ := strings.Cut(commodity, "(")
+		keys := commodityWords(base)
+		if len(keys) == 0 {
+			continue
+		}
+		matched := true
+		for _, k := range keys {
+			if !words[k] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return commodity
+		}
+	}
+	return ""
+}
+
+// commodityWords splits s into lower-case words without a plural "s"
+func commodityWords(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for i, f := range fields {
+		if len(f) > 3 {
+			fields[i] = strings.TrimSuffix(f, "s")
+		}
+	}
+	return fields
+}
@@ -0,0 +1,89 @@
+package pricing
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"¥299", 299},
+		{"1,299.00元", 1299},
+		{"¥1.5万", 15000},
+		{"$500", 500},
+		{"¥100起", 100},
+		{"Free", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := ParsePrice(tt.in); got != tt.want {
+			t.Errorf("ParsePrice(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"8.5%", 8.5},
+		{"年化 8.5%", 8.5},
+		{"12%", 12},
+		{"Limited Edition", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseRate(tt.in); got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTerm(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"12个月", 365},
+		{"6 Months", 183},
+		{"90天", 90},
+		{"30日", 30},
+		{"1 year", 365},
+		{"2年", 730},
+		{"4周", 28},
+		{"2 weeks", 14},
+		{"45", 45},
+		{"Permanent", 0},
+		{"Lifetime", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseTerm(tt.in); got != tt.want {
+			t.Errorf("ParseTerm(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCommodity(t *testing.T) {
+	commodities := []string{"Corn (Yellow)", "Soybeans", "Wheat (Soft Red)"}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Wheat Harvest Share", "Wheat (Soft Red)"},
+		{"Corn Growers Fund", "Corn (Yellow)"},
+		{"Soybean Cooperative Note", "Soybeans"},
+		{"soybeans 2025", "Soybeans"},
+		{"Buckwheat Noodles", ""},
+		{"Popcorn Machine NFT", ""},
+		{"Digital Art Collection", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MatchCommodity(tt.name, commodities); got != tt.want {
+			t.Errorf("MatchCommodity(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package suitability
+
+import (
+	"fmt"
+
+	"conflux-demo/backend/config"
+)
+
+// Risk tiers a questionnaire score maps to
+const (
+	TierConservative = "conservative"
+	TierBalanced     = "balanced"
+	TierAggressive   = "aggressive"
+)
+
+// Policies for investments whose risk exceeds the user's tier
+const (
+	PolicyAcknowledge = "acknowledge" // Allowed once the user explicitly acknowledges the risk
+	PolicyReject      = "reject"      // Never allowed
+)
+
+// Option is one answer to a question and the points it scores
+type Option struct {
+	ID     string `json:"id"`
+	Text   string `json:"text"`
+	Points int    `json:"-"`
+}
+
+// Question is one item of the risk questionnaire
+type Question struct {
+	ID      string   `json:"id"`
+	Text    string   `json:"text"`
+	Options []Option `json:"options"`
+}
+
+// Questions is the risk questionnaire. Each answer scores 1 (most cautious)
+// to 4 (most risk tolerant).
+var Questions = []Question{
+	{ID: "horizon", Text: "How long do you plan to hold your investments?", Options: []Option{
+		{ID: "lt1y", Text: "Less than 1 year", Points: 1},
+		{ID: "1to3y", Text: "1 to 3 years", Points: 2},
+		{ID: "3to5y", Text: "3 to 5 years", Points: 3},
+		{ID: "gt5y", Text: "More than 5 years", Points: 4},
+	}},
+	{ID: "loss_reaction", Text: "What would you do if an investment fell 20% in a month?", Options: []Option{
+		{ID: "sell_all", Text: "Sell everything", Points: 1},
+		{ID: "sell_some", Text: "Sell some of it", Points: 2},
+		{ID: "hold", Text: "Hold and wait", Points: 3},
+		{ID: "buy_more", Text: "Buy more", Points: 4},
+	}},
+	{ID: "experience", Text: "Which products have you invested in before?", Options: []Option{
+		{ID: "none", Text: "None", Points: 1},
+		{ID: "deposits", Text: "Deposits and wealth management products", Points: 2},
+		{ID: "funds_stocks", Text: "Funds or stocks", Points: 3},
+		{ID: "derivatives", Text: "Futures, options or crypto assets", Points: 4},
+	}},
+	{ID: "asset_share", Text: "What share of your liquid assets will you invest?", Options: []Option{
+		{ID: "gt50", Text: "More than 50%", Points: 1},
+		{ID: "25to50", Text: "25% to 50%", Points: 2},
+		{ID: "10to25", Text: "10% to 25%", Points: 3},
+		{ID: "lt10", Text: "Less than 10%", Points: 4},
+	}},
+	{ID: "objective", Text: "What is your main investment objective?", Options: []Option{
+		{ID: "preserve", Text: "Preserve capital", Points: 1},
+		{ID: "income", Text: "Steady income", Points: 2},
+		{ID: "growth", Text: "Balanced growth", Points: 3},
+		{ID: "max_growth", Text: "Maximum growth", Points: 4},
+	}},
+}
+
+// Highest score of each tier
+const (
+	maxConservativeScore = 9
+	maxBalancedScore     = 14
+)
+
+// tierMaxRisk is the riskiest product level each tier may buy without
+// acknowledgement
+var tierMaxRisk = map[string]string{
+	TierConservative: "low",
+	TierBalanced:     "medium",
+	TierAggressive:   "high",
+}
+
+var riskRank = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+var policy = PolicyAcknowledge
+
+// Initialize applies the configured policy for risk above the user's tier.
+// An unknown policy is an error rather than a silent fallback, so a typo
+// cannot turn "reject" into "acknowledge".
+func Initialize(cfg *config.Config) error {
+	switch cfg.RiskExcessPolicy {
+	case PolicyAcknowledge, PolicyReject:
+		policy = cfg.RiskExcessPolicy
+		return nil
+	default:
+		return fmt.Errorf("invalid RISK_EXCESS_POLICY %q: must be %q or %q", cfg.RiskExcessPolicy, PolicyAcknowledge, PolicyReject)
+	}
+}
+
+// Policy returns the policy for investments above the user's tier
+func Policy() string {
+	return policy
+}
+
+// Score totals the answers, keyed by question ID, and returns the tier the
+// score falls in. Every question must be answered with one of its options.
+func Score(answers map[string]string) (int, string, error) {
+	score := 0
+	for _, q := range Questions {
+		answer, ok := answers[q.ID]
+		if !ok {
+			return 0, "", fmt.Errorf("question %q is not answered", q.ID)
+		}
+		points := 0
+		for _, o := range q.Options {
+			if o.ID == answer {
+				points = o.Points
+			}
+		}
+		if points == 0 {
+			return 0, "", fmt.Errorf("invalid answer %q to question %q", answer, q.ID)
+		}
+		score += points
+	}
+
+	switch {
+	case score <= maxConservativeScore:
+		return score, TierConservative, nil
+	case score <= maxBalancedScore:
+		return score, TierBalanced, nil
+	default:
+		return score, TierAggressive, nil
+	}
+}
+
+// MaxRisk returns the riskiest product level a tier is suited to. Users who
+// have not taken the questionnaire are treated as conservative.
+func MaxRisk(tier string) string {
+	if risk, ok := tierMaxRisk[tier]; ok {
+		return risk
+	}
+	return tierMaxRisk[TierConservative]
+}
+
+// Exceeds reports whether a product's risk level is above what the tier is
+// suited to. Unknown product levels are treated as high risk.
+func Exceeds(tier, productRisk string) bool {
+	rank, ok := riskRank[productRisk]
+	if !ok {
+		rank = riskRank["high"]
+	}
+	return rank > riskRank[MaxRisk(tier)]
+}
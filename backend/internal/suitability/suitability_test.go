@@ -0,0 +1,99 @@
+package suitability
+
+import (
+	"strings"
+	"testing"
+
+	"conflux-demo/backend/config"
+)
+
+// answersScoring answers every question with the option worth points
+func answersScoring(points ...int) map[string]string {
+	answers := map[string]string{}
+	for i, q := range Questions {
+		for _, o := range q.Options {
+			if o.Points == points[i] {
+				answers[q.ID] = o.ID
+			}
+		}
+	}
+	return answers
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		answers   map[string]string
+		wantScore int
+		wantTier  string
+	}{
+		{"most cautious", answersScoring(1, 1, 1, 1, 1), 5, TierConservative},
+		{"top of conservative", answersScoring(2, 2, 2, 2, 1), 9, TierConservative},
+		{"bottom of balanced", answersScoring(2, 2, 2, 2, 2), 10, TierBalanced},
+		{"top of balanced", answersScoring(3, 3, 3, 3, 2), 14, TierBalanced},
+		{"bottom of aggressive", answersScoring(3, 3, 3, 3, 3), 15, TierAggressive},
+		{"most risk tolerant", answersScoring(4, 4, 4, 4, 4), 20, TierAggressive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, tier, err := Score(tt.answers)
+			if err != nil {
+				t.Fatalf("score: %v", err)
+			}
+			if score != tt.wantScore || tier != tt.wantTier {
+				t.Errorf("Score = %d, %q, want %d, %q", score, tier, tt.wantScore, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestScoreRejectsIncompleteAnswers(t *testing.T) {
+	missing := answersScoring(1, 1, 1, 1, 1)
+	delete(missing, "objective")
+	if _, _, err := Score(missing); err == nil || !strings.Contains(err.Error(), "not answered") {
+		t.Errorf("missing answer: err = %v", err)
+	}
+
+	invalid := answersScoring(1, 1, 1, 1, 1)
+	invalid["horizon"] = "forever"
+	if _, _, err := Score(invalid); err == nil || !strings.Contains(err.Error(), "invalid answer") {
+		t.Errorf("invalid answer: err = %v", err)
+	}
+}
+
+func TestExceeds(t *testing.T) {
+	tests := []struct {
+		tier, risk string
+		want       bool
+	}{
+		{TierConservative, "low", false},
+		{TierConservative, "medium", true},
+		{TierConservative, "high", true},
+		{TierBalanced, "medium", false},
+		{TierBalanced, "high", true},
+		{TierAggressive, "high", false},
+		{"", "low", false}, // No questionnaire is conservative
+		{"", "medium", true},
+		{TierAggressive, "", false}, // Unknown levels count as high
+		{TierBalanced, "extreme", true},
+	}
+	for _, tt := range tests {
+		if got := Exceeds(tt.tier, tt.risk); got != tt.want {
+			t.Errorf("Exceeds(%q, %q) = %v, want %v", tt.tier, tt.risk, got, tt.want)
+		}
+	}
+}
+
+func TestInitializeRejectsUnknownPolicy(t *testing.T) {
+	t.Cleanup(func() { policy = PolicyAcknowledge })
+
+	if err := Initialize(&config.Config{RiskExcessPolicy: PolicyReject}); err != nil || Policy() != PolicyReject {
+		t.Fatalf("reject policy: err = %v, policy = %q", err, Policy())
+	}
+	if err := Initialize(&config.Config{RiskExcessPolicy: "rejct"}); err == nil {
+		t.Error("misspelled policy was accepted")
+	}
+	if Policy() != PolicyReject {
+		t.Errorf("policy = %q after a failed initialize, want it unchanged", Policy())
+	}
+}
@@ -0,0 +1,52 @@
+package yield
+
+import (
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+)
+
+// Projection is the expected return of one asset
+type Projection struct {
+	DailyYield       float64
+	AccruedYield     float64
+	PaidYield        float64
+	MaturityDate     *time.Time
+	DaysRemaining    int
+	ProjectedPending float64 // Yield still to accrue until maturity, or over the next year if open-ended
+	ProjectedTotal   float64 // Accrued plus pending
+}
+
+// Project estimates what an asset will still earn, assuming the current
+// rate holds
+func Project(asset *models.UserAsset, now time.Time) Projection {
+	daily := DailyYield(asset, &asset.Product)
+	p := Projection{
+		DailyYield:   daily,
+		AccruedYield: asset.AccruedYield,
+		PaidYield:    asset.PaidYield,
+	}
+
+	from := purchaseDay(asset)
+	if asset.AccruedThrough != nil {
+		from = *asset.AccruedThrough
+	}
+
+	maturity, ok := MaturityDate(asset, &asset.Product)
+	if ok {
+		p.MaturityDate = &maturity
+		if asset.Status == "active" && maturity.After(from) {
+			p.DaysRemaining = int(maturity.Sub(startOfDay(now)).Hours() / 24)
+			if p.DaysRemaining < 0 {
+				p.DaysRemaining = 0
+			}
+			pendingDays := int(maturity.Sub(from).Hours() / 24)
+			p.ProjectedPending = daily * float64(pendingDays)
+		}
+	} else if asset.Status == "active" {
+		p.ProjectedPending = daily * daysPerYear
+	}
+
+	p.ProjectedTotal = p.AccruedYield + p.ProjectedPending
+	return p
+}
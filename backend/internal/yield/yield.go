@@ -0,0 +1,336 @@
+package yield
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"conflux-demo/backend/config"
+	"conflux-demo/backend/internal/database"
+	"conflux-demo/backend/internal/database/models"
+	"conflux-demo/backend/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+// How often the accrual job checks for completed days. Each run only
+// accrues days that have not been accrued yet, so running more often than
+// daily is harmless and catches up quickly after downtime.
+const runInterval = time.Hour
+
+const daysPerYear = 365
+
+var payoutPeriod = 30 * 24 * time.Hour
+
+// Result summarises one accrual run
+type Result struct {
+	Accrued int // Assets that accrued at least one day
+	Paid    int // Payouts credited
+	Matured int // Assets flipped to "matured"
+}
+
+// Initialize applies the payout period and starts the daily accrual job
+func Initialize(cfg *config.Config) {
+	if cfg.YieldPayoutDays > 0 {
+		payoutPeriod = time.Duration(cfg.YieldPayoutDays) * 24 * time.Hour
+	}
+	StartAccrualWorker(context.Background(), database.GetDB(), runInterval)
+	log.Printf("Yield accrual worker started (payout every %s)", payoutPeriod)
+}
+
+// DailyYield returns the yield one asset earns per day
+func DailyYield(asset *models.UserAsset, product *models.Product) float64 {
+	return asset.InvestmentAmount * product.AnnualYield / 100 / daysPerYear
+}
+
+// MaturityDate returns the day an asset stops accruing. ok is false for
+// open-ended products.
+func MaturityDate(asset *models.UserAsset, product *models.Product) (date time.Time, ok bool) {
+	if product.TermDays <= 0 {
+		return time.Time{}, false
+	}
+	return TermStart(asset).AddDate(0, 0, product.TermDays), true
+}
+
+// TermStart returns the first day of an asset's term. An asset bought on
+// resale continues the term of the asset it was bought from.
+func TermStart(asset *models.UserAsset) time.Time {
+	if asset.TermStartDate != nil {
+		return startOfDay(*asset.TermStartDate)
+	}
+	return purchaseDay(asset)
+}
+
+// purchaseDay is the first day an asset accrues yield
+func purchaseDay(asset *models.UserAsset) time.Time {
+	if asset.PurchaseDate.IsZero() {
+		return startOfDay(asset.CreatedAt)
+	}
+	return startOfDay(asset.PurchaseDate)
+}
+
+// Run accrues every completed day for all active assets up to now, credits
+// payouts that are due and matures assets past their term
+func Run(db *gorm.DB, now time.Time) (Result, error) {
+	var result Result
+	var assets []models.UserAsset
+	err := db.Preload("Product").Where("status = ?", "active").
+		FindInBatches(&assets, 100, func(batch *gorm.DB, _ int) error {
+			for i := range assets {
+				accrued, paid, matured, err := processAsset(db, &assets[i], now)
+				if err != nil {
+					return fmt.Errorf("asset %d: %w", assets[i].ID, err)
+				}
+				if accrued {
+					result.Accrued++
+				}
+				if paid {
+					result.Paid++
+				}
+				if matured {
+					result.Matured++
+				}
+			}
+			return nil
+		}).Error
+	return result, err
+}
+
+// StartAccrualWorker runs the accrual job every interval until ctx is
+// cancelled
+func StartAccrualWorker(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			result, err := Run(db, time.Now())
+			if err != nil {
+				log.Printf("Yield accrual failed: %v", err)
+			} else if result != (Result{}) {
+				log.Printf("Yield accrual: %d assets accrued, %d payouts, %d matured",
+					result.Accrued, result.Paid, result.Matured)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// processAsset accrues, pays out and matures a single asset in one
+// transaction. The unique (asset, date) accrual index guarantees a day is
+// never accrued twice. An asset past its term matures only once its final
+// payout is credited; until its owner has an account it stays active with
+// the yield accrued.
+func processAsset(db *gorm.DB, asset *models.UserAsset, now time.Time) (accrued, paid, matured bool, err error) {
+	today := startOfDay(now)
+	maturity, hasTerm := MaturityDate(asset, &asset.Product)
+	due := hasTerm && !today.Before(maturity)
+
+	var payout float64
+	end := today
+	if due {
+		end = maturity
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{}
+
+		var err error
+		if accrued, err = accrueDays(tx, asset, end, updates); err != nil {
+			return err
+		}
+
+		lastPayout := purchaseDay(asset)
+		if asset.LastPayoutAt != nil {
+			lastPayout = *asset.LastPayoutAt
+		}
+		if due || now.Sub(lastPayout) >= payoutPeriod {
+			if payout, err = payOut(tx, asset, now, due, updates); err != nil {
+				return err
+			}
+			paid = payout > 0
+		}
+
+		matured = due && unpaid(asset, true) <= 0
+		if matured {
+			asset.Status = "matured"
+			asset.MaturedAt = &now
+			updates["status"] = "matured"
+			updates["matured_at"] = now
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&models.UserAsset{}).Where("id = ?", asset.ID).Updates(updates).Error
+	})
+	if err == nil && (paid || matured) {
+		notifyOwner(db, asset, payout, matured)
+	}
+	return accrued, paid, matured, err
+}
+
+// SettleTransfer brings an asset's yield up to date before it changes
+// hands: every completed day is accrued and all yield not yet paid out is
+// credited to the current owner. It runs in the caller's transaction and
+// returns the amount paid. asset.Product must be loaded.
+func SettleTransfer(tx *gorm.DB, asset *models.UserAsset, now time.Time) (float64, error) {
+	end := startOfDay(now)
+	if maturity, ok := MaturityDate(asset, &asset.Product); ok && maturity.Before(end) {
+		end = maturity
+	}
+
+	updates := map[string]interface{}{}
+	if _, err := accrueDays(tx, asset, end, updates); err != nil {
+		return 0, err
+	}
+	payout, err := payOut(tx, asset, now, false, updates)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(updates) == 0 {
+		return payout, nil
+	}
+	return payout, tx.Model(&models.UserAsset{}).Where("id = ?", asset.ID).Updates(updates).Error
+}
+
+// accrueDays records each day from the asset's last accrual up to end and
+// adds the changed columns to updates
+func accrueDays(tx *gorm.DB, asset *models.UserAsset, end time.Time, updates map[string]interface{}) (bool, error) {
+	from := purchaseDay(asset)
+	if asset.AccruedThrough != nil {
+		from = *asset.AccruedThrough
+	}
+	if !end.After(from) {
+		return false, nil
+	}
+
+	accrued := false
+	if daily := DailyYield(asset, &asset.Product); daily > 0 {
+		var rows []models.YieldAccrual
+		for d := from; d.Before(end); d = d.AddDate(0, 0, 1) {
+			rows = append(rows, models.YieldAccrual{
+				AssetID:       asset.ID,
+				WalletAddress: asset.WalletAddress,
+				AccrualDate:   d,
+				Amount:        daily,
+			})
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return false, err
+		}
+		asset.AccruedYield += daily * float64(len(rows))
+		updates["accrued_yield"] = asset.AccruedYield
+		accrued = true
+	}
+	asset.AccruedThrough = &end
+	updates["accrued_through"] = end
+	return accrued, nil
+}
+
+// payOut credits the owner with the yield accrued but not yet paid, in
+// whole fen, and adds the changed columns to updates. The final payout at
+// maturity rounds the remainder instead of carrying it over. It returns the
+// amount credited, 0 if nothing was due or the owner has no account.
+func payOut(tx *gorm.DB, asset *models.UserAsset, now time.Time, final bool, updates map[string]interface{}) (float64, error) {
+	amount := unpaid(asset, final)
+	if amount <= 0 {
+		return 0, nil
+	}
+	credited, err := creditPayout(tx, asset, amount, now)
+	if err != nil || !credited {
+		return 0, err
+	}
+
+	asset.PaidYield += amount
+	asset.LastPayoutAt = &now
+	updates["paid_yield"] = asset.PaidYield
+	updates["last_payout_at"] = now
+	return amount, nil
+}
+
+// notifyOwner tells the asset's owner about a payout and maturity
+func notifyOwner(db *gorm.DB, asset *models.UserAsset, payout float64, matured bool) {
+	userID, err := notify.UserIDForWallet(db, asset.WalletAddress)
+	if err != nil || userID == 0 {
+		return
+	}
+
+	data := map[string]interface{}{"asset_id": asset.ID, "product_id": asset.ProductID}
+	if payout > 0 {
+		data["amount"] = payout
+		notify.Publish(notify.Message{
+			UserID: userID,
+			Type:   notify.TypeYieldPayout,
+			Title:  "Yield paid out",
+			Body:   fmt.Sprintf("%.2f of yield from %s was credited to your balance", payout, asset.Product.Name),
+			Data:   data,
+		})
+	}
+	if matured {
+		notify.Publish(notify.Message{
+			UserID: userID,
+			Type:   notify.TypeAssetMatured,
+			Title:  "Investment matured",
+			Body:   fmt.Sprintf("Your %s investment has reached the end of its term", asset.Product.Name),
+			Data:   map[string]interface{}{"asset_id": asset.ID, "product_id": asset.ProductID},
+		})
+	}
+}
+
+// creditPayout adds amount to the owner's balance and records a yieldPayout
+// transaction. It returns false when the wallet has no user account, in
+// which case the yield stays accrued until one exists.
+func creditPayout(tx *gorm.DB, asset *models.UserAsset, amount float64, now time.Time) (bool, error) {
+	var user models.User
+	if err := tx.Where("wallet_address = ?", asset.WalletAddress).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+		Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		return false, err
+	}
+
+	return true, tx.Create(&models.Transaction{
+		UserID:        user.ID,
+		Type:          "yieldPayout",
+		Amount:        fmt.Sprintf("%.2f", amount),
+		Status:        "success",
+		PaymentMethod: "balance",
+		TxHash:        fmt.Sprintf("yield-%d-%s", asset.ID, now.Format("20060102150405")),
+		CreatedAt:     now,
+	}).Error
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// unpaid returns the yield accrued on an asset but not yet paid, in whole
+// fen: rounded when final, else truncated
+func unpaid(asset *models.UserAsset, final bool) float64 {
+	if final {
+		return math.Round((asset.AccruedYield-asset.PaidYield)*100) / 100
+	}
+	return floorFen(asset.AccruedYield - asset.PaidYield)
+}
+
+// floorFen truncates to whole fen so payouts never exceed the accrued yield;
+// the remainder carries over to the next payout
+func floorFen(v float64) float64 {
+	return math.Floor(v*100+1e-6) / 100
+}
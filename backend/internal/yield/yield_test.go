@@ -0,0 +1,276 @@
+package yield
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"conflux-demo/backend/internal/database/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var purchased = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func day(n int) time.Time {
+	return purchased.AddDate(0, 0, n)
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "yield.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Product{}, &models.UserAsset{}, &models.YieldAccrual{}, &models.Transaction{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// createAsset creates an owner and a 1000 yuan asset earning 1 yuan a day
+func createAsset(t *testing.T, db *gorm.DB, termDays int) models.UserAsset {
+	t.Helper()
+
+	db.Create(&models.User{WalletAddress: "cfx:owner", Email: "owner@example.com"})
+	product := models.Product{Name: "Tea garden", AnnualYield: 36.5, TermDays: termDays}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	asset := models.UserAsset{
+		WalletAddress:    "cfx:owner",
+		ProductID:        product.ID,
+		InvestmentAmount: 1000,
+		PurchaseDate:     purchased,
+		Status:           "active",
+	}
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatalf("create asset: %v", err)
+	}
+	return asset
+}
+
+func reload(t *testing.T, db *gorm.DB, id uint) models.UserAsset {
+	t.Helper()
+
+	var asset models.UserAsset
+	if err := db.Preload("Product").First(&asset, id).Error; err != nil {
+		t.Fatalf("load asset: %v", err)
+	}
+	return asset
+}
+
+func balance(db *gorm.DB, wallet string) float64 {
+	var user models.User
+	db.Where("wallet_address = ?", wallet).First(&user)
+	return user.Balance
+}
+
+func accrualCount(db *gorm.DB, assetID uint) int64 {
+	var n int64
+	db.Model(&models.YieldAccrual{}).Where("asset_id = ?", assetID).Count(&n)
+	return n
+}
+
+func TestRunAccruesCompletedDaysOnce(t *testing.T) {
+	db := openTestDB(t)
+	asset := createAsset(t, db, 0)
+
+	// Ten full days have passed; the eleventh is still in progress
+	now := day(10).Add(-time.Hour)
+	result, err := Run(db, now)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result != (Result{Accrued: 1}) {
+		t.Errorf("result = %+v, want one asset accrued", result)
+	}
+
+	got := reload(t, db, asset.ID)
+	if got.AccruedYield != 10 || accrualCount(db, asset.ID) != 10 {
+		t.Errorf("accrued %.2f over %d days, want 10 over 10", got.AccruedYield, accrualCount(db, asset.ID))
+	}
+	if got.PaidYield != 0 {
+		t.Errorf("paid %.2f before the payout period, want 0", got.PaidYield)
+	}
+
+	// Running again the same day accrues nothing more
+	result, err = Run(db, now.Add(30*time.Minute))
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if result != (Result{}) {
+		t.Errorf("second run result = %+v, want nothing", result)
+	}
+	if got := reload(t, db, asset.ID); got.AccruedYield != 10 || accrualCount(db, asset.ID) != 10 {
+		t.Errorf("after second run accrued %.2f over %d days, want 10 over 10", got.AccruedYield, accrualCount(db, asset.ID))
+	}
+}
+
+func TestRunPaysOutEachPeriod(t *testing.T) {
+	db := openTestDB(t)
+	asset := createAsset(t, db, 0)
+
+	if _, err := Run(db, day(31)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got := reload(t, db, asset.ID)
+	if got.PaidYield != 31 || balance(db, "cfx:owner") != 31 {
+		t.Fatalf("paid %.2f, balance %.2f, want 31", got.PaidYield, balance(db, "cfx:owner"))
+	}
+
+	// The next day accrues but the next payout is a full period away
+	result, err := Run(db, day(32))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.Paid != 0 {
+		t.Errorf("paid %d times within the period, want 0", result.Paid)
+	}
+	if got := reload(t, db, asset.ID); got.AccruedYield != 32 || got.PaidYield != 31 {
+		t.Errorf("accrued %.2f, paid %.2f, want 32 and 31", got.AccruedYield, got.PaidYield)
+	}
+
+	var payouts int64
+	db.Model(&models.Transaction{}).Where("type = ?", "yieldPayout").Count(&payouts)
+	if payouts != 1 {
+		t.Errorf("recorded %d payouts, want 1", payouts)
+	}
+}
+
+func TestRunMaturesAtTermEnd(t *testing.T) {
+	db := openTestDB(t)
+	asset := createAsset(t, db, 90)
+
+	result, err := Run(db, day(100))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result != (Result{Accrued: 1, Paid: 1, Matured: 1}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	got := reload(t, db, asset.ID)
+	if got.Status != "matured" || got.MaturedAt == nil {
+		t.Errorf("status = %q, want matured", got.Status)
+	}
+	// Accrual stops at maturity and everything accrued is paid out
+	if got.AccruedYield != 90 || got.PaidYield != 90 || balance(db, "cfx:owner") != 90 {
+		t.Errorf("accrued %.2f, paid %.2f, balance %.2f, want 90", got.AccruedYield, got.PaidYield, balance(db, "cfx:owner"))
+	}
+
+	if result, err := Run(db, day(101)); err != nil || result != (Result{}) {
+		t.Errorf("run after maturity = %+v, %v, want nothing", result, err)
+	}
+}
+
+func TestRunMaturesOnlyOnceFinalPayoutIsCredited(t *testing.T) {
+	db := openTestDB(t)
+	asset := createAsset(t, db, 90)
+	db.Model(&models.UserAsset{}).Where("id = ?", asset.ID).Update("wallet_address", "cfx:unregistered")
+
+	result, err := Run(db, day(100))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result != (Result{Accrued: 1}) {
+		t.Errorf("result = %+v, want accrued only", result)
+	}
+	if got := reload(t, db, asset.ID); got.Status != "active" || got.AccruedYield != 90 || got.PaidYield != 0 {
+		t.Errorf("status %q, accrued %.2f, paid %.2f, want active with 90 unpaid", got.Status, got.AccruedYield, got.PaidYield)
+	}
+
+	// Once the owner registers, the next run pays out and matures
+	db.Create(&models.User{WalletAddress: "cfx:unregistered", Email: "late@example.com"})
+	result, err = Run(db, day(101))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result != (Result{Paid: 1, Matured: 1}) {
+		t.Errorf("result = %+v, want paid and matured", result)
+	}
+	if got := reload(t, db, asset.ID); got.Status != "matured" || got.PaidYield != 90 || balance(db, "cfx:unregistered") != 90 {
+		t.Errorf("status %q, paid %.2f, balance %.2f, want matured with 90 paid", got.Status, got.PaidYield, balance(db, "cfx:unregistered"))
+	}
+}
+
+func TestFinalPayoutIncludesRemainder(t *testing.T) {
+	db := openTestDB(t)
+	asset := createAsset(t, db, 41)
+	// 0.008 a day: 0.248 by the first payout and 0.328 at maturity
+	db.Model(&models.Product{}).Where("id = ?", asset.ProductID).Update("annual_yield", 0.292)
+
+	if _, err := Run(db, day(31)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := reload(t, db, asset.ID); math.Abs(got.PaidYield-0.24) > 1e-9 {
+		t.Errorf("first payout %.4f, want 0.24 with the remainder carried over", got.PaidYield)
+	}
+
+	if _, err := Run(db, day(41)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got := reload(t, db, asset.ID)
+	if got.Status != "matured" || math.Abs(got.PaidYield-0.33) > 1e-9 || math.Abs(balance(db, "cfx:owner")-0.33) > 1e-9 {
+		t.Errorf("status %q, paid %.4f, balance %.4f, want matured with 0.33 paid", got.Status, got.PaidYield, balance(db, "cfx:owner"))
+	}
+}
+
+func TestResaleSettlesSellerAndContinuesTerm(t *testing.T) {
+	db := openTestDB(t)
+	asset := createAsset(t, db, 90)
+
+	if _, err := Run(db, day(10)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// Sold in the afternoon of day 12
+	sold := day(12).Add(5 * time.Hour)
+	seller := reload(t, db, asset.ID)
+	paid, err := SettleTransfer(db, &seller, sold)
+	if err != nil {
+		t.Fatalf("settle transfer: %v", err)
+	}
+	if paid != 12 || balance(db, "cfx:owner") != 12 {
+		t.Errorf("paid seller %.2f, balance %.2f, want 12", paid, balance(db, "cfx:owner"))
+	}
+	if got := reload(t, db, asset.ID); got.AccruedYield != 12 || got.PaidYield != 12 {
+		t.Errorf("seller accrued %.2f, paid %.2f, want 12", got.AccruedYield, got.PaidYield)
+	}
+	db.Model(&models.UserAsset{}).Where("id = ?", asset.ID).Update("status", "sold")
+
+	db.Create(&models.User{WalletAddress: "cfx:buyer", Email: "buyer@example.com"})
+	termStart := TermStart(&seller)
+	resold := models.UserAsset{
+		WalletAddress:    "cfx:buyer",
+		ProductID:        asset.ProductID,
+		InvestmentAmount: 1000,
+		PurchaseDate:     sold,
+		TermStartDate:    &termStart,
+		AccruedThrough:   seller.AccruedThrough,
+		LastPayoutAt:     &sold,
+		Status:           "active",
+	}
+	db.Create(&resold)
+
+	if maturity, _ := MaturityDate(&resold, &seller.Product); !maturity.Equal(startOfDay(day(90))) {
+		t.Errorf("maturity = %v, want the original %v", maturity, startOfDay(day(90)))
+	}
+
+	if _, err := Run(db, day(100)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got := reload(t, db, resold.ID)
+	if got.Status != "matured" {
+		t.Errorf("status = %q, want matured at the original term end", got.Status)
+	}
+	// The buyer earns only the days from the sale to maturity
+	if got.AccruedYield != 78 || balance(db, "cfx:buyer") != 78 {
+		t.Errorf("buyer accrued %.2f, balance %.2f, want 78", got.AccruedYield, balance(db, "cfx:buyer"))
+	}
+}
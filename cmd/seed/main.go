@@ -37,11 +37,14 @@ func main() {
 			checkDatabase(db)
 		case "seed":
 			seedDatabase(db)
+		case "link":
+			linkProducts(db)
 		default:
-			fmt.Println("用法: go run cmd/seed/main.go [reset|check|seed]")
+			fmt.Println("用法: go run cmd/seed/main.go [reset|check|seed|link]")
 			fmt.Println("  reset - 重置数据库（删除所有数据）")
 			fmt.Println("  check - 检查数据库状态")
 			fmt.Println("  seed  - 重新插入种子数据")
+			fmt.Println("  link  - 将未关联的溯源记录和证书匹配到农产品")
 		}
 	} else {
 		checkDatabase(db)
@@ -53,6 +56,11 @@ func resetDatabase(db *gorm.DB) {
 	
 	// 删除所有表数据
 	db.Exec("DELETE FROM trace_timeline")
+	db.Exec("DELETE FROM telemetry_excursions")
+	db.Exec("DELETE FROM sensor_readings")
+	db.Exec("DELETE FROM scan_events")
+	db.Exec("DELETE FROM inspection_items")
+	db.Exec("DELETE FROM inspection_reports")
 	db.Exec("DELETE FROM trace_records")
 	db.Exec("DELETE FROM certificates")
 	db.Exec("DELETE FROM farm_products")
@@ -88,6 +96,7 @@ func checkDatabase(db *gorm.DB) {
 	if productCount > 0 {
 		var products []models.FarmProduct
 		db.Limit(3).Find(&products)
+		database.AttachBatchCounts(db, products)
 		fmt.Println("\n📦 前3个产品:")
 		for _, p := range products {
 			fmt.Printf("  - %s %s (%s) - %d批次, %d企业\n", 
@@ -104,6 +113,8 @@ func seedDatabase(db *gorm.DB) {
 	db.Exec("DELETE FROM certificates") 
 	db.Exec("DELETE FROM trace_records")
 	db.Exec("DELETE FROM trace_timeline")
+	db.Exec("DELETE FROM inspection_items")
+	db.Exec("DELETE FROM inspection_reports")
 	
 	// 调用数据库包的种子数据函数
 	if err := database.SeedData(db); err != nil {
@@ -112,4 +123,22 @@ func seedDatabase(db *gorm.DB) {
 	
 	fmt.Println("✅ 种子数据插入完成")
 	checkDatabase(db)
-}
\ No newline at end of file
+}
+
+func linkProducts(db *gorm.DB) {
+	fmt.Println("🔗 关联溯源记录与农产品...")
+
+	linked, err := database.LinkTraceProducts(db)
+	if err != nil {
+		log.Fatal("关联失败:", err)
+	}
+
+	fmt.Printf("✅ 已关联 %d 条溯源记录\n", linked)
+
+	linked, err = database.LinkCertificateProducts(db)
+	if err != nil {
+		log.Fatal("关联失败:", err)
+	}
+
+	fmt.Printf("✅ 已关联 %d 张证书\n", linked)
+}
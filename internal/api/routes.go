@@ -18,6 +18,7 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		// 农产品相关
 		api.GET("/products", h.GetFarmProducts)
 		api.GET("/products/:id", h.GetFarmProductByID)
+		api.GET("/products/:id/batches", h.GetProductBatches)
 
 		// 证书相关
 		api.GET("/certificates", h.GetCertificates)
@@ -26,11 +27,31 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		// 溯源相关
 		api.GET("/trace", h.GetTraceRecords)
 		api.GET("/trace/:id", h.GetTraceRecordByID)
+		api.GET("/trace/:id/telemetry", h.GetTraceTelemetry)
+
+		// 消费者扫码
+		api.GET("/scan/:code", h.ScanTraceCode)
+
+		// 传感器数据接入（MQTT-over-HTTP 或 line protocol），需设备密钥
+		api.POST("/telemetry", h.IngestTelemetry)
+
+		// 检测报告
+		api.GET("/inspections/:id", h.GetInspectionReport)
 
 		// 统计数据
 		api.GET("/statistics", h.GetStatistics)
 	}
 
+	// 管理接口
+	admin := router.Group("/api/admin", gin.BasicAuth(gin.Accounts{cfg.AdminUser: cfg.AdminPass}))
+	{
+		admin.POST("/inspections", h.CreateInspectionReport)
+		admin.POST("/inspections/:id/file", h.UploadInspectionFile)
+		admin.POST("/devices", h.RegisterSensorDevice)
+		admin.POST("/devices/:id/disable", h.DisableSensorDevice)
+		admin.POST("/trace/:id/clear-risk", h.ClearCounterfeitRisk)
+	}
+
 	// 原有的业务路由（保持兼容）
 	router.POST("/topup", h.Topup)
 	router.GET("/balance/:address", h.GetBalance)
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -11,6 +13,23 @@ type Config struct {
 	Port         string
 	AdminUser    string
 	AdminPass    string
+	UploadDir    string
+	RelayerURL   string
+
+	// 冷链温度允许范围（摄氏度）及需冷链运输的农产品品类
+	ColdChainMinTemp    float64
+	ColdChainMaxTemp    float64
+	ColdChainCategories []string
+
+	// 扫码防伪阈值：窗口期内扫码客户端数或城市数超限即标记风险
+	ScanWindowHours int
+	ScanMaxCount    int
+	ScanMaxCities   int
+	// 可信 CDN 注入城市的请求头（如 CF-IPCity），为空时不按城市判断风险
+	ScanGeoHeader string
+
+	// 可信反向代理，仅其转发的 X-Forwarded-For 用于确定客户端 IP
+	TrustedProxies []string
 }
 
 func Load() *Config {
@@ -21,6 +40,19 @@ func Load() *Config {
 		Port:        getEnv("PORT", "8080"),
 		AdminUser:   getEnv("ADMIN_USER", "admin"),
 		AdminPass:   getEnv("ADMIN_PASS", "admin123"),
+		UploadDir:   getEnv("UPLOAD_DIR", "./uploads"),
+		RelayerURL:  getEnv("RELAYER_URL", ""),
+
+		ColdChainMinTemp:    getEnvFloat("COLD_CHAIN_MIN_TEMP", 0),
+		ColdChainMaxTemp:    getEnvFloat("COLD_CHAIN_MAX_TEMP", 8),
+		ColdChainCategories: getEnvList("COLD_CHAIN_CATEGORIES", "vegetable,fruit"),
+
+		ScanWindowHours: getEnvInt("SCAN_WINDOW_HOURS", 24),
+		ScanMaxCount:    getEnvInt("SCAN_MAX_COUNT", 200),
+		ScanMaxCities:   getEnvInt("SCAN_MAX_CITIES", 5),
+		ScanGeoHeader:   getEnv("SCAN_GEO_HEADER", ""),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES", ""),
 	}
 }
 
@@ -29,4 +61,33 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// 逗号分隔的列表，忽略空项
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -20,6 +20,12 @@ func Initialize(databaseURL string) (*gorm.DB, error) {
 		&models.Certificate{},
 		&models.TraceRecord{},
 		&models.TraceTimeline{},
+		&models.SensorReading{},
+		&models.SensorDevice{},
+		&models.TelemetryExcursion{},
+		&models.InspectionReport{},
+		&models.InspectionItem{},
+		&models.ScanEvent{},
 		&models.Account{},
 		&models.Order{},
 		&models.AuditLog{},
@@ -33,6 +39,14 @@ func Initialize(databaseURL string) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// 将历史溯源记录关联到农产品
+	if err := runOnce(db, "trace_products", migrateTraceProducts); err != nil {
+		return nil, err
+	}
+	if err := runOnce(db, "certificate_products", migrateCertificateProducts); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
@@ -50,18 +64,18 @@ func SeedData(db *gorm.DB) error {
 
 	// 农产品种子数据
 	products := []models.FarmProduct{
-		{Name: "有机大米", Category: "grain", CategoryName: "粮食作物", Icon: "🌾", Description: "来自黑龙江五常的优质有机大米，无农药无化肥，口感香甜软糯", Batches: 1250, Enterprises: 45, Color: "#FFD700"},
-		{Name: "普洱茶", Category: "tea", CategoryName: "茶叶", Icon: "🍵", Description: "云南普洱古树茶，经过传统工艺发酵，茶香浓郁，回甘持久", Batches: 856, Enterprises: 32, Color: "#8B4513"},
-		{Name: "新鲜蔬菜", Category: "vegetable", CategoryName: "蔬菜", Icon: "🥬", Description: "山东寿光大棚蔬菜，新鲜采摘，绿色健康，当日配送", Batches: 2340, Enterprises: 78, Color: "#32CD32"},
-		{Name: "苹果", Category: "fruit", CategoryName: "水果", Icon: "🍎", Description: "陕西洛川红富士苹果，果形端正，色泽鲜艳，脆甜多汁", Batches: 1680, Enterprises: 56, Color: "#FF4500"},
-		{Name: "小麦", Category: "grain", CategoryName: "粮食作物", Icon: "🌾", Description: "河南优质小麦，籽粒饱满，蛋白质含量高，适合制作面粉", Batches: 980, Enterprises: 38, Color: "#DAA520"},
-		{Name: "龙井茶", Category: "tea", CategoryName: "茶叶", Icon: "🍃", Description: "杭州西湖龙井，明前采摘，色泽翠绿，香气清高，味道甘醇", Batches: 645, Enterprises: 28, Color: "#90EE90"},
-		{Name: "西红柿", Category: "vegetable", CategoryName: "蔬菜", Icon: "🍅", Description: "新疆番茄，日照充足，糖分高，口感酸甜适中", Batches: 1420, Enterprises: 52, Color: "#FF6347"},
-		{Name: "橙子", Category: "fruit", CategoryName: "水果", Icon: "🍊", Description: "江西赣南脐橙，果肉细嫩，汁多味甜，维生素C含量丰富", Batches: 1890, Enterprises: 64, Color: "#FFA500"},
-		{Name: "玉米", Category: "grain", CategoryName: "粮食作物", Icon: "🌽", Description: "吉林甜玉米，颗粒饱满，口感香甜，营养价值高", Batches: 1120, Enterprises: 42, Color: "#FFD700"},
-		{Name: "铁观音", Category: "tea", CategoryName: "茶叶", Icon: "🍵", Description: "福建安溪铁观音，兰花香浓郁，滋味醇厚，回甘明显", Batches: 720, Enterprises: 30, Color: "#556B2F"},
-		{Name: "黄瓜", Category: "vegetable", CategoryName: "蔬菜", Icon: "🥒", Description: "有机黄瓜，清脆爽口，水分充足，适合生食或凉拌", Batches: 1560, Enterprises: 48, Color: "#228B22"},
-		{Name: "草莓", Category: "fruit", CategoryName: "水果", Icon: "🍓", Description: "大棚草莓，果实鲜红，香气浓郁，甜度高，口感细腻", Batches: 980, Enterprises: 36, Color: "#DC143C"},
+		{Name: "有机大米", Category: "grain", CategoryName: "粮食作物", Icon: "🌾", Description: "来自黑龙江五常的优质有机大米，无农药无化肥，口感香甜软糯", Color: "#FFD700"},
+		{Name: "普洱茶", Category: "tea", CategoryName: "茶叶", Icon: "🍵", Description: "云南普洱古树茶，经过传统工艺发酵，茶香浓郁，回甘持久", Color: "#8B4513"},
+		{Name: "新鲜蔬菜", Category: "vegetable", CategoryName: "蔬菜", Icon: "🥬", Description: "山东寿光大棚蔬菜，新鲜采摘，绿色健康，当日配送", Color: "#32CD32"},
+		{Name: "苹果", Category: "fruit", CategoryName: "水果", Icon: "🍎", Description: "陕西洛川红富士苹果，果形端正，色泽鲜艳，脆甜多汁", Color: "#FF4500"},
+		{Name: "小麦", Category: "grain", CategoryName: "粮食作物", Icon: "🌾", Description: "河南优质小麦，籽粒饱满，蛋白质含量高，适合制作面粉", Color: "#DAA520"},
+		{Name: "龙井茶", Category: "tea", CategoryName: "茶叶", Icon: "🍃", Description: "杭州西湖龙井，明前采摘，色泽翠绿，香气清高，味道甘醇", Color: "#90EE90"},
+		{Name: "西红柿", Category: "vegetable", CategoryName: "蔬菜", Icon: "🍅", Description: "新疆番茄，日照充足，糖分高，口感酸甜适中", Color: "#FF6347"},
+		{Name: "橙子", Category: "fruit", CategoryName: "水果", Icon: "🍊", Description: "江西赣南脐橙，果肉细嫩，汁多味甜，维生素C含量丰富", Color: "#FFA500"},
+		{Name: "玉米", Category: "grain", CategoryName: "粮食作物", Icon: "🌽", Description: "吉林甜玉米，颗粒饱满，口感香甜，营养价值高", Color: "#FFD700"},
+		{Name: "铁观音", Category: "tea", CategoryName: "茶叶", Icon: "🍵", Description: "福建安溪铁观音，兰花香浓郁，滋味醇厚，回甘明显", Color: "#556B2F"},
+		{Name: "黄瓜", Category: "vegetable", CategoryName: "蔬菜", Icon: "🥒", Description: "有机黄瓜，清脆爽口，水分充足，适合生食或凉拌", Color: "#228B22"},
+		{Name: "草莓", Category: "fruit", CategoryName: "水果", Icon: "🍓", Description: "大棚草莓，果实鲜红，香气浓郁，甜度高，口感细腻", Color: "#DC143C"},
 	}
 
 	if err := db.Create(&products).Error; err != nil {
@@ -129,5 +143,37 @@ func SeedData(db *gorm.DB) error {
 		return err
 	}
 
-	return nil
+	// 检测报告种子数据
+	reports := []models.InspectionReport{
+		{
+			ID: "QC20241210001", TraceID: "TB20241210001", Lab: "国家粮食质量监督检验中心",
+			SampledAt: time.Date(2024, 10, 11, 9, 0, 0, 0, time.UTC), ReportedAt: time.Date(2024, 10, 12, 9, 0, 0, 0, time.UTC),
+			Passed: true, Conclusion: "所检项目均符合 GB 2763、GB 2762 要求",
+			Items: []models.InspectionItem{
+				{Category: "pesticide_residue", Name: "毒死蜱", Result: 0, Limit: 0.5, Unit: "mg/kg", Passed: true},
+				{Category: "pesticide_residue", Name: "三唑磷", Result: 0, Limit: 0.05, Unit: "mg/kg", Passed: true},
+				{Category: "heavy_metal", Name: "镉(Cd)", Result: 0.04, Limit: 0.2, Unit: "mg/kg", Passed: true},
+				{Category: "heavy_metal", Name: "铅(Pb)", Result: 0.03, Limit: 0.2, Unit: "mg/kg", Passed: true},
+			},
+		},
+		{
+			ID: "QC20241210003", TraceID: "TB20241210003", Lab: "山东省农产品质量安全检测中心",
+			SampledAt: time.Date(2024, 12, 10, 8, 0, 0, 0, time.UTC), ReportedAt: time.Date(2024, 12, 10, 16, 0, 0, 0, time.UTC),
+			Passed: false, Conclusion: "腐霉利残留超出限量，不予放行",
+			Items: []models.InspectionItem{
+				{Category: "pesticide_residue", Name: "腐霉利", Result: 6.2, Limit: 5, Unit: "mg/kg", Passed: false},
+				{Category: "heavy_metal", Name: "铅(Pb)", Result: 0.05, Limit: 0.3, Unit: "mg/kg", Passed: true},
+			},
+		},
+	}
+
+	if err := db.Create(&reports).Error; err != nil {
+		return err
+	}
+
+	if _, err := LinkTraceProducts(db); err != nil {
+		return err
+	}
+	_, err := LinkCertificateProducts(db)
+	return err
 }
\ No newline at end of file
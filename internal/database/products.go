@@ -0,0 +1,243 @@
+package database
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"conflux-farm/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 名称至少需要重合的字数，避免仅凭“茶”“米”这类单字误匹配
+const minNameOverlap = 2
+
+// 农产品的实时批次与企业统计
+type BatchCount struct {
+	ProductID   uint
+	Batches     int64
+	Enterprises int64
+}
+
+// 统计各农产品关联的溯源批次数与企业数
+func ProductBatchCounts(db *gorm.DB, productIDs []uint) (map[uint]BatchCount, error) {
+	counts := make(map[uint]BatchCount, len(productIDs))
+	if len(productIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []BatchCount
+	if err := db.Model(&models.TraceRecord{}).
+		Select("product_id, COUNT(*) AS batches, COUNT(DISTINCT enterprise) AS enterprises").
+		Where("product_id IN ?", productIDs).
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ProductID] = row
+	}
+	return counts, nil
+}
+
+// 填充农产品列表的批次与企业数
+func AttachBatchCounts(db *gorm.DB, products []models.FarmProduct) error {
+	ids := make([]uint, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+
+	counts, err := ProductBatchCounts(db, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		products[i].Batches = counts[products[i].ID].Batches
+		products[i].Enterprises = counts[products[i].ID].Enterprises
+	}
+	return nil
+}
+
+// 已执行的一次性迁移
+type schemaMigration struct {
+	Name      string `gorm:"primaryKey;size:64"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+// 执行名为 name 的一次性迁移，成功后记录，之后启动时跳过
+func runOnce(db *gorm.DB, name string, migrate func(*gorm.DB) error) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	var count int64
+	if err := db.Model(&schemaMigration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if err := migrate(db); err != nil {
+		return err
+	}
+	return db.Create(&schemaMigration{Name: name, AppliedAt: time.Now()}).Error
+}
+
+// 一次性迁移：移除手工维护的统计列，并关联历史溯源记录
+func migrateTraceProducts(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, column := range []string{"batches", "enterprises"} {
+		if migrator.HasColumn(&models.FarmProduct{}, column) {
+			if err := migrator.DropColumn(&models.FarmProduct{}, column); err != nil {
+				return err
+			}
+		}
+	}
+
+	linked, err := LinkTraceProducts(db)
+	if err != nil {
+		return err
+	}
+	if linked > 0 {
+		log.Printf("Linked %d trace records to farm products", linked)
+	}
+	return nil
+}
+
+// 按名称模糊匹配，为尚未关联的溯源记录设置 product_id
+// 例如 “云南普洱茶” 匹配 “普洱茶”，“山东寿光蔬菜” 匹配 “新鲜蔬菜”
+func LinkTraceProducts(db *gorm.DB) (int, error) {
+	var products []models.FarmProduct
+	if err := db.Find(&products).Error; err != nil {
+		return 0, err
+	}
+
+	var records []models.TraceRecord
+	if err := db.Where("product_id IS NULL").Find(&records).Error; err != nil {
+		return 0, err
+	}
+
+	linked := 0
+	for _, record := range records {
+		product := matchProduct(record.Product, record.Icon, record.Origin, products)
+		if product == nil {
+			log.Printf("No farm product matches trace record %s (%s)", record.ID, record.Product)
+			continue
+		}
+		if err := db.Model(&models.TraceRecord{}).Where("id = ?", record.ID).
+			Update("product_id", product.ID).Error; err != nil {
+			return linked, err
+		}
+		linked++
+	}
+	return linked, nil
+}
+
+// 按名称为尚未关联的证书设置 product_id
+func LinkCertificateProducts(db *gorm.DB) (int, error) {
+	var products []models.FarmProduct
+	if err := db.Find(&products).Error; err != nil {
+		return 0, err
+	}
+
+	var certificates []models.Certificate
+	if err := db.Where("product_id IS NULL").Find(&certificates).Error; err != nil {
+		return 0, err
+	}
+
+	linked := 0
+	for _, cert := range certificates {
+		// 证书图标表示证书类型而非品类，不参与匹配
+		product := matchProduct(cert.Product, "", "", products)
+		if product == nil {
+			log.Printf("No farm product matches certificate %s (%s)", cert.ID, cert.Product)
+			continue
+		}
+		if err := db.Model(&models.Certificate{}).Where("id = ?", cert.ID).
+			Update("product_id", product.ID).Error; err != nil {
+			return linked, err
+		}
+		linked++
+	}
+	return linked, nil
+}
+
+// 一次性迁移：关联历史证书
+func migrateCertificateProducts(db *gorm.DB) error {
+	linked, err := LinkCertificateProducts(db)
+	if err != nil {
+		return err
+	}
+	if linked > 0 {
+		log.Printf("Linked %d certificates to farm products", linked)
+	}
+	return nil
+}
+
+// 只在可由图标或名称推断的品类内匹配；名称重合度优先，产地出现在产品描述中作为次要依据
+func matchProduct(name, icon, origin string, products []models.FarmProduct) *models.FarmProduct {
+	categories := productCategories(name, icon, products)
+
+	var best *models.FarmProduct
+	bestScore := 0
+
+	for i := range products {
+		p := &products[i]
+		if len(categories) > 0 && !categories[p.Category] {
+			continue
+		}
+		if p.Name == name {
+			return p
+		}
+
+		overlap := longestCommonSubstring(name, p.Name)
+		if overlap < minNameOverlap && overlap < len([]rune(p.Name)) {
+			continue
+		}
+
+		score := overlap * 10
+		if origin != "" && strings.Contains(p.Description, origin) {
+			score += 5
+		}
+		if score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	return best
+}
+
+// 由图标或名称中的品类名推断品类，例如 🍵 或 “山东寿光蔬菜” 中的 “蔬菜”
+// 无法推断时返回空集合，不限制品类
+func productCategories(name, icon string, products []models.FarmProduct) map[string]bool {
+	categories := map[string]bool{}
+	for _, p := range products {
+		if (icon != "" && p.Icon == icon) ||
+			(p.CategoryName != "" && strings.Contains(name, p.CategoryName)) {
+			categories[p.Category] = true
+		}
+	}
+	return categories
+}
+
+func longestCommonSubstring(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	best := 0
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		for j := 1; j <= len(rb); j++ {
+			if ra[i-1] == rb[j-1] {
+				cur[j] = prev[j-1] + 1
+				if cur[j] > best {
+					best = cur[j]
+				}
+			}
+		}
+		prev = cur
+	}
+	return best
+}
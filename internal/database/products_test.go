@@ -0,0 +1,65 @@
+package database
+
+import (
+	"testing"
+
+	"conflux-farm/internal/models"
+)
+
+func TestLongestCommonSubstring(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"云南普洱茶", "普洱茶", 3},
+		{"山东寿光蔬菜", "新鲜蔬菜", 2},
+		{"有机黄瓜", "有机大米", 2},
+		{"abc", "xbcy", 2},
+		{"普洱茶", "铁观音", 0},
+		{"", "普洱茶", 0},
+	}
+	for _, tt := range tests {
+		if got := longestCommonSubstring(tt.a, tt.b); got != tt.want {
+			t.Errorf("longestCommonSubstring(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMatchProduct(t *testing.T) {
+	products := []models.FarmProduct{
+		{ID: 1, Name: "有机大米", Category: "grain", CategoryName: "粮食作物", Icon: "🌾", Description: "来自黑龙江五常的优质有机大米"},
+		{ID: 2, Name: "普洱茶", Category: "tea", CategoryName: "茶叶", Icon: "🍵", Description: "云南普洱古树茶"},
+		{ID: 3, Name: "新鲜蔬菜", Category: "vegetable", CategoryName: "蔬菜", Icon: "🥬", Description: "山东寿光大棚蔬菜"},
+		{ID: 4, Name: "苹果", Category: "fruit", CategoryName: "水果", Icon: "🍎", Description: "陕西洛川红富士苹果"},
+		{ID: 5, Name: "龙井茶", Category: "tea", CategoryName: "茶叶", Icon: "🍃", Description: "杭州西湖龙井"},
+		{ID: 6, Name: "铁观音", Category: "tea", CategoryName: "茶叶", Icon: "🍵", Description: "福建安溪铁观音"},
+		{ID: 7, Name: "有机黄瓜", Category: "vegetable", CategoryName: "蔬菜", Icon: "🥒", Description: "有机黄瓜，清脆爽口"},
+	}
+
+	tests := []struct {
+		name   string
+		record models.TraceRecord
+		want   uint
+	}{
+		{"exact name", models.TraceRecord{Product: "有机大米", Icon: "🌾"}, 1},
+		{"name contains product", models.TraceRecord{Product: "云南普洱茶", Icon: "🍵"}, 2},
+		{"category name and origin", models.TraceRecord{Product: "山东寿光蔬菜", Icon: "🥬", Origin: "山东寿光"}, 3},
+		{"short product name matches whole", models.TraceRecord{Product: "陕西洛川苹果", Icon: "🍎"}, 4},
+		{"icon of another product in the category", models.TraceRecord{Product: "福建安溪铁观音", Icon: "🍃"}, 6},
+		{"single shared character is not enough", models.TraceRecord{Product: "西湖绿茶", Icon: "🍃"}, 0},
+		{"other categories are not considered", models.TraceRecord{Product: "有机绿茶", Icon: "🍵"}, 0},
+		{"unknown category matches any", models.TraceRecord{Product: "五常有机大米"}, 1},
+		{"certificate product name", models.TraceRecord{Product: "杭州龙井茶"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got uint
+			if p := matchProduct(tt.record.Product, tt.record.Icon, tt.record.Origin, products); p != nil {
+				got = p.ID
+			}
+			if got != tt.want {
+				t.Errorf("matchProduct(%q) = %d, want %d", tt.record.Product, got, tt.want)
+			}
+		})
+	}
+}
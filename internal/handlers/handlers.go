@@ -1,25 +1,32 @@
 package handlers
 
 import (
+	"bytes"
 	"conflux-farm/internal/config"
+	"conflux-farm/internal/database"
 	"conflux-farm/internal/models"
+	"conflux-farm/internal/telemetry"
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db        *gorm.DB
+	cfg       *config.Config
+	telemetry *telemetry.Engine
 }
 
 func NewHandler(db *gorm.DB, cfg *config.Config) *Handler {
 	return &Handler{
-		db:  db,
-		cfg: cfg,
+		db:        db,
+		cfg:       cfg,
+		telemetry: telemetry.NewEngine(telemetry.ColdChainRule(cfg.ColdChainMinTemp, cfg.ColdChainMaxTemp, cfg.ColdChainCategories)),
 	}
 }
 
@@ -42,6 +49,14 @@ func (h *Handler) GetFarmProducts(c *gin.Context) {
 		return
 	}
 	
+	if err := database.AttachBatchCounts(h.db, products); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to count product batches",
+		})
+		return
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"ok":       true,
 		"products": products,
@@ -68,9 +83,59 @@ func (h *Handler) GetFarmProductByID(c *gin.Context) {
 		return
 	}
 	
+	products := []models.FarmProduct{product}
+	if err := database.AttachBatchCounts(h.db, products); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to count product batches",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"ok":      true,
+		"product": products[0],
+	})
+}
+
+// 获取农产品关联的溯源批次
+func (h *Handler) GetProductBatches(c *gin.Context) {
+	id := c.Param("id")
+	
+	var product models.FarmProduct
+	if err := h.db.First(&product, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"ok":    false,
+				"error": "Product not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get product",
+		})
+		return
+	}
+	
+	query := h.db.Model(&models.TraceRecord{}).Where("product_id = ?", product.ID)
+	if status := c.Query("status"); status != "" && status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	
+	var records []models.TraceRecord
+	if err := query.Order("created_at DESC").Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get product batches",
+		})
+		return
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"ok":      true,
 		"product": product,
+		"batches": records,
 	})
 }
 
@@ -158,10 +223,8 @@ func (h *Handler) GetTraceRecords(c *gin.Context) {
 func (h *Handler) GetTraceRecordByID(c *gin.Context) {
 	id := c.Param("id")
 	
-	var record models.TraceRecord
-	if err := h.db.Preload("Timeline", func(db *gorm.DB) *gorm.DB {
-		return db.Order("sort_order ASC")
-	}).First(&record, "id = ?", id).Error; err != nil {
+	record, err := h.loadTraceRecord(id)
+	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"ok":    false,
@@ -182,6 +245,18 @@ func (h *Handler) GetTraceRecordByID(c *gin.Context) {
 	})
 }
 
+// 加载溯源记录及其时间线和检测报告
+func (h *Handler) loadTraceRecord(id string) (*models.TraceRecord, error) {
+	var record models.TraceRecord
+	err := h.db.Preload("Timeline", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC")
+	}).Preload("Inspections.Items").First(&record, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
 // 获取统计数据
 func (h *Handler) GetStatistics(c *gin.Context) {
 	var stats models.Statistics
@@ -195,15 +270,15 @@ func (h *Handler) GetStatistics(c *gin.Context) {
 	// 有效证书数量
 	h.db.Model(&models.Certificate{}).Where("status = ?", "有效").Count(&stats.Certificates)
 	
-	// 企业总数（从农产品表汇总）
-	var enterpriseSum struct {
-		Total int64
-	}
-	h.db.Model(&models.FarmProduct{}).Select("SUM(enterprises) as total").Scan(&enterpriseSum)
-	stats.Enterprises = enterpriseSum.Total
+	// 企业总数（从溯源记录去重统计）
+	h.db.Model(&models.TraceRecord{}).Distinct("enterprise").Count(&stats.Enterprises)
+	
+	// 今日扫码查询次数
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	h.db.Model(&models.ScanEvent{}).Where("created_at >= ?", today).Count(&stats.TodayQueries)
 	
 	// 模拟其他统计数据
-	stats.TodayQueries = 3245
 	stats.SuccessRate = 98.5
 	stats.InTransit = 156
 	stats.AvgQueryTime = "2.3秒"
@@ -290,6 +365,7 @@ func (h *Handler) MintNFT(c *gin.Context) {
 		NFTAddress   string `json:"nftAddress" binding:"required"`
 		Origin       string `json:"origin" binding:"required"`
 		HarvestTime  int64  `json:"harvestTime" binding:"required"`
+		TraceID      string `json:"traceId" binding:"required"`
 		InspectionID string `json:"inspectionId" binding:"required"`
 		URI          string `json:"uri"`
 	}
@@ -302,6 +378,53 @@ func (h *Handler) MintNFT(c *gin.Context) {
 		return
 	}
 	
+	var record models.TraceRecord
+	if err := h.db.Select("id").First(&record, "id = ?", req.TraceID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"ok":    false,
+				"error": "Trace record not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get trace record",
+		})
+		return
+	}
+	
+	// 必须存在本批次已通过的检测报告才允许铸造
+	var report models.InspectionReport
+	if err := h.db.First(&report, "id = ?", req.InspectionID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"ok":    false,
+				"error": "Inspection report not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get inspection report",
+		})
+		return
+	}
+	if report.TraceID != record.ID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"ok":    false,
+			"error": "Inspection report belongs to another batch",
+		})
+		return
+	}
+	if !report.Passed {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"ok":    false,
+			"error": "Inspection report did not pass",
+		})
+		return
+	}
+	
 	// 这里应该调用区块链相关的铸造逻辑
 	// 为了演示，我们返回成功响应
 	c.JSON(http.StatusOK, gin.H{
@@ -313,6 +436,16 @@ func (h *Handler) MintNFT(c *gin.Context) {
 
 // NFT 转移（简化版本）
 func (h *Handler) TransferNFT(c *gin.Context) {
+	// 中继只替令牌持有人转移自己的 NFT，需转发调用方的 Bearer 令牌
+	authorization := c.GetHeader("Authorization")
+	if authorization == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"ok":    false,
+			"error": "Authorization header required",
+		})
+		return
+	}
+	
 	var req struct {
 		From       string `json:"from" binding:"required"`
 		To         string `json:"to" binding:"required"`
@@ -328,13 +461,58 @@ func (h *Handler) TransferNFT(c *gin.Context) {
 		return
 	}
 	
-	// 这里应该调用区块链相关的转移逻辑
-	// 为了演示，我们返回成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"ok":         true,
-		"txHash":     "0x" + strings.Repeat("b", 64), // 模拟交易哈希
-		"chargedRMB": 0.3,                            // 模拟费用
-	})
+	if strings.EqualFold(req.From, req.To) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Cannot transfer to the current owner",
+		})
+		return
+	}
+	
+	// 转移由后端中继服务完成：链上校验持有人、提交 safeTransferFrom 并等待回执
+	if h.cfg.RelayerURL == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"ok":    false,
+			"error": "NFT relayer not configured",
+		})
+		return
+	}
+	
+	payload, _ := json.Marshal(req)
+	relayReq, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, strings.TrimRight(h.cfg.RelayerURL, "/")+"/relay/nft/transfer", bytes.NewReader(payload))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to build relayer request",
+		})
+		return
+	}
+	relayReq.Header.Set("Content-Type", "application/json")
+	relayReq.Header.Set("Authorization", authorization)
+	
+	client := &http.Client{Timeout: 90 * time.Second}
+	resp, err := client.Do(relayReq)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"ok":    false,
+			"error": "Failed to reach NFT relayer",
+		})
+		return
+	}
+	defer resp.Body.Close()
+	
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"ok":    false,
+			"error": "Invalid response from NFT relayer",
+		})
+		return
+	}
+	if _, ok := result["ok"]; !ok {
+		result["ok"] = resp.StatusCode < 300
+	}
+	c.JSON(resp.StatusCode, result)
 }
 
 // 获取 NFT 详情（简化版本）
@@ -361,7 +539,7 @@ func (h *Handler) GetNFTDetails(c *gin.Context) {
 		"onchain": gin.H{
 			"origin":       "云南普洱",
 			"harvestTime":  1699200000,
-			"inspectionId": "QC20240101",
+			"inspectionId": "QC20241210001",
 			"contentHash":  "0x" + strings.Repeat("c", 64),
 		},
 		"offchain": gin.H{
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"conflux-farm/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 检测报告附件大小上限
+const maxInspectionFileSize = 20 << 20
+
+// 允许上传的报告附件类型
+var inspectionFileTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// 获取检测报告
+func (h *Handler) GetInspectionReport(c *gin.Context) {
+	id := c.Param("id")
+
+	var report models.InspectionReport
+	if err := h.db.Preload("Items").First(&report, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"ok":    false,
+				"error": "Inspection report not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get inspection report",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":     true,
+		"report": report,
+	})
+}
+
+// 录入检测报告，逐项比对限量值得出结论
+func (h *Handler) CreateInspectionReport(c *gin.Context) {
+	var req struct {
+		ID         string    `json:"id" binding:"required"`
+		TraceID    string    `json:"traceId" binding:"required"`
+		Lab        string    `json:"lab" binding:"required"`
+		SampledAt  time.Time `json:"sampledAt"`
+		ReportedAt time.Time `json:"reportedAt"`
+		Conclusion string    `json:"conclusion"`
+		Items      []struct {
+			Category string  `json:"category" binding:"required"`
+			Name     string  `json:"name" binding:"required"`
+			Result   float64 `json:"result"`
+			Limit    float64 `json:"limit" binding:"required,gt=0"`
+			Unit     string  `json:"unit"`
+		} `json:"items" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Invalid input",
+		})
+		return
+	}
+
+	var record models.TraceRecord
+	if err := h.db.Select("id").First(&record, "id = ?", req.TraceID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Trace record not found",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.InspectionReport{}).Where("id = ?", req.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"ok":    false,
+			"error": "Inspection report already exists",
+		})
+		return
+	}
+
+	report := models.InspectionReport{
+		ID:         req.ID,
+		TraceID:    req.TraceID,
+		Lab:        req.Lab,
+		SampledAt:  req.SampledAt,
+		ReportedAt: req.ReportedAt,
+		Passed:     true,
+		Conclusion: req.Conclusion,
+	}
+	if report.ReportedAt.IsZero() {
+		report.ReportedAt = time.Now()
+	}
+
+	for _, item := range req.Items {
+		passed := item.Result <= item.Limit
+		if !passed {
+			report.Passed = false
+		}
+		report.Items = append(report.Items, models.InspectionItem{
+			Category: item.Category,
+			Name:     item.Name,
+			Result:   item.Result,
+			Limit:    item.Limit,
+			Unit:     item.Unit,
+			Passed:   passed,
+		})
+	}
+
+	if err := h.db.Create(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to create inspection report",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ok":     true,
+		"report": report,
+	})
+}
+
+// 上传检测报告附件（PDF 或图片），记录 SHA-256 便于链上存证比对
+func (h *Handler) UploadInspectionFile(c *gin.Context) {
+	id := c.Param("id")
+
+	var report models.InspectionReport
+	if err := h.db.First(&report, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"ok":    false,
+				"error": "Inspection report not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get inspection report",
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "File is required",
+		})
+		return
+	}
+	if fileHeader.Size > maxInspectionFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "File too large",
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Failed to read file",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxInspectionFileSize+1))
+	if err != nil || len(data) > maxInspectionFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Failed to read file",
+		})
+		return
+	}
+
+	// 以文件内容判断类型，不信任客户端声明
+	ext, ok := inspectionFileTypes[strings.Split(http.DetectContentType(data), ";")[0]]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Unsupported file type",
+		})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	name := report.ID + "-" + hash[:12] + ext
+
+	dir := filepath.Join(h.cfg.UploadDir, "inspections")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to store file",
+		})
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to store file",
+		})
+		return
+	}
+
+	report.FileName = filepath.Base(fileHeader.Filename)
+	report.FileURL = "/uploads/inspections/" + name
+	report.FileHash = hash
+	if err := h.db.Model(&report).Select("file_name", "file_url", "file_hash").Updates(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to update inspection report",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":     true,
+		"report": report,
+	})
+}
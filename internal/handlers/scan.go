@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"conflux-farm/internal/config"
+	"conflux-farm/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 消费者扫码查询：返回溯源记录与证书，记录扫码并评估防伪风险
+// 可选参数: city, lat, lng（客户端定位，仅保留一位小数，仅用于展示）
+func (h *Handler) ScanTraceCode(c *gin.Context) {
+	code := strings.TrimSpace(c.Param("code"))
+
+	record, err := h.loadTraceRecord(code)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"ok":    false,
+				"error": "Trace record not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get trace record",
+		})
+		return
+	}
+
+	event := models.ScanEvent{
+		TraceID:    record.ID,
+		City:       scanCity(c),
+		Latitude:   coarseCoordinate(c.Query("lat"), 90),
+		Longitude:  coarseCoordinate(c.Query("lng"), 180),
+		IPPrefix:   coarseIP(c.ClientIP()),
+		ClientHash: h.clientHash(c.ClientIP()),
+		GeoCity:    h.geoCity(c),
+		UserAgent:  truncate(c.Request.UserAgent(), 512),
+	}
+	if err := h.db.Create(&event).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to record scan",
+		})
+		return
+	}
+
+	clients, cities, err := h.evaluateScanRisk(record)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to evaluate scan risk",
+		})
+		return
+	}
+
+	// 按关联的农产品查找证书，未关联农产品的批次不展示证书
+	certificates := []models.Certificate{}
+	if record.ProductID != nil {
+		if err := h.db.Where("product_id = ? AND status = ?", *record.ProductID, "有效").
+			Order("issue_date DESC").Find(&certificates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"ok":    false,
+				"error": "Failed to get certificates",
+			})
+			return
+		}
+	}
+
+	// 本次扫码已写入，首次扫码记录必然存在
+	var first models.ScanEvent
+	if err := h.db.Where("trace_id = ?", record.ID).Order("created_at ASC").First(&first).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get first scan",
+		})
+		return
+	}
+
+	resp := gin.H{
+		"ok":           true,
+		"record":       record,
+		"certificates": certificates,
+		"scan": gin.H{
+			"windowClients":  clients,
+			"windowCities":   cities,
+			"firstScannedAt": first.CreatedAt,
+		},
+	}
+	if record.CounterfeitRisk {
+		resp["warning"] = "该批次扫码异常，可能存在假冒风险，请谨慎购买"
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 统计窗口期内扫码的不同客户端数与可信城市数，超过阈值时标记批次
+// 同一客户端重复扫码只计一次；管理员解除标记前的扫码不再计入
+func (h *Handler) evaluateScanRisk(record *models.TraceRecord) (int64, int64, error) {
+	since := time.Now().Add(-time.Duration(h.cfg.ScanWindowHours) * time.Hour)
+	if record.RiskClearedAt != nil && record.RiskClearedAt.After(since) {
+		since = *record.RiskClearedAt
+	}
+
+	var clients, cities int64
+	if err := h.db.Model(&models.ScanEvent{}).
+		Where("trace_id = ? AND created_at >= ? AND client_hash <> ''", record.ID, since).
+		Distinct("client_hash").Count(&clients).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := h.db.Model(&models.ScanEvent{}).
+		Where("trace_id = ? AND created_at >= ? AND geo_city <> ''", record.ID, since).
+		Distinct("geo_city").Count(&cities).Error; err != nil {
+		return 0, 0, err
+	}
+
+	if record.CounterfeitRisk {
+		return clients, cities, nil
+	}
+
+	reason := scanRiskReason(h.cfg, clients, cities)
+	if reason == "" {
+		return clients, cities, nil
+	}
+
+	now := time.Now()
+	if err := h.db.Model(&models.TraceRecord{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"counterfeit_risk": true,
+		"risk_reason":      reason,
+		"risk_flagged_at":  now,
+	}).Error; err != nil {
+		return 0, 0, err
+	}
+	record.CounterfeitRisk = true
+	record.RiskReason = reason
+	record.RiskFlaggedAt = &now
+	return clients, cities, nil
+}
+
+// 客户端数或城市数超过阈值时返回标记原因，否则为空；阈值为 0 表示不检查
+func scanRiskReason(cfg *config.Config, clients, cities int64) string {
+	switch {
+	case cfg.ScanMaxCount > 0 && clients > int64(cfg.ScanMaxCount):
+		return fmt.Sprintf("%d 小时内被 %d 个客户端扫码，超过阈值 %d", cfg.ScanWindowHours, clients, cfg.ScanMaxCount)
+	case cfg.ScanMaxCities > 0 && cities > int64(cfg.ScanMaxCities):
+		return fmt.Sprintf("%d 小时内在 %d 个城市被扫码，超过阈值 %d", cfg.ScanWindowHours, cities, cfg.ScanMaxCities)
+	default:
+		return ""
+	}
+}
+
+// 管理员核实后解除批次的假冒风险标记，此前的扫码不再计入风险判断
+func (h *Handler) ClearCounterfeitRisk(c *gin.Context) {
+	now := time.Now()
+	result := h.db.Model(&models.TraceRecord{}).Where("id = ?", c.Param("id")).Updates(map[string]interface{}{
+		"counterfeit_risk": false,
+		"risk_reason":      "",
+		"risk_flagged_at":  nil,
+		"risk_cleared_at":  now,
+	})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to clear risk",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"ok":    false,
+			"error": "Trace record not found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// 可信 CDN 头给出的城市；未配置 SCAN_GEO_HEADER 时为空
+func (h *Handler) geoCity(c *gin.Context) string {
+	if h.cfg.ScanGeoHeader == "" {
+		return ""
+	}
+	return truncate(strings.TrimSpace(c.GetHeader(h.cfg.ScanGeoHeader)), 64)
+}
+
+// 以服务端密钥计算客户端 IP 的 HMAC，可去重但无法枚举还原原始 IP
+func (h *Handler) clientHash(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(h.cfg.JWTSecret))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 展示用城市：优先使用客户端上报的城市，其次为地理位置头，均可被客户端伪造
+func scanCity(c *gin.Context) string {
+	for _, v := range []string{c.Query("city"), c.GetHeader("X-Geo-City"), c.GetHeader("CF-IPCity")} {
+		if v = strings.TrimSpace(v); v != "" {
+			return truncate(v, 64)
+		}
+	}
+	return ""
+}
+
+// 坐标保留一位小数（约 10 公里精度）
+func coarseCoordinate(v string, limit float64) *float64 {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.Abs(f) > limit {
+		return nil
+	}
+	f = math.Round(f*10) / 10
+	return &f
+}
+
+// IPv4 保留 /24，IPv6 保留 /48
+func coarseIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
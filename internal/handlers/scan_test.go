@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"conflux-farm/internal/config"
+)
+
+func TestScanRiskReason(t *testing.T) {
+	cfg := &config.Config{ScanWindowHours: 24, ScanMaxCount: 200, ScanMaxCities: 5}
+
+	tests := []struct {
+		name           string
+		cfg            *config.Config
+		clients        int64
+		cities         int64
+		wantFlagged    bool
+		wantReasonPart string
+	}{
+		{"below both thresholds", cfg, 10, 2, false, ""},
+		{"clients at threshold", cfg, 200, 5, false, ""},
+		{"clients over threshold", cfg, 201, 1, true, "201 个客户端"},
+		{"cities over threshold", cfg, 3, 6, true, "6 个城市"},
+		{"clients checked before cities", cfg, 300, 9, true, "300 个客户端"},
+		{"client threshold disabled", &config.Config{ScanWindowHours: 24, ScanMaxCities: 5}, 100000, 5, false, ""},
+		{"city threshold disabled", &config.Config{ScanWindowHours: 24, ScanMaxCount: 200}, 1, 100, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := scanRiskReason(tt.cfg, tt.clients, tt.cities)
+			if (reason != "") != tt.wantFlagged {
+				t.Fatalf("scanRiskReason(%d, %d) = %q, want flagged %v", tt.clients, tt.cities, reason, tt.wantFlagged)
+			}
+			if !strings.Contains(reason, tt.wantReasonPart) {
+				t.Errorf("reason %q does not mention %q", reason, tt.wantReasonPart)
+			}
+		})
+	}
+}
+
+func TestCoarseScanLocation(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.57", "203.0.113.0/24"},
+		{"2001:db8:1234:5678::1", "2001:db8:1234::/48"},
+		{"not an ip", ""},
+	}
+	for _, tt := range tests {
+		if got := coarseIP(tt.ip); got != tt.want {
+			t.Errorf("coarseIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+
+	if got := coarseCoordinate("31.2345", 90); got == nil || *got != 31.2 {
+		t.Errorf("coarseCoordinate(31.2345) = %v, want 31.2", got)
+	}
+	if got := coarseCoordinate("91", 90); got != nil {
+		t.Errorf("coarseCoordinate(91) = %v, want nil", *got)
+	}
+}
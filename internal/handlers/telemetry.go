@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"conflux-farm/internal/models"
+	"conflux-farm/internal/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 单次上报最大读数
+const maxTelemetryBatch = 5000
+
+// 默认降采样后最多返回的点数
+const maxTelemetryPoints = 200
+
+// 规则引擎回溯窗口，用于拼接跨批次的超限区间
+const excursionLookback = 6 * time.Hour
+
+// 登记传感器设备并生成上报密钥；已登记的设备重新生成密钥，旧密钥立即失效
+// 密钥仅在此返回一次，服务端只保存其 SHA-256
+func (h *Handler) RegisterSensorDevice(c *gin.Context) {
+	var req struct {
+		ID string `json:"id" binding:"required,max=64"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Invalid input",
+		})
+		return
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to generate device key",
+		})
+		return
+	}
+	key := hex.EncodeToString(raw)
+
+	device := models.SensorDevice{ID: req.ID, KeyHash: hashDeviceKey(key), Enabled: true}
+	if err := h.db.Save(&device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to register device",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":     true,
+		"device": device,
+		"key":    key,
+	})
+}
+
+// 停用传感器设备，之后的上报均被拒绝
+func (h *Handler) DisableSensorDevice(c *gin.Context) {
+	result := h.db.Model(&models.SensorDevice{}).Where("id = ?", c.Param("id")).Update("enabled", false)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to disable device",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"ok":    false,
+			"error": "Device not found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func hashDeviceKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// 校验 X-Device-ID 与 X-Device-Key，失败时写入 401 并返回 false
+func (h *Handler) authenticateDevice(c *gin.Context) (*models.SensorDevice, bool) {
+	id := c.GetHeader("X-Device-ID")
+	key := c.GetHeader("X-Device-Key")
+	var device models.SensorDevice
+	if id == "" || key == "" ||
+		h.db.First(&device, "id = ? AND enabled = ?", id, true).Error != nil ||
+		subtle.ConstantTimeCompare([]byte(device.KeyHash), []byte(hashDeviceKey(key))) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"ok":    false,
+			"error": "Invalid device credentials",
+		})
+		return nil, false
+	}
+	return &device, true
+}
+
+// 接入传感器读数，设备需先经管理接口登记
+// Content-Type 为 application/json 时按 MQTT 消息解析，否则按 line protocol 解析
+// 读数中的设备号须为空或与认证设备一致
+func (h *Handler) IngestTelemetry(c *gin.Context) {
+	device, ok := h.authenticateDevice(c)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 8<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Failed to read body",
+		})
+		return
+	}
+
+	var readings []telemetry.Reading
+	if strings.Contains(c.ContentType(), "json") {
+		readings, err = telemetry.ParseMQTT(body)
+	} else {
+		readings, err = telemetry.ParseLineProtocol(string(body), c.Query("precision"))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": err.Error(),
+		})
+		return
+	}
+	if len(readings) == 0 || len(readings) > maxTelemetryBatch {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":    false,
+			"error": "Batch must contain 1-" + strconv.Itoa(maxTelemetryBatch) + " readings",
+		})
+		return
+	}
+	for i := range readings {
+		if readings[i].DeviceID == "" {
+			readings[i].DeviceID = device.ID
+		}
+		if readings[i].DeviceID != device.ID {
+			c.JSON(http.StatusForbidden, gin.H{
+				"ok":    false,
+				"error": "Readings must come from the authenticated device",
+			})
+			return
+		}
+	}
+
+	// 校验溯源批次是否存在
+	traceIDs := map[string]bool{}
+	for _, r := range readings {
+		traceIDs[r.TraceID] = true
+	}
+	ids := make([]string, 0, len(traceIDs))
+	for id := range traceIDs {
+		ids = append(ids, id)
+	}
+	var found []string
+	if err := h.db.Model(&models.TraceRecord{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to verify trace records",
+		})
+		return
+	}
+	if len(found) != len(ids) {
+		known := map[string]bool{}
+		for _, id := range found {
+			known[id] = true
+		}
+		var unknown []string
+		for _, id := range ids {
+			if !known[id] {
+				unknown = append(unknown, id)
+			}
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"ok":      false,
+			"error":   "Unknown trace records",
+			"unknown": unknown,
+		})
+		return
+	}
+
+	rows := make([]models.SensorReading, 0, len(readings))
+	for _, r := range readings {
+		rows = append(rows, models.SensorReading{
+			TraceID:    r.TraceID,
+			DeviceID:   r.DeviceID,
+			Metric:     r.Metric,
+			Value:      r.Value,
+			RecordedAt: r.RecordedAt,
+		})
+	}
+	if err := h.db.CreateInBatches(&rows, 500).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to store readings",
+		})
+		return
+	}
+	now := time.Now()
+	h.db.Model(device).Update("last_seen_at", &now)
+
+	flagged, err := h.flagExcursions(readings)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"ok":       true,
+			"accepted": len(rows),
+			"warning":  "Readings stored but rule evaluation failed",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":       true,
+		"accepted": len(rows),
+		"flagged":  flagged,
+	})
+}
+
+// 对本批次涉及的溯源记录执行规则，并将超限事件写入时间线
+func (h *Handler) flagExcursions(batch []telemetry.Reading) (int, error) {
+	metrics := h.telemetry.Metrics()
+	if len(metrics) == 0 {
+		return 0, nil
+	}
+
+	type window struct{ from, to time.Time }
+	windows := map[string]*window{}
+	for _, r := range batch {
+		w, ok := windows[r.TraceID]
+		if !ok {
+			windows[r.TraceID] = &window{from: r.RecordedAt, to: r.RecordedAt}
+			continue
+		}
+		if r.RecordedAt.Before(w.from) {
+			w.from = r.RecordedAt
+		}
+		if r.RecordedAt.After(w.to) {
+			w.to = r.RecordedAt
+		}
+	}
+
+	flagged := 0
+	for traceID, w := range windows {
+		engine, err := h.engineForTrace(traceID)
+		if err != nil {
+			return flagged, err
+		}
+		if len(engine.Rules) == 0 {
+			continue
+		}
+
+		// 已记录的超限区间若延续到窗口内，从其起点重新计算，使起点保持不变
+		from := w.from.Add(-excursionLookback)
+		var open []models.TelemetryExcursion
+		if err := h.db.Where("trace_id = ? AND start_at < ? AND end_at >= ?", traceID, from, from).
+			Order("start_at ASC").Limit(1).Find(&open).Error; err != nil {
+			return flagged, err
+		}
+		if len(open) > 0 {
+			from = open[0].StartAt
+		}
+
+		var rows []models.SensorReading
+		if err := h.db.Where("trace_id = ? AND metric IN ? AND recorded_at BETWEEN ? AND ?",
+			traceID, engine.Metrics(), from, w.to).
+			Order("recorded_at ASC").Find(&rows).Error; err != nil {
+			return flagged, err
+		}
+
+		readings := make([]telemetry.Reading, 0, len(rows))
+		for _, row := range rows {
+			readings = append(readings, telemetry.Reading{
+				TraceID:    row.TraceID,
+				DeviceID:   row.DeviceID,
+				Metric:     row.Metric,
+				Value:      row.Value,
+				RecordedAt: row.RecordedAt,
+			})
+		}
+
+		for _, ex := range engine.Evaluate(readings) {
+			if err := h.recordExcursion(ex); err != nil {
+				return flagged, err
+			}
+			flagged++
+		}
+	}
+	return flagged, nil
+}
+
+// 溯源批次所属农产品品类适用的规则；未关联农产品的批次只适用不限品类的规则
+func (h *Handler) engineForTrace(traceID string) (*telemetry.Engine, error) {
+	var category string
+	err := h.db.Model(&models.TraceRecord{}).
+		Select("farm_products.category").
+		Joins("JOIN farm_products ON farm_products.id = trace_records.product_id").
+		Where("trace_records.id = ?", traceID).
+		Limit(1).Scan(&category).Error
+	if err != nil {
+		return nil, err
+	}
+	return h.telemetry.ForCategory(category), nil
+}
+
+// 以 (trace_id, rule, start_at) 去重，同一超限区间跨批次增长时更新结束时间和描述
+func (h *Handler) recordExcursion(ex telemetry.Excursion) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.TelemetryExcursion
+		err := tx.Where("trace_id = ? AND rule = ? AND start_at = ?", ex.TraceID, ex.Rule.Name, ex.Start).
+			First(&existing).Error
+		if err == nil {
+			if err := tx.Model(&existing).Update("end_at", ex.End).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.TraceTimeline{}).Where("id = ?", existing.TimelineID).
+				Update("description", ex.Description()).Error
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		var maxOrder struct{ Max int }
+		if err := tx.Model(&models.TraceTimeline{}).Select("COALESCE(MAX(sort_order), 0) AS max").
+			Where("trace_id = ?", ex.TraceID).Scan(&maxOrder).Error; err != nil {
+			return err
+		}
+
+		entry := models.TraceTimeline{
+			TraceID:     ex.TraceID,
+			Title:       ex.Rule.Name,
+			Time:        ex.Start,
+			Description: ex.Description(),
+			Location:    "冷链监测",
+			Operator:    "规则引擎",
+			SortOrder:   maxOrder.Max + 1,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TelemetryExcursion{
+			TraceID:    ex.TraceID,
+			Rule:       ex.Rule.Name,
+			StartAt:    ex.Start,
+			EndAt:      ex.End,
+			TimelineID: entry.ID,
+		}).Error
+	})
+}
+
+// 降采样后的数据点
+type telemetryPoint struct {
+	Metric string    `json:"metric"`
+	Time   time.Time `json:"time"`
+	Avg    float64   `json:"avg"`
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	Count  int64     `json:"count"`
+}
+
+// 获取溯源批次的传感器数据（按时间桶降采样）
+// 参数: metric, from/to (RFC3339 或 Unix 秒), interval (如 5m, 1h, 1d)
+func (h *Handler) GetTraceTelemetry(c *gin.Context) {
+	id := c.Param("id")
+
+	var record models.TraceRecord
+	if err := h.db.Select("id").First(&record, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"ok":    false,
+				"error": "Trace record not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get trace record",
+		})
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-7 * 24 * time.Hour)
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = parseTimeParam(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"ok": false, "error": "Invalid from"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = parseTimeParam(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"ok": false, "error": "Invalid to"})
+			return
+		}
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "error": "from must be before to"})
+		return
+	}
+
+	interval := to.Sub(from) / maxTelemetryPoints
+	if v := c.Query("interval"); v != "" {
+		if interval, err = parseInterval(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"ok": false, "error": "Invalid interval"})
+			return
+		}
+	}
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	bucket := int64(interval / time.Second)
+
+	query := h.db.Model(&models.SensorReading{}).
+		Select("metric, FLOOR(UNIX_TIMESTAMP(recorded_at) / ?) * ? AS bucket, AVG(value) AS avg, MIN(value) AS min, MAX(value) AS max, COUNT(*) AS count", bucket, bucket).
+		Where("trace_id = ? AND recorded_at BETWEEN ? AND ?", id, from, to)
+	if metric := c.Query("metric"); metric != "" {
+		query = query.Where("metric = ?", metric)
+	}
+
+	var rows []struct {
+		Metric string
+		Bucket int64
+		Avg    float64
+		Min    float64
+		Max    float64
+		Count  int64
+	}
+	if err := query.Group("metric, bucket").Order("metric ASC, bucket ASC").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"ok":    false,
+			"error": "Failed to get telemetry",
+		})
+		return
+	}
+
+	series := map[string][]telemetryPoint{}
+	for _, row := range rows {
+		series[row.Metric] = append(series[row.Metric], telemetryPoint{
+			Metric: row.Metric,
+			Time:   time.Unix(row.Bucket, 0),
+			Avg:    row.Avg,
+			Min:    row.Min,
+			Max:    row.Max,
+			Count:  row.Count,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":       true,
+		"traceId":  id,
+		"from":     from,
+		"to":       to,
+		"interval": interval.String(),
+		"series":   series,
+	})
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// 支持 Go duration 以及按天的 "1d"
+func parseInterval(v string) (time.Duration, error) {
+	if strings.HasSuffix(v, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+		if err != nil || days <= 0 {
+			return 0, strconv.ErrSyntax
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(v)
+}
@@ -2,7 +2,6 @@ package models
 
 import (
 	"time"
-	"gorm.io/gorm"
 )
 
 // 农产品种类
@@ -13,8 +12,8 @@ type FarmProduct struct {
 	CategoryName string `json:"categoryName" gorm:"column:category_name;not null"`
 	Icon         string `json:"icon" gorm:"not null"`
 	Description  string `json:"desc" gorm:"column:description;type:text"`
-	Batches      int    `json:"batches" gorm:"default:0"`
-	Enterprises  int    `json:"enterprises" gorm:"default:0"`
+	Batches      int64  `json:"batches" gorm:"-"`     // 由溯源记录实时统计
+	Enterprises  int64  `json:"enterprises" gorm:"-"` // 由溯源记录实时统计
 	Color        string `json:"color" gorm:"not null"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
@@ -28,6 +27,7 @@ type Certificate struct {
 	TypeClass   string    `json:"typeClass" gorm:"column:type_class;not null"`
 	Icon        string    `json:"icon" gorm:"not null"`
 	Title       string    `json:"title" gorm:"not null"`
+	ProductID   *uint     `json:"productId" gorm:"column:product_id;index"`
 	Product     string    `json:"product" gorm:"not null"`
 	Enterprise  string    `json:"enterprise" gorm:"not null"`
 	Issuer      string    `json:"issuer" gorm:"not null"`
@@ -42,13 +42,19 @@ type Certificate struct {
 // 溯源记录
 type TraceRecord struct {
 	ID         string    `json:"id" gorm:"primaryKey"`
+	ProductID  *uint     `json:"productId" gorm:"column:product_id;index"`
 	Product    string    `json:"product" gorm:"not null"`
 	Icon       string    `json:"icon" gorm:"not null"`
 	Status     string    `json:"status" gorm:"not null;index"`
 	StatusText string    `json:"statusText" gorm:"column:status_text;not null"`
 	Enterprise string    `json:"enterprise" gorm:"not null"`
 	Origin     string    `json:"origin" gorm:"not null"`
+	CounterfeitRisk bool       `json:"counterfeitRisk" gorm:"column:counterfeit_risk;default:false;index"`
+	RiskReason      string     `json:"riskReason,omitempty" gorm:"column:risk_reason"`
+	RiskFlaggedAt   *time.Time `json:"riskFlaggedAt,omitempty" gorm:"column:risk_flagged_at"`
+	RiskClearedAt   *time.Time `json:"riskClearedAt,omitempty" gorm:"column:risk_cleared_at"`
 	Timeline   []TraceTimeline `json:"timeline" gorm:"foreignKey:TraceID"`
+	Inspections []InspectionReport `json:"inspections,omitempty" gorm:"foreignKey:TraceID"`
 	CreatedAt  time.Time `json:"createdAt"`
 	UpdatedAt  time.Time `json:"updatedAt"`
 }
@@ -67,6 +73,84 @@ type TraceTimeline struct {
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
+// 检测报告，ID 即 NFT BatchInfo 中的 inspectionId
+type InspectionReport struct {
+	ID         string           `json:"id" gorm:"primaryKey;size:64"`
+	TraceID    string           `json:"traceId" gorm:"column:trace_id;size:64;not null;index"`
+	Lab        string           `json:"lab" gorm:"not null"`
+	SampledAt  time.Time        `json:"sampledAt" gorm:"column:sampled_at"`
+	ReportedAt time.Time        `json:"reportedAt" gorm:"column:reported_at"`
+	Passed     bool             `json:"passed" gorm:"not null;index"`
+	Conclusion string           `json:"conclusion" gorm:"type:text"`
+	FileName   string           `json:"fileName" gorm:"column:file_name"`
+	FileURL    string           `json:"fileUrl" gorm:"column:file_url"`
+	FileHash   string           `json:"fileHash" gorm:"column:file_hash;size:64"`
+	Items      []InspectionItem `json:"items" gorm:"foreignKey:ReportID"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+}
+
+// 检测项目
+type InspectionItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReportID  string    `json:"reportId" gorm:"column:report_id;size:64;not null;index"`
+	Category  string    `json:"category" gorm:"size:32;not null"` // pesticide_residue, heavy_metal, microbial, other
+	Name      string    `json:"name" gorm:"not null"`
+	Result    float64   `json:"result"`
+	Limit     float64   `json:"limit" gorm:"column:limit_value"`
+	Unit      string    `json:"unit"`
+	Passed    bool      `json:"passed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 传感器读数（时序数据）
+type SensorReading struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TraceID    string    `json:"traceId" gorm:"column:trace_id;size:64;not null;index:idx_sensor_trace_metric_time,priority:1"`
+	DeviceID   string    `json:"deviceId" gorm:"column:device_id;size:64;index"`
+	Metric     string    `json:"metric" gorm:"size:32;not null;index:idx_sensor_trace_metric_time,priority:2"`
+	Value      float64   `json:"value" gorm:"not null"`
+	RecordedAt time.Time `json:"recordedAt" gorm:"column:recorded_at;not null;index:idx_sensor_trace_metric_time,priority:3"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// 传感器设备，上报读数时以 X-Device-ID 和 X-Device-Key 认证
+type SensorDevice struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:64"`
+	KeyHash    string     `json:"-" gorm:"column:key_hash;size:64;not null"`
+	Enabled    bool       `json:"enabled" gorm:"not null;default:true"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty" gorm:"column:last_seen_at"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// 已记录的超限事件，以 (trace_id, rule, start_at) 去重
+type TelemetryExcursion struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TraceID    string    `json:"traceId" gorm:"column:trace_id;size:64;not null;uniqueIndex:idx_excursion_trace_rule_start,priority:1"`
+	Rule       string    `json:"rule" gorm:"size:64;not null;uniqueIndex:idx_excursion_trace_rule_start,priority:2"`
+	StartAt    time.Time `json:"startAt" gorm:"column:start_at;not null;uniqueIndex:idx_excursion_trace_rule_start,priority:3"`
+	EndAt      time.Time `json:"endAt" gorm:"column:end_at;not null;index"`
+	TimelineID uint      `json:"timelineId" gorm:"column:timeline_id;not null"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// 消费者扫码记录，仅保存粗粒度位置
+// ClientHash 为客户端 IP 的哈希，GeoCity 为可信 CDN 头给出的城市，风险判断只使用这两项
+type ScanEvent struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TraceID    string    `json:"traceId" gorm:"column:trace_id;size:64;not null;index:idx_scan_trace_time,priority:1"`
+	City       string    `json:"city" gorm:"size:64"`
+	Latitude   *float64  `json:"latitude,omitempty"`
+	Longitude  *float64  `json:"longitude,omitempty"`
+	IPPrefix   string    `json:"-" gorm:"column:ip_prefix;size:64"`
+	ClientHash string    `json:"-" gorm:"column:client_hash;size:64;index"`
+	GeoCity    string    `json:"-" gorm:"column:geo_city;size:64"`
+	UserAgent  string    `json:"userAgent" gorm:"column:user_agent;size:512"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index:idx_scan_trace_time,priority:2;index"`
+}
+
 // 账户余额
 type Account struct {
 	Address   string  `json:"address" gorm:"primaryKey"`
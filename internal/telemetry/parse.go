@@ -0,0 +1,170 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 单条传感器读数
+type Reading struct {
+	TraceID    string
+	DeviceID   string
+	Metric     string
+	Value      float64
+	RecordedAt time.Time
+}
+
+// MQTT 消息（HTTP 桥接）
+// topic 格式: farm/{traceId}/{deviceId}
+// payload 示例: {"ts": 1733800000, "temperature": 4.2, "humidity": 85}
+type MQTTMessage struct {
+	Topic   string                     `json:"topic"`
+	Payload map[string]json.RawMessage `json:"payload"`
+}
+
+// 解析 MQTT-over-HTTP 批量消息，支持单条消息或 {"messages": [...]}
+func ParseMQTT(body []byte) ([]Reading, error) {
+	var batch struct {
+		Messages []MQTTMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if len(batch.Messages) == 0 {
+		var single MQTTMessage
+		if err := json.Unmarshal(body, &single); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		if single.Topic == "" {
+			return nil, fmt.Errorf("no messages")
+		}
+		batch.Messages = []MQTTMessage{single}
+	}
+
+	var readings []Reading
+	for i, msg := range batch.Messages {
+		traceID, deviceID, err := parseTopic(msg.Topic)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+
+		ts := time.Now()
+		if raw, ok := msg.Payload["ts"]; ok {
+			var sec int64
+			if err := json.Unmarshal(raw, &sec); err != nil {
+				return nil, fmt.Errorf("message %d: invalid ts", i)
+			}
+			ts = time.Unix(sec, 0)
+		}
+
+		for key, raw := range msg.Payload {
+			if key == "ts" {
+				continue
+			}
+			var value float64
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, fmt.Errorf("message %d: field %s is not numeric", i, key)
+			}
+			readings = append(readings, Reading{
+				TraceID:    traceID,
+				DeviceID:   deviceID,
+				Metric:     key,
+				Value:      value,
+				RecordedAt: ts,
+			})
+		}
+	}
+	return readings, nil
+}
+
+func parseTopic(topic string) (traceID, deviceID string, err error) {
+	parts := strings.Split(strings.Trim(topic, "/"), "/")
+	if len(parts) < 2 || parts[0] != "farm" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid topic %q, expected farm/{traceId}/{deviceId}", topic)
+	}
+	if len(parts) > 2 {
+		deviceID = parts[2]
+	}
+	return parts[1], deviceID, nil
+}
+
+// 解析 InfluxDB line protocol 批量数据
+// 示例: sensor,trace_id=TB20241210002,device=cc-01 temperature=4.2,humidity=85 1733800000000000000
+// precision 可选 s/ms/us/ns，默认 ns
+func ParseLineProtocol(body string, precision string) ([]Reading, error) {
+	unit, err := precisionUnit(precision)
+	if err != nil {
+		return nil, err
+	}
+
+	var readings []Reading
+	for n, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: malformed", n+1)
+		}
+
+		tags := map[string]string{}
+		for _, kv := range strings.Split(fields[0], ",")[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed tag %q", n+1, kv)
+			}
+			tags[k] = v
+		}
+		traceID := tags["trace_id"]
+		if traceID == "" {
+			return nil, fmt.Errorf("line %d: missing trace_id tag", n+1)
+		}
+
+		ts := time.Now()
+		if len(fields) == 3 {
+			raw, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid timestamp", n+1)
+			}
+			ts = time.Unix(0, raw*int64(unit))
+		}
+
+		for _, kv := range strings.Split(fields[1], ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed field %q", n+1, kv)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSuffix(v, "i"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: field %s is not numeric", n+1, k)
+			}
+			readings = append(readings, Reading{
+				TraceID:    traceID,
+				DeviceID:   tags["device"],
+				Metric:     k,
+				Value:      value,
+				RecordedAt: ts,
+			})
+		}
+	}
+	return readings, nil
+}
+
+func precisionUnit(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "ns":
+		return time.Nanosecond, nil
+	case "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	}
+	return 0, fmt.Errorf("unsupported precision %q", precision)
+}
@@ -0,0 +1,142 @@
+package telemetry
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sortReadings(readings []Reading) {
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].TraceID != readings[j].TraceID {
+			return readings[i].TraceID < readings[j].TraceID
+		}
+		return readings[i].Metric < readings[j].Metric
+	})
+}
+
+func TestParseMQTT(t *testing.T) {
+	ts := time.Unix(1733800000, 0)
+	tests := []struct {
+		name    string
+		body    string
+		want    []Reading
+		wantErr string
+	}{
+		{
+			name: "single message",
+			body: `{"topic":"farm/TB1/cc-01","payload":{"ts":1733800000,"temperature":4.2,"humidity":85}}`,
+			want: []Reading{
+				{TraceID: "TB1", DeviceID: "cc-01", Metric: "humidity", Value: 85, RecordedAt: ts},
+				{TraceID: "TB1", DeviceID: "cc-01", Metric: "temperature", Value: 4.2, RecordedAt: ts},
+			},
+		},
+		{
+			name: "batch",
+			body: `{"messages":[{"topic":"farm/TB1/cc-01","payload":{"ts":1733800000,"temperature":1}},{"topic":"/farm/TB2/","payload":{"ts":1733800000,"temperature":2}}]}`,
+			want: []Reading{
+				{TraceID: "TB1", DeviceID: "cc-01", Metric: "temperature", Value: 1, RecordedAt: ts},
+				{TraceID: "TB2", Metric: "temperature", Value: 2, RecordedAt: ts},
+			},
+		},
+		{name: "invalid json", body: `{`, wantErr: "invalid json"},
+		{name: "no messages", body: `{"messages":[]}`, wantErr: "no messages"},
+		{name: "bad topic", body: `{"topic":"sensor/TB1","payload":{"temperature":1}}`, wantErr: "invalid topic"},
+		{name: "missing trace", body: `{"topic":"farm//cc-01","payload":{"temperature":1}}`, wantErr: "invalid topic"},
+		{name: "bad ts", body: `{"topic":"farm/TB1","payload":{"ts":"now","temperature":1}}`, wantErr: "invalid ts"},
+		{name: "non-numeric field", body: `{"topic":"farm/TB1","payload":{"ts":1,"temperature":"cold"}}`, wantErr: "not numeric"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMQTT([]byte(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortReadings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMQTTDefaultsToNow(t *testing.T) {
+	before := time.Now()
+	got, err := ParseMQTT([]byte(`{"topic":"farm/TB1","payload":{"temperature":3}}`))
+	if err != nil || len(got) != 1 {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	if got[0].RecordedAt.Before(before) {
+		t.Errorf("RecordedAt = %v, want now", got[0].RecordedAt)
+	}
+}
+
+func TestParseLineProtocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		precision string
+		want      []Reading
+		wantErr   string
+	}{
+		{
+			name: "nanoseconds by default",
+			body: "sensor,trace_id=TB1,device=cc-01 temperature=4.2,humidity=85i 1733800000000000000",
+			want: []Reading{
+				{TraceID: "TB1", DeviceID: "cc-01", Metric: "humidity", Value: 85, RecordedAt: time.Unix(1733800000, 0)},
+				{TraceID: "TB1", DeviceID: "cc-01", Metric: "temperature", Value: 4.2, RecordedAt: time.Unix(1733800000, 0)},
+			},
+		},
+		{
+			name:      "seconds, comments and blank lines",
+			body:      "# header\n\nsensor,trace_id=TB1 temperature=1 1733800000\nsensor,trace_id=TB2 temperature=2 1733800060\n",
+			precision: "s",
+			want: []Reading{
+				{TraceID: "TB1", Metric: "temperature", Value: 1, RecordedAt: time.Unix(1733800000, 0)},
+				{TraceID: "TB2", Metric: "temperature", Value: 2, RecordedAt: time.Unix(1733800060, 0)},
+			},
+		},
+		{
+			name:      "milliseconds",
+			body:      "sensor,trace_id=TB1 temperature=1 1733800000500",
+			precision: "ms",
+			want: []Reading{
+				{TraceID: "TB1", Metric: "temperature", Value: 1, RecordedAt: time.Unix(1733800000, 500*int64(time.Millisecond))},
+			},
+		},
+		{name: "bad precision", body: "sensor,trace_id=TB1 t=1", precision: "h", wantErr: "unsupported precision"},
+		{name: "malformed line", body: "sensor,trace_id=TB1", wantErr: "line 1: malformed"},
+		{name: "malformed tag", body: "sensor,trace_id temperature=1", wantErr: "malformed tag"},
+		{name: "missing trace", body: "sensor,device=cc-01 temperature=1", wantErr: "missing trace_id"},
+		{name: "bad timestamp", body: "sensor,trace_id=TB1 temperature=1 soon", wantErr: "invalid timestamp"},
+		{name: "malformed field", body: "sensor,trace_id=TB1 temperature", wantErr: "malformed field"},
+		{name: "non-numeric field", body: "sensor,trace_id=TB1 temperature=cold", wantErr: "not numeric"},
+		{name: "error names the line", body: "sensor,trace_id=TB1 t=1 1\nsensor t=1 1", wantErr: "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLineProtocol(tt.body, tt.precision)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortReadings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
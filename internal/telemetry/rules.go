@@ -0,0 +1,162 @@
+package telemetry
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 阈值规则：读数持续超出 [Min, Max] 超过 MinDuration 即视为异常
+// Categories 为空时适用于所有品类
+type Rule struct {
+	Name        string
+	Metric      string
+	Min         float64
+	Max         float64
+	MinDuration time.Duration
+	Categories  []string
+}
+
+// 规则是否适用于该农产品品类
+func (r Rule) AppliesTo(category string) bool {
+	if len(r.Categories) == 0 {
+		return true
+	}
+	for _, c := range r.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// 一次超限事件
+type Excursion struct {
+	Rule    Rule
+	TraceID string
+	Start   time.Time
+	End     time.Time
+	Peak    float64
+	Count   int
+}
+
+func (e Excursion) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// 时间线描述文本
+func (e Excursion) Description() string {
+	return fmt.Sprintf("%s超出允许范围 %.1f~%.1f，持续 %s，峰值 %.1f（%d 条读数）",
+		e.Rule.Metric, e.Rule.Min, e.Rule.Max, e.Duration().Round(time.Minute), e.Peak, e.Count)
+}
+
+type Engine struct {
+	Rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{Rules: rules}
+}
+
+// 默认冷链规则：需冷藏品类的温度需保持在 minTemp~maxTemp，连续超限 15 分钟以上记为异常
+func ColdChainRule(minTemp, maxTemp float64, categories []string) Rule {
+	return Rule{
+		Name:        "冷链温度异常",
+		Metric:      "temperature",
+		Min:         minTemp,
+		Max:         maxTemp,
+		MinDuration: 15 * time.Minute,
+		Categories:  categories,
+	}
+}
+
+// 仅包含适用于该品类规则的引擎
+func (e *Engine) ForCategory(category string) *Engine {
+	var rules []Rule
+	for _, r := range e.Rules {
+		if r.AppliesTo(category) {
+			rules = append(rules, r)
+		}
+	}
+	return NewEngine(rules...)
+}
+
+// 规则涉及的指标
+func (e *Engine) Metrics() []string {
+	seen := map[string]bool{}
+	var metrics []string
+	for _, r := range e.Rules {
+		if !seen[r.Metric] {
+			seen[r.Metric] = true
+			metrics = append(metrics, r.Metric)
+		}
+	}
+	return metrics
+}
+
+// 对同一批次的读数执行规则，返回所有超限事件
+func (e *Engine) Evaluate(readings []Reading) []Excursion {
+	sorted := make([]Reading, len(readings))
+	copy(sorted, readings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].RecordedAt.Before(sorted[j].RecordedAt)
+	})
+
+	var excursions []Excursion
+	for _, rule := range e.Rules {
+		byTrace := map[string][]Reading{}
+		var traces []string
+		for _, r := range sorted {
+			if r.Metric != rule.Metric {
+				continue
+			}
+			if _, ok := byTrace[r.TraceID]; !ok {
+				traces = append(traces, r.TraceID)
+			}
+			byTrace[r.TraceID] = append(byTrace[r.TraceID], r)
+		}
+		for _, traceID := range traces {
+			excursions = append(excursions, evaluateRule(rule, traceID, byTrace[traceID])...)
+		}
+	}
+	return excursions
+}
+
+func evaluateRule(rule Rule, traceID string, readings []Reading) []Excursion {
+	var result []Excursion
+	var current *Excursion
+
+	flush := func() {
+		if current != nil && current.Duration() >= rule.MinDuration {
+			result = append(result, *current)
+		}
+		current = nil
+	}
+
+	for _, r := range readings {
+		if r.Value >= rule.Min && r.Value <= rule.Max {
+			flush()
+			continue
+		}
+		if current == nil {
+			current = &Excursion{Rule: rule, TraceID: traceID, Start: r.RecordedAt, Peak: r.Value}
+		}
+		current.End = r.RecordedAt
+		current.Count++
+		if deviation(r.Value, rule) > deviation(current.Peak, rule) {
+			current.Peak = r.Value
+		}
+	}
+	flush()
+	return result
+}
+
+func deviation(v float64, rule Rule) float64 {
+	if v < rule.Min {
+		return rule.Min - v
+	}
+	if v > rule.Max {
+		return v - rule.Max
+	}
+	return 0
+}
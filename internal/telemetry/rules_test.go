@@ -0,0 +1,108 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	base := time.Date(2024, 12, 10, 8, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return base.Add(time.Duration(min) * time.Minute) }
+	temps := func(trace string, values map[int]float64) []Reading {
+		var readings []Reading
+		for min, v := range values {
+			readings = append(readings, Reading{TraceID: trace, Metric: "temperature", Value: v, RecordedAt: at(min)})
+		}
+		return readings
+	}
+	rule := ColdChainRule(0, 8, nil)
+
+	type span struct {
+		trace      string
+		start, end int
+		peak       float64
+		count      int
+	}
+	tests := []struct {
+		name     string
+		readings []Reading
+		want     []span
+	}{
+		{
+			name:     "in range",
+			readings: temps("TB1", map[int]float64{0: 4, 10: 0, 20: 8}),
+		},
+		{
+			name:     "shorter than the minimum duration",
+			readings: temps("TB1", map[int]float64{0: 4, 5: 9, 15: 10, 20: 4}),
+		},
+		{
+			name:     "sustained excursion",
+			readings: temps("TB1", map[int]float64{0: 4, 5: 9, 15: 12, 20: 10, 25: 5}),
+			want:     []span{{"TB1", 5, 20, 12, 3}},
+		},
+		{
+			name:     "below range counts too and peak is the largest deviation",
+			readings: temps("TB1", map[int]float64{0: -1, 10: 11, 20: -5}),
+			want:     []span{{"TB1", 0, 20, -5, 3}},
+		},
+		{
+			name:     "still open at the end",
+			readings: temps("TB1", map[int]float64{0: 4, 10: 9, 30: 9}),
+			want:     []span{{"TB1", 10, 30, 9, 2}},
+		},
+		{
+			name:     "two excursions",
+			readings: temps("TB1", map[int]float64{0: 9, 20: 9, 30: 4, 40: 9, 60: 9}),
+			want:     []span{{"TB1", 0, 20, 9, 2}, {"TB1", 40, 60, 9, 2}},
+		},
+		{
+			name: "traces are evaluated separately",
+			readings: append(temps("TB1", map[int]float64{0: 9, 20: 9}),
+				temps("TB2", map[int]float64{10: 9, 15: 4})...),
+			want: []span{{"TB1", 0, 20, 9, 2}},
+		},
+		{
+			name:     "other metrics are ignored",
+			readings: []Reading{{TraceID: "TB1", Metric: "humidity", Value: 99, RecordedAt: at(0)}, {TraceID: "TB1", Metric: "humidity", Value: 99, RecordedAt: at(30)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []span
+			for _, ex := range NewEngine(rule).Evaluate(tt.readings) {
+				got = append(got, span{ex.TraceID, int(ex.Start.Sub(base) / time.Minute), int(ex.End.Sub(base) / time.Minute), ex.Peak, ex.Count})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForCategory(t *testing.T) {
+	coldChain := ColdChainRule(0, 8, []string{"vegetable", "fruit"})
+	humidity := Rule{Name: "湿度异常", Metric: "humidity", Min: 0, Max: 90}
+	engine := NewEngine(coldChain, humidity)
+
+	tests := []struct {
+		category string
+		want     []string
+	}{
+		{"fruit", []string{"冷链温度异常", "湿度异常"}},
+		{"vegetable", []string{"冷链温度异常", "湿度异常"}},
+		{"tea", []string{"湿度异常"}},
+		{"grain", []string{"湿度异常"}},
+		{"", []string{"湿度异常"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range engine.ForCategory(tt.category).Rules {
+			got = append(got, r.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ForCategory(%q) = %v, want %v", tt.category, got, tt.want)
+		}
+	}
+}
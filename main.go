@@ -34,11 +34,15 @@ func main() {
 
 	// 创建路由
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
 	// 静态文件服务
 	router.Static("/static", "./public")
 	router.StaticFile("/", "./public/index.html")
 	router.StaticFile("/favicon.ico", "./public/favicon.ico")
+	router.Static("/uploads", cfg.UploadDir)
 
 	// API 路由
 	api.SetupRoutes(router, db, cfg)
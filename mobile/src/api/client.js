@@ -10,10 +10,21 @@ const DEV_API_URL = Platform.select({
 
 export const API_BASE_URL = DEV_API_URL;
 
+// Bearer token from /api/v1/auth/login, required by transfers and community writes
+let authToken = null;
+
+export function setAuthToken(token) {
+    authToken = token;
+}
+
+function authHeaders() {
+    return authToken ? { Authorization: `Bearer ${authToken}` } : {};
+}
+
 export const apiClient = {
     async get(endpoint) {
         try {
-            const response = await fetch(`${API_BASE_URL}${endpoint}`);
+            const response = await fetch(`${API_BASE_URL}${endpoint}`, { headers: authHeaders() });
             const data = await response.json();
             return data;
         } catch (error) {
@@ -28,6 +39,7 @@ export const apiClient = {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
+                    ...authHeaders(),
                 },
                 body: JSON.stringify(body),
             });
@@ -142,7 +142,7 @@ const ProductScreen = () => {
         </View>
         <View style={styles.infoItem}>
           <Ionicons name="cube-outline" size={20} color="#666" />
-          <Text style={styles.infoValue}>{item.stock || 'Unlimited'}</Text>
+          <Text style={styles.infoValue}>{item.stock > 0 ? item.stock : item.stock < 0 ? 'Sold out' : 'Unlimited'}</Text>
           <Text style={styles.infoLabel}>Stock</Text>
         </View>
         <View style={styles.infoItem}>
@@ -191,7 +191,7 @@ const ProductScreen = () => {
             <Text style={styles.modalTitle}>Purchase {selectedProduct.name}</Text>
             <Text style={styles.modalSubtitle}>Category: {selectedProduct.category || 'Digital Asset'}</Text>
             <Text style={styles.modalPrice}>Price: {selectedProduct.price}</Text>
-            {selectedProduct.stock && (
+            {selectedProduct.stock > 0 && (
               <Text style={styles.modalStock}>Available: {selectedProduct.stock} units</Text>
             )}
 
@@ -108,6 +108,7 @@ async function mintNFT() {
     const tokenIdStr = document.getElementById('tokenId').value.trim();
     const origin = document.getElementById('origin').value.trim();
     const harvestTimeStr = document.getElementById('harvestTime').value.trim();
+    const traceId = document.getElementById('traceId').value.trim();
     const inspectionId = document.getElementById('inspectionId').value.trim();
     const uri = document.getElementById('uri').value.trim();
 
@@ -117,13 +118,14 @@ async function mintNFT() {
     if (tokenIdStr === '') { showToast('❌ 输入错误', '请填写批次编号', false); return; }
     if (!origin) { showToast('❌ 输入错误', '请填写产地', false); return; }
     if (!harvestTimeStr) { showToast('❌ 输入错误', '请填写采收时间', false); return; }
+    if (!traceId) { showToast('❌ 输入错误', '请填写溯源批次', false); return; }
     if (!inspectionId) { showToast('❌ 输入错误', '请填写质检编号', false); return; }
 
     const tokenId = parseInt(tokenIdStr);
     const harvestTime = parseInt(harvestTimeStr);
 
     const result = await apiCall('/relay/nft/mint', 'POST', {
-        from, to, nftAddress, tokenId, origin, harvestTime, inspectionId, uri
+        from, to, nftAddress, tokenId, origin, harvestTime, traceId, inspectionId, uri
     });
     if (result.ok) {
         showToast('✅ 登记成功', `产品批次 #${tokenId} 登记成功!`, true);
@@ -17,7 +17,8 @@ const Operations = () => {
         tokenId: '',
         origin: '云南普洱',
         harvestTime: Math.floor(Date.now() / 1000),
-        inspectionId: 'QC20240101',
+        traceId: 'TB20241210001',
+        inspectionId: 'QC20241210001',
         uri: ''
     });
     const [transferForm, setTransferForm] = useState({
@@ -151,6 +152,8 @@ const Operations = () => {
                         <div className="form-group"><label>产品证书合约</label><input type="text" value={mintForm.nftAddress} onChange={e => setMintForm({ ...mintForm, nftAddress: e.target.value })} /></div>
                         <div className="form-group"><label>批次编号</label><input type="number" value={mintForm.tokenId} onChange={e => setMintForm({ ...mintForm, tokenId: e.target.value })} placeholder="1" /></div>
                         <div className="form-group"><label>产地</label><input type="text" value={mintForm.origin} onChange={e => setMintForm({ ...mintForm, origin: e.target.value })} /></div>
+                        <div className="form-group"><label>溯源批次</label><input type="text" value={mintForm.traceId} onChange={e => setMintForm({ ...mintForm, traceId: e.target.value })} placeholder="TB20241210001" /></div>
+                        <div className="form-group"><label>质检编号</label><input type="text" value={mintForm.inspectionId} onChange={e => setMintForm({ ...mintForm, inspectionId: e.target.value })} placeholder="QC20241210001" /></div>
                     </div>
                     <button className="btn" style={{ padding: '12px 24px', background: '#7c8ff5', color: 'white', border: 'none', borderRadius: '6px', fontSize: '14px', fontWeight: '600', cursor: 'pointer' }} onClick={mintNFT}>登记批次</button>
                 </div>